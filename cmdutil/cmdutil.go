@@ -1,12 +1,8 @@
 package cmdutil
 
 import (
-	"context"
 	"os"
-	"time"
 
-	"github.com/jackc/pgx/v5"
-	"github.com/jackc/pgx/v5/pgxpool"
 	"go.uber.org/zap"
 )
 
@@ -23,16 +19,3 @@ func NewLogger(service string) *zap.Logger {
 
 	return logger
 }
-
-func NewDatabasePool(ctx context.Context) (*pgxpool.Pool, error) {
-	config, err := pgxpool.ParseConfig(os.Getenv("DATABASE_CONNECTION_POOL_URL"))
-	if err != nil {
-		return nil, err
-	}
-
-	// Setting the build statement cache to nil helps this work with pgbouncer
-	config.ConnConfig.DefaultQueryExecMode = pgx.QueryExecModeSimpleProtocol
-	config.MaxConnLifetime = 1 * time.Hour
-	config.MaxConnIdleTime = 30 * time.Second
-	return pgxpool.NewWithConfig(ctx, config)
-}
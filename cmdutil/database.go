@@ -0,0 +1,229 @@
+package cmdutil
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"go.uber.org/zap"
+)
+
+// maxConnectBackoff caps the wait between two connection attempts
+const maxConnectBackoff = 30 * time.Second
+
+// execModes are the DATABASE_QUERY_EXEC_MODE values. Only the simple protocol
+// works through pgbouncer in transaction mode, the others prepare statements on
+// a connection and expect to find them there again.
+var execModes = map[string]pgx.QueryExecMode{
+	"simple_protocol": pgx.QueryExecModeSimpleProtocol,
+	"cache_statement": pgx.QueryExecModeCacheStatement,
+	"cache_describe":  pgx.QueryExecModeCacheDescribe,
+	"describe_exec":   pgx.QueryExecModeDescribeExec,
+	"exec":            pgx.QueryExecModeExec,
+}
+
+// DatabaseConfig holds the pool settings applied on top of the connection URL
+type DatabaseConfig struct {
+	// MaxConns and MinConns bound the pool size, zero keeps the value of the URL or the pgx default
+	MaxConns int32
+	MinConns int32
+	// MaxConnLifetime, MaxConnIdleTime and HealthCheckPeriod also keep the value
+	// of the URL or the pgx default when zero
+	MaxConnLifetime   time.Duration
+	MaxConnIdleTime   time.Duration
+	HealthCheckPeriod time.Duration
+	// StatementTimeout is sent as statement_timeout when connecting, zero keeps the server setting
+	StatementTimeout time.Duration
+	ExecMode         pgx.QueryExecMode
+	// ConnectRetries is the number of retries when the database is not reachable on startup
+	ConnectRetries int
+	// ConnectBackoff is the first wait between retries, it doubles with every retry
+	ConnectBackoff time.Duration
+	// SlowQueryThreshold is the duration from which statements are logged, zero logs none
+	SlowQueryThreshold time.Duration
+}
+
+func DefaultDatabaseConfig() DatabaseConfig {
+	return DatabaseConfig{
+		ExecMode:           pgx.QueryExecModeSimpleProtocol,
+		ConnectRetries:     5,
+		ConnectBackoff:     500 * time.Millisecond,
+		SlowQueryThreshold: 500 * time.Millisecond,
+	}
+}
+
+// DatabaseConfigFromEnv reads the DATABASE_* pool settings, unset ones keep their default
+func DatabaseConfigFromEnv() (DatabaseConfig, error) {
+	cfg := DefaultDatabaseConfig()
+
+	ints := map[string]*int32{
+		"DATABASE_MAX_CONNS": &cfg.MaxConns,
+		"DATABASE_MIN_CONNS": &cfg.MinConns,
+	}
+	for key, dst := range ints {
+		if v := os.Getenv(key); v != "" {
+			n, err := strconv.ParseInt(v, 10, 32)
+			if err != nil || n < 0 {
+				return cfg, fmt.Errorf("invalid %s %q", key, v)
+			}
+			*dst = int32(n)
+		}
+	}
+
+	durations := map[string]*time.Duration{
+		"DATABASE_MAX_CONN_LIFETIME":    &cfg.MaxConnLifetime,
+		"DATABASE_MAX_CONN_IDLE_TIME":   &cfg.MaxConnIdleTime,
+		"DATABASE_HEALTH_CHECK_PERIOD":  &cfg.HealthCheckPeriod,
+		"DATABASE_STATEMENT_TIMEOUT":    &cfg.StatementTimeout,
+		"DATABASE_CONNECT_BACKOFF":      &cfg.ConnectBackoff,
+		"DATABASE_SLOW_QUERY_THRESHOLD": &cfg.SlowQueryThreshold,
+	}
+	for key, dst := range durations {
+		if v := os.Getenv(key); v != "" {
+			d, err := time.ParseDuration(v)
+			if err != nil || d < 0 {
+				return cfg, fmt.Errorf("invalid %s %q", key, v)
+			}
+			*dst = d
+		}
+	}
+	// The pool ticks every HealthCheckPeriod, a ticker cannot run at zero
+	if v := os.Getenv("DATABASE_HEALTH_CHECK_PERIOD"); v != "" && cfg.HealthCheckPeriod <= 0 {
+		return cfg, fmt.Errorf("invalid DATABASE_HEALTH_CHECK_PERIOD %q, it must be positive", v)
+	}
+
+	if v := os.Getenv("DATABASE_CONNECT_RETRIES"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			return cfg, fmt.Errorf("invalid DATABASE_CONNECT_RETRIES %q", v)
+		}
+		cfg.ConnectRetries = n
+	}
+
+	if v := os.Getenv("DATABASE_QUERY_EXEC_MODE"); v != "" {
+		mode, ok := execModes[v]
+		if !ok {
+			return cfg, fmt.Errorf("invalid DATABASE_QUERY_EXEC_MODE %q", v)
+		}
+		cfg.ExecMode = mode
+	}
+
+	return cfg, nil
+}
+
+// Apply sets the pool settings on config, zero values keep those of the URL
+func (c DatabaseConfig) Apply(config *pgxpool.Config) {
+	if c.MaxConns > 0 {
+		config.MaxConns = c.MaxConns
+	}
+	if c.MinConns > 0 {
+		config.MinConns = c.MinConns
+	}
+	if c.MaxConnLifetime > 0 {
+		config.MaxConnLifetime = c.MaxConnLifetime
+	}
+	if c.MaxConnIdleTime > 0 {
+		config.MaxConnIdleTime = c.MaxConnIdleTime
+	}
+	if c.HealthCheckPeriod > 0 {
+		config.HealthCheckPeriod = c.HealthCheckPeriod
+	}
+	config.ConnConfig.DefaultQueryExecMode = c.ExecMode
+	if c.StatementTimeout > 0 {
+		config.ConnConfig.RuntimeParams["statement_timeout"] = strconv.FormatInt(c.StatementTimeout.Milliseconds(), 10)
+	}
+}
+
+// NewDatabasePool connects to DATABASE_CONNECTION_POOL_URL with the pool settings of the environment
+func NewDatabasePool(ctx context.Context, logger *zap.Logger) (*pgxpool.Pool, error) {
+	cfg, err := DatabaseConfigFromEnv()
+	if err != nil {
+		return nil, err
+	}
+	return newDatabasePool(ctx, logger, os.Getenv("DATABASE_CONNECTION_POOL_URL"), cfg)
+}
+
+// NewDatabaseReplicaPools returns a pool for every read replica in the comma
+// separated DATABASE_REPLICA_URLS, which may be empty
+func NewDatabaseReplicaPools(ctx context.Context, logger *zap.Logger) ([]*pgxpool.Pool, error) {
+	cfg, err := DatabaseConfigFromEnv()
+	if err != nil {
+		return nil, err
+	}
+
+	var pools []*pgxpool.Pool
+	for _, url := range strings.Split(os.Getenv("DATABASE_REPLICA_URLS"), ",") {
+		if url = strings.TrimSpace(url); url == "" {
+			continue
+		}
+
+		pool, err := newDatabasePool(ctx, logger, url, cfg)
+		if err != nil {
+			for _, p := range pools {
+				p.Close()
+			}
+			return nil, err
+		}
+		pools = append(pools, pool)
+	}
+	return pools, nil
+}
+
+func newDatabasePool(ctx context.Context, logger *zap.Logger, url string, cfg DatabaseConfig) (*pgxpool.Pool, error) {
+	config, err := pgxpool.ParseConfig(url)
+	if err != nil {
+		return nil, err
+	}
+	cfg.Apply(config)
+	if cfg.SlowQueryThreshold > 0 {
+		config.ConnConfig.Tracer = NewSlowQueryTracer(logger, cfg.SlowQueryThreshold)
+	}
+
+	logger.Info("database pool",
+		zap.String("host", config.ConnConfig.Host),
+		zap.String("database", config.ConnConfig.Database),
+		zap.Int32("max#conns", config.MaxConns),
+		zap.Int32("min#conns", config.MinConns),
+		zap.Duration("max#conn_lifetime", config.MaxConnLifetime),
+		zap.Duration("max#conn_idle_time", config.MaxConnIdleTime),
+		zap.Duration("health#check_period", config.HealthCheckPeriod),
+		zap.Duration("statement#timeout", cfg.StatementTimeout),
+		zap.Duration("slow#query_threshold", cfg.SlowQueryThreshold),
+		zap.String("exec#mode", config.ConnConfig.DefaultQueryExecMode.String()),
+	)
+
+	pool, err := pgxpool.NewWithConfig(ctx, config)
+	if err != nil {
+		return nil, err
+	}
+	if err := connect(ctx, logger, pool, cfg); err != nil {
+		pool.Close()
+		return nil, err
+	}
+	return pool, nil
+}
+
+// connect waits for the database to accept connections, which it may not yet
+// do when everything starts at once
+func connect(ctx context.Context, logger *zap.Logger, pool *pgxpool.Pool, cfg DatabaseConfig) error {
+	backoff := cfg.ConnectBackoff
+	for attempt := 0; ; attempt++ {
+		err := pool.Ping(ctx)
+		if err == nil || attempt >= cfg.ConnectRetries {
+			return err
+		}
+
+		logger.Warn("database not reachable, retrying", zap.Int("attempt", attempt+1), zap.Duration("backoff", backoff), zap.Error(err))
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(backoff):
+		}
+		backoff = min(2*backoff, maxConnectBackoff)
+	}
+}
@@ -0,0 +1,82 @@
+package cmdutil_test
+
+import (
+	"go-project-template/cmdutil"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDatabaseConfigFromEnv(t *testing.T) { //nolint:paralleltest
+	testCases := map[string]struct {
+		env  map[string]string
+		want func(*cmdutil.DatabaseConfig)
+		err  bool
+	}{
+		"defaults": {map[string]string{}, func(*cmdutil.DatabaseConfig) {}, false},
+		"pool size": {map[string]string{"DATABASE_MAX_CONNS": "20", "DATABASE_MIN_CONNS": "2"}, func(c *cmdutil.DatabaseConfig) {
+			c.MaxConns, c.MinConns = 20, 2
+		}, false},
+		"durations": {map[string]string{
+			"DATABASE_MAX_CONN_LIFETIME":   "30m",
+			"DATABASE_MAX_CONN_IDLE_TIME":  "1m",
+			"DATABASE_HEALTH_CHECK_PERIOD": "15s",
+			"DATABASE_STATEMENT_TIMEOUT":   "5s",
+		}, func(c *cmdutil.DatabaseConfig) {
+			c.MaxConnLifetime, c.MaxConnIdleTime, c.HealthCheckPeriod, c.StatementTimeout = 30*time.Minute, time.Minute, 15*time.Second, 5*time.Second
+		}, false},
+		"retries": {map[string]string{"DATABASE_CONNECT_RETRIES": "0", "DATABASE_CONNECT_BACKOFF": "1s"}, func(c *cmdutil.DatabaseConfig) {
+			c.ConnectRetries, c.ConnectBackoff = 0, time.Second
+		}, false},
+		"exec mode": {map[string]string{"DATABASE_QUERY_EXEC_MODE": "cache_statement"}, func(c *cmdutil.DatabaseConfig) {
+			c.ExecMode = pgx.QueryExecModeCacheStatement
+		}, false},
+		"invalid size":      {map[string]string{"DATABASE_MAX_CONNS": "many"}, nil, true},
+		"negative duration": {map[string]string{"DATABASE_STATEMENT_TIMEOUT": "-1s"}, nil, true},
+		"no health check":   {map[string]string{"DATABASE_HEALTH_CHECK_PERIOD": "0s"}, nil, true},
+		"unknown exec mode": {map[string]string{"DATABASE_QUERY_EXEC_MODE": "prepared"}, nil, true},
+	}
+
+	for scenario, tc := range testCases {
+		t.Run(scenario, func(t *testing.T) {
+			for key, value := range tc.env {
+				t.Setenv(key, value)
+			}
+
+			got, err := cmdutil.DatabaseConfigFromEnv()
+			if tc.err {
+				assert.Error(t, err)
+				return
+			}
+
+			require.NoError(t, err)
+			want := cmdutil.DefaultDatabaseConfig()
+			tc.want(&want)
+			assert.Equal(t, want, got)
+		})
+	}
+}
+
+func TestDatabaseConfig_Apply(t *testing.T) {
+	t.Parallel()
+
+	config, err := pgxpool.ParseConfig("postgres://user@localhost:5432/project?pool_max_conns=7&pool_max_conn_lifetime=2h&pool_max_conn_idle_time=5m")
+	require.NoError(t, err)
+
+	cfg := cmdutil.DefaultDatabaseConfig()
+	cfg.StatementTimeout = 2500 * time.Millisecond
+	cfg.HealthCheckPeriod = 15 * time.Second
+	cfg.Apply(config)
+
+	// Settings left at zero keep the value of the URL
+	assert.Equal(t, int32(7), config.MaxConns)
+	assert.Equal(t, 2*time.Hour, config.MaxConnLifetime)
+	assert.Equal(t, 5*time.Minute, config.MaxConnIdleTime)
+	assert.Equal(t, 15*time.Second, config.HealthCheckPeriod)
+	assert.Equal(t, pgx.QueryExecModeSimpleProtocol, config.ConnConfig.DefaultQueryExecMode)
+	assert.Equal(t, "2500", config.ConnConfig.RuntimeParams["statement_timeout"])
+}
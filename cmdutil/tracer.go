@@ -0,0 +1,80 @@
+package cmdutil
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"go.uber.org/zap"
+)
+
+// SlowQueryTracer logs the statements and batches that took at least its
+// threshold. Arguments are left out, they may hold personal data.
+type SlowQueryTracer struct {
+	logger    *zap.Logger
+	threshold time.Duration
+}
+
+// NewSlowQueryTracer returns a tracer for [pgx.ConnConfig.Tracer]
+func NewSlowQueryTracer(logger *zap.Logger, threshold time.Duration) *SlowQueryTracer {
+	return &SlowQueryTracer{logger: logger, threshold: threshold}
+}
+
+type traceStartKey struct{}
+
+type traceStart struct {
+	at time.Time
+	// sql holds the statements of a batch, which are only known as they are sent
+	sql []string
+}
+
+func (t *SlowQueryTracer) TraceQueryStart(ctx context.Context, _ *pgx.Conn, data pgx.TraceQueryStartData) context.Context {
+	return context.WithValue(ctx, traceStartKey{}, &traceStart{at: time.Now(), sql: []string{data.SQL}})
+}
+
+func (t *SlowQueryTracer) TraceQueryEnd(ctx context.Context, _ *pgx.Conn, data pgx.TraceQueryEndData) {
+	t.end(ctx, "slow query", data.Err)
+}
+
+func (t *SlowQueryTracer) TraceBatchStart(ctx context.Context, _ *pgx.Conn, _ pgx.TraceBatchStartData) context.Context {
+	return context.WithValue(ctx, traceStartKey{}, &traceStart{at: time.Now()})
+}
+
+func (t *SlowQueryTracer) TraceBatchQuery(ctx context.Context, _ *pgx.Conn, data pgx.TraceBatchQueryData) {
+	if start, ok := ctx.Value(traceStartKey{}).(*traceStart); ok {
+		start.sql = append(start.sql, data.SQL)
+	}
+}
+
+func (t *SlowQueryTracer) TraceBatchEnd(ctx context.Context, _ *pgx.Conn, data pgx.TraceBatchEndData) {
+	t.end(ctx, "slow batch", data.Err)
+}
+
+func (t *SlowQueryTracer) end(ctx context.Context, msg string, err error) {
+	start, ok := ctx.Value(traceStartKey{}).(*traceStart)
+	if !ok {
+		return
+	}
+
+	duration := time.Since(start.at)
+	if duration < t.threshold {
+		return
+	}
+
+	fields := []zap.Field{
+		zap.Duration("duration", duration),
+		zap.Int("statements", len(start.sql)),
+		zap.Error(err),
+	}
+	if len(start.sql) > 0 {
+		// Batches usually repeat one statement, the first one stands for all of them
+		fields = append(fields, zap.String("sql", compactSQL(start.sql[0])))
+	}
+	t.logger.Warn(msg, fields...)
+}
+
+// compactSQL puts a statement on a single line
+func compactSQL(sql string) string {
+	return strings.Join(strings.Fields(sql), " ")
+}
@@ -0,0 +1,51 @@
+package cmdutil_test
+
+import (
+	"context"
+	"errors"
+	"go-project-template/cmdutil"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestSlowQueryTracer(t *testing.T) {
+	t.Parallel()
+
+	core, logs := observer.New(zap.WarnLevel)
+	tracer := cmdutil.NewSlowQueryTracer(zap.New(core), 20*time.Millisecond)
+	ctx := context.Background()
+
+	query := func(sql string, d time.Duration, err error) {
+		ctx := tracer.TraceQueryStart(ctx, nil, pgx.TraceQueryStartData{SQL: sql})
+		time.Sleep(d)
+		tracer.TraceQueryEnd(ctx, nil, pgx.TraceQueryEndData{Err: err})
+	}
+
+	query("SELECT 1", 0, nil)
+	query("SELECT *\n\t\tFROM users\n\t\tWHERE uuid = $1", 30*time.Millisecond, errors.New("canceled"))
+
+	batch := tracer.TraceBatchStart(ctx, nil, pgx.TraceBatchStartData{})
+	tracer.TraceBatchQuery(batch, nil, pgx.TraceBatchQueryData{SQL: "INSERT INTO users"})
+	tracer.TraceBatchQuery(batch, nil, pgx.TraceBatchQueryData{SQL: "INSERT INTO users"})
+	time.Sleep(30 * time.Millisecond)
+	tracer.TraceBatchEnd(batch, nil, pgx.TraceBatchEndData{})
+
+	// Only the statements above the threshold are logged
+	entries := logs.All()
+	require.Len(t, entries, 2)
+
+	assert.Equal(t, "slow query", entries[0].Message)
+	fields := entries[0].ContextMap()
+	assert.Equal(t, "SELECT * FROM users WHERE uuid = $1", fields["sql"])
+	assert.Equal(t, "canceled", fields["error"])
+	assert.GreaterOrEqual(t, fields["duration"], 20*time.Millisecond)
+
+	assert.Equal(t, "slow batch", entries[1].Message)
+	assert.Equal(t, int64(2), entries[1].ContextMap()["statements"])
+}
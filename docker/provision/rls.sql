@@ -0,0 +1,40 @@
+-- Optional row-level security for the users, credentials, organizations and
+-- groups tables, applied on top of init.sql. Members, invitations and subgroups
+-- are only reached through their organization or group.
+-- Every query already filters by tenant, the policies make Postgres refuse
+-- rows of other tenants too. Set TENANT_ROW_LEVEL_SECURITY=true so the service
+-- sends app.tenant_id with every statement, without it the policies show no rows.
+--
+-- Superusers always bypass the policies, the service has to connect as a role
+-- that is not one. FORCE extends the policies to the owner of the table.
+ALTER TABLE users ENABLE ROW LEVEL SECURITY;
+ALTER TABLE users FORCE ROW LEVEL SECURITY;
+
+DROP POLICY IF EXISTS users_tenant_isolation ON users;
+CREATE POLICY users_tenant_isolation ON users
+    USING (tenant_id = current_setting('app.tenant_id', true))
+    WITH CHECK (tenant_id = current_setting('app.tenant_id', true));
+
+ALTER TABLE credentials ENABLE ROW LEVEL SECURITY;
+ALTER TABLE credentials FORCE ROW LEVEL SECURITY;
+
+DROP POLICY IF EXISTS credentials_tenant_isolation ON credentials;
+CREATE POLICY credentials_tenant_isolation ON credentials
+    USING (tenant_id = current_setting('app.tenant_id', true))
+    WITH CHECK (tenant_id = current_setting('app.tenant_id', true));
+
+ALTER TABLE organizations ENABLE ROW LEVEL SECURITY;
+ALTER TABLE organizations FORCE ROW LEVEL SECURITY;
+
+DROP POLICY IF EXISTS organizations_tenant_isolation ON organizations;
+CREATE POLICY organizations_tenant_isolation ON organizations
+    USING (tenant_id = current_setting('app.tenant_id', true))
+    WITH CHECK (tenant_id = current_setting('app.tenant_id', true));
+
+ALTER TABLE groups ENABLE ROW LEVEL SECURITY;
+ALTER TABLE groups FORCE ROW LEVEL SECURITY;
+
+DROP POLICY IF EXISTS groups_tenant_isolation ON groups;
+CREATE POLICY groups_tenant_isolation ON groups
+    USING (tenant_id = current_setting('app.tenant_id', true))
+    WITH CHECK (tenant_id = current_setting('app.tenant_id', true));
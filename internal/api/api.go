@@ -3,8 +3,13 @@ package api
 import (
 	"context"
 	"fmt"
+	"go-project-template/internal/auth"
+	"go-project-template/internal/cache"
 	"go-project-template/internal/domain"
+	"go-project-template/internal/eventbus"
 	"go-project-template/internal/repository"
+	"go-project-template/internal/stream"
+	"go-project-template/internal/webhook"
 	"net"
 	"net/http"
 	"os"
@@ -23,20 +28,140 @@ type api struct {
 	// statsd     *statsd.Client
 	httpClient *http.Client
 
-	userRepo domain.UserRepository
+	userRepo        domain.UserRepository
+	idempotencyRepo domain.IdempotencyRepository
+	idempotencyTTL  time.Duration
+	// idempotencyLease is how long a request in progress holds its key
+	idempotencyLease time.Duration
+	webhookRepo      domain.WebhookRepository
+	orgRepo          domain.OrganizationRepository
+	groupRepo        domain.GroupRepository
+	invitationTTL    time.Duration
+	credentialRepo   domain.CredentialRepository
+	auth             *auth.Service
+	accessTokenTTL   time.Duration
+	refreshTokenTTL  time.Duration
+	userEvents       *stream.Hub
+	events           *eventbus.Bus
+	timeouts         routeTimeouts
+	tenants          tenantConfig
+
+	// Cache-Control policies of GET /users/{userid} and GET /users
+	userCacheControl     string
+	userListCacheControl string
+}
+
+// Dependencies are the stores and services the API is built from. Background
+// workers only run for the dependencies that are set, so tests can build the API
+// from just the repositories they exercise.
+type Dependencies struct {
+	UserRepo        domain.UserRepository
+	IdempotencyRepo domain.IdempotencyRepository
+	WebhookRepo     domain.WebhookRepository
+	OrgRepo         domain.OrganizationRepository
+	GroupRepo       domain.GroupRepository
+	CredentialRepo  domain.CredentialRepository
+	// OutboxRepo and Listener feed the user change stream
+	OutboxRepo domain.OutboxRepository
+	Listener   stream.Listener
+	// Events is the bus the user repository publishes to, it is closed when ctx is done
+	Events     *eventbus.Bus
+	HTTPClient *http.Client
 }
 
-func NewAPI(ctx context.Context, logger *zap.Logger, pool *pgxpool.Pool) *api {
-	userRepo := repository.NewUserRepository(pool)
+// NewAPI returns the API backed by Postgres. User reads are spread over the
+// replicas, everything else runs on pool.
+func NewAPI(ctx context.Context, logger *zap.Logger, pool *pgxpool.Pool, replicas ...*pgxpool.Pool) *api {
+	events := eventbus.New(logger)
+	events.SubscribeAsync("audit", eventbus.LogHandler(logger))
+
+	conn := repository.RLSConnectionFromEnv(pool)
+	replicaConns := make([]repository.Connection, len(replicas))
+	for i, replica := range replicas {
+		replicaConns[i] = repository.RLSConnectionFromEnv(replica)
+	}
+	db := repository.NewRouter(conn, replicaConns, logger, routerConfig())
+	go db.Run(ctx)
+
+	listener := repository.NewListener(pool)
+	userRepo := cache.NewUserRepository(
+		repository.NewUserRepository(db, repository.WithEventPublisher(events), repository.WithLogger(logger)),
+		userCacheStore(logger),
+		logger,
+		userCacheConfig(),
+	)
+	go userRepo.Listen(ctx, listener)
+
+	var webhookOpts []repository.WebhookRepositoryOption
+	if allowPrivateWebhooks() {
+		webhookOpts = append(webhookOpts, repository.WithPrivateWebhookURLs())
+	}
 
-	client := &http.Client{}
+	return New(ctx, logger, Dependencies{
+		UserRepo:        userRepo,
+		IdempotencyRepo: repository.NewIdempotencyRepository(pool),
+		WebhookRepo:     repository.NewWebhookRepository(pool, webhookOpts...),
+		OrgRepo:         repository.NewOrganizationRepository(conn),
+		GroupRepo:       repository.NewGroupRepository(conn),
+		CredentialRepo:  repository.NewCredentialRepository(conn),
+		OutboxRepo:      repository.NewOutboxRepository(pool),
+		Listener:        listener,
+		Events:          events,
+	})
+}
 
-	return &api{
+// New returns the API built from deps and starts its background workers until ctx is done
+func New(ctx context.Context, logger *zap.Logger, deps Dependencies) *api {
+	client := deps.HTTPClient
+	if client == nil {
+		client = webhook.NewClient(30*time.Second, allowPrivateWebhooks())
+	}
+
+	accessTokenTTL, refreshTokenTTL := tokenTTLs()
+	a := &api{
 		logger:     logger,
 		httpClient: client,
 
-		userRepo: userRepo,
+		userRepo:         deps.UserRepo,
+		idempotencyRepo:  deps.IdempotencyRepo,
+		idempotencyTTL:   idempotencyTTL(),
+		idempotencyLease: idempotencyLease(),
+		webhookRepo:      deps.WebhookRepo,
+		orgRepo:          deps.OrgRepo,
+		groupRepo:        deps.GroupRepo,
+		invitationTTL:    invitationTTL(),
+		credentialRepo:   deps.CredentialRepo,
+		accessTokenTTL:   accessTokenTTL,
+		refreshTokenTTL:  refreshTokenTTL,
+		events:           deps.Events,
+		timeouts:         routeTimeoutsFromEnv(),
+		tenants:          tenantConfigFromEnv(),
+
+		userCacheControl:     cacheControl("USER_CACHE_CONTROL"),
+		userListCacheControl: cacheControl("USER_LIST_CACHE_CONTROL"),
+	}
+
+	if a.credentialRepo != nil {
+		a.auth = auth.NewService(a.userRepo, a.credentialRepo, authConfigFromEnv())
+	}
+	if a.idempotencyRepo != nil {
+		go a.idempotencyCleanup(ctx)
+	}
+	if a.webhookRepo != nil {
+		go webhook.NewDispatcher(a.webhookRepo, client, logger, webhook.DefaultConfig()).Run(ctx)
+	}
+	if deps.OutboxRepo != nil && deps.Listener != nil {
+		a.userEvents = stream.NewHub(deps.OutboxRepo, deps.Listener, logger, userStreamConfig())
+		go a.userEvents.Run(ctx)
+	}
+	if a.events != nil {
+		go func() {
+			<-ctx.Done()
+			a.events.Close()
+		}()
 	}
+
+	return a
 }
 
 func (a *api) Server(port int) *http.Server {
@@ -52,26 +177,109 @@ func (a *api) Routes() *chi.Mux {
 	r.Use(cors.Handler(cors.Options{
 		AllowedOrigins:   []string{os.Getenv("ALLOWED_DOMAIN")},
 		AllowedMethods:   []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
-		AllowedHeaders:   []string{"Accept", "Authorization", "Content-Type", "X-CSRF-Token"},
+		AllowedHeaders:   []string{"Accept", "Authorization", "Content-Type", "X-CSRF-Token", "Idempotency-Key", "Last-Event-ID", "If-None-Match", "If-Modified-Since", tenantHeader},
+		ExposedHeaders:   []string{"ETag", "Last-Modified"},
 		AllowCredentials: true,
 	}))
 
 	r.Use(a.loggingMiddleware)
 	r.Use(a.requestIdMiddleware)
+	r.Use(a.readYourWritesMiddleware)
 	r.Use(middleware.Recoverer)
 
 	r.Route("/v1", func(r chi.Router) {
 		// Health Check
 		r.Get("/health", a.healthCheckHandler)
 
-		r.Route("/users", func(r chi.Router) {
-			// Users
-			r.Post("/", a.upsertUserHandler)
-			r.Delete("/{userid}", a.deleteUserHandler)
-			r.Get("/{userid}", a.getByIdUserHandler)
-			r.Get("/", a.getUserListHandler)
+		r.Group(func(r chi.Router) {
+			// Sign-in routes, their clients have no access token yet
+			r.Use(a.signInTenantMiddleware)
+
+			r.With(a.timeoutMiddleware(a.timeouts.write)).Post("/invitations:accept", a.acceptInvitationHandler)
 
+			r.Route("/auth", func(r chi.Router) {
+				r.With(a.timeoutMiddleware(a.timeouts.write)).Post("/login", a.loginHandler)
+				r.With(a.timeoutMiddleware(a.timeouts.write)).Post("/refresh", a.refreshHandler)
+			})
 		})
+
+		r.Group(func(r chi.Router) {
+			// Users, credentials, organizations, groups and webhooks belong to the tenant of the request
+			r.Use(a.tenantMiddleware)
+
+			// Exports and event streams run as long as the client reads them
+			r.With(a.timeoutMiddleware(a.timeouts.batch), a.idempotencyMiddleware).Post("/users:batch", a.batchUpsertUserHandler)
+			r.Get("/users:export", a.exportUserHandler)
+
+			r.Route("/users", func(r chi.Router) {
+				// Users
+				r.With(a.timeoutMiddleware(a.timeouts.write), a.idempotencyMiddleware).Post("/", a.upsertUserHandler)
+				r.Get("/events", a.userEventsHandler)
+				r.With(a.timeoutMiddleware(a.timeouts.write)).Delete("/{userid}", a.deleteUserHandler)
+				r.With(a.timeoutMiddleware(a.timeouts.read)).Get("/{userid}", a.getByIdUserHandler)
+				r.With(a.timeoutMiddleware(a.timeouts.list)).Get("/", a.getUserListHandler)
+
+				// Lifecycle
+				r.With(a.timeoutMiddleware(a.timeouts.write)).Post("/{userid}/activate", a.activateUserHandler)
+				r.With(a.timeoutMiddleware(a.timeouts.write)).Post("/{userid}/suspend", a.suspendUserHandler)
+				r.With(a.timeoutMiddleware(a.timeouts.write)).Post("/{userid}/deactivate", a.deactivateUserHandler)
+
+				// Password
+				r.With(a.timeoutMiddleware(a.timeouts.write), a.selfOrAdminMiddleware).Put("/{userid}/password", a.setPasswordHandler)
+				r.With(a.timeoutMiddleware(a.timeouts.write), a.selfOrAdminMiddleware).Delete("/{userid}/password", a.deletePasswordHandler)
+
+				r.With(a.timeoutMiddleware(a.timeouts.list)).Get("/{userid}/orgs", a.listUserOrganizationHandler)
+				r.With(a.timeoutMiddleware(a.timeouts.list)).Get("/{userid}/groups", a.listUserGroupHandler)
+			})
+
+			r.Route("/orgs", func(r chi.Router) {
+				// Organizations
+				r.With(a.timeoutMiddleware(a.timeouts.write), a.idempotencyMiddleware).Post("/", a.createOrganizationHandler)
+				r.With(a.timeoutMiddleware(a.timeouts.list)).Get("/", a.listOrganizationHandler)
+				r.With(a.timeoutMiddleware(a.timeouts.read)).Get("/{orgid}", a.getOrganizationHandler)
+				r.With(a.timeoutMiddleware(a.timeouts.write)).Put("/{orgid}", a.updateOrganizationHandler)
+				r.With(a.timeoutMiddleware(a.timeouts.write)).Delete("/{orgid}", a.deleteOrganizationHandler)
+
+				// Members
+				r.With(a.timeoutMiddleware(a.timeouts.list)).Get("/{orgid}/members", a.listMemberHandler)
+				r.With(a.timeoutMiddleware(a.timeouts.write)).Put("/{orgid}/members/{userid}", a.setMemberHandler)
+				r.With(a.timeoutMiddleware(a.timeouts.write)).Delete("/{orgid}/members/{userid}", a.removeMemberHandler)
+
+				// Invitations
+				r.With(a.timeoutMiddleware(a.timeouts.write), a.idempotencyMiddleware).Post("/{orgid}/invitations", a.createInvitationHandler)
+				r.With(a.timeoutMiddleware(a.timeouts.read)).Get("/{orgid}/invitations", a.listInvitationHandler)
+				r.With(a.timeoutMiddleware(a.timeouts.write)).Delete("/{orgid}/invitations/{invitationid}", a.revokeInvitationHandler)
+			})
+
+			r.Route("/groups", func(r chi.Router) {
+				// Groups
+				r.With(a.timeoutMiddleware(a.timeouts.write), a.idempotencyMiddleware).Post("/", a.createGroupHandler)
+				r.With(a.timeoutMiddleware(a.timeouts.list)).Get("/", a.listGroupHandler)
+				r.With(a.timeoutMiddleware(a.timeouts.read)).Get("/{groupid}", a.getGroupHandler)
+				r.With(a.timeoutMiddleware(a.timeouts.write)).Put("/{groupid}", a.updateGroupHandler)
+				r.With(a.timeoutMiddleware(a.timeouts.write)).Delete("/{groupid}", a.deleteGroupHandler)
+
+				// Members and subgroups, added and removed in bulk
+				r.With(a.timeoutMiddleware(a.timeouts.list)).Get("/{groupid}/members", a.listGroupMemberHandler)
+				r.With(a.timeoutMiddleware(a.timeouts.write)).Post("/{groupid}/members:add", a.addGroupMemberHandler)
+				r.With(a.timeoutMiddleware(a.timeouts.write)).Post("/{groupid}/members:remove", a.removeGroupMemberHandler)
+				r.With(a.timeoutMiddleware(a.timeouts.list)).Get("/{groupid}/subgroups", a.listSubgroupHandler)
+				r.With(a.timeoutMiddleware(a.timeouts.write)).Post("/{groupid}/subgroups:add", a.addSubgroupHandler)
+				r.With(a.timeoutMiddleware(a.timeouts.write)).Post("/{groupid}/subgroups:remove", a.removeSubgroupHandler)
+			})
+
+			r.Route("/webhooks", func(r chi.Router) {
+				// Webhooks
+				r.With(a.timeoutMiddleware(a.timeouts.write)).Post("/", a.createWebhookHandler)
+				r.With(a.timeoutMiddleware(a.timeouts.list)).Get("/", a.listWebhookHandler)
+				r.With(a.timeoutMiddleware(a.timeouts.read)).Get("/{webhookid}", a.getWebhookHandler)
+				r.With(a.timeoutMiddleware(a.timeouts.write)).Put("/{webhookid}", a.updateWebhookHandler)
+				r.With(a.timeoutMiddleware(a.timeouts.write)).Delete("/{webhookid}", a.deleteWebhookHandler)
+				r.With(a.timeoutMiddleware(a.timeouts.list)).Get("/{webhookid}/deliveries", a.listWebhookDeliveryHandler)
+				r.With(a.timeoutMiddleware(a.timeouts.write)).Post("/{webhookid}/deliveries/{deliveryid}/redeliver", a.redeliverWebhookHandler)
+			})
+		})
+
 	})
 
 	return r
@@ -98,6 +306,11 @@ func (lrw *LoggingResponseWriter) WriteHeader(statusCode int) {
 	lrw.statusCode = statusCode
 }
 
+// Unwrap exposes the underlying writer to [http.ResponseController] so handlers can flush
+func (lrw *LoggingResponseWriter) Unwrap() http.ResponseWriter {
+	return lrw.w
+}
+
 func (a *api) requestIdMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		id := uuid.New().String()
@@ -116,6 +329,7 @@ func (a *api) loggingMiddleware(next http.Handler) http.Handler {
 
 		start := time.Now()
 		lrw := &LoggingResponseWriter{w: w}
+		r, reqErr := withErrorSlot(r)
 
 		// Call the next handler, which can be another middleware in the chain, or the final handler.
 		next.ServeHTTP(lrw, r)
@@ -141,11 +355,14 @@ func (a *api) loggingMiddleware(next http.Handler) http.Handler {
 			zap.String("request#id", lrw.Header().Get("X-Project-Request-Id")),
 		}
 
-		if lrw.statusCode == 200 || lrw.statusCode == 201 {
+		if lrw.statusCode < 400 {
 			a.logger.Info("", fields...)
 		} else {
-			err := lrw.Header().Get("X-Project-Error")
-			a.logger.Error(err, fields...)
+			msg := lrw.Header().Get("X-Project-Error")
+			if *reqErr != nil {
+				msg = (*reqErr).Error()
+			}
+			a.logger.Error(msg, fields...)
 		}
 
 		// tags := []string{fmt.Sprintf("status:%d", lrw.statusCode)}
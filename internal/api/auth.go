@@ -0,0 +1,340 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"go-project-template/internal/auth"
+	"go-project-template/internal/domain"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+)
+
+const (
+	defaultAccessTokenTTL  = 15 * time.Minute
+	defaultRefreshTokenTTL = 30 * 24 * time.Hour
+
+	// refreshTokenUse marks refresh tokens, which are only accepted by
+	// /auth/refresh and never as bearer tokens
+	refreshTokenUse = "refresh"
+)
+
+// errLoginDisabled is returned by the auth routes while JWT_SECRET is not set
+var errLoginDisabled = errors.New("login requires JWT_SECRET to sign tokens")
+
+// errOtherUser rejects tokens acting on a user that is not their subject
+var errOtherUser = errors.New("token may only act on its own user")
+
+// selfOrAdminMiddleware lets a request act on the user of its route only with
+// a token of that user or one with the admin scope. Setting a password unlocks
+// it, so anyone else could undo a lockout. Without JWT_SECRET there are no
+// tokens to check and every request passes.
+func (a *api) selfOrAdminMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !a.tenants.authenticated() {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		claims := claimsFromContext(r.Context())
+		if claims == nil {
+			a.errorResponse(w, r, http.StatusUnauthorized, errBearerRequired)
+			return
+		}
+		if !claims.hasScope(adminScope) {
+			id, err := urlUUID(r, "userid")
+			subject, subErr := uuid.Parse(claims.Subject)
+			if err != nil || subErr != nil || id != subject {
+				a.errorResponse(w, r, http.StatusForbidden, errOtherUser)
+				return
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// authConfigFromEnv reads the Argon2id parameters from ARGON2_MEMORY (KiB),
+// ARGON2_ITERATIONS and ARGON2_PARALLELISM, and the lockout from
+// LOGIN_MAX_ATTEMPTS and LOGIN_LOCKOUT
+func authConfigFromEnv() auth.Config {
+	cfg := auth.DefaultConfig()
+	if n, err := strconv.ParseUint(os.Getenv("ARGON2_MEMORY"), 10, 32); err == nil && n > 0 {
+		cfg.Params.Memory = uint32(n)
+	}
+	if n, err := strconv.ParseUint(os.Getenv("ARGON2_ITERATIONS"), 10, 32); err == nil && n > 0 {
+		cfg.Params.Iterations = uint32(n)
+	}
+	if n, err := strconv.ParseUint(os.Getenv("ARGON2_PARALLELISM"), 10, 8); err == nil && n > 0 {
+		cfg.Params.Parallelism = uint8(n)
+	}
+	if n, err := strconv.Atoi(os.Getenv("LOGIN_MAX_ATTEMPTS")); err == nil && n > 0 {
+		cfg.MaxAttempts = n
+	}
+	if d, err := time.ParseDuration(os.Getenv("LOGIN_LOCKOUT")); err == nil && d > 0 {
+		cfg.Lockout = d
+	}
+	return cfg
+}
+
+// tokenTTLs reads ACCESS_TOKEN_TTL and REFRESH_TOKEN_TTL
+func tokenTTLs() (access time.Duration, refresh time.Duration) {
+	access, refresh = defaultAccessTokenTTL, defaultRefreshTokenTTL
+	if d, err := time.ParseDuration(os.Getenv("ACCESS_TOKEN_TTL")); err == nil && d > 0 {
+		access = d
+	}
+	if d, err := time.ParseDuration(os.Getenv("REFRESH_TOKEN_TTL")); err == nil && d > 0 {
+		refresh = d
+	}
+	return access, refresh
+}
+
+// Login Request model
+// @Description Email and password of a user of the tenant
+type loginRequest struct {
+	Email    string `json:"email" example:"john@mail.com"`
+	Password string `json:"password" example:"correct horse Battery 9"`
+}
+
+// Refresh Request model
+// @Description Refresh token returned by a login
+type refreshRequest struct {
+	RefreshToken string `json:"refresh_token" example:"eyJhbGciOi..."`
+}
+
+// Password Request model
+// @Description New password of a user
+type passwordRequest struct {
+	Password string `json:"password" example:"correct horse Battery 9"`
+}
+
+// Token Response model
+// @Description Signed tokens of a user, the access token is sent as a bearer token
+type tokenResponse struct {
+	AccessToken  string `json:"access_token" example:"eyJhbGciOi..."`
+	RefreshToken string `json:"refresh_token" example:"eyJhbGciOi..."`
+	TokenType    string `json:"token_type" example:"Bearer"`
+	// ExpiresIn is the lifetime of the access token in seconds
+	ExpiresIn int `json:"expires_in" example:"900"`
+}
+
+// issueTokens signs an access and a refresh token for the user
+func (a *api) issueTokens(u domain.User) (tokenResponse, error) {
+	now := time.Now()
+	claims := func(ttl time.Duration, use string) tenantClaims {
+		return tenantClaims{
+			RegisteredClaims: jwt.RegisteredClaims{
+				ID:        uuid.NewString(),
+				Subject:   u.UUID.String(),
+				IssuedAt:  jwt.NewNumericDate(now),
+				ExpiresAt: jwt.NewNumericDate(now.Add(ttl)),
+			},
+			TenantID: u.TenantID,
+			TokenUse: use,
+		}
+	}
+
+	access, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims(a.accessTokenTTL, "")).SignedString(a.tenants.jwtSecret)
+	if err != nil {
+		return tokenResponse{}, err
+	}
+	refresh, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims(a.refreshTokenTTL, refreshTokenUse)).SignedString(a.tenants.jwtSecret)
+	if err != nil {
+		return tokenResponse{}, err
+	}
+
+	return tokenResponse{
+		AccessToken:  access,
+		RefreshToken: refresh,
+		TokenType:    "Bearer",
+		ExpiresIn:    int(a.accessTokenTTL.Seconds()),
+	}, nil
+}
+
+// Login godoc
+// @Summary Log In
+// @Description Accepts the email and password of a user of the tenant and returns signed access and refresh tokens. Too many failed logins in a row lock the password for a while, invited users become active with their first login.
+// @Tags  Auth
+// @Accept json
+// @Produce json
+// @Param payload body loginRequest true "Credentials"
+// @Param X-Tenant-ID header string false "tenant of the request"
+// @Success 200 {object} tokenResponse
+// @Failure 400
+// @Failure 401
+// @Failure 403
+// @Failure 429
+// @Failure 501
+// @Failure 503 {object} problem
+// @Failure 504 {object} problem
+// @Router /auth/login [post]
+func (a *api) loginHandler(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+
+	if len(a.tenants.jwtSecret) == 0 {
+		a.errorResponse(w, r, http.StatusNotImplemented, errLoginDisabled)
+		return
+	}
+
+	var req loginRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		a.errorResponse(w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	u, err := a.auth.Login(ctx, req.Email, req.Password)
+	if err != nil {
+		a.errorResponse(w, r, errorStatus(err), err)
+		return
+	}
+
+	tokens, err := a.issueTokens(u)
+	if err != nil {
+		a.errorResponse(w, r, http.StatusInternalServerError, err)
+		return
+	}
+	w.Header().Set("Cache-Control", "no-store")
+	writeJSON(w, http.StatusOK, tokens)
+}
+
+// Refresh godoc
+// @Summary Refresh Tokens
+// @Description Accepts a refresh token and returns new access and refresh tokens. Refresh tokens issued before the password last changed are refused.
+// @Tags  Auth
+// @Accept json
+// @Produce json
+// @Param payload body refreshRequest true "Refresh token"
+// @Param X-Tenant-ID header string false "tenant of the request"
+// @Success 200 {object} tokenResponse
+// @Failure 400
+// @Failure 401
+// @Failure 403
+// @Failure 501
+// @Failure 503 {object} problem
+// @Failure 504 {object} problem
+// @Router /auth/refresh [post]
+func (a *api) refreshHandler(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+
+	if len(a.tenants.jwtSecret) == 0 {
+		a.errorResponse(w, r, http.StatusNotImplemented, errLoginDisabled)
+		return
+	}
+
+	var req refreshRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		a.errorResponse(w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	var claims tenantClaims
+	_, err := jwt.ParseWithClaims(req.RefreshToken, &claims, func(*jwt.Token) (interface{}, error) {
+		return a.tenants.jwtSecret, nil
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}), jwt.WithExpirationRequired(), jwt.WithIssuedAt())
+	if err != nil || claims.TokenUse != refreshTokenUse || claims.IssuedAt == nil {
+		a.errorResponse(w, r, http.StatusUnauthorized, domain.ErrInvalidCredentials)
+		return
+	}
+	userUUID, err := uuid.Parse(claims.Subject)
+	if err != nil {
+		a.errorResponse(w, r, http.StatusUnauthorized, domain.ErrInvalidCredentials)
+		return
+	}
+
+	// The tenant middleware did not see the token, it has to name the tenant of the request
+	if tenant, _ := domain.TenantFromContext(ctx); claims.TenantID != tenant {
+		a.errorResponse(w, r, http.StatusForbidden, errors.New("refresh token names another tenant"))
+		return
+	}
+
+	u, err := a.auth.Refresh(ctx, userUUID, claims.IssuedAt.Time)
+	if err != nil {
+		a.errorResponse(w, r, errorStatus(err), err)
+		return
+	}
+
+	tokens, err := a.issueTokens(u)
+	if err != nil {
+		a.errorResponse(w, r, http.StatusInternalServerError, err)
+		return
+	}
+	w.Header().Set("Cache-Control", "no-store")
+	writeJSON(w, http.StatusOK, tokens)
+}
+
+// Set Password godoc
+// @Summary Set Password
+// @Description Sets the password a user logs in with and unlocks it. With JWT_SECRET set it needs a token of the user or one with the admin scope. The password has to follow the password policy, and the user needs an email no other user of the tenant with a password has.
+// @Tags  Users
+// @Accept json
+// @Param userid path string true "userid"
+// @Param payload body passwordRequest true "Password"
+// @Param X-Tenant-ID header string false "tenant of the request"
+// @Success 204
+// @Failure 400
+// @Failure 401
+// @Failure 403
+// @Failure 404
+// @Failure 409
+// @Failure 422
+// @Failure 503 {object} problem
+// @Failure 504 {object} problem
+// @Router /users/{userid}/password [put]
+func (a *api) setPasswordHandler(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+
+	id, err := urlUUID(r, "userid")
+	if err != nil {
+		a.errorResponse(w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	var req passwordRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		a.errorResponse(w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	if err := a.auth.SetPassword(ctx, id, req.Password); err != nil {
+		a.errorResponse(w, r, errorStatus(err), err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Delete Password godoc
+// @Summary Delete Password
+// @Description Removes the password of a user, who cannot log in anymore. With JWT_SECRET set it needs a token of the user or one with the admin scope. Refresh tokens of the user are refused from then on.
+// @Tags  Users
+// @Param userid path string true "userid"
+// @Param X-Tenant-ID header string false "tenant of the request"
+// @Success 204
+// @Failure 400
+// @Failure 401
+// @Failure 403
+// @Failure 503 {object} problem
+// @Failure 504 {object} problem
+// @Router /users/{userid}/password [delete]
+func (a *api) deletePasswordHandler(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+
+	id, err := urlUUID(r, "userid")
+	if err != nil {
+		a.errorResponse(w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	if err := a.credentialRepo.Delete(ctx, id); err != nil {
+		a.errorResponse(w, r, errorStatus(err), err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,167 @@
+package api_test
+
+import (
+	"go-project-template/internal/domain"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+)
+
+const testPassword = "correct horse Battery 9"
+
+type tokens struct {
+	AccessToken  string `json:"access_token"`
+	RefreshToken string `json:"refresh_token"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int    `json:"expires_in"`
+}
+
+// setAuthEnv signs tokens with testJWTSecret and makes hashing cheap
+func setAuthEnv(t *testing.T) {
+	t.Helper()
+	t.Setenv("JWT_SECRET", testJWTSecret)
+	t.Setenv("ARGON2_MEMORY", "1024")
+	t.Setenv("ARGON2_ITERATIONS", "1")
+	t.Setenv("ARGON2_PARALLELISM", "1")
+	t.Setenv("LOGIN_MAX_ATTEMPTS", "3")
+}
+
+// serviceToken returns an admin bearer token of the default tenant for the
+// calls made besides signing in
+func serviceToken(t *testing.T) string {
+	t.Helper()
+	return bearer(t, testJWTSecret, jwt.MapClaims{"tenant_id": domain.DefaultTenant, "scope": "admin", "exp": time.Now().Add(time.Hour).Unix()})
+}
+
+func (h *harness) login(email string, password string, status int) tokens {
+	h.t.Helper()
+	var tt tokens
+	res := h.request(http.MethodPost, "/v1/auth/login").
+		withJSON(map[string]string{"email": email, "password": password}).
+		expect(status)
+	if status == http.StatusOK {
+		res.hasHeader("Cache-Control", "no-store").decode(&tt)
+	}
+	return tt
+}
+
+func TestAuth_Login(t *testing.T) { //nolint:paralleltest
+	setAuthEnv(t)
+	h := newHarness(t)
+	token := serviceToken(t)
+	h.seed(testUser(johnUUID, "John", "Wick"))
+	path := "/v1/users/" + johnUUID.String() + "/password"
+
+	h.request(http.MethodPut, path).withHeader("Authorization", token).withJSON(map[string]string{"password": "short"}).expect(http.StatusUnprocessableEntity)
+	h.request(http.MethodPut, "/v1/users/"+helenUUID.String()+"/password").withHeader("Authorization", token).withJSON(map[string]string{"password": testPassword}).expect(http.StatusNotFound)
+	h.request(http.MethodPut, path).withHeader("Authorization", token).withJSON(map[string]string{"password": testPassword}).expect(http.StatusNoContent)
+
+	h.login("john@mail.com", "wrong horse Battery 9", http.StatusUnauthorized)
+	h.login("nobody@mail.com", testPassword, http.StatusUnauthorized)
+	h.request(http.MethodPost, "/v1/auth/login").withJSON(`{"email":`).expect(http.StatusBadRequest)
+
+	tt := h.login("john@mail.com", testPassword, http.StatusOK)
+	assert.Equal(t, "Bearer", tt.TokenType)
+	assert.Equal(t, 900, tt.ExpiresIn)
+
+	// The access token names the tenant, the refresh token is no bearer token
+	h.request(http.MethodGet, "/v1/users/"+johnUUID.String()).withHeader("Authorization", "Bearer "+tt.AccessToken).expect(http.StatusOK)
+	h.request(http.MethodGet, "/v1/users/"+johnUUID.String()).withHeader("Authorization", "Bearer "+tt.RefreshToken).expect(http.StatusUnauthorized)
+
+	var refreshed tokens
+	h.request(http.MethodPost, "/v1/auth/refresh").withJSON(map[string]string{"refresh_token": tt.RefreshToken}).expect(http.StatusOK).decode(&refreshed)
+	assert.NotEmpty(t, refreshed.AccessToken)
+	h.request(http.MethodPost, "/v1/auth/refresh").withJSON(map[string]string{"refresh_token": tt.AccessToken}).expect(http.StatusUnauthorized)
+	h.request(http.MethodPost, "/v1/auth/refresh").
+		withHeader("X-Tenant-ID", "acme").
+		withJSON(map[string]string{"refresh_token": tt.RefreshToken}).
+		expect(http.StatusForbidden)
+
+	// Without a password the refresh tokens are refused
+	h.request(http.MethodDelete, path).withHeader("Authorization", token).expect(http.StatusNoContent)
+	h.request(http.MethodPost, "/v1/auth/refresh").withJSON(map[string]string{"refresh_token": tt.RefreshToken}).expect(http.StatusUnauthorized)
+	h.login("john@mail.com", testPassword, http.StatusUnauthorized)
+}
+
+func TestAuth_PasswordAuthorization(t *testing.T) { //nolint:paralleltest
+	setAuthEnv(t)
+	h := newHarness(t)
+	h.seed(testUser(johnUUID, "John", "Wick"), testUser(helenUUID, "Helen", "Wick"))
+	path := "/v1/users/" + johnUUID.String() + "/password"
+	body := map[string]string{"password": testPassword}
+
+	exp := time.Now().Add(time.Hour).Unix()
+	token := func(claims jwt.MapClaims) string {
+		claims["tenant_id"], claims["exp"] = domain.DefaultTenant, exp
+		return bearer(t, testJWTSecret, claims)
+	}
+
+	// Anyone could otherwise reset the password and its lockout
+	h.request(http.MethodPut, path).withJSON(body).expect(http.StatusUnauthorized)
+	h.request(http.MethodDelete, path).expect(http.StatusUnauthorized)
+	h.request(http.MethodPut, path).withHeader("Authorization", "Bearer forged").withJSON(body).expect(http.StatusUnauthorized)
+
+	helen := token(jwt.MapClaims{"sub": helenUUID.String()})
+	h.request(http.MethodPut, path).withHeader("Authorization", helen).withJSON(body).expect(http.StatusForbidden)
+	h.request(http.MethodDelete, path).withHeader("Authorization", helen).expect(http.StatusForbidden)
+	h.request(http.MethodPut, path).withHeader("Authorization", token(jwt.MapClaims{})).withJSON(body).expect(http.StatusForbidden)
+
+	h.request(http.MethodPut, path).withHeader("Authorization", token(jwt.MapClaims{"scope": "admin"})).withJSON(body).expect(http.StatusNoContent)
+	tt := h.login("john@mail.com", testPassword, http.StatusOK)
+	h.request(http.MethodPut, path).withHeader("Authorization", "Bearer "+tt.AccessToken).withJSON(body).expect(http.StatusNoContent)
+	h.request(http.MethodDelete, path).withHeader("Authorization", "Bearer "+tt.AccessToken).expect(http.StatusNoContent)
+}
+
+func TestAuth_Lockout(t *testing.T) { //nolint:paralleltest
+	setAuthEnv(t)
+	h := newHarness(t)
+	token := serviceToken(t)
+	h.seed(testUser(johnUUID, "John", "Wick"))
+	h.request(http.MethodPut, "/v1/users/"+johnUUID.String()+"/password").withHeader("Authorization", token).withJSON(map[string]string{"password": testPassword}).expect(http.StatusNoContent)
+
+	for range 3 {
+		h.login("john@mail.com", "wrong horse Battery 9", http.StatusUnauthorized)
+	}
+	h.request(http.MethodPost, "/v1/auth/login").
+		withJSON(map[string]string{"email": "john@mail.com", "password": testPassword}).
+		expect(http.StatusTooManyRequests).
+		hasError(domain.ErrAccountLocked.Error())
+
+	// Setting the password again unlocks it
+	h.request(http.MethodPut, "/v1/users/"+johnUUID.String()+"/password").withHeader("Authorization", token).withJSON(map[string]string{"password": testPassword}).expect(http.StatusNoContent)
+	h.login("john@mail.com", testPassword, http.StatusOK)
+}
+
+func TestAuth_Status(t *testing.T) { //nolint:paralleltest
+	setAuthEnv(t)
+	h := newHarness(t)
+	token := serviceToken(t)
+	john := testUser(johnUUID, "John", "Wick")
+	john.Status = domain.UserStatusInvited
+	h.seed(john)
+	h.request(http.MethodPut, "/v1/users/"+johnUUID.String()+"/password").withHeader("Authorization", token).withJSON(map[string]string{"password": testPassword}).expect(http.StatusNoContent)
+
+	// The first login accepts the invitation
+	h.login("john@mail.com", testPassword, http.StatusOK)
+	var u domain.User
+	h.request(http.MethodGet, "/v1/users/"+johnUUID.String()).withHeader("Authorization", token).expect(http.StatusOK).decode(&u)
+	assert.Equal(t, domain.UserStatusActive, u.Status)
+
+	h.request(http.MethodPost, "/v1/users/"+johnUUID.String()+"/suspend").withHeader("Authorization", token).withJSON(map[string]string{"reason": "fraud"}).expect(http.StatusOK)
+	h.login("john@mail.com", testPassword, http.StatusForbidden)
+}
+
+func TestAuth_WithoutSecret(t *testing.T) { //nolint:paralleltest
+	setAuthEnv(t)
+	t.Setenv("JWT_SECRET", "")
+	h := newHarness(t)
+
+	h.request(http.MethodPost, "/v1/auth/login").
+		withJSON(map[string]string{"email": "john@mail.com", "password": testPassword}).
+		expect(http.StatusNotImplemented).
+		hasError("login requires JWT_SECRET to sign tokens")
+	h.request(http.MethodPost, "/v1/auth/refresh").withJSON(map[string]string{"refresh_token": "token"}).expect(http.StatusNotImplemented)
+}
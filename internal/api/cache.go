@@ -0,0 +1,54 @@
+package api
+
+import (
+	"go-project-template/internal/cache"
+	"os"
+	"strconv"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const (
+	defaultUserCacheSize = 10000
+	// defaultCacheControl lets clients keep responses but revalidate them on every use
+	defaultCacheControl = "private, no-cache"
+)
+
+// userCacheStore returns a Redis store when REDIS_URL is set and an in-process
+// store of USER_CACHE_SIZE users otherwise
+func userCacheStore(logger *zap.Logger) cache.Store {
+	if rawURL := os.Getenv("REDIS_URL"); rawURL != "" {
+		store, err := cache.NewRedis(rawURL)
+		if err == nil {
+			return store
+		}
+		logger.Error("invalid REDIS_URL, caching users in process instead", zap.Error(err))
+	}
+
+	size, err := strconv.Atoi(os.Getenv("USER_CACHE_SIZE"))
+	if err != nil || size <= 0 {
+		size = defaultUserCacheSize
+	}
+	return cache.NewLRU(size)
+}
+
+// userCacheConfig reads the USER_CACHE_TTL and USER_CACHE_LOAD_TIMEOUT durations from the environment
+func userCacheConfig() cache.Config {
+	cfg := cache.DefaultConfig()
+	if ttl, err := time.ParseDuration(os.Getenv("USER_CACHE_TTL")); err == nil && ttl > 0 {
+		cfg.TTL = ttl
+	}
+	if timeout := envDuration("USER_CACHE_LOAD_TIMEOUT", cfg.LoadTimeout); timeout > 0 {
+		cfg.LoadTimeout = timeout
+	}
+	return cfg
+}
+
+// cacheControl reads the Cache-Control policy of a route from the environment
+func cacheControl(key string) string {
+	if policy := os.Getenv(key); policy != "" {
+		return policy
+	}
+	return defaultCacheControl
+}
@@ -0,0 +1,63 @@
+package api
+
+import (
+	"mime"
+	"strconv"
+	"strings"
+)
+
+// exportFlushRows is the number of rows written between flushes of a streamed export
+const exportFlushRows = 500
+
+// negotiateContentType returns the offered media type the Accept header
+// prefers. Every offer gets the quality of the most specific media range that
+// matches it and q=0 refuses it. Between equal qualities the range listed
+// first wins, then the earlier offer. An empty header accepts the first offer.
+func negotiateContentType(accept string, offers ...string) string {
+	if strings.TrimSpace(accept) == "" {
+		return offers[0]
+	}
+
+	parts := strings.Split(accept, ",")
+	best, bestQ, bestPos := "", 0.0, len(parts)
+	for _, offer := range offers {
+		q, pos, specificity := 0.0, len(parts), -1
+		for i, part := range parts {
+			mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+			if err != nil {
+				continue
+			}
+
+			s := mediaRangeSpecificity(mediaType, offer)
+			if s <= specificity {
+				continue
+			}
+			rangeQ := 1.0
+			if v, ok := params["q"]; ok {
+				if rangeQ, err = strconv.ParseFloat(v, 64); err != nil {
+					continue
+				}
+			}
+			q, pos, specificity = rangeQ, i, s
+		}
+
+		if q > bestQ || (q > 0 && q == bestQ && pos < bestPos) {
+			best, bestQ, bestPos = offer, q, pos
+		}
+	}
+	return best
+}
+
+// mediaRangeSpecificity returns 2 if mediaRange is offer, 1 if it is the type
+// of offer with any subtype, 0 for */* and -1 if it does not match offer
+func mediaRangeSpecificity(mediaRange, offer string) int {
+	switch {
+	case mediaRange == offer:
+		return 2
+	case mediaRange == "*/*":
+		return 0
+	case strings.HasSuffix(mediaRange, "/*") && strings.HasPrefix(offer, strings.TrimSuffix(mediaRange, "*")):
+		return 1
+	}
+	return -1
+}
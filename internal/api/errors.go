@@ -1,10 +1,108 @@
 package api
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
+	"go-project-template/internal/domain"
 	"net/http"
+	"strings"
+
+	validation "github.com/go-ozzo/ozzo-validation/v4"
+	"github.com/jackc/pgx/v5/pgconn"
 )
 
-func (a *api) errorResponse(w http.ResponseWriter, _ *http.Request, status int, err error) {
-	w.Header().Set("X-Project-Error", err.Error())
-	http.Error(w, err.Error(), status)
+// queryCanceled is the SQLSTATE of statements cancelled by statement_timeout or a cancel request
+const queryCanceled = "57014"
+
+// problem is an RFC 9457 problem details body
+type problem struct {
+	Type   string `json:"type"`
+	Title  string `json:"title"`
+	Status int    `json:"status"`
+	Detail string `json:"detail,omitempty"`
+}
+
+// errorKey carries the slot loggingMiddleware reads the error of a failed request from
+type errorKey struct{}
+
+// withErrorSlot returns r with a slot for the error of the request
+func withErrorSlot(r *http.Request) (*http.Request, *error) {
+	slot := new(error)
+	return r.WithContext(context.WithValue(r.Context(), errorKey{}, slot)), slot
+}
+
+// errorCode names a status for the X-Project-Error header, e.g. not_found
+func errorCode(status int) string {
+	return strings.ReplaceAll(strings.ToLower(http.StatusText(status)), " ", "_")
+}
+
+// errorResponse writes the error response for status. The error itself only
+// goes to the log, X-Project-Error carries a stable code, and server errors do
+// not show it in the body either.
+func (a *api) errorResponse(w http.ResponseWriter, r *http.Request, status int, err error) {
+	if slot, ok := r.Context().Value(errorKey{}).(*error); ok {
+		*slot = err
+	}
+	w.Header().Set("X-Project-Error", errorCode(status))
+
+	switch status {
+	case http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		// The error would expose the statement, clients only learn that they may retry
+		if status == http.StatusServiceUnavailable {
+			w.Header().Set("Retry-After", "1")
+		}
+		w.Header().Set("Content-Type", "application/problem+json")
+		w.WriteHeader(status)
+		_ = json.NewEncoder(w).Encode(problem{
+			Type:   "about:blank",
+			Title:  http.StatusText(status),
+			Status: status,
+			Detail: timeoutDetail(status),
+		})
+	case http.StatusInternalServerError:
+		http.Error(w, http.StatusText(status), status)
+	default:
+		http.Error(w, err.Error(), status)
+	}
+}
+
+func timeoutDetail(status int) string {
+	if status == http.StatusGatewayTimeout {
+		return "the request ran out of time"
+	}
+	return "the database cancelled the request, try again later"
+}
+
+// errorStatus maps repository errors to response codes
+func errorStatus(err error) int {
+	var verr validation.Errors
+	var pgErr *pgconn.PgError
+	switch {
+	case errors.Is(err, domain.ErrNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, domain.ErrConflict), errors.Is(err, domain.ErrLastOwner), errors.Is(err, domain.ErrGroupCycle),
+		errors.Is(err, domain.ErrIllegalTransition):
+		return http.StatusConflict
+	case errors.Is(err, domain.ErrInvitationInvalid):
+		return http.StatusGone
+	case errors.Is(err, domain.ErrInvitationEmail), errors.Is(err, domain.ErrAccountDisabled):
+		return http.StatusForbidden
+	case errors.Is(err, domain.ErrInvalidCredentials):
+		return http.StatusUnauthorized
+	case errors.Is(err, domain.ErrAccountLocked):
+		return http.StatusTooManyRequests
+	case errors.Is(err, domain.ErrCredentialEmail):
+		return http.StatusUnprocessableEntity
+	case errors.Is(err, domain.ErrTenantRequired):
+		return http.StatusBadRequest
+	case errors.As(err, &verr):
+		return http.StatusUnprocessableEntity
+	case errors.Is(err, context.DeadlineExceeded):
+		return http.StatusGatewayTimeout
+	case errors.As(err, &pgErr) && pgErr.Code == queryCanceled:
+		return http.StatusServiceUnavailable
+	default:
+		return http.StatusInternalServerError
+	}
 }
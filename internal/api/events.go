@@ -0,0 +1,161 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go-project-template/internal/domain"
+	"go-project-template/internal/stream"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const (
+	sseHeartbeat  = 15 * time.Second
+	sseRetry      = 3 * time.Second
+	sseRetryAfter = "10"
+)
+
+// userStreamConfig reads the SSE_MAX_SUBSCRIBERS cap from the environment
+func userStreamConfig() stream.Config {
+	cfg := stream.DefaultConfig()
+	if n, err := strconv.Atoi(os.Getenv("SSE_MAX_SUBSCRIBERS")); err == nil && n > 0 {
+		cfg.MaxSubscribers = n
+	}
+	return cfg
+}
+
+// lastEventID reads the Last-Event-ID header sent by reconnecting clients, or
+// the last_event_id query parameter for the first connection of a page
+func lastEventID(r *http.Request) (int64, error) {
+	v := r.Header.Get("Last-Event-ID")
+	if v == "" {
+		v = r.URL.Query().Get("last_event_id")
+	}
+	if v == "" {
+		return 0, nil
+	}
+	return strconv.ParseInt(v, 10, 64)
+}
+
+// User Events godoc
+// @Summary Stream User Changes
+// @Description Streams user.created, user.updated and user.deleted events as Server-Sent Events. Reconnecting clients resume after Last-Event-ID. A reset event tells the client it missed too many events and should reload its data. Comment lines are sent as heartbeats.
+// @Tags  Users
+// @Produce text/event-stream
+// @Param types query string false "comma separated event types"
+// @Param uuid query string false "only events of this user"
+// @Param Last-Event-ID header string false "ID of the last event received"
+// @Param last_event_id query string false "ID of the last event received, for clients that cannot set headers"
+// @Param X-Tenant-ID header string false "tenant of the request"
+// @Success 200
+// @Failure 400
+// @Failure 503
+// @Router /users/events [get]
+func (a *api) userEventsHandler(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+
+	filter, err := stream.ParseFilter(r.URL.Query().Get("types"), r.URL.Query().Get("uuid"))
+	if err != nil {
+		a.errorResponse(w, r, http.StatusBadRequest, err)
+		return
+	}
+	// Subscribers only ever see the changes of their own tenant
+	if filter.TenantID, err = domain.TenantFromContext(ctx); err != nil {
+		a.errorResponse(w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	after, err := lastEventID(r)
+	if err != nil {
+		a.errorResponse(w, r, http.StatusBadRequest, fmt.Errorf("invalid last event id: %w", err))
+		return
+	}
+
+	reset := false
+	sub, replay, err := a.userEvents.Subscribe(ctx, filter, after)
+	if errors.Is(err, stream.ErrReplayTooLong) {
+		reset = true
+		sub, replay, err = a.userEvents.Subscribe(ctx, filter, 0)
+	}
+	if errors.Is(err, stream.ErrTooManySubscribers) {
+		w.Header().Set("Retry-After", sseRetryAfter)
+		a.errorResponse(w, r, http.StatusServiceUnavailable, err)
+		return
+	}
+	if err != nil {
+		a.errorResponse(w, r, http.StatusInternalServerError, err)
+		return
+	}
+	defer a.userEvents.Unsubscribe(sub)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	// Stop reverse proxies from buffering the stream
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	rc := http.NewResponseController(w)
+	_, _ = fmt.Fprintf(w, "retry: %d\n\n", sseRetry.Milliseconds())
+	if reset {
+		_, _ = fmt.Fprint(w, "event: reset\ndata: {}\n\n")
+	}
+
+	replayed := make(map[int64]struct{}, len(replay))
+	for _, e := range replay {
+		if err := writeEvent(w, e); err != nil {
+			return
+		}
+		replayed[e.ID] = struct{}{}
+	}
+	if err := rc.Flush(); err != nil {
+		return
+	}
+
+	heartbeat := time.NewTicker(sseHeartbeat)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case e, ok := <-sub.C:
+			if !ok {
+				// Dropped for falling behind or shutting down, the client reconnects
+				return
+			}
+			// A replayed event can also arrive live
+			if _, ok := replayed[e.ID]; ok {
+				continue
+			}
+			if err := writeEvent(w, e); err != nil {
+				a.logger.Debug("user event stream closed", zap.Error(err))
+				return
+			}
+		case <-heartbeat.C:
+			if _, err := fmt.Fprint(w, ": ping\n\n"); err != nil {
+				return
+			}
+		}
+
+		if err := rc.Flush(); err != nil {
+			return
+		}
+	}
+}
+
+func writeEvent(w http.ResponseWriter, e domain.Event) error {
+	data, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", e.ID, e.Type, data)
+	return err
+}
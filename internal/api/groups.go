@@ -0,0 +1,488 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"go-project-template/internal/domain"
+	"go-project-template/internal/utils"
+	"net/http"
+	"strconv"
+
+	"github.com/google/uuid"
+)
+
+// maxGroupBatchSize caps the users or groups added or removed by one request
+const maxGroupBatchSize = 1000
+
+// Group Request model
+// @Description Fields of a group that can be set by clients
+type groupRequest struct {
+	Name string `json:"name" example:"Engineering"`
+}
+
+// Group Members Request model
+// @Description Users to add to or remove from a group
+type groupMembersRequest struct {
+	UserUUIDs []uuid.UUID `json:"user_uuids" example:"3fe82b1f-ab3d-40a1-8bd8-bccd4dd166f8"`
+}
+
+// Subgroups Request model
+// @Description Groups to add to or remove from a group
+type subgroupsRequest struct {
+	GroupIDs []uuid.UUID `json:"group_ids" example:"5b8e2d4f-7a1c-4e3b-9d6f-1c3e5a7b9d2f"`
+}
+
+// decodeIDs reads a request body into v and checks the number of ids in it
+func decodeIDs(r *http.Request, v interface{}, ids func() []uuid.UUID) error {
+	if err := json.NewDecoder(r.Body).Decode(v); err != nil {
+		return err
+	}
+	if len(ids()) > maxGroupBatchSize {
+		return fmt.Errorf("request exceeds %d ids", maxGroupBatchSize)
+	}
+	return nil
+}
+
+// nestedParam reads the nested query parameter, which defaults to false
+func nestedParam(r *http.Request) (bool, error) {
+	v := r.URL.Query().Get("nested")
+	if v == "" {
+		return false, nil
+	}
+	nested, err := strconv.ParseBool(v)
+	if err != nil {
+		return false, fmt.Errorf("nested: %w", err)
+	}
+	return nested, nil
+}
+
+// Create Group godoc
+// @Summary Create Group
+// @Description Creates an empty group
+// @Tags  Groups
+// @Accept json
+// @Produce json
+// @Param payload body groupRequest true "Group"
+// @Param Idempotency-Key header string false "unique key that makes retries of this request safe"
+// @Param X-Tenant-ID header string false "tenant of the request"
+// @Success 201 {object} domain.Group
+// @Failure 400
+// @Failure 409
+// @Failure 422
+// @Router /groups [post]
+func (a *api) createGroupHandler(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+
+	req := &groupRequest{}
+	if err := json.NewDecoder(r.Body).Decode(req); err != nil {
+		a.errorResponse(w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	g, err := a.groupRepo.Create(ctx, &domain.Group{Name: req.Name})
+	if err != nil {
+		a.errorResponse(w, r, errorStatus(err), err)
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, g)
+}
+
+// Get Group List godoc
+// @Summary Get List of Groups
+// @Description Accepts pagination based query parameters and returns a paginated response.
+// @Tags  Groups
+// @Produce json
+// @Param page query int false "page number" Format(page)
+// @Param size query int false "number of elements per page" Format(size)
+// @Param orderBy query string false "id, name or created_at" Format(orderBy)
+// @Param orderDir query string false "asc or desc" Format(orderDir)
+// @Param X-Tenant-ID header string false "tenant of the request"
+// @Success 200 {object} []domain.Group
+// @Failure 400
+// @Router /groups [get]
+func (a *api) listGroupHandler(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+
+	pagQuery, err := utils.GetPaginationFromRequest(r)
+	if err != nil {
+		a.errorResponse(w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	list, err := a.groupRepo.GetList(ctx, pagQuery)
+	if err != nil {
+		a.errorResponse(w, r, errorStatus(err), err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, list)
+}
+
+// Get Group godoc
+// @Summary Get Group
+// @Description Accepts an ID and returns the group
+// @Tags  Groups
+// @Produce json
+// @Param groupid path string true "groupid"
+// @Param X-Tenant-ID header string false "tenant of the request"
+// @Success 200 {object} domain.Group
+// @Failure 400
+// @Failure 404
+// @Router /groups/{groupid} [get]
+func (a *api) getGroupHandler(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+
+	id, err := urlUUID(r, "groupid")
+	if err != nil {
+		a.errorResponse(w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	g, err := a.groupRepo.GetByID(ctx, id)
+	if err != nil {
+		a.errorResponse(w, r, errorStatus(err), err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, g)
+}
+
+// Update Group godoc
+// @Summary Update Group
+// @Description Renames a group
+// @Tags  Groups
+// @Accept json
+// @Produce json
+// @Param groupid path string true "groupid"
+// @Param payload body groupRequest true "Group"
+// @Param X-Tenant-ID header string false "tenant of the request"
+// @Success 200 {object} domain.Group
+// @Failure 400
+// @Failure 404
+// @Failure 422
+// @Router /groups/{groupid} [put]
+func (a *api) updateGroupHandler(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+
+	id, err := urlUUID(r, "groupid")
+	if err != nil {
+		a.errorResponse(w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	req := &groupRequest{}
+	if err := json.NewDecoder(r.Body).Decode(req); err != nil {
+		a.errorResponse(w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	g, err := a.groupRepo.Update(ctx, &domain.Group{ID: id, Name: req.Name})
+	if err != nil {
+		a.errorResponse(w, r, errorStatus(err), err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, g)
+}
+
+// Delete Group godoc
+// @Summary Delete Group
+// @Description Deletes a group, its members and its place in other groups
+// @Tags  Groups
+// @Param groupid path string true "groupid"
+// @Param X-Tenant-ID header string false "tenant of the request"
+// @Success 204
+// @Failure 400
+// @Failure 404
+// @Router /groups/{groupid} [delete]
+func (a *api) deleteGroupHandler(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+
+	id, err := urlUUID(r, "groupid")
+	if err != nil {
+		a.errorResponse(w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	if err := a.groupRepo.Delete(ctx, id); err != nil {
+		a.errorResponse(w, r, errorStatus(err), err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// List Group Members godoc
+// @Summary Get List of Group Members
+// @Description Returns the users of a group ordered by UUID. With nested=true the users of its subgroups at any depth are included, every user once.
+// @Tags  Groups
+// @Produce json
+// @Param groupid path string true "groupid"
+// @Param nested query bool false "include the members of subgroups"
+// @Param page query int false "page number" Format(page)
+// @Param size query int false "number of elements per page" Format(size)
+// @Param X-Tenant-ID header string false "tenant of the request"
+// @Success 200 {object} []domain.User
+// @Failure 400
+// @Failure 404
+// @Router /groups/{groupid}/members [get]
+func (a *api) listGroupMemberHandler(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+
+	id, err := urlUUID(r, "groupid")
+	if err != nil {
+		a.errorResponse(w, r, http.StatusBadRequest, err)
+		return
+	}
+	nested, err := nestedParam(r)
+	if err != nil {
+		a.errorResponse(w, r, http.StatusBadRequest, err)
+		return
+	}
+	pagQuery, err := utils.GetPaginationFromRequest(r)
+	if err != nil {
+		a.errorResponse(w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	list, err := a.groupRepo.Members(ctx, id, nested, pagQuery)
+	if err != nil {
+		a.errorResponse(w, r, errorStatus(err), err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, list)
+}
+
+// Add Group Members godoc
+// @Summary Add Group Members
+// @Description Adds up to 1000 users to a group. Users that already are members are left as they are, nothing is added if one of the users is not found.
+// @Tags  Groups
+// @Accept json
+// @Param groupid path string true "groupid"
+// @Param payload body groupMembersRequest true "Users"
+// @Param X-Tenant-ID header string false "tenant of the request"
+// @Success 204
+// @Failure 400
+// @Failure 404
+// @Router /groups/{groupid}/members:add [post]
+func (a *api) addGroupMemberHandler(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+
+	id, err := urlUUID(r, "groupid")
+	if err != nil {
+		a.errorResponse(w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	req := &groupMembersRequest{}
+	if err := decodeIDs(r, req, func() []uuid.UUID { return req.UserUUIDs }); err != nil {
+		a.errorResponse(w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	if err := a.groupRepo.AddMembers(ctx, id, req.UserUUIDs); err != nil {
+		a.errorResponse(w, r, errorStatus(err), err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Remove Group Members godoc
+// @Summary Remove Group Members
+// @Description Removes up to 1000 users from a group, users that are no members are ignored
+// @Tags  Groups
+// @Accept json
+// @Param groupid path string true "groupid"
+// @Param payload body groupMembersRequest true "Users"
+// @Param X-Tenant-ID header string false "tenant of the request"
+// @Success 204
+// @Failure 400
+// @Failure 404
+// @Router /groups/{groupid}/members:remove [post]
+func (a *api) removeGroupMemberHandler(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+
+	id, err := urlUUID(r, "groupid")
+	if err != nil {
+		a.errorResponse(w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	req := &groupMembersRequest{}
+	if err := decodeIDs(r, req, func() []uuid.UUID { return req.UserUUIDs }); err != nil {
+		a.errorResponse(w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	if err := a.groupRepo.RemoveMembers(ctx, id, req.UserUUIDs); err != nil {
+		a.errorResponse(w, r, errorStatus(err), err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// List Subgroups godoc
+// @Summary Get List of Subgroups
+// @Description Returns the groups that are direct members of a group, ordered by name
+// @Tags  Groups
+// @Produce json
+// @Param groupid path string true "groupid"
+// @Param page query int false "page number" Format(page)
+// @Param size query int false "number of elements per page" Format(size)
+// @Param X-Tenant-ID header string false "tenant of the request"
+// @Success 200 {object} []domain.Group
+// @Failure 400
+// @Failure 404
+// @Router /groups/{groupid}/subgroups [get]
+func (a *api) listSubgroupHandler(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+
+	id, err := urlUUID(r, "groupid")
+	if err != nil {
+		a.errorResponse(w, r, http.StatusBadRequest, err)
+		return
+	}
+	pagQuery, err := utils.GetPaginationFromRequest(r)
+	if err != nil {
+		a.errorResponse(w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	list, err := a.groupRepo.Subgroups(ctx, id, pagQuery)
+	if err != nil {
+		a.errorResponse(w, r, errorStatus(err), err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, list)
+}
+
+// Add Subgroups godoc
+// @Summary Add Subgroups
+// @Description Makes up to 1000 groups members of a group. Nothing is added if a group is not found or if the group would end up containing itself.
+// @Tags  Groups
+// @Accept json
+// @Param groupid path string true "groupid"
+// @Param payload body subgroupsRequest true "Groups"
+// @Param X-Tenant-ID header string false "tenant of the request"
+// @Success 204
+// @Failure 400
+// @Failure 404
+// @Failure 409
+// @Router /groups/{groupid}/subgroups:add [post]
+func (a *api) addSubgroupHandler(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+
+	id, err := urlUUID(r, "groupid")
+	if err != nil {
+		a.errorResponse(w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	req := &subgroupsRequest{}
+	if err := decodeIDs(r, req, func() []uuid.UUID { return req.GroupIDs }); err != nil {
+		a.errorResponse(w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	if err := a.groupRepo.AddSubgroups(ctx, id, req.GroupIDs); err != nil {
+		a.errorResponse(w, r, errorStatus(err), err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Remove Subgroups godoc
+// @Summary Remove Subgroups
+// @Description Removes up to 1000 groups from a group, groups that are no members are ignored
+// @Tags  Groups
+// @Accept json
+// @Param groupid path string true "groupid"
+// @Param payload body subgroupsRequest true "Groups"
+// @Param X-Tenant-ID header string false "tenant of the request"
+// @Success 204
+// @Failure 400
+// @Failure 404
+// @Router /groups/{groupid}/subgroups:remove [post]
+func (a *api) removeSubgroupHandler(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+
+	id, err := urlUUID(r, "groupid")
+	if err != nil {
+		a.errorResponse(w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	req := &subgroupsRequest{}
+	if err := decodeIDs(r, req, func() []uuid.UUID { return req.GroupIDs }); err != nil {
+		a.errorResponse(w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	if err := a.groupRepo.RemoveSubgroups(ctx, id, req.GroupIDs); err != nil {
+		a.errorResponse(w, r, errorStatus(err), err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// List User Groups godoc
+// @Summary Get List of User Groups
+// @Description Returns the groups a user is a member of, ordered by name. With nested=true the groups containing them at any depth are included.
+// @Tags  Users
+// @Produce json
+// @Param userid path string true "userid"
+// @Param nested query bool false "include the groups containing the groups of the user"
+// @Param page query int false "page number" Format(page)
+// @Param size query int false "number of elements per page" Format(size)
+// @Param X-Tenant-ID header string false "tenant of the request"
+// @Success 200 {object} []domain.Group
+// @Failure 400
+// @Failure 404
+// @Router /users/{userid}/groups [get]
+func (a *api) listUserGroupHandler(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+
+	userID, err := urlUUID(r, "userid")
+	if err != nil {
+		a.errorResponse(w, r, http.StatusBadRequest, err)
+		return
+	}
+	nested, err := nestedParam(r)
+	if err != nil {
+		a.errorResponse(w, r, http.StatusBadRequest, err)
+		return
+	}
+	pagQuery, err := utils.GetPaginationFromRequest(r)
+	if err != nil {
+		a.errorResponse(w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	list, err := a.groupRepo.UserGroups(ctx, userID, nested, pagQuery)
+	if err != nil {
+		a.errorResponse(w, r, errorStatus(err), err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, list)
+}
@@ -0,0 +1,140 @@
+package api_test
+
+import (
+	"go-project-template/internal/domain"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// createGroup creates a group through the API
+func (h *harness) createGroup(name string) domain.Group {
+	h.t.Helper()
+	var g domain.Group
+	h.request(http.MethodPost, "/v1/groups").withJSON(map[string]string{"name": name}).expect(http.StatusCreated).decode(&g)
+	return g
+}
+
+func TestGroups_CRUD(t *testing.T) {
+	t.Parallel()
+	h := newHarness(t)
+
+	g := h.createGroup("Engineering")
+	assert.Equal(t, domain.DefaultTenant, g.TenantID)
+	h.request(http.MethodPost, "/v1/groups").withJSON(map[string]string{}).expect(http.StatusUnprocessableEntity)
+
+	var got domain.Group
+	h.request(http.MethodPut, "/v1/groups/"+g.ID.String()).withJSON(map[string]string{"name": "Platform"}).expect(http.StatusOK).decode(&got)
+	assert.Equal(t, "Platform", got.Name)
+	h.request(http.MethodGet, "/v1/groups/"+g.ID.String()).expect(http.StatusOK).decode(&got)
+	assert.Equal(t, "Platform", got.Name)
+
+	var list struct {
+		TotalCount int            `json:"total_count"`
+		Values     []domain.Group `json:"values"`
+	}
+	h.request(http.MethodGet, "/v1/groups?page=1&size=10").expect(http.StatusOK).decode(&list)
+	assert.Equal(t, 1, list.TotalCount)
+
+	h.request(http.MethodDelete, "/v1/groups/"+g.ID.String()).expect(http.StatusNoContent)
+	h.request(http.MethodGet, "/v1/groups/"+g.ID.String()).expect(http.StatusNotFound)
+	h.request(http.MethodGet, "/v1/groups/not-a-uuid").expect(http.StatusBadRequest)
+}
+
+func TestGroups_NestedMembers(t *testing.T) {
+	t.Parallel()
+	h := newHarness(t)
+	h.seed(testUser(johnUUID, "John", "Wick"), testUser(helenUUID, "Helen", "Wick"), testUser(adaUUID, "Ada", "Lovelace"))
+
+	company, engineering := h.createGroup("Company"), h.createGroup("Engineering")
+	h.request(http.MethodPost, "/v1/groups/"+company.ID.String()+"/subgroups:add").
+		withJSON(map[string]interface{}{"group_ids": []uuid.UUID{engineering.ID}}).
+		expect(http.StatusNoContent)
+	h.request(http.MethodPost, "/v1/groups/"+company.ID.String()+"/members:add").
+		withJSON(map[string]interface{}{"user_uuids": []uuid.UUID{johnUUID}}).
+		expect(http.StatusNoContent)
+	h.request(http.MethodPost, "/v1/groups/"+engineering.ID.String()+"/members:add").
+		withJSON(map[string]interface{}{"user_uuids": []uuid.UUID{helenUUID, adaUUID}}).
+		expect(http.StatusNoContent)
+
+	members := func(query string) []uuid.UUID {
+		var list struct {
+			Values []domain.User `json:"values"`
+		}
+		h.request(http.MethodGet, "/v1/groups/"+company.ID.String()+"/members?page=1&size=10"+query).expect(http.StatusOK).decode(&list)
+		ids := []uuid.UUID{}
+		for _, u := range list.Values {
+			ids = append(ids, u.UUID)
+		}
+		return ids
+	}
+	assert.Equal(t, []uuid.UUID{johnUUID}, members(""))
+	assert.Equal(t, []uuid.UUID{adaUUID, johnUUID, helenUUID}, members("&nested=true"))
+	h.request(http.MethodGet, "/v1/groups/"+company.ID.String()+"/members?nested=maybe").expect(http.StatusBadRequest)
+
+	var groups struct {
+		TotalCount int            `json:"total_count"`
+		Values     []domain.Group `json:"values"`
+	}
+	h.request(http.MethodGet, "/v1/users/"+adaUUID.String()+"/groups?page=1&size=10&nested=true").expect(http.StatusOK).decode(&groups)
+	require.Equal(t, 2, groups.TotalCount)
+	assert.Equal(t, "Company", groups.Values[0].Name)
+	assert.Equal(t, "Engineering", groups.Values[1].Name)
+
+	// Company already contains engineering, so engineering cannot contain company
+	h.request(http.MethodPost, "/v1/groups/"+engineering.ID.String()+"/subgroups:add").
+		withJSON(map[string]interface{}{"group_ids": []uuid.UUID{company.ID}}).
+		expect(http.StatusConflict).
+		hasError(domain.ErrGroupCycle.Error())
+
+	h.request(http.MethodPost, "/v1/groups/"+engineering.ID.String()+"/members:remove").
+		withJSON(map[string]interface{}{"user_uuids": []uuid.UUID{adaUUID}}).
+		expect(http.StatusNoContent)
+	assert.Equal(t, []uuid.UUID{johnUUID, helenUUID}, members("&nested=true"))
+
+	h.request(http.MethodPost, "/v1/groups/"+company.ID.String()+"/subgroups:remove").
+		withJSON(map[string]interface{}{"group_ids": []uuid.UUID{engineering.ID}}).
+		expect(http.StatusNoContent)
+	assert.Equal(t, []uuid.UUID{johnUUID}, members("&nested=true"))
+}
+
+func TestGroups_BulkErrors(t *testing.T) {
+	t.Parallel()
+	h := newHarness(t)
+	h.seed(testUser(johnUUID, "John", "Wick"))
+	g := h.createGroup("Engineering")
+
+	tooMany := make([]string, 1001)
+	for i := range tooMany {
+		tooMany[i] = `"` + uuid.NewString() + `"`
+	}
+
+	testCases := map[string]struct {
+		path   string
+		body   interface{}
+		status int
+	}{
+		"unknown user":  {"/members:add", map[string]interface{}{"user_uuids": []uuid.UUID{johnUUID, helenUUID}}, http.StatusNotFound},
+		"unknown group": {"/subgroups:add", map[string]interface{}{"group_ids": []uuid.UUID{uuid.New()}}, http.StatusNotFound},
+		"itself":        {"/subgroups:add", map[string]interface{}{"group_ids": []uuid.UUID{g.ID}}, http.StatusConflict},
+		"malformed":     {"/members:add", `{"user_uuids": ["john"]}`, http.StatusBadRequest},
+		"too many":      {"/members:remove", `{"user_uuids": [` + strings.Join(tooMany, ",") + `]}`, http.StatusBadRequest},
+	}
+
+	for scenario, tc := range testCases { //nolint:paralleltest
+		t.Run(scenario, func(t *testing.T) {
+			h.request(http.MethodPost, "/v1/groups/"+g.ID.String()+tc.path).withJSON(tc.body).expect(tc.status)
+		})
+	}
+
+	// The failed addition left the group empty
+	var list struct {
+		TotalCount int `json:"total_count"`
+	}
+	h.request(http.MethodGet, "/v1/groups/"+g.ID.String()+"/members?page=1&size=10").expect(http.StatusOK).decode(&list)
+	assert.Equal(t, 0, list.TotalCount)
+}
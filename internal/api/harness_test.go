@@ -0,0 +1,351 @@
+package api_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
+	"go-project-template/internal/api"
+	"go-project-template/internal/domain"
+	"go-project-template/internal/repository"
+	"io"
+	"mime"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files in testdata")
+
+// testTime is the time of every write, so timestamps in golden files are stable
+var testTime = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// harness serves the API over httptest, backed by in-memory repositories
+type harness struct {
+	t      *testing.T
+	server *httptest.Server
+
+	users  domain.UserRepository
+	outbox *fakeOutboxRepository
+}
+
+// newHarness builds the API from in-memory dependencies, opts replace some of them
+func newHarness(t *testing.T, opts ...func(*api.Dependencies)) *harness {
+	t.Helper()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	outbox := &fakeOutboxRepository{}
+	deps := api.Dependencies{
+		UserRepo:        repository.NewMemoryUserRepository(repository.WithClock(func() time.Time { return testTime })),
+		IdempotencyRepo: newFakeIdempotencyRepository(),
+		OutboxRepo:      outbox,
+		Listener:        fakeListener{},
+	}
+	for _, opt := range opts {
+		opt(&deps)
+	}
+	if deps.OrgRepo == nil {
+		deps.OrgRepo = repository.NewMemoryOrganizationRepository(deps.UserRepo, repository.WithOrganizationClock(func() time.Time { return testTime }))
+	}
+	if deps.GroupRepo == nil {
+		deps.GroupRepo = repository.NewMemoryGroupRepository(deps.UserRepo, repository.WithGroupClock(func() time.Time { return testTime }))
+	}
+	if deps.CredentialRepo == nil {
+		deps.CredentialRepo = repository.NewMemoryCredentialRepository(deps.UserRepo)
+	}
+
+	h := &harness{
+		t:      t,
+		users:  deps.UserRepo,
+		outbox: outbox,
+	}
+
+	a := api.New(ctx, zap.NewNop(), deps)
+	h.server = httptest.NewServer(a.Routes())
+	t.Cleanup(h.server.Close)
+
+	return h
+}
+
+// seed stores users of the default tenant directly in the repository
+func (h *harness) seed(uu ...*domain.User) {
+	h.t.Helper()
+	h.seedTenant(domain.DefaultTenant, uu...)
+}
+
+func (h *harness) seedTenant(tenant string, uu ...*domain.User) {
+	h.t.Helper()
+	ctx := domain.WithTenant(context.Background(), tenant)
+	for _, u := range uu {
+		_, err := h.users.CreateOrUpdate(ctx, u)
+		require.NoError(h.t, err)
+	}
+}
+
+func (h *harness) url(path string) string {
+	return h.server.URL + path
+}
+
+// request starts building a request against the test server
+func (h *harness) request(method string, path string) *request {
+	return &request{h: h, method: method, path: path, header: http.Header{}}
+}
+
+type request struct {
+	h      *harness
+	method string
+	path   string
+	host   string
+	header http.Header
+	body   io.Reader
+}
+
+func (r *request) withHeader(key string, value string) *request {
+	r.header.Set(key, value)
+	return r
+}
+
+// withHost sends the request to another virtual host of the test server
+func (r *request) withHost(host string) *request {
+	r.host = host
+	return r
+}
+
+// withJSON sends v encoded as JSON, strings are sent as they are so tests can send malformed bodies
+func (r *request) withJSON(v interface{}) *request {
+	r.h.t.Helper()
+
+	body, ok := v.(string)
+	if !ok {
+		b, err := json.Marshal(v)
+		require.NoError(r.h.t, err)
+		body = string(b)
+	}
+	return r.withBody("application/json", body)
+}
+
+func (r *request) withBody(contentType string, body string) *request {
+	r.header.Set("Content-Type", contentType)
+	r.body = strings.NewReader(body)
+	return r
+}
+
+// expect sends the request and asserts the response status
+func (r *request) expect(status int) *response {
+	t := r.h.t
+	t.Helper()
+
+	req, err := http.NewRequest(r.method, r.h.url(r.path), r.body)
+	require.NoError(t, err)
+	req.Header = r.header
+	if r.host != "" {
+		req.Host = r.host
+	}
+
+	res, err := r.h.server.Client().Do(req)
+	require.NoError(t, err)
+	defer res.Body.Close()
+
+	body, err := io.ReadAll(res.Body)
+	require.NoError(t, err)
+	require.Equal(t, status, res.StatusCode, "unexpected status, body: %s", body)
+
+	return &response{t: t, res: res, body: body}
+}
+
+type response struct {
+	t    *testing.T
+	res  *http.Response
+	body []byte
+}
+
+func (r *response) hasHeader(key string, want string) *response {
+	r.t.Helper()
+	assert.Equal(r.t, want, r.res.Header.Get(key), "header %s", key)
+	return r
+}
+
+// hasError asserts the error message of a plain text error response
+func (r *response) hasError(want string) *response {
+	r.t.Helper()
+	assert.Equal(r.t, want, strings.TrimSpace(string(r.body)))
+	return r
+}
+
+func (r *response) decode(v interface{}) *response {
+	r.t.Helper()
+	require.NoError(r.t, json.Unmarshal(r.body, v))
+	return r
+}
+
+// matchesGolden compares the status, content type and body with testdata/<name>.golden.
+// Run the tests with -update to rewrite the file after an intended change.
+func (r *response) matchesGolden(name string) *response {
+	r.t.Helper()
+
+	contentType := r.res.Header.Get("Content-Type")
+	body := r.body
+	if mediaType, _, _ := mime.ParseMediaType(contentType); mediaType == "application/json" || strings.HasSuffix(mediaType, "+json") {
+		var buf bytes.Buffer
+		require.NoError(r.t, json.Indent(&buf, body, "", "  "))
+		body = buf.Bytes()
+	}
+
+	var got bytes.Buffer
+	got.WriteString(r.res.Status + "\n")
+	got.WriteString("Content-Type: " + contentType + "\n\n")
+	got.Write(bytes.TrimSpace(body))
+	got.WriteString("\n")
+
+	path := filepath.Join("testdata", name+".golden")
+	if *update {
+		require.NoError(r.t, os.MkdirAll("testdata", 0o755))
+		require.NoError(r.t, os.WriteFile(path, got.Bytes(), 0o644))
+	}
+
+	want, err := os.ReadFile(path)
+	require.NoError(r.t, err, "missing golden file, run the tests with -update")
+	assert.Equal(r.t, string(want), got.String())
+	return r
+}
+
+// fakeIdempotencyRepository mirrors the Postgres key reservation rules
+type fakeIdempotencyRepository struct {
+	mu      sync.Mutex
+	records map[string]*domain.IdempotencyRecord
+}
+
+func newFakeIdempotencyRepository() *fakeIdempotencyRepository {
+	return &fakeIdempotencyRepository{records: map[string]*domain.IdempotencyRecord{}}
+}
+
+func (f *fakeIdempotencyRepository) Reserve(_ context.Context, key string, fingerprint string, lease time.Duration, ttl time.Duration) (*domain.IdempotencyRecord, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	now := time.Now()
+	rec, ok := f.records[key]
+	switch {
+	case !ok, rec.Fingerprint == fingerprint && !rec.Completed() && rec.LockedUntil.Before(now):
+		rec = &domain.IdempotencyRecord{Key: key, Fingerprint: fingerprint, CreatedAt: now, ExpiresAt: now.Add(ttl), LockedUntil: now.Add(lease)}
+		f.records[key] = rec
+	case rec.Fingerprint != fingerprint:
+		return nil, domain.ErrKeyReuse
+	case !rec.Completed():
+		return nil, domain.ErrInProgress
+	}
+
+	cp := *rec
+	return &cp, nil
+}
+
+func (f *fakeIdempotencyRepository) Complete(_ context.Context, r *domain.IdempotencyRecord) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	cp := *r
+	f.records[r.Key] = &cp
+	return nil
+}
+
+func (f *fakeIdempotencyRepository) Release(_ context.Context, r *domain.IdempotencyRecord) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if rec, ok := f.records[r.Key]; ok && !rec.Completed() && rec.LockedUntil.Equal(r.LockedUntil) {
+		delete(f.records, r.Key)
+	}
+	return nil
+}
+
+func (f *fakeIdempotencyRepository) DeleteExpired(_ context.Context) (int64, error) {
+	return 0, nil
+}
+
+// fakeOutboxRepository serves events appended by the test to the change stream
+type fakeOutboxRepository struct {
+	mu     sync.Mutex
+	events []domain.Event
+}
+
+func (f *fakeOutboxRepository) add(eventType string, aggregateID uuid.UUID, payload string) {
+	f.addTenant(domain.DefaultTenant, eventType, aggregateID, payload)
+}
+
+func (f *fakeOutboxRepository) addTenant(tenant string, eventType string, aggregateID uuid.UUID, payload string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.events = append(f.events, domain.Event{
+		ID:            int64(len(f.events) + 1),
+		AggregateType: domain.AggregateUser,
+		AggregateID:   aggregateID,
+		TenantID:      tenant,
+		Type:          eventType,
+		Payload:       json.RawMessage(payload),
+		OccurredAt:    time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+	})
+}
+
+func (f *fakeOutboxRepository) Pending(_ context.Context, _ int) ([]domain.Event, error) {
+	return nil, nil
+}
+
+func (f *fakeOutboxRepository) MarkPublished(_ context.Context, _ int64) error {
+	return nil
+}
+
+func (f *fakeOutboxRepository) MarkFailed(_ context.Context, _ int64, _ error) error {
+	return nil
+}
+
+func (f *fakeOutboxRepository) DeletePublished(_ context.Context, _ time.Time) (int64, error) {
+	return 0, nil
+}
+
+func (f *fakeOutboxRepository) After(_ context.Context, aggregateType string, afterID int64, limit int) ([]domain.Event, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	var ee []domain.Event
+	for _, e := range f.events {
+		if e.AggregateType == aggregateType && e.ID > afterID && len(ee) < limit {
+			ee = append(ee, e)
+		}
+	}
+	return ee, nil
+}
+
+func (f *fakeOutboxRepository) LastID(_ context.Context) (int64, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return int64(len(f.events)), nil
+}
+
+// Horizon reports no running transactions, every event is committed when added
+func (f *fakeOutboxRepository) Horizon(_ context.Context) (int64, int64, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	next := int64(len(f.events) + 1)
+	return next, next, nil
+}
+
+// fakeListener never notifies, the tests read events through replay
+type fakeListener struct{}
+
+func (fakeListener) Listen(ctx context.Context, _ string, _ func(string)) error {
+	<-ctx.Done()
+	return ctx.Err()
+}
@@ -1,7 +1,6 @@
 package api
 
 import (
-	"encoding/json"
 	"net/http"
 )
 
@@ -10,7 +9,5 @@ func (a *api) healthCheckHandler(w http.ResponseWriter, r *http.Request) {
 		"status": "available",
 	}
 
-	w.WriteHeader(http.StatusOK)
-	w.Header().Set("Content-Type", "application/json")
-	_ = json.NewEncoder(w).Encode(data)
+	writeJSON(w, http.StatusOK, data)
 }
@@ -0,0 +1,173 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"go-project-template/internal/domain"
+	"io"
+	"net/http"
+	"os"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const (
+	idempotencyKeyHeader       = "Idempotency-Key"
+	idempotencyReplayedHeader  = "Idempotent-Replayed"
+	idempotencyKeyMaxLength    = 255
+	defaultIdempotencyTTL      = 24 * time.Hour
+	defaultIdempotencyLease    = 2 * time.Minute
+	idempotencyCleanupInterval = 1 * time.Hour
+)
+
+// idempotencyTTL reads the IDEMPOTENCY_KEY_TTL duration from the environment
+func idempotencyTTL() time.Duration {
+	ttl, err := time.ParseDuration(os.Getenv("IDEMPOTENCY_KEY_TTL"))
+	if err != nil || ttl <= 0 {
+		return defaultIdempotencyTTL
+	}
+	return ttl
+}
+
+// idempotencyLease reads the IDEMPOTENCY_KEY_LEASE duration from the
+// environment. A request that has not completed within it, for example because
+// its process crashed, no longer blocks retries with the same key.
+func idempotencyLease() time.Duration {
+	lease, err := time.ParseDuration(os.Getenv("IDEMPOTENCY_KEY_LEASE"))
+	if err != nil || lease <= 0 {
+		return defaultIdempotencyLease
+	}
+	return lease
+}
+
+// idempotencyFingerprint hashes the parts of a request that must match for a key to be replayed
+func idempotencyFingerprint(r *http.Request, body []byte) string {
+	h := sha256.New()
+	_, _ = fmt.Fprintf(h, "%s\n%s\n", r.Method, r.URL.Path)
+	_, _ = h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+type IdempotencyResponseWriter struct {
+	w          http.ResponseWriter
+	statusCode int
+	body       bytes.Buffer
+}
+
+func (irw *IdempotencyResponseWriter) Header() http.Header {
+	return irw.w.Header()
+}
+
+func (irw *IdempotencyResponseWriter) Write(bb []byte) (int, error) {
+	if irw.statusCode == 0 {
+		irw.statusCode = http.StatusOK
+	}
+	irw.body.Write(bb)
+	return irw.w.Write(bb)
+}
+
+func (irw *IdempotencyResponseWriter) WriteHeader(statusCode int) {
+	irw.w.WriteHeader(statusCode)
+	irw.statusCode = statusCode
+}
+
+func (irw *IdempotencyResponseWriter) Unwrap() http.ResponseWriter {
+	return irw.w
+}
+
+func (a *api) idempotencyMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := r.Header.Get(idempotencyKeyHeader)
+		if key == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if len(key) > idempotencyKeyMaxLength {
+			a.errorResponse(w, r, http.StatusBadRequest, fmt.Errorf("%s must be at most %d characters", idempotencyKeyHeader, idempotencyKeyMaxLength))
+			return
+		}
+
+		// Tenants pick their keys independently, a key only replays within its tenant
+		if tenant, err := domain.TenantFromContext(r.Context()); err == nil {
+			key = tenant + "/" + key
+		}
+
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			a.errorResponse(w, r, http.StatusBadRequest, err)
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+
+		rec, err := a.idempotencyRepo.Reserve(r.Context(), key, idempotencyFingerprint(r, body), a.idempotencyLease, a.idempotencyTTL)
+		switch {
+		case errors.Is(err, domain.ErrInProgress):
+			a.errorResponse(w, r, http.StatusConflict, err)
+			return
+		case errors.Is(err, domain.ErrKeyReuse):
+			a.errorResponse(w, r, http.StatusUnprocessableEntity, err)
+			return
+		case err != nil:
+			a.errorResponse(w, r, http.StatusInternalServerError, err)
+			return
+		}
+
+		// Replay the stored response of a completed request
+		if rec.Completed() {
+			if rec.ContentType != "" {
+				w.Header().Set("Content-Type", rec.ContentType)
+			}
+			w.Header().Set(idempotencyReplayedHeader, "true")
+			w.WriteHeader(rec.StatusCode)
+			_, _ = w.Write(rec.Body)
+			return
+		}
+
+		irw := &IdempotencyResponseWriter{w: w}
+		next.ServeHTTP(irw, r)
+
+		// Persist the outcome even if the client has already gone away
+		ctx := context.WithoutCancel(r.Context())
+
+		// Server errors are not stored so the client can retry with the same key
+		if irw.statusCode == 0 || irw.statusCode >= http.StatusInternalServerError {
+			if err := a.idempotencyRepo.Release(ctx, rec); err != nil {
+				a.logger.Error("failed to release idempotency key", zap.String("key", key), zap.Error(err))
+			}
+			return
+		}
+
+		rec.StatusCode = irw.statusCode
+		rec.ContentType = irw.Header().Get("Content-Type")
+		rec.Body = irw.body.Bytes()
+		if err := a.idempotencyRepo.Complete(ctx, rec); err != nil {
+			a.logger.Error("failed to store idempotent response", zap.String("key", key), zap.Error(err))
+		}
+	})
+}
+
+// idempotencyCleanup periodically removes expired idempotency keys until ctx is done
+func (a *api) idempotencyCleanup(ctx context.Context) {
+	ticker := time.NewTicker(idempotencyCleanupInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			n, err := a.idempotencyRepo.DeleteExpired(ctx)
+			if err != nil {
+				a.logger.Error("failed to delete expired idempotency keys", zap.Error(err))
+				continue
+			}
+			a.logger.Debug("deleted expired idempotency keys", zap.Int64("count", n))
+		}
+	}
+}
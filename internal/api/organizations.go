@@ -0,0 +1,518 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"go-project-template/internal/domain"
+	"go-project-template/internal/utils"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/go-chi/chi"
+	"github.com/google/uuid"
+)
+
+const defaultInvitationTTL = 7 * 24 * time.Hour
+
+// invitationTTL reads INVITATION_TTL, how long an invitation can be accepted
+func invitationTTL() time.Duration {
+	ttl, err := time.ParseDuration(os.Getenv("INVITATION_TTL"))
+	if err != nil || ttl <= 0 {
+		return defaultInvitationTTL
+	}
+	return ttl
+}
+
+// Organization Request model
+// @Description Fields of an organization that can be set by clients
+type organizationRequest struct {
+	Name string `json:"name" example:"Acme Research"`
+	// OwnerUUID is the user that becomes the first owner, it is only read on creation
+	OwnerUUID uuid.UUID `json:"owner_uuid,omitempty" example:"3fe82b1f-ab3d-40a1-8bd8-bccd4dd166f8"`
+}
+
+// Membership Request model
+// @Description Role to give a member
+type membershipRequest struct {
+	Role domain.Role `json:"role" example:"admin"`
+}
+
+// Invitation Request model
+// @Description Email to invite and the role it joins with, member when omitted
+type invitationRequest struct {
+	Email string      `json:"email" example:"helen@mail.com"`
+	Role  domain.Role `json:"role,omitempty" example:"member"`
+}
+
+// Accept Invitation Request model
+// @Description Token of an invitation and the user accepting it
+type acceptInvitationRequest struct {
+	Token    string    `json:"token" example:"inv_7d0f..."`
+	UserUUID uuid.UUID `json:"user_uuid" example:"79f8aa8e-f7ed-4e47-b9e4-4cd5db68a297"`
+}
+
+// urlUUID parses the path parameter key as a UUID
+func urlUUID(r *http.Request, key string) (uuid.UUID, error) {
+	return uuid.Parse(chi.URLParam(r, key))
+}
+
+// Create Organization godoc
+// @Summary Create Organization
+// @Description Creates an organization owned by the given user
+// @Tags  Organizations
+// @Accept json
+// @Produce json
+// @Param payload body organizationRequest true "Organization"
+// @Param Idempotency-Key header string false "unique key that makes retries of this request safe"
+// @Param X-Tenant-ID header string false "tenant of the request"
+// @Success 201 {object} domain.Organization
+// @Failure 400
+// @Failure 404
+// @Failure 409
+// @Failure 422
+// @Router /orgs [post]
+func (a *api) createOrganizationHandler(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+
+	req := &organizationRequest{}
+	if err := json.NewDecoder(r.Body).Decode(req); err != nil {
+		a.errorResponse(w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	o, err := a.orgRepo.Create(ctx, &domain.Organization{Name: req.Name}, req.OwnerUUID)
+	if err != nil {
+		a.errorResponse(w, r, errorStatus(err), err)
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, o)
+}
+
+// Get Organization List godoc
+// @Summary Get List of Organizations
+// @Description Accepts pagination based query parameters and returns a paginated response.
+// @Tags  Organizations
+// @Produce json
+// @Param page query int false "page number" Format(page)
+// @Param size query int false "number of elements per page" Format(size)
+// @Param orderBy query string false "id, name or created_at" Format(orderBy)
+// @Param orderDir query string false "asc or desc" Format(orderDir)
+// @Param X-Tenant-ID header string false "tenant of the request"
+// @Success 200 {object} []domain.Organization
+// @Failure 400
+// @Router /orgs [get]
+func (a *api) listOrganizationHandler(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+
+	pagQuery, err := utils.GetPaginationFromRequest(r)
+	if err != nil {
+		a.errorResponse(w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	list, err := a.orgRepo.GetList(ctx, pagQuery)
+	if err != nil {
+		a.errorResponse(w, r, errorStatus(err), err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, list)
+}
+
+// Get Organization godoc
+// @Summary Get Organization
+// @Description Accepts an ID and returns the organization
+// @Tags  Organizations
+// @Produce json
+// @Param orgid path string true "orgid"
+// @Param X-Tenant-ID header string false "tenant of the request"
+// @Success 200 {object} domain.Organization
+// @Failure 400
+// @Failure 404
+// @Router /orgs/{orgid} [get]
+func (a *api) getOrganizationHandler(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+
+	id, err := urlUUID(r, "orgid")
+	if err != nil {
+		a.errorResponse(w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	o, err := a.orgRepo.GetByID(ctx, id)
+	if err != nil {
+		a.errorResponse(w, r, errorStatus(err), err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, o)
+}
+
+// Update Organization godoc
+// @Summary Update Organization
+// @Description Renames an organization
+// @Tags  Organizations
+// @Accept json
+// @Produce json
+// @Param orgid path string true "orgid"
+// @Param payload body organizationRequest true "Organization"
+// @Param X-Tenant-ID header string false "tenant of the request"
+// @Success 200 {object} domain.Organization
+// @Failure 400
+// @Failure 404
+// @Failure 422
+// @Router /orgs/{orgid} [put]
+func (a *api) updateOrganizationHandler(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+
+	id, err := urlUUID(r, "orgid")
+	if err != nil {
+		a.errorResponse(w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	req := &organizationRequest{}
+	if err := json.NewDecoder(r.Body).Decode(req); err != nil {
+		a.errorResponse(w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	o, err := a.orgRepo.Update(ctx, &domain.Organization{ID: id, Name: req.Name})
+	if err != nil {
+		a.errorResponse(w, r, errorStatus(err), err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, o)
+}
+
+// Delete Organization godoc
+// @Summary Delete Organization
+// @Description Deletes an organization together with its members and invitations
+// @Tags  Organizations
+// @Param orgid path string true "orgid"
+// @Param X-Tenant-ID header string false "tenant of the request"
+// @Success 204
+// @Failure 400
+// @Failure 404
+// @Router /orgs/{orgid} [delete]
+func (a *api) deleteOrganizationHandler(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+
+	id, err := urlUUID(r, "orgid")
+	if err != nil {
+		a.errorResponse(w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	if err := a.orgRepo.Delete(ctx, id); err != nil {
+		a.errorResponse(w, r, errorStatus(err), err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// List Members godoc
+// @Summary Get List of Members
+// @Description Returns the members of an organization with their roles, oldest first
+// @Tags  Organizations
+// @Produce json
+// @Param orgid path string true "orgid"
+// @Param page query int false "page number" Format(page)
+// @Param size query int false "number of elements per page" Format(size)
+// @Param X-Tenant-ID header string false "tenant of the request"
+// @Success 200 {object} []domain.Membership
+// @Failure 400
+// @Failure 404
+// @Router /orgs/{orgid}/members [get]
+func (a *api) listMemberHandler(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+
+	id, err := urlUUID(r, "orgid")
+	if err != nil {
+		a.errorResponse(w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	pagQuery, err := utils.GetPaginationFromRequest(r)
+	if err != nil {
+		a.errorResponse(w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	list, err := a.orgRepo.Members(ctx, id, pagQuery)
+	if err != nil {
+		a.errorResponse(w, r, errorStatus(err), err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, list)
+}
+
+// Set Member godoc
+// @Summary Add or Update Member
+// @Description Adds a user of the tenant to the organization or changes its role. An organization always keeps at least one owner.
+// @Tags  Organizations
+// @Accept json
+// @Produce json
+// @Param orgid path string true "orgid"
+// @Param userid path string true "userid"
+// @Param payload body membershipRequest true "Membership"
+// @Param X-Tenant-ID header string false "tenant of the request"
+// @Success 200 {object} domain.Membership
+// @Failure 400
+// @Failure 404
+// @Failure 409
+// @Failure 422
+// @Router /orgs/{orgid}/members/{userid} [put]
+func (a *api) setMemberHandler(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+
+	orgID, err := urlUUID(r, "orgid")
+	if err != nil {
+		a.errorResponse(w, r, http.StatusBadRequest, err)
+		return
+	}
+	userID, err := urlUUID(r, "userid")
+	if err != nil {
+		a.errorResponse(w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	req := &membershipRequest{}
+	if err := json.NewDecoder(r.Body).Decode(req); err != nil {
+		a.errorResponse(w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	m, err := a.orgRepo.SetMember(ctx, &domain.Membership{OrganizationID: orgID, UserID: userID, Role: req.Role})
+	if err != nil {
+		a.errorResponse(w, r, errorStatus(err), err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, m)
+}
+
+// Remove Member godoc
+// @Summary Remove Member
+// @Description Removes a user from the organization, the last owner cannot be removed
+// @Tags  Organizations
+// @Param orgid path string true "orgid"
+// @Param userid path string true "userid"
+// @Param X-Tenant-ID header string false "tenant of the request"
+// @Success 204
+// @Failure 400
+// @Failure 404
+// @Failure 409
+// @Router /orgs/{orgid}/members/{userid} [delete]
+func (a *api) removeMemberHandler(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+
+	orgID, err := urlUUID(r, "orgid")
+	if err != nil {
+		a.errorResponse(w, r, http.StatusBadRequest, err)
+		return
+	}
+	userID, err := urlUUID(r, "userid")
+	if err != nil {
+		a.errorResponse(w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	if err := a.orgRepo.RemoveMember(ctx, orgID, userID); err != nil {
+		a.errorResponse(w, r, errorStatus(err), err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Create Invitation godoc
+// @Summary Create Invitation
+// @Description Invites an email to join the organization. The token that accepts the invitation is sent to the invited address by email and never returned. Invitations expire after INVITATION_TTL.
+// @Tags  Organizations
+// @Accept json
+// @Produce json
+// @Param orgid path string true "orgid"
+// @Param payload body invitationRequest true "Invitation"
+// @Param Idempotency-Key header string false "unique key that makes retries of this request safe"
+// @Param X-Tenant-ID header string false "tenant of the request"
+// @Success 201 {object} domain.Invitation
+// @Failure 400
+// @Failure 404
+// @Failure 422
+// @Router /orgs/{orgid}/invitations [post]
+func (a *api) createInvitationHandler(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+
+	orgID, err := urlUUID(r, "orgid")
+	if err != nil {
+		a.errorResponse(w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	req := &invitationRequest{}
+	if err := json.NewDecoder(r.Body).Decode(req); err != nil {
+		a.errorResponse(w, r, http.StatusBadRequest, err)
+		return
+	}
+	if req.Role == "" {
+		req.Role = domain.RoleMember
+	}
+
+	inv, err := a.orgRepo.CreateInvitation(ctx, &domain.Invitation{OrganizationID: orgID, Email: req.Email, Role: req.Role}, a.invitationTTL)
+	if err != nil {
+		a.errorResponse(w, r, errorStatus(err), err)
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, inv)
+}
+
+// List Invitations godoc
+// @Summary List Invitations
+// @Description Returns the invitations of an organization that can still be accepted
+// @Tags  Organizations
+// @Produce json
+// @Param orgid path string true "orgid"
+// @Param X-Tenant-ID header string false "tenant of the request"
+// @Success 200 {object} []domain.Invitation
+// @Failure 400
+// @Failure 404
+// @Router /orgs/{orgid}/invitations [get]
+func (a *api) listInvitationHandler(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+
+	orgID, err := urlUUID(r, "orgid")
+	if err != nil {
+		a.errorResponse(w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	ii, err := a.orgRepo.Invitations(ctx, orgID)
+	if err != nil {
+		a.errorResponse(w, r, errorStatus(err), err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, ii)
+}
+
+// Revoke Invitation godoc
+// @Summary Revoke Invitation
+// @Description Deletes an invitation so its token no longer accepts it
+// @Tags  Organizations
+// @Param orgid path string true "orgid"
+// @Param invitationid path string true "invitationid"
+// @Param X-Tenant-ID header string false "tenant of the request"
+// @Success 204
+// @Failure 400
+// @Failure 404
+// @Router /orgs/{orgid}/invitations/{invitationid} [delete]
+func (a *api) revokeInvitationHandler(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+
+	orgID, err := urlUUID(r, "orgid")
+	if err != nil {
+		a.errorResponse(w, r, http.StatusBadRequest, err)
+		return
+	}
+	id, err := urlUUID(r, "invitationid")
+	if err != nil {
+		a.errorResponse(w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	if err := a.orgRepo.RevokeInvitation(ctx, orgID, id); err != nil {
+		a.errorResponse(w, r, errorStatus(err), err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Accept Invitation godoc
+// @Summary Accept Invitation
+// @Description Makes the user a member of the organization the invitation is for. The user needs the email the invitation was sent to, a user who already is a member keeps its role.
+// @Tags  Organizations
+// @Accept json
+// @Produce json
+// @Param payload body acceptInvitationRequest true "Invitation"
+// @Param X-Tenant-ID header string false "tenant of the request"
+// @Success 200 {object} domain.Membership
+// @Failure 400
+// @Failure 403
+// @Failure 404
+// @Failure 410
+// @Router /invitations:accept [post]
+func (a *api) acceptInvitationHandler(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+
+	req := &acceptInvitationRequest{}
+	if err := json.NewDecoder(r.Body).Decode(req); err != nil {
+		a.errorResponse(w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	m, err := a.orgRepo.AcceptInvitation(ctx, req.Token, req.UserUUID)
+	if err != nil {
+		a.errorResponse(w, r, errorStatus(err), err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, m)
+}
+
+// List User Organizations godoc
+// @Summary Get List of User Organizations
+// @Description Returns the organizations a user is a member of with its role in each, ordered by name
+// @Tags  Users
+// @Produce json
+// @Param userid path string true "userid"
+// @Param page query int false "page number" Format(page)
+// @Param size query int false "number of elements per page" Format(size)
+// @Param X-Tenant-ID header string false "tenant of the request"
+// @Success 200 {object} []domain.UserOrganization
+// @Failure 400
+// @Failure 404
+// @Router /users/{userid}/orgs [get]
+func (a *api) listUserOrganizationHandler(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+
+	userID, err := urlUUID(r, "userid")
+	if err != nil {
+		a.errorResponse(w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	pagQuery, err := utils.GetPaginationFromRequest(r)
+	if err != nil {
+		a.errorResponse(w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	list, err := a.orgRepo.UserOrganizations(ctx, userID, pagQuery)
+	if err != nil {
+		a.errorResponse(w, r, errorStatus(err), err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, list)
+}
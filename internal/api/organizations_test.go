@@ -0,0 +1,246 @@
+package api_test
+
+import (
+	"context"
+	"go-project-template/internal/api"
+	"go-project-template/internal/domain"
+	"go-project-template/internal/repository"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// createOrg creates an organization owned by owner through the API
+func (h *harness) createOrg(name string, owner uuid.UUID) domain.Organization {
+	h.t.Helper()
+	var o domain.Organization
+	h.request(http.MethodPost, "/v1/orgs").
+		withJSON(map[string]interface{}{"name": name, "owner_uuid": owner}).
+		expect(http.StatusCreated).
+		decode(&o)
+	return o
+}
+
+// invitationMailbox keeps the tokens the repository hands out, in place of the
+// email job that delivers them
+type invitationMailbox struct {
+	domain.OrganizationRepository
+
+	mu     sync.Mutex
+	tokens map[uuid.UUID]string
+}
+
+func (m *invitationMailbox) CreateInvitation(ctx context.Context, inv *domain.Invitation, ttl time.Duration) (*domain.Invitation, error) {
+	created, err := m.OrganizationRepository.CreateInvitation(ctx, inv, ttl)
+	if err == nil {
+		m.mu.Lock()
+		m.tokens[created.ID] = created.Token
+		m.mu.Unlock()
+	}
+	return created, err
+}
+
+func (m *invitationMailbox) token(id uuid.UUID) string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.tokens[id]
+}
+
+func TestOrganizations_CRUD(t *testing.T) {
+	t.Parallel()
+	h := newHarness(t)
+	h.seed(testUser(johnUUID, "John", "Wick"))
+
+	o := h.createOrg("Continental", johnUUID)
+	assert.Equal(t, "Continental", o.Name)
+	assert.Equal(t, domain.DefaultTenant, o.TenantID)
+
+	var got domain.Organization
+	h.request(http.MethodGet, "/v1/orgs/"+o.ID.String()).expect(http.StatusOK).decode(&got)
+	assert.Equal(t, o, got)
+
+	h.request(http.MethodPut, "/v1/orgs/"+o.ID.String()).withJSON(map[string]string{"name": "High Table"}).expect(http.StatusOK).decode(&got)
+	assert.Equal(t, "High Table", got.Name)
+
+	var list struct {
+		TotalCount int                   `json:"total_count"`
+		Values     []domain.Organization `json:"values"`
+	}
+	h.request(http.MethodGet, "/v1/orgs?page=1&size=10").expect(http.StatusOK).decode(&list)
+	require.Equal(t, 1, list.TotalCount)
+	assert.Equal(t, "High Table", list.Values[0].Name)
+
+	h.request(http.MethodDelete, "/v1/orgs/"+o.ID.String()).expect(http.StatusNoContent)
+	h.request(http.MethodGet, "/v1/orgs/"+o.ID.String()).expect(http.StatusNotFound)
+}
+
+func TestOrganizations_CreateErrors(t *testing.T) {
+	t.Parallel()
+	h := newHarness(t)
+	h.seed(testUser(johnUUID, "John", "Wick"))
+
+	testCases := map[string]struct {
+		body   interface{}
+		status int
+	}{
+		"missing name":  {map[string]interface{}{"owner_uuid": johnUUID}, http.StatusUnprocessableEntity},
+		"unknown owner": {map[string]interface{}{"name": "Continental", "owner_uuid": helenUUID}, http.StatusNotFound},
+		"malformed":     {`{"name": `, http.StatusBadRequest},
+	}
+
+	for scenario, tc := range testCases { //nolint:paralleltest
+		t.Run(scenario, func(t *testing.T) {
+			h.request(http.MethodPost, "/v1/orgs").withJSON(tc.body).expect(tc.status)
+		})
+	}
+
+	h.request(http.MethodGet, "/v1/orgs/not-a-uuid").expect(http.StatusBadRequest)
+}
+
+func TestOrganizations_Members(t *testing.T) {
+	t.Parallel()
+	h := newHarness(t)
+	h.seed(testUser(johnUUID, "John", "Wick"), testUser(helenUUID, "Helen", "Wick"))
+	o := h.createOrg("Continental", johnUUID)
+	members := "/v1/orgs/" + o.ID.String() + "/members/"
+
+	var m domain.Membership
+	h.request(http.MethodPut, members+helenUUID.String()).withJSON(map[string]string{"role": "admin"}).expect(http.StatusOK).decode(&m)
+	assert.Equal(t, domain.RoleAdmin, m.Role)
+	assert.Equal(t, helenUUID, m.UserID)
+
+	h.request(http.MethodPut, members+helenUUID.String()).withJSON(map[string]string{"role": "janitor"}).expect(http.StatusUnprocessableEntity)
+	h.request(http.MethodPut, members+adaUUID.String()).withJSON(map[string]string{"role": "member"}).expect(http.StatusNotFound)
+
+	// The only owner can neither be demoted nor removed
+	h.request(http.MethodPut, members+johnUUID.String()).withJSON(map[string]string{"role": "member"}).expect(http.StatusConflict)
+	h.request(http.MethodDelete, members+johnUUID.String()).expect(http.StatusConflict)
+
+	// Once there is a second owner the first one can leave
+	h.request(http.MethodPut, members+helenUUID.String()).withJSON(map[string]string{"role": "owner"}).expect(http.StatusOK)
+	h.request(http.MethodDelete, members+johnUUID.String()).expect(http.StatusNoContent)
+
+	var list struct {
+		TotalCount int                 `json:"total_count"`
+		Values     []domain.Membership `json:"values"`
+	}
+	h.request(http.MethodGet, "/v1/orgs/"+o.ID.String()+"/members?page=1&size=10").expect(http.StatusOK).decode(&list)
+	require.Equal(t, 1, list.TotalCount)
+	assert.Equal(t, helenUUID, list.Values[0].UserID)
+	assert.Equal(t, domain.RoleOwner, list.Values[0].Role)
+}
+
+func TestOrganizations_UserOrganizations(t *testing.T) {
+	t.Parallel()
+	h := newHarness(t)
+	h.seed(testUser(johnUUID, "John", "Wick"), testUser(helenUUID, "Helen", "Wick"))
+	continental := h.createOrg("Continental", johnUUID)
+	h.createOrg("Bowery", helenUUID)
+	h.request(http.MethodPut, "/v1/orgs/"+continental.ID.String()+"/members/"+helenUUID.String()).withJSON(map[string]string{"role": "member"}).expect(http.StatusOK)
+
+	var list struct {
+		TotalCount int                       `json:"total_count"`
+		Values     []domain.UserOrganization `json:"values"`
+	}
+	h.request(http.MethodGet, "/v1/users/"+helenUUID.String()+"/orgs?page=1&size=10").expect(http.StatusOK).decode(&list)
+	require.Equal(t, 2, list.TotalCount)
+	assert.Equal(t, "Bowery", list.Values[0].Name)
+	assert.Equal(t, domain.RoleOwner, list.Values[0].Role)
+	assert.Equal(t, "Continental", list.Values[1].Name)
+	assert.Equal(t, domain.RoleMember, list.Values[1].Role)
+}
+
+func TestOrganizations_Invitations(t *testing.T) {
+	t.Parallel()
+
+	var mu sync.Mutex
+	now := testTime
+	clock := func() time.Time {
+		mu.Lock()
+		defer mu.Unlock()
+		return now
+	}
+	mailbox := &invitationMailbox{tokens: map[uuid.UUID]string{}}
+	h := newHarness(t, func(deps *api.Dependencies) {
+		mailbox.OrganizationRepository = repository.NewMemoryOrganizationRepository(deps.UserRepo, repository.WithOrganizationClock(clock))
+		deps.OrgRepo = mailbox
+	})
+	h.seed(testUser(johnUUID, "John", "Wick"), testUser(helenUUID, "Helen", "Wick"), testUser(adaUUID, "Ada", "Lovelace"))
+	o := h.createOrg("Continental", johnUUID)
+	invitations := "/v1/orgs/" + o.ID.String() + "/invitations"
+
+	invite := func(email string) domain.Invitation {
+		var inv domain.Invitation
+		res := h.request(http.MethodPost, invitations).withJSON(map[string]string{"email": email}).expect(http.StatusCreated).decode(&inv)
+		// The token only goes to the invited address, never into the response
+		assert.NotContains(t, string(res.body), "token")
+		inv.Token = mailbox.token(inv.ID)
+		return inv
+	}
+	accept := func(token string, user uuid.UUID, status int) *response {
+		return h.request(http.MethodPost, "/v1/invitations:accept").
+			withJSON(map[string]interface{}{"token": token, "user_uuid": user}).
+			expect(status)
+	}
+
+	inv := invite("helen@mail.com")
+	require.NotEmpty(t, inv.Token)
+	assert.Equal(t, domain.RoleMember, inv.Role)
+	assert.Equal(t, testTime.Add(7*24*time.Hour), inv.ExpiresAt)
+
+	var list []domain.Invitation
+	h.request(http.MethodGet, invitations).expect(http.StatusOK).decode(&list)
+	require.Len(t, list, 1)
+	assert.NotContains(t, string(h.request(http.MethodGet, invitations).expect(http.StatusOK).body), "token")
+
+	h.request(http.MethodPost, invitations).withJSON(map[string]string{"email": "not-an-email"}).expect(http.StatusUnprocessableEntity)
+
+	accept("inv_unknown", helenUUID, http.StatusNotFound)
+	accept(inv.Token, adaUUID, http.StatusForbidden)
+
+	var m domain.Membership
+	accept(inv.Token, helenUUID, http.StatusOK).decode(&m)
+	assert.Equal(t, o.ID, m.OrganizationID)
+	assert.Equal(t, domain.RoleMember, m.Role)
+	accept(inv.Token, helenUUID, http.StatusGone)
+
+	expired := invite("ada@mail.com")
+	mu.Lock()
+	now = now.Add(7*24*time.Hour + time.Second)
+	mu.Unlock()
+	accept(expired.Token, adaUUID, http.StatusGone)
+
+	revoked := invite("ada@mail.com")
+	h.request(http.MethodDelete, invitations+"/"+revoked.ID.String()).expect(http.StatusNoContent)
+	accept(revoked.Token, adaUUID, http.StatusNotFound)
+}
+
+func TestOrganizations_Tenancy(t *testing.T) {
+	t.Parallel()
+	h := newHarness(t)
+	h.seedTenant("acme", testUser(johnUUID, "John", "Wick"))
+	h.seedTenant("globex", testUser(helenUUID, "Helen", "Wick"))
+
+	var o domain.Organization
+	h.request(http.MethodPost, "/v1/orgs").
+		withHeader("X-Tenant-ID", "acme").
+		withJSON(map[string]interface{}{"name": "Continental", "owner_uuid": johnUUID}).
+		expect(http.StatusCreated).
+		decode(&o)
+	assert.Equal(t, "acme", o.TenantID)
+
+	as := func(method string, path string) *request {
+		return h.request(method, path).withHeader("X-Tenant-ID", "globex")
+	}
+	as(http.MethodGet, "/v1/orgs/"+o.ID.String()).expect(http.StatusNotFound)
+	as(http.MethodDelete, "/v1/orgs/"+o.ID.String()).expect(http.StatusNotFound)
+	as(http.MethodPut, "/v1/orgs/"+o.ID.String()+"/members/"+helenUUID.String()).withJSON(map[string]string{"role": "owner"}).expect(http.StatusNotFound)
+
+	// A user of another tenant cannot own an organization either
+	as(http.MethodPost, "/v1/orgs").withJSON(map[string]interface{}{"name": "Bowery", "owner_uuid": johnUUID}).expect(http.StatusNotFound)
+}
@@ -0,0 +1,84 @@
+package api
+
+import (
+	"go-project-template/internal/repository"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+)
+
+// readPrimaryCookie carries the end of a client's sticky window between requests
+const readPrimaryCookie = "read_primary_until"
+
+// routerConfig reads the DATABASE_REPLICA_MAX_LAG and DATABASE_REPLICA_STICKY durations from the environment
+func routerConfig() repository.RouterConfig {
+	cfg := repository.DefaultRouterConfig()
+	if lag, err := time.ParseDuration(os.Getenv("DATABASE_REPLICA_MAX_LAG")); err == nil && lag > 0 {
+		cfg.MaxLag = lag
+	}
+	if sticky, err := time.ParseDuration(os.Getenv("DATABASE_REPLICA_STICKY")); err == nil && sticky > 0 {
+		cfg.Sticky = sticky
+	}
+	return cfg
+}
+
+// readYourWritesMiddleware gives every request a database session, so reads that
+// follow a write of the same client go to the primary while replicas catch up.
+// The session outlives the request in a cookie.
+func (a *api) readYourWritesMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var until time.Time
+		if c, err := r.Cookie(readPrimaryCookie); err == nil {
+			if ms, err := strconv.ParseInt(c.Value, 10, 64); err == nil {
+				until = time.UnixMilli(ms)
+			}
+		}
+
+		session := repository.NewSession(until)
+		srw := &sessionResponseWriter{w: w, session: session, until: until}
+		next.ServeHTTP(srw, r.WithContext(repository.WithSession(r.Context(), session)))
+	})
+}
+
+// sessionResponseWriter sets the session cookie when the request wrote, which
+// is known by the time the handler writes its response
+type sessionResponseWriter struct {
+	w           http.ResponseWriter
+	session     *repository.Session
+	until       time.Time
+	wroteHeader bool
+}
+
+func (srw *sessionResponseWriter) Header() http.Header {
+	return srw.w.Header()
+}
+
+func (srw *sessionResponseWriter) Write(bb []byte) (int, error) {
+	if !srw.wroteHeader {
+		srw.WriteHeader(http.StatusOK)
+	}
+	return srw.w.Write(bb)
+}
+
+func (srw *sessionResponseWriter) WriteHeader(statusCode int) {
+	if !srw.wroteHeader {
+		srw.wroteHeader = true
+		if until := srw.session.PrimaryUntil(); until.After(srw.until) {
+			http.SetCookie(srw.w, &http.Cookie{
+				Name:     readPrimaryCookie,
+				Value:    strconv.FormatInt(until.UnixMilli(), 10),
+				Path:     "/",
+				Expires:  until,
+				HttpOnly: true,
+				SameSite: http.SameSiteLaxMode,
+			})
+		}
+	}
+	srw.w.WriteHeader(statusCode)
+}
+
+// Unwrap exposes the underlying writer to [http.ResponseController] so handlers can flush
+func (srw *sessionResponseWriter) Unwrap() http.ResponseWriter {
+	return srw.w
+}
@@ -0,0 +1,209 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"go-project-template/internal/domain"
+	"net"
+	"net/http"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const tenantHeader = "X-Tenant-ID"
+
+// tenantConfig tells the tenant middleware where a request names its tenant
+type tenantConfig struct {
+	// jwtSecret verifies the HS256 bearer tokens whose tenant_id claim names the
+	// tenant, bearer tokens are not looked at without it
+	jwtSecret []byte
+	// baseDomain is the domain whose subdomains name tenants, acme.api.example.com
+	// is the tenant acme of api.example.com
+	baseDomain string
+	// fallback is the tenant of requests that name none, when empty they are rejected
+	fallback string
+}
+
+// tenantConfigFromEnv reads JWT_SECRET, TENANT_BASE_DOMAIN and DEFAULT_TENANT from
+// the environment. DEFAULT_TENANT defaults to "default", set it empty to require
+// every request to name its tenant.
+func tenantConfigFromEnv() tenantConfig {
+	cfg := tenantConfig{
+		jwtSecret:  []byte(os.Getenv("JWT_SECRET")),
+		baseDomain: strings.ToLower(strings.Trim(os.Getenv("TENANT_BASE_DOMAIN"), ".")),
+		fallback:   domain.DefaultTenant,
+	}
+	if fallback, ok := os.LookupEnv("DEFAULT_TENANT"); ok {
+		cfg.fallback = fallback
+	}
+	return cfg
+}
+
+// tenantClaims are the claims of a bearer token that names a tenant
+type tenantClaims struct {
+	jwt.RegisteredClaims
+	TenantID string `json:"tenant_id"`
+	// TokenUse is refreshTokenUse for refresh tokens and empty for access tokens
+	TokenUse string `json:"token_use,omitempty"`
+	// Scope lists space separated permissions beyond those of the subject itself
+	Scope string `json:"scope,omitempty"`
+}
+
+// adminScope lets a token act on every user of its tenant
+const adminScope = "admin"
+
+// hasScope reports whether the token was granted scope
+func (c *tenantClaims) hasScope(scope string) bool {
+	return slices.Contains(strings.Fields(c.Scope), scope)
+}
+
+type claimsKey struct{}
+
+// claimsFromContext returns the verified token of the request, which is nil
+// without a token or a JWT_SECRET
+func claimsFromContext(ctx context.Context) *tenantClaims {
+	claims, _ := ctx.Value(claimsKey{}).(*tenantClaims)
+	return claims
+}
+
+// tenantMiddleware scopes the repository calls of the request to its tenant.
+// With a JWT_SECRET the request needs a verified token, which alone names the
+// tenant, an X-Tenant-ID header or subdomain naming another one is refused.
+// Without it the header is trusted over the subdomain, which is the mode for
+// local development.
+func (a *api) tenantMiddleware(next http.Handler) http.Handler {
+	return a.scopeTenant(next, false)
+}
+
+// signInTenantMiddleware scopes the sign-in routes, whose clients have no token
+// yet and may name the tenant with the header or subdomain. Their credentials
+// are checked against that tenant, so naming another one gains nothing.
+func (a *api) signInTenantMiddleware(next http.Handler) http.Handler {
+	return a.scopeTenant(next, true)
+}
+
+func (a *api) scopeTenant(next http.Handler, signIn bool) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		claims, tenant, status, err := a.resolveTenant(r, signIn)
+		if err != nil {
+			a.errorResponse(w, r, status, err)
+			return
+		}
+		ctx := domain.WithTenant(r.Context(), tenant)
+		if claims != nil {
+			ctx = context.WithValue(ctx, claimsKey{}, claims)
+		}
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// resolveTenant returns the verified token and the tenant of r, or the status
+// and error to reject it with
+func (a *api) resolveTenant(r *http.Request, signIn bool) (*tenantClaims, string, int, error) {
+	claims, err := a.tenants.verifyToken(r)
+	if err != nil {
+		return nil, "", http.StatusUnauthorized, err
+	}
+
+	header := strings.TrimSpace(r.Header.Get(tenantHeader))
+	subdomain := a.tenants.subdomainTenant(r)
+	if claims == nil && a.tenants.authenticated() && !signIn {
+		return nil, "", http.StatusUnauthorized, errBearerRequired
+	}
+
+	fromToken := ""
+	if claims != nil {
+		// Tokens without a tenant_id claim belong to the fallback tenant, never
+		// to the one a header names
+		if fromToken = claims.TenantID; fromToken == "" {
+			fromToken = a.tenants.fallback
+		}
+		if fromToken == "" {
+			return nil, "", http.StatusUnauthorized, errors.New("bearer token names no tenant")
+		}
+	}
+
+	sources := []struct {
+		name   string
+		tenant string
+	}{
+		{"token", fromToken},
+		{tenantHeader + " header", header},
+		{"subdomain", subdomain},
+	}
+
+	tenant, namedBy := "", ""
+	for _, src := range sources {
+		switch {
+		case src.tenant == "":
+		case tenant == "":
+			if err := domain.ValidateTenantID(src.tenant); err != nil {
+				return nil, "", http.StatusBadRequest, fmt.Errorf("%s: %w", src.name, err)
+			}
+			tenant, namedBy = src.tenant, src.name
+		case src.tenant != tenant:
+			err := fmt.Errorf("%s names another tenant than the %s", src.name, namedBy)
+			if fromToken != "" {
+				return nil, "", http.StatusForbidden, err
+			}
+			return nil, "", http.StatusBadRequest, err
+		}
+	}
+
+	if tenant == "" {
+		tenant = a.tenants.fallback
+	}
+	if tenant == "" {
+		return nil, "", http.StatusBadRequest, domain.ErrTenantRequired
+	}
+	return claims, tenant, 0, nil
+}
+
+// errBearerRequired rejects requests without a token once JWT_SECRET is set
+var errBearerRequired = errors.New("bearer token required")
+
+// authenticated reports whether requests have to carry a verified token
+func (c tenantConfig) authenticated() bool {
+	return len(c.jwtSecret) > 0
+}
+
+// verifyToken returns the claims of the bearer token, which are nil without a
+// token or a JWT_SECRET to verify it with
+func (c tenantConfig) verifyToken(r *http.Request) (*tenantClaims, error) {
+	raw, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok || !c.authenticated() {
+		return nil, nil
+	}
+
+	var claims tenantClaims
+	_, err := jwt.ParseWithClaims(strings.TrimSpace(raw), &claims, func(*jwt.Token) (interface{}, error) {
+		return c.jwtSecret, nil
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}), jwt.WithExpirationRequired())
+	if err != nil || claims.TokenUse == refreshTokenUse {
+		return nil, errors.New("invalid bearer token")
+	}
+	return &claims, nil
+}
+
+// subdomainTenant returns the label in front of the base domain, hosts that are
+// not a direct subdomain of it name no tenant
+func (c tenantConfig) subdomainTenant(r *http.Request) string {
+	if c.baseDomain == "" {
+		return ""
+	}
+
+	host := strings.ToLower(r.Host)
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+
+	label, ok := strings.CutSuffix(host, "."+c.baseDomain)
+	if !ok || strings.Contains(label, ".") {
+		return ""
+	}
+	return label
+}
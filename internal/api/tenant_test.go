@@ -0,0 +1,211 @@
+package api_test
+
+import (
+	"bufio"
+	"context"
+	"go-project-template/internal/domain"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testJWTSecret = "test-secret"
+
+// bearer returns an Authorization header value for a token naming tenant
+func bearer(t *testing.T, secret string, claims jwt.MapClaims) string {
+	t.Helper()
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(secret))
+	require.NoError(t, err)
+	return "Bearer " + token
+}
+
+func TestUsers_Tenancy(t *testing.T) {
+	t.Parallel()
+	h := newHarness(t)
+	h.seedTenant("acme", testUser(johnUUID, "John", "Wick"))
+	h.seedTenant("globex", testUser(helenUUID, "Helen", "Wick"))
+
+	as := func(tenant string, method string, path string) *request {
+		return h.request(method, path).withHeader("X-Tenant-ID", tenant)
+	}
+
+	as("acme", http.MethodGet, "/v1/users/"+johnUUID.String()).expect(http.StatusOK)
+	as("globex", http.MethodGet, "/v1/users/"+johnUUID.String()).expect(http.StatusNotFound)
+
+	var list struct {
+		TotalCount int           `json:"total_count"`
+		Values     []domain.User `json:"values"`
+	}
+	as("globex", http.MethodGet, "/v1/users").expect(http.StatusOK).decode(&list)
+	require.Equal(t, 1, list.TotalCount)
+	assert.Equal(t, helenUUID, list.Values[0].UUID)
+
+	export := as("globex", http.MethodGet, "/v1/users:export").expect(http.StatusOK)
+	assert.NotContains(t, string(export.body), johnUUID.String())
+
+	// The user of another tenant can neither be overwritten nor deleted
+	as("globex", http.MethodPost, "/v1/users").withJSON(testUser(johnUUID, "Mallory", "Wick")).expect(http.StatusConflict)
+	as("globex", http.MethodPost, "/v1/users:batch?mode=partial").withJSON([]*domain.User{testUser(johnUUID, "Mallory", "Wick")}).expect(http.StatusMultiStatus)
+	as("globex", http.MethodDelete, "/v1/users/"+johnUUID.String()).expect(http.StatusOK)
+
+	var got domain.User
+	as("acme", http.MethodGet, "/v1/users/"+johnUUID.String()).expect(http.StatusOK).decode(&got)
+	assert.Equal(t, "john", got.FirstName)
+	assert.Equal(t, "acme", got.TenantID)
+
+	// A tenant cannot pick another tenant in the body either
+	body := `{"uuid": "0b6b3c8e-54a4-4f0c-9a3e-6c1f0f2b7d11", "tenant_id": "acme", "first_name": "Ada", "last_name": "Lovelace", "email": "ada@mail.com"}`
+	as("globex", http.MethodPost, "/v1/users").withJSON(body).expect(http.StatusCreated).decode(&got)
+	assert.Equal(t, "globex", got.TenantID)
+	as("acme", http.MethodGet, "/v1/users/"+adaUUID.String()).expect(http.StatusNotFound)
+}
+
+func TestUsers_TenantIdempotencyKeys(t *testing.T) {
+	t.Parallel()
+	h := newHarness(t)
+
+	// The same key of two tenants names two requests, not a reuse of the key
+	for tenant, u := range map[string]*domain.User{
+		"acme":   testUser(johnUUID, "John", "Wick"),
+		"globex": testUser(helenUUID, "Helen", "Wick"),
+	} {
+		h.request(http.MethodPost, "/v1/users").
+			withHeader("X-Tenant-ID", tenant).
+			withHeader("Idempotency-Key", "create-user").
+			withJSON(u).
+			expect(http.StatusCreated).
+			hasHeader("Idempotent-Replayed", "")
+	}
+}
+
+func TestUsers_TenantEvents(t *testing.T) {
+	t.Parallel()
+	h := newHarness(t)
+	h.outbox.addTenant("acme", domain.EventUserCreated, johnUUID, `{"uuid": "3fe82b1f-ab3d-40a1-8bd8-bccd4dd166f8"}`)
+	h.outbox.addTenant("globex", domain.EventUserCreated, helenUUID, `{"uuid": "79f8aa8e-f7ed-4e47-b9e4-4cd5db68a297"}`)
+	h.outbox.addTenant("acme", domain.EventUserDeleted, johnUUID, `{"uuid": "3fe82b1f-ab3d-40a1-8bd8-bccd4dd166f8"}`)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, h.url("/v1/users/events"), nil)
+	require.NoError(t, err)
+	req.Header.Set("X-Tenant-ID", "acme")
+	req.Header.Set("Last-Event-ID", "1")
+
+	res, err := h.server.Client().Do(req)
+	require.NoError(t, err)
+	defer res.Body.Close()
+	require.Equal(t, http.StatusOK, res.StatusCode)
+
+	// Resuming after the first event skips the event of the other tenant
+	var id string
+	scanner := bufio.NewScanner(res.Body)
+	for scanner.Scan() {
+		var ok bool
+		if id, ok = strings.CutPrefix(scanner.Text(), "id: "); ok {
+			break
+		}
+	}
+	assert.Equal(t, "3", id)
+}
+
+func TestUsers_TenantResolution(t *testing.T) { //nolint:paralleltest
+	t.Setenv("JWT_SECRET", testJWTSecret)
+	t.Setenv("TENANT_BASE_DOMAIN", "api.example.com")
+	h := newHarness(t)
+	h.seedTenant("acme", testUser(johnUUID, "John", "Wick"))
+
+	exp := time.Now().Add(time.Hour).Unix()
+	acmeToken := bearer(t, testJWTSecret, jwt.MapClaims{"tenant_id": "acme", "exp": exp})
+
+	// With a secret only the token names the tenant
+	testCases := map[string]struct {
+		header map[string]string
+		host   string
+		status int
+	}{
+		"no token":             {nil, "", http.StatusUnauthorized},
+		"header":               {map[string]string{"X-Tenant-ID": "acme"}, "", http.StatusUnauthorized},
+		"subdomain":            {nil, "acme.api.example.com", http.StatusUnauthorized},
+		"token":                {map[string]string{"Authorization": acmeToken}, "", http.StatusOK},
+		"token and header":     {map[string]string{"Authorization": acmeToken, "X-Tenant-ID": "acme"}, "", http.StatusOK},
+		"token and subdomain":  {map[string]string{"Authorization": acmeToken}, "acme.api.example.com", http.StatusOK},
+		"header against token": {map[string]string{"Authorization": acmeToken, "X-Tenant-ID": "globex"}, "", http.StatusForbidden},
+		"subdomain against token": {
+			map[string]string{"Authorization": acmeToken}, "globex.api.example.com", http.StatusForbidden,
+		},
+		"token without tenant": {
+			map[string]string{"Authorization": bearer(t, testJWTSecret, jwt.MapClaims{"exp": exp}), "X-Tenant-ID": "acme"}, "", http.StatusForbidden,
+		},
+		"forged token": {
+			map[string]string{"Authorization": bearer(t, "other-secret", jwt.MapClaims{"tenant_id": "acme", "exp": exp})}, "", http.StatusUnauthorized,
+		},
+		"expired token": {
+			map[string]string{"Authorization": bearer(t, testJWTSecret, jwt.MapClaims{"tenant_id": "acme", "exp": time.Now().Add(-time.Minute).Unix()})}, "", http.StatusUnauthorized,
+		},
+		"token without expiry": {
+			map[string]string{"Authorization": bearer(t, testJWTSecret, jwt.MapClaims{"tenant_id": "acme"})}, "", http.StatusUnauthorized,
+		},
+	}
+
+	for scenario, tc := range testCases { //nolint:paralleltest
+		t.Run(scenario, func(t *testing.T) {
+			req := h.request(http.MethodGet, "/v1/users/"+johnUUID.String()).withHost(tc.host)
+			for key, value := range tc.header {
+				req.withHeader(key, value)
+			}
+			req.expect(tc.status)
+		})
+	}
+}
+
+func TestUsers_TenantResolutionWithoutSecret(t *testing.T) { //nolint:paralleltest
+	t.Setenv("JWT_SECRET", "")
+	t.Setenv("TENANT_BASE_DOMAIN", "api.example.com")
+	h := newHarness(t)
+	h.seedTenant("acme", testUser(johnUUID, "John", "Wick"))
+
+	testCases := map[string]struct {
+		header map[string]string
+		host   string
+		status int
+	}{
+		"default tenant":           {nil, "", http.StatusNotFound},
+		"header":                   {map[string]string{"X-Tenant-ID": "acme"}, "", http.StatusOK},
+		"header of another":        {map[string]string{"X-Tenant-ID": "globex"}, "", http.StatusNotFound},
+		"invalid header":           {map[string]string{"X-Tenant-ID": "Acme!"}, "", http.StatusBadRequest},
+		"subdomain":                {nil, "acme.api.example.com", http.StatusOK},
+		"subdomain with port":      {nil, "acme.api.example.com:8080", http.StatusOK},
+		"nested subdomain":         {nil, "www.acme.api.example.com", http.StatusNotFound},
+		"header against subdomain": {map[string]string{"X-Tenant-ID": "acme"}, "globex.api.example.com", http.StatusBadRequest},
+		// Tokens are not verified without a secret, so they name no tenant
+		"token": {map[string]string{"Authorization": "Bearer token", "X-Tenant-ID": "acme"}, "", http.StatusOK},
+	}
+
+	for scenario, tc := range testCases { //nolint:paralleltest
+		t.Run(scenario, func(t *testing.T) {
+			req := h.request(http.MethodGet, "/v1/users/"+johnUUID.String()).withHost(tc.host)
+			for key, value := range tc.header {
+				req.withHeader(key, value)
+			}
+			req.expect(tc.status)
+		})
+	}
+}
+
+func TestUsers_TenantRequired(t *testing.T) { //nolint:paralleltest
+	t.Setenv("DEFAULT_TENANT", "")
+	h := newHarness(t)
+
+	h.request(http.MethodGet, "/v1/users").expect(http.StatusBadRequest).hasError(domain.ErrTenantRequired.Error())
+	h.request(http.MethodGet, "/v1/users").withHeader("X-Tenant-ID", "acme").expect(http.StatusOK)
+	h.request(http.MethodGet, "/v1/webhooks").expect(http.StatusBadRequest).hasError(domain.ErrTenantRequired.Error())
+	// Health checks are not scoped to a tenant
+	h.request(http.MethodGet, "/v1/health").expect(http.StatusOK)
+}
@@ -0,0 +1,52 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"time"
+)
+
+// routeTimeouts are the deadlines of the user routes. Repositories run their
+// statements within what is left, zero means no deadline.
+type routeTimeouts struct {
+	read  time.Duration
+	list  time.Duration
+	write time.Duration
+	batch time.Duration
+}
+
+// routeTimeoutsFromEnv reads USER_READ_TIMEOUT, USER_LIST_TIMEOUT, USER_WRITE_TIMEOUT and USER_BATCH_TIMEOUT
+func routeTimeoutsFromEnv() routeTimeouts {
+	return routeTimeouts{
+		read:  envDuration("USER_READ_TIMEOUT", 2*time.Second),
+		list:  envDuration("USER_LIST_TIMEOUT", 5*time.Second),
+		write: envDuration("USER_WRITE_TIMEOUT", 5*time.Second),
+		batch: envDuration("USER_BATCH_TIMEOUT", 1*time.Minute),
+	}
+}
+
+// envDuration reads a duration from the environment, "0" turns the deadline off
+func envDuration(key string, def time.Duration) time.Duration {
+	d, err := time.ParseDuration(os.Getenv(key))
+	if err != nil || d < 0 {
+		return def
+	}
+	return d
+}
+
+// timeoutMiddleware gives the request a deadline of timeout. Handlers keep
+// running past it, but every database call they make fails once it is reached.
+func (a *api) timeoutMiddleware(timeout time.Duration) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		if timeout <= 0 {
+			return next
+		}
+
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx, cancel := context.WithTimeout(r.Context(), timeout)
+			defer cancel()
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
@@ -3,12 +3,24 @@ package api
 import (
 	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"go-project-template/internal/domain"
+	"go-project-template/internal/userio"
 	"go-project-template/internal/utils"
+	"io"
+	"mime"
 	"net/http"
+	"time"
 
-	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi"
 	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+const (
+	maxBatchSize  = 10000
+	maxBatchBytes = 32 << 20
 )
 
 // Upsert godoc
@@ -18,7 +30,14 @@ import (
 // @Accept json
 // @Produce json
 // @Param payload body domain.User true "User"
-// @Success 200
+// @Param Idempotency-Key header string false "unique key that makes retries of this request safe"
+// @Param X-Tenant-ID header string false "tenant of the request"
+// @Success 201 {object} domain.User
+// @Failure 400
+// @Failure 409
+// @Failure 422
+// @Failure 503 {object} problem
+// @Failure 504 {object} problem
 // @Router /users [post]
 func (a *api) upsertUserHandler(w http.ResponseWriter, r *http.Request) {
 	ctx, cancel := context.WithCancel(r.Context())
@@ -26,18 +45,17 @@ func (a *api) upsertUserHandler(w http.ResponseWriter, r *http.Request) {
 
 	u := &domain.User{}
 	if err := json.NewDecoder(r.Body).Decode(u); err != nil {
-		a.errorResponse(w, r, http.StatusInternalServerError, err)
+		a.errorResponse(w, r, http.StatusBadRequest, err)
 		return
 	}
 
 	u, err := a.userRepo.CreateOrUpdate(ctx, u)
 	if err != nil {
-		a.errorResponse(w, r, http.StatusInternalServerError, err)
+		a.errorResponse(w, r, errorStatus(err), err)
 		return
 	}
 
-	w.WriteHeader(http.StatusCreated)
-	_ = json.NewEncoder(w).Encode(u)
+	writeJSON(w, http.StatusCreated, u)
 }
 
 // Delete godoc
@@ -45,7 +63,11 @@ func (a *api) upsertUserHandler(w http.ResponseWriter, r *http.Request) {
 // @Description Accepts an ID and processes the delete. Returns a 200 header if no errors occured during the delete.
 // @Tags  Users
 // @Param userid path string true "userid"
+// @Param X-Tenant-ID header string false "tenant of the request"
 // @Success 200
+// @Failure 400
+// @Failure 503 {object} problem
+// @Failure 504 {object} problem
 // @Router /users/{userid} [delete]
 func (a *api) deleteUserHandler(w http.ResponseWriter, r *http.Request) {
 	ctx, cancel := context.WithCancel(r.Context())
@@ -53,13 +75,13 @@ func (a *api) deleteUserHandler(w http.ResponseWriter, r *http.Request) {
 
 	parsedUserId, err := uuid.Parse(chi.URLParam(r, "userid"))
 	if err != nil {
-		a.errorResponse(w, r, http.StatusInternalServerError, err)
+		a.errorResponse(w, r, http.StatusBadRequest, err)
 		return
 	}
 
 	err = a.userRepo.Delete(ctx, parsedUserId)
 	if err != nil {
-		a.errorResponse(w, r, http.StatusInternalServerError, err)
+		a.errorResponse(w, r, errorStatus(err), err)
 		return
 	}
 
@@ -72,7 +94,15 @@ func (a *api) deleteUserHandler(w http.ResponseWriter, r *http.Request) {
 // @Tags  Users
 // @Produce json
 // @Param userid path string true "userid"
+// @Param If-None-Match header string false "ETag of a cached response"
+// @Param If-Modified-Since header string false "Last-Modified of a cached response"
+// @Param X-Tenant-ID header string false "tenant of the request"
 // @Success 200 {object} domain.User
+// @Success 304
+// @Failure 400
+// @Failure 404
+// @Failure 503 {object} problem
+// @Failure 504 {object} problem
 // @Router /users/{userid} [get]
 func (a *api) getByIdUserHandler(w http.ResponseWriter, r *http.Request) {
 	ctx, cancel := context.WithCancel(r.Context())
@@ -80,18 +110,17 @@ func (a *api) getByIdUserHandler(w http.ResponseWriter, r *http.Request) {
 
 	parsedUserId, err := uuid.Parse(chi.URLParam(r, "userid"))
 	if err != nil {
-		a.errorResponse(w, r, http.StatusInternalServerError, err)
+		a.errorResponse(w, r, http.StatusBadRequest, err)
 		return
 	}
 
 	user, err := a.userRepo.GetByID(ctx, parsedUserId)
 	if err != nil {
-		a.errorResponse(w, r, http.StatusInternalServerError, err)
+		a.errorResponse(w, r, errorStatus(err), err)
 		return
 	}
 
-	w.WriteHeader(http.StatusOK)
-	_ = json.NewEncoder(w).Encode(user)
+	writeCacheable(w, r, user, user.UpdatedAt, a.userCacheControl)
 }
 
 // Get List godoc
@@ -103,8 +132,17 @@ func (a *api) getByIdUserHandler(w http.ResponseWriter, r *http.Request) {
 // @Param size query int false "number of elements per page" Format(size)
 // @Param orderBy query string false "filter name" Format(orderBy)
 // @Param orderDir query string false "filter name" Format(orderDir)
+// @Param first_name query string false "first name"
+// @Param last_name query string false "last name"
+// @Param email query string false "email"
+// @Param status query string false "status" Enums(invited, active, suspended, deactivated)
+// @Param If-None-Match header string false "ETag of a cached response"
+// @Param X-Tenant-ID header string false "tenant of the request"
 // @Success 200 {object} []domain.User
+// @Success 304
 // @Failure 400
+// @Failure 503 {object} problem
+// @Failure 504 {object} problem
 // @Router /users [get]
 func (a *api) getUserListHandler(w http.ResponseWriter, r *http.Request) {
 	ctx, cancel := context.WithCancel(r.Context())
@@ -112,16 +150,292 @@ func (a *api) getUserListHandler(w http.ResponseWriter, r *http.Request) {
 
 	pagQuery, err := utils.GetPaginationFromRequest(r)
 	if err != nil {
-		a.errorResponse(w, r, http.StatusInternalServerError, err)
+		a.errorResponse(w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	filter, err := userFilterFromRequest(r)
+	if err != nil {
+		a.errorResponse(w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	user, err := a.userRepo.GetList(ctx, pagQuery, filter)
+	if err != nil {
+		a.errorResponse(w, r, errorStatus(err), err)
+		return
+	}
+
+	// No Last-Modified: a deleted user changes the page but leaves no updated_at
+	// behind, only the ETag of the body notices
+	writeCacheable(w, r, user, time.Time{}, a.userListCacheControl)
+}
+
+// Batch Upsert godoc
+// @Summary Batch Create or Update Users
+// @Description Accepts a JSON array or NDJSON stream of users and upserts them in a single transaction. In atomic mode nothing is written if any item fails, in partial mode every valid item is written. Returns a per-item status report.
+// @Tags  Users
+// @Accept json
+// @Accept application/x-ndjson
+// @Produce json
+// @Param payload body []domain.User true "Users"
+// @Param mode query string false "atomic or partial" Enums(atomic, partial)
+// @Param X-Tenant-ID header string false "tenant of the request"
+// @Success 200 {object} []domain.BatchItemResult
+// @Success 207 {object} []domain.BatchItemResult
+// @Failure 400
+// @Failure 422 {object} []domain.BatchItemResult
+// @Failure 503 {object} problem
+// @Failure 504 {object} problem
+// @Router /users:batch [post]
+func (a *api) batchUpsertUserHandler(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+
+	mode := domain.BatchMode(r.URL.Query().Get("mode"))
+	if mode == "" {
+		mode = domain.BatchModeAtomic
+	}
+	if err := mode.Validate(); err != nil {
+		a.errorResponse(w, r, http.StatusBadRequest, fmt.Errorf("mode: %w", err))
 		return
 	}
 
-	user, err := a.userRepo.GetList(ctx, pagQuery)
+	uu, err := decodeUserBatch(w, r)
 	if err != nil {
-		a.errorResponse(w, r, http.StatusInternalServerError, err)
+		a.errorResponse(w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	results, err := a.userRepo.BatchCreateOrUpdate(ctx, uu, mode)
+	if err != nil && !errors.Is(err, domain.ErrBatchRejected) {
+		a.errorResponse(w, r, errorStatus(err), err)
+		return
+	}
+
+	status := http.StatusOK
+	if errors.Is(err, domain.ErrBatchRejected) {
+		status = http.StatusUnprocessableEntity
+	} else {
+		for _, res := range results {
+			if res.Status != domain.BatchStatusOK {
+				status = http.StatusMultiStatus
+				break
+			}
+		}
+	}
+
+	writeJSON(w, status, results)
+}
+
+// decodeUserBatch reads a JSON array or, for application/x-ndjson, one user per line
+func decodeUserBatch(w http.ResponseWriter, r *http.Request) ([]*domain.User, error) {
+	body := http.MaxBytesReader(w, r.Body, maxBatchBytes)
+
+	var uu []*domain.User
+	if mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); mediaType == userio.FormatNDJSON.ContentType() {
+		dec, err := userio.NewDecoder(body, userio.FormatNDJSON, nil)
+		if err != nil {
+			return nil, err
+		}
+
+		for {
+			rec, err := dec.Next()
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			if err != nil {
+				return nil, err
+			}
+			if rec.Err != nil {
+				return nil, fmt.Errorf("line %d: %w", rec.Line, rec.Err)
+			}
+			uu = append(uu, rec.User)
+		}
+	} else if err := json.NewDecoder(body).Decode(&uu); err != nil {
+		return nil, err
+	}
+
+	if len(uu) == 0 {
+		return nil, errors.New("batch is empty")
+	}
+	if len(uu) > maxBatchSize {
+		return nil, fmt.Errorf("batch exceeds %d users", maxBatchSize)
+	}
+	return uu, nil
+}
+
+// Export godoc
+// @Summary Export Users
+// @Description Streams every user matching the filters as NDJSON or CSV, chosen by the Accept header.
+// @Tags  Users
+// @Produce application/x-ndjson
+// @Produce text/csv
+// @Param first_name query string false "first name"
+// @Param last_name query string false "last name"
+// @Param email query string false "email"
+// @Param status query string false "status" Enums(invited, active, suspended, deactivated)
+// @Param X-Tenant-ID header string false "tenant of the request"
+// @Success 200
+// @Failure 406
+// @Router /users:export [get]
+func (a *api) exportUserHandler(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+
+	filter, err := userFilterFromRequest(r)
+	if err != nil {
+		a.errorResponse(w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	contentType := negotiateContentType(r.Header.Get("Accept"), userio.FormatNDJSON.ContentType(), userio.FormatCSV.ContentType())
+	if contentType == "" {
+		a.errorResponse(w, r, http.StatusNotAcceptable, errors.New("supported formats are application/x-ndjson and text/csv"))
 		return
 	}
 
+	format := userio.FormatNDJSON
+	if contentType == userio.FormatCSV.ContentType() {
+		format = userio.FormatCSV
+		w.Header().Set("Content-Disposition", `attachment; filename="users.csv"`)
+	}
+	enc := userio.NewEncoder(w, format)
+
+	w.Header().Set("Content-Type", contentType)
 	w.WriteHeader(http.StatusOK)
-	_ = json.NewEncoder(w).Encode(user)
+
+	rc := http.NewResponseController(w)
+	rows := 0
+	err = a.userRepo.Stream(ctx, filter, func(u *domain.User) error {
+		if err := enc.Encode(u); err != nil {
+			return err
+		}
+
+		rows++
+		if rows%exportFlushRows == 0 {
+			if err := enc.Flush(); err != nil {
+				return err
+			}
+			return rc.Flush()
+		}
+		return nil
+	})
+	if err == nil {
+		err = enc.Flush()
+	}
+
+	// The status has already been sent, so failures can only be logged
+	if err != nil {
+		a.logger.Error("user export aborted", zap.Int("rows", rows), zap.Error(err))
+	}
+}
+
+// userFilterFromRequest reads the user filter query parameters
+func userFilterFromRequest(r *http.Request) (domain.UserFilter, error) {
+	f := domain.UserFilter{
+		FirstName: r.URL.Query().Get("first_name"),
+		LastName:  r.URL.Query().Get("last_name"),
+		Email:     r.URL.Query().Get("email"),
+		Status:    domain.UserStatus(r.URL.Query().Get("status")),
+	}
+	if err := f.Status.Validate(); err != nil {
+		return domain.UserFilter{}, fmt.Errorf("status: %w", err)
+	}
+	return f, nil
+}
+
+// Status Reason Request model
+// @Description Why the status of a user changes, required to suspend or deactivate
+type statusReasonRequest struct {
+	Reason string `json:"reason,omitempty" example:"chargeback fraud"`
+}
+
+// Activate godoc
+// @Summary Activate User
+// @Description Moves an invited or suspended user to active and clears the reason of the suspension
+// @Tags  Users
+// @Accept json
+// @Produce json
+// @Param userid path string true "userid"
+// @Param payload body statusReasonRequest false "Reason"
+// @Param X-Tenant-ID header string false "tenant of the request"
+// @Success 200 {object} domain.User
+// @Failure 400
+// @Failure 404
+// @Failure 409
+// @Failure 503 {object} problem
+// @Failure 504 {object} problem
+// @Router /users/{userid}/activate [post]
+func (a *api) activateUserHandler(w http.ResponseWriter, r *http.Request) {
+	a.transitionUser(w, r, domain.UserStatusActive)
+}
+
+// Suspend godoc
+// @Summary Suspend User
+// @Description Moves an active user to suspended until it is activated again
+// @Tags  Users
+// @Accept json
+// @Produce json
+// @Param userid path string true "userid"
+// @Param payload body statusReasonRequest true "Reason"
+// @Param X-Tenant-ID header string false "tenant of the request"
+// @Success 200 {object} domain.User
+// @Failure 400
+// @Failure 404
+// @Failure 409
+// @Failure 422
+// @Failure 503 {object} problem
+// @Failure 504 {object} problem
+// @Router /users/{userid}/suspend [post]
+func (a *api) suspendUserHandler(w http.ResponseWriter, r *http.Request) {
+	a.transitionUser(w, r, domain.UserStatusSuspended)
+}
+
+// Deactivate godoc
+// @Summary Deactivate User
+// @Description Closes a user for good, a deactivated user cannot move to any other status
+// @Tags  Users
+// @Accept json
+// @Produce json
+// @Param userid path string true "userid"
+// @Param payload body statusReasonRequest true "Reason"
+// @Param X-Tenant-ID header string false "tenant of the request"
+// @Success 200 {object} domain.User
+// @Failure 400
+// @Failure 404
+// @Failure 409
+// @Failure 422
+// @Failure 503 {object} problem
+// @Failure 504 {object} problem
+// @Router /users/{userid}/deactivate [post]
+func (a *api) deactivateUserHandler(w http.ResponseWriter, r *http.Request) {
+	a.transitionUser(w, r, domain.UserStatusDeactivated)
+}
+
+// transitionUser moves the user of the path to status, the body is optional
+// where no reason is required
+func (a *api) transitionUser(w http.ResponseWriter, r *http.Request, status domain.UserStatus) {
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+
+	id, err := urlUUID(r, "userid")
+	if err != nil {
+		a.errorResponse(w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	var req statusReasonRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+		a.errorResponse(w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	u, err := a.userRepo.Transition(ctx, id, domain.StatusChange{Status: status, Reason: req.Reason})
+	if err != nil {
+		a.errorResponse(w, r, errorStatus(err), err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, u)
 }
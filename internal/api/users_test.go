@@ -0,0 +1,452 @@
+package api_test
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"go-project-template/internal/api"
+	"go-project-template/internal/domain"
+	"go-project-template/internal/utils"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var (
+	johnUUID  = uuid.MustParse("3fe82b1f-ab3d-40a1-8bd8-bccd4dd166f8")
+	helenUUID = uuid.MustParse("79f8aa8e-f7ed-4e47-b9e4-4cd5db68a297")
+	adaUUID   = uuid.MustParse("0b6b3c8e-54a4-4f0c-9a3e-6c1f0f2b7d11")
+)
+
+func testUser(id uuid.UUID, firstName string, lastName string) *domain.User {
+	email := strings.ToLower(firstName) + "@mail.com"
+	return &domain.User{UUID: id, FirstName: firstName, LastName: lastName, Email: &email}
+}
+
+func TestHealth(t *testing.T) {
+	t.Parallel()
+	h := newHarness(t)
+
+	h.request(http.MethodGet, "/v1/health").expect(http.StatusOK).matchesGolden("health")
+}
+
+func TestUsers_Upsert(t *testing.T) {
+	t.Parallel()
+	h := newHarness(t)
+
+	testCases := map[string]struct {
+		body   interface{}
+		status int
+		golden string
+	}{
+		"valid":          {testUser(johnUUID, "John", "Wick"), http.StatusCreated, "upsert_user"},
+		"missing fields": {domain.User{UUID: helenUUID, FirstName: "Helen"}, http.StatusUnprocessableEntity, "upsert_user_invalid"},
+		"malformed":      {`{"first_name": `, http.StatusBadRequest, ""},
+	}
+
+	for scenario, tc := range testCases { //nolint:paralleltest
+		t.Run(scenario, func(t *testing.T) {
+			res := h.request(http.MethodPost, "/v1/users").withJSON(tc.body).expect(tc.status)
+			if tc.golden != "" {
+				res.matchesGolden(tc.golden)
+			}
+		})
+	}
+
+	// Names are stored normalized
+	var got domain.User
+	h.request(http.MethodGet, "/v1/users/"+johnUUID.String()).expect(http.StatusOK).decode(&got)
+	assert.Equal(t, "john", got.FirstName)
+}
+
+func TestUsers_UpsertIdempotency(t *testing.T) {
+	t.Parallel()
+	h := newHarness(t)
+
+	h.request(http.MethodPost, "/v1/users").
+		withHeader("Idempotency-Key", "create-john").
+		withJSON(testUser(johnUUID, "John", "Wick")).
+		expect(http.StatusCreated).
+		hasHeader("Idempotent-Replayed", "")
+
+	// A retry replays the stored response
+	h.request(http.MethodPost, "/v1/users").
+		withHeader("Idempotency-Key", "create-john").
+		withJSON(testUser(johnUUID, "John", "Wick")).
+		expect(http.StatusCreated).
+		hasHeader("Idempotent-Replayed", "true").
+		matchesGolden("upsert_user")
+
+	h.request(http.MethodPost, "/v1/users").
+		withHeader("Idempotency-Key", "create-john").
+		withJSON(testUser(johnUUID, "Jonathan", "Wick")).
+		expect(http.StatusUnprocessableEntity).
+		hasError(domain.ErrKeyReuse.Error())
+
+	h.request(http.MethodPost, "/v1/users").
+		withHeader("Idempotency-Key", strings.Repeat("k", 256)).
+		withJSON(testUser(johnUUID, "John", "Wick")).
+		expect(http.StatusBadRequest)
+}
+
+func TestUsers_Get(t *testing.T) {
+	t.Parallel()
+	h := newHarness(t)
+	h.seed(testUser(johnUUID, "John", "Wick"))
+
+	testCases := map[string]struct {
+		id     string
+		status int
+		golden string
+	}{
+		"existing":     {johnUUID.String(), http.StatusOK, "get_user"},
+		"missing":      {helenUUID.String(), http.StatusNotFound, "get_user_missing"},
+		"invalid uuid": {"not-a-uuid", http.StatusBadRequest, "get_user_invalid"},
+	}
+
+	for scenario, tc := range testCases { //nolint:paralleltest
+		t.Run(scenario, func(t *testing.T) {
+			h.request(http.MethodGet, "/v1/users/"+tc.id).expect(tc.status).matchesGolden(tc.golden)
+		})
+	}
+}
+
+func TestUsers_Delete(t *testing.T) {
+	t.Parallel()
+	h := newHarness(t)
+	h.seed(testUser(johnUUID, "John", "Wick"))
+
+	h.request(http.MethodDelete, "/v1/users/"+johnUUID.String()).expect(http.StatusOK)
+	h.request(http.MethodGet, "/v1/users/"+johnUUID.String()).expect(http.StatusNotFound)
+
+	// Deleting a missing user succeeds
+	h.request(http.MethodDelete, "/v1/users/"+johnUUID.String()).expect(http.StatusOK)
+	h.request(http.MethodDelete, "/v1/users/not-a-uuid").expect(http.StatusBadRequest)
+}
+
+func TestUsers_List(t *testing.T) {
+	t.Parallel()
+	h := newHarness(t)
+	h.seed(
+		testUser(johnUUID, "John", "Wick"),
+		testUser(helenUUID, "Helen", "Wick"),
+		testUser(adaUUID, "Ada", "Lovelace"),
+	)
+
+	testCases := map[string]struct {
+		query  string
+		status int
+		golden string
+	}{
+		"ordered":        {"?page=1&size=10&orderBy=first_name&orderDir=desc", http.StatusOK, "list_users"},
+		"paginated":      {"?page=2&size=2&orderBy=first_name", http.StatusOK, "list_users_page"},
+		"filtered":       {"?page=1&size=10&last_name=Wick&orderBy=first_name", http.StatusOK, "list_users_filtered"},
+		"no match":       {"?page=1&size=10&last_name=Smith", http.StatusOK, "list_users_empty"},
+		"by status":      {"?page=1&size=10&status=suspended", http.StatusOK, "list_users_empty"},
+		"invalid page":   {"?page=first", http.StatusBadRequest, ""},
+		"invalid size":   {"?page=1&size=ten", http.StatusBadRequest, ""},
+		"invalid status": {"?page=1&size=10&status=banned", http.StatusBadRequest, ""},
+	}
+
+	for scenario, tc := range testCases { //nolint:paralleltest
+		t.Run(scenario, func(t *testing.T) {
+			res := h.request(http.MethodGet, "/v1/users"+tc.query).expect(tc.status)
+			if tc.golden != "" {
+				res.matchesGolden(tc.golden)
+			}
+		})
+	}
+}
+
+func TestUsers_Lifecycle(t *testing.T) {
+	t.Parallel()
+	h := newHarness(t)
+	h.seed(testUser(johnUUID, "John", "Wick"))
+	path := "/v1/users/" + johnUUID.String()
+
+	var u domain.User
+	h.request(http.MethodPost, path+"/suspend").withJSON(map[string]string{}).expect(http.StatusUnprocessableEntity)
+	h.request(http.MethodPost, path+"/suspend").withJSON(map[string]string{"reason": "chargeback"}).expect(http.StatusOK).decode(&u)
+	assert.Equal(t, domain.UserStatusSuspended, u.Status)
+	assert.Equal(t, "chargeback", u.StatusReason)
+
+	var list struct {
+		TotalCount int `json:"total_count"`
+	}
+	h.request(http.MethodGet, "/v1/users?page=1&size=10&status=suspended").expect(http.StatusOK).decode(&list)
+	assert.Equal(t, 1, list.TotalCount)
+
+	h.request(http.MethodPost, path+"/suspend").withJSON(map[string]string{"reason": "again"}).
+		expect(http.StatusConflict).
+		hasError(domain.ErrIllegalTransition.Error() + " from suspended to suspended")
+
+	// The body is optional where no reason is needed
+	var active domain.User
+	h.request(http.MethodPost, path+"/activate").expect(http.StatusOK).decode(&active)
+	assert.Equal(t, domain.UserStatusActive, active.Status)
+	assert.Empty(t, active.StatusReason)
+
+	h.request(http.MethodPost, path+"/deactivate").withJSON(map[string]string{"reason": "closed"}).expect(http.StatusOK)
+	h.request(http.MethodPost, path+"/activate").expect(http.StatusConflict)
+
+	h.request(http.MethodPost, "/v1/users/"+helenUUID.String()+"/activate").expect(http.StatusNotFound)
+	h.request(http.MethodPost, "/v1/users/not-a-uuid/activate").expect(http.StatusBadRequest)
+	h.request(http.MethodPost, path+"/suspend").withJSON(`{"reason":`).expect(http.StatusBadRequest)
+}
+
+func TestUsers_Batch(t *testing.T) {
+	t.Parallel()
+	h := newHarness(t)
+
+	valid := `[
+		{"uuid": "3fe82b1f-ab3d-40a1-8bd8-bccd4dd166f8", "first_name": "John", "last_name": "Wick", "email": "john@mail.com"},
+		{"uuid": "79f8aa8e-f7ed-4e47-b9e4-4cd5db68a297", "first_name": "Helen", "last_name": "Wick", "email": "helen@mail.com"}
+	]`
+	mixed := `[
+		{"uuid": "3fe82b1f-ab3d-40a1-8bd8-bccd4dd166f8", "first_name": "John", "last_name": "Wick", "email": "john@mail.com"},
+		{"uuid": "0b6b3c8e-54a4-4f0c-9a3e-6c1f0f2b7d11", "first_name": "Ada"}
+	]`
+	ndjson := `{"uuid": "3fe82b1f-ab3d-40a1-8bd8-bccd4dd166f8", "first_name": "John", "last_name": "Wick", "email": "john@mail.com"}
+{"uuid": "79f8aa8e-f7ed-4e47-b9e4-4cd5db68a297", "first_name": "Helen", "last_name": "Wick", "email": "helen@mail.com"}
+`
+
+	testCases := map[string]struct {
+		query       string
+		contentType string
+		body        string
+		status      int
+		golden      string
+	}{
+		"atomic":       {"", "application/json", valid, http.StatusOK, "batch_users"},
+		"ndjson":       {"?mode=atomic", "application/x-ndjson", ndjson, http.StatusOK, "batch_users"},
+		"rejected":     {"?mode=atomic", "application/json", mixed, http.StatusUnprocessableEntity, "batch_users_rejected"},
+		"partial":      {"?mode=partial", "application/json", mixed, http.StatusMultiStatus, "batch_users_partial"},
+		"unknown mode": {"?mode=eventual", "application/json", valid, http.StatusBadRequest, ""},
+		"empty":        {"", "application/json", `[]`, http.StatusBadRequest, ""},
+		"malformed":    {"", "application/json", `[{`, http.StatusBadRequest, ""},
+	}
+
+	for scenario, tc := range testCases { //nolint:paralleltest
+		t.Run(scenario, func(t *testing.T) {
+			res := h.request(http.MethodPost, "/v1/users:batch"+tc.query).withBody(tc.contentType, tc.body).expect(tc.status)
+			if tc.golden != "" {
+				res.matchesGolden(tc.golden)
+			}
+		})
+	}
+}
+
+func TestUsers_Export(t *testing.T) {
+	t.Parallel()
+	h := newHarness(t)
+	h.seed(
+		testUser(johnUUID, "John", "Wick"),
+		testUser(helenUUID, "Helen", "Wick"),
+		testUser(adaUUID, "Ada", "Lovelace"),
+	)
+
+	testCases := map[string]struct {
+		query  string
+		accept string
+		status int
+		golden string
+	}{
+		"ndjson":       {"", "application/x-ndjson", http.StatusOK, "export_users_ndjson"},
+		"csv":          {"?last_name=wick", "text/csv", http.StatusOK, "export_users_csv"},
+		"refused csv":  {"", "text/csv;q=0, application/x-ndjson", http.StatusOK, "export_users_ndjson"},
+		"preferred":    {"?last_name=wick", "application/x-ndjson;q=0.5, text/csv", http.StatusOK, "export_users_csv"},
+		"wildcard":     {"?last_name=wick", "application/x-ndjson;q=0, */*", http.StatusOK, "export_users_csv"},
+		"header order": {"?last_name=wick", "text/csv, application/x-ndjson", http.StatusOK, "export_users_csv"},
+		"unsupported":  {"", "application/xml", http.StatusNotAcceptable, ""},
+		"all refused":  {"", "*/*;q=0", http.StatusNotAcceptable, ""},
+	}
+
+	for scenario, tc := range testCases { //nolint:paralleltest
+		t.Run(scenario, func(t *testing.T) {
+			res := h.request(http.MethodGet, "/v1/users:export"+tc.query).withHeader("Accept", tc.accept).expect(tc.status)
+			if tc.golden != "" {
+				res.matchesGolden(tc.golden)
+			}
+		})
+	}
+}
+
+func TestUsers_Events(t *testing.T) {
+	t.Parallel()
+	h := newHarness(t)
+	h.outbox.add(domain.EventUserCreated, johnUUID, `{"uuid": "3fe82b1f-ab3d-40a1-8bd8-bccd4dd166f8", "first_name": "john"}`)
+	h.outbox.add(domain.EventUserCreated, helenUUID, `{"uuid": "79f8aa8e-f7ed-4e47-b9e4-4cd5db68a297", "first_name": "helen"}`)
+	h.outbox.add(domain.EventUserDeleted, johnUUID, `{"uuid": "3fe82b1f-ab3d-40a1-8bd8-bccd4dd166f8"}`)
+
+	h.request(http.MethodGet, "/v1/users/events?uuid=not-a-uuid").expect(http.StatusBadRequest)
+	h.request(http.MethodGet, "/v1/users/events?last_event_id=first").expect(http.StatusBadRequest)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// Resuming after the first event replays the events of John after it
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, h.url("/v1/users/events?uuid="+johnUUID.String()), nil)
+	require.NoError(t, err)
+	req.Header.Set("Last-Event-ID", "1")
+
+	res, err := h.server.Client().Do(req)
+	require.NoError(t, err)
+	defer res.Body.Close()
+
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+	assert.Equal(t, "text/event-stream", res.Header.Get("Content-Type"))
+
+	var lines []string
+	scanner := bufio.NewScanner(res.Body)
+	for scanner.Scan() {
+		line := scanner.Text()
+		lines = append(lines, line)
+		if strings.HasPrefix(line, "data: ") {
+			break
+		}
+	}
+
+	assert.Equal(t, []string{
+		"retry: 3000",
+		"",
+		"id: 3",
+		"event: user.deleted",
+		`data: {"id":3,"aggregate_type":"user","aggregate_id":"3fe82b1f-ab3d-40a1-8bd8-bccd4dd166f8","tenant_id":"default","type":"user.deleted","payload":{"uuid":"3fe82b1f-ab3d-40a1-8bd8-bccd4dd166f8"},"occurred_at":"2024-01-01T00:00:00Z"}`,
+	}, lines)
+}
+
+func TestUsers_ConditionalGet(t *testing.T) {
+	t.Parallel()
+	h := newHarness(t)
+	h.seed(testUser(johnUUID, "John", "Wick"), testUser(helenUUID, "Helen", "Wick"))
+
+	lastModified := "Mon, 01 Jan 2024 00:00:00 GMT"
+	for path, modified := range map[string]string{
+		"/v1/users/" + johnUUID.String(): lastModified,
+		// A list has no Last-Modified, deletes would not move it
+		"/v1/users?last_name=wick": "",
+	} {
+		res := h.request(http.MethodGet, path).expect(http.StatusOK).
+			hasHeader("Last-Modified", modified).
+			hasHeader("Cache-Control", "private, no-cache")
+		etag := res.res.Header.Get("ETag")
+		require.Regexp(t, `^"[0-9a-f]{64}"$`, etag)
+
+		// Without Last-Modified, If-Modified-Since is ignored
+		sinceStatus := http.StatusNotModified
+		if modified == "" {
+			sinceStatus = http.StatusOK
+		}
+
+		testCases := map[string]struct {
+			header map[string]string
+			status int
+		}{
+			"matching etag":       {map[string]string{"If-None-Match": etag}, http.StatusNotModified},
+			"weak etag in a list": {map[string]string{"If-None-Match": `"other", W/` + etag}, http.StatusNotModified},
+			"any etag":            {map[string]string{"If-None-Match": "*"}, http.StatusNotModified},
+			"stale etag":          {map[string]string{"If-None-Match": `"other"`}, http.StatusOK},
+			"not modified since":  {map[string]string{"If-Modified-Since": lastModified}, sinceStatus},
+			"modified since":      {map[string]string{"If-Modified-Since": "Sun, 31 Dec 2023 23:59:59 GMT"}, http.StatusOK},
+			"invalid date":        {map[string]string{"If-Modified-Since": "yesterday"}, http.StatusOK},
+			// If-Modified-Since only counts without If-None-Match
+			"etag takes precedence": {map[string]string{"If-None-Match": `"other"`, "If-Modified-Since": lastModified}, http.StatusOK},
+		}
+
+		for scenario, tc := range testCases { //nolint:paralleltest
+			t.Run(path+"/"+scenario, func(t *testing.T) {
+				req := h.request(http.MethodGet, path)
+				for key, value := range tc.header {
+					req.withHeader(key, value)
+				}
+				res := req.expect(tc.status).hasHeader("ETag", etag).hasHeader("Cache-Control", "private, no-cache")
+				if tc.status == http.StatusNotModified {
+					assert.Empty(t, res.body)
+				}
+			})
+		}
+
+		// Any change to the response changes its ETag
+		h.seed(testUser(johnUUID, "Jonathan", "Wick"))
+		h.request(http.MethodGet, path).withHeader("If-None-Match", etag).expect(http.StatusOK)
+		h.seed(testUser(johnUUID, "John", "Wick"))
+	}
+
+	// A deleted user changes the ETag of the list
+	etag := h.request(http.MethodGet, "/v1/users?last_name=wick").expect(http.StatusOK).res.Header.Get("ETag")
+	h.request(http.MethodDelete, "/v1/users/"+helenUUID.String()).expect(http.StatusOK)
+	h.request(http.MethodGet, "/v1/users?last_name=wick").withHeader("If-None-Match", etag).expect(http.StatusOK)
+}
+
+func TestUsers_CacheControl(t *testing.T) { //nolint:paralleltest
+	t.Setenv("USER_CACHE_CONTROL", "private, max-age=60")
+	t.Setenv("USER_LIST_CACHE_CONTROL", "no-store")
+	h := newHarness(t)
+	h.seed(testUser(johnUUID, "John", "Wick"))
+
+	h.request(http.MethodGet, "/v1/users/"+johnUUID.String()).expect(http.StatusOK).hasHeader("Cache-Control", "private, max-age=60")
+	h.request(http.MethodGet, "/v1/users").expect(http.StatusOK).hasHeader("Cache-Control", "no-store")
+}
+
+// stalledUserRepository reads users like a database that stopped answering
+type stalledUserRepository struct {
+	domain.UserRepository
+}
+
+func (stalledUserRepository) GetByID(ctx context.Context, _ uuid.UUID) (domain.User, error) {
+	<-ctx.Done()
+	return domain.User{}, fmt.Errorf("fetching user: %w", ctx.Err())
+}
+
+func (stalledUserRepository) GetList(context.Context, *utils.PaginationQuery, domain.UserFilter) (*utils.PaginationResponse[domain.User], error) {
+	return nil, &pgconn.PgError{Code: "57014", Message: "canceling statement due to statement timeout"}
+}
+
+// brokenUserRepository fails reads with an error that must not reach clients
+type brokenUserRepository struct {
+	domain.UserRepository
+}
+
+func (brokenUserRepository) GetByID(context.Context, uuid.UUID) (domain.User, error) {
+	return domain.User{}, errors.New(`relation "users_internal" does not exist`)
+}
+
+func TestUsers_ErrorHeader(t *testing.T) {
+	t.Parallel()
+	h := newHarness(t, func(deps *api.Dependencies) {
+		deps.UserRepo = brokenUserRepository{deps.UserRepo}
+	})
+
+	// The header carries a code, the error itself only goes to the log
+	res := h.request(http.MethodGet, "/v1/users/"+johnUUID.String()).
+		expect(http.StatusInternalServerError).
+		hasHeader("X-Project-Error", "internal_server_error").
+		hasError("Internal Server Error")
+	assert.NotContains(t, string(res.body), "users_internal")
+
+	h.request(http.MethodGet, "/v1/users/not-a-uuid").
+		expect(http.StatusBadRequest).
+		hasHeader("X-Project-Error", "bad_request")
+}
+
+func TestUsers_Timeouts(t *testing.T) { //nolint:paralleltest
+	t.Setenv("USER_READ_TIMEOUT", "20ms")
+	h := newHarness(t, func(deps *api.Dependencies) {
+		deps.UserRepo = stalledUserRepository{deps.UserRepo}
+	})
+
+	h.request(http.MethodGet, "/v1/users/"+johnUUID.String()).
+		expect(http.StatusGatewayTimeout).
+		matchesGolden("get_user_timeout")
+
+	h.request(http.MethodGet, "/v1/users").
+		expect(http.StatusServiceUnavailable).
+		hasHeader("Retry-After", "1").
+		matchesGolden("list_users_canceled")
+}
@@ -0,0 +1,286 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"go-project-template/internal/domain"
+	"net/http"
+	"os"
+	"strconv"
+
+	"github.com/go-chi/chi"
+	"github.com/google/uuid"
+)
+
+const (
+	defaultDeliveryLimit = 50
+	maxDeliveryLimit     = 500
+)
+
+// allowPrivateWebhooks reads WEBHOOK_ALLOW_PRIVATE_URLS, which lets webhooks
+// point to loopback and private addresses for local development
+func allowPrivateWebhooks() bool {
+	enabled, _ := strconv.ParseBool(os.Getenv("WEBHOOK_ALLOW_PRIVATE_URLS"))
+	return enabled
+}
+
+// Webhook Request model
+// @Description Fields of a webhook that can be set by clients
+type webhookRequest struct {
+	URL        string   `json:"url" example:"https://example.com/hooks/users"`
+	EventTypes []string `json:"event_types" example:"user.created,user.deleted"`
+	// Active re-enables or pauses a webhook, it is left unchanged when omitted
+	Active *bool `json:"active,omitempty" example:"true"`
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+// Create Webhook godoc
+// @Summary Create Webhook
+// @Description Subscribes a URL to the domain events of the tenant. The response contains the secret used to sign deliveries, it is not returned again.
+// @Tags  Webhooks
+// @Accept json
+// @Produce json
+// @Param payload body webhookRequest true "Webhook"
+// @Param X-Tenant-ID header string false "tenant of the request"
+// @Success 201 {object} domain.Webhook
+// @Failure 400
+// @Failure 422
+// @Router /webhooks [post]
+func (a *api) createWebhookHandler(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+
+	req := &webhookRequest{}
+	if err := json.NewDecoder(r.Body).Decode(req); err != nil {
+		a.errorResponse(w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	wh, err := a.webhookRepo.Create(ctx, &domain.Webhook{URL: req.URL, EventTypes: req.EventTypes})
+	if err != nil {
+		a.errorResponse(w, r, errorStatus(err), err)
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, wh)
+}
+
+// List Webhooks godoc
+// @Summary List Webhooks
+// @Description Returns every webhook subscription
+// @Tags  Webhooks
+// @Produce json
+// @Param X-Tenant-ID header string false "tenant of the request"
+// @Success 200 {object} []domain.Webhook
+// @Router /webhooks [get]
+func (a *api) listWebhookHandler(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+
+	ww, err := a.webhookRepo.List(ctx)
+	if err != nil {
+		a.errorResponse(w, r, http.StatusInternalServerError, err)
+		return
+	}
+
+	for i := range ww {
+		ww[i].Secret = ""
+	}
+	writeJSON(w, http.StatusOK, ww)
+}
+
+// Get Webhook godoc
+// @Summary Get Webhook
+// @Description Accepts an ID and returns the webhook subscription
+// @Tags  Webhooks
+// @Produce json
+// @Param webhookid path string true "webhookid"
+// @Param X-Tenant-ID header string false "tenant of the request"
+// @Success 200 {object} domain.Webhook
+// @Failure 404
+// @Router /webhooks/{webhookid} [get]
+func (a *api) getWebhookHandler(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+
+	id, err := uuid.Parse(chi.URLParam(r, "webhookid"))
+	if err != nil {
+		a.errorResponse(w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	wh, err := a.webhookRepo.GetByID(ctx, id)
+	if err != nil {
+		a.errorResponse(w, r, errorStatus(err), err)
+		return
+	}
+
+	wh.Secret = ""
+	writeJSON(w, http.StatusOK, wh)
+}
+
+// Update Webhook godoc
+// @Summary Update Webhook
+// @Description Replaces the URL and event types of a webhook. Setting active to true re-enables a disabled webhook and clears its failure count.
+// @Tags  Webhooks
+// @Accept json
+// @Produce json
+// @Param webhookid path string true "webhookid"
+// @Param payload body webhookRequest true "Webhook"
+// @Param X-Tenant-ID header string false "tenant of the request"
+// @Success 200 {object} domain.Webhook
+// @Failure 400
+// @Failure 404
+// @Failure 422
+// @Router /webhooks/{webhookid} [put]
+func (a *api) updateWebhookHandler(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+
+	id, err := uuid.Parse(chi.URLParam(r, "webhookid"))
+	if err != nil {
+		a.errorResponse(w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	req := &webhookRequest{}
+	if err := json.NewDecoder(r.Body).Decode(req); err != nil {
+		a.errorResponse(w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	wh, err := a.webhookRepo.GetByID(ctx, id)
+	if err != nil {
+		a.errorResponse(w, r, errorStatus(err), err)
+		return
+	}
+
+	wh.URL = req.URL
+	wh.EventTypes = req.EventTypes
+	if req.Active != nil {
+		wh.Active = *req.Active
+	}
+
+	wh, err = a.webhookRepo.Update(ctx, wh)
+	if err != nil {
+		a.errorResponse(w, r, errorStatus(err), err)
+		return
+	}
+
+	wh.Secret = ""
+	writeJSON(w, http.StatusOK, wh)
+}
+
+// Delete Webhook godoc
+// @Summary Delete Webhook
+// @Description Deletes a webhook subscription together with its delivery log
+// @Tags  Webhooks
+// @Param webhookid path string true "webhookid"
+// @Param X-Tenant-ID header string false "tenant of the request"
+// @Success 204
+// @Failure 404
+// @Router /webhooks/{webhookid} [delete]
+func (a *api) deleteWebhookHandler(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+
+	id, err := uuid.Parse(chi.URLParam(r, "webhookid"))
+	if err != nil {
+		a.errorResponse(w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	if err := a.webhookRepo.Delete(ctx, id); err != nil {
+		a.errorResponse(w, r, errorStatus(err), err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// List Deliveries godoc
+// @Summary List Webhook Deliveries
+// @Description Returns the most recent deliveries of a webhook with the outcome of their last attempt, newest first
+// @Tags  Webhooks
+// @Produce json
+// @Param webhookid path string true "webhookid"
+// @Param limit query int false "number of deliveries, at most 500"
+// @Param X-Tenant-ID header string false "tenant of the request"
+// @Success 200 {object} []domain.WebhookDelivery
+// @Failure 400
+// @Failure 404
+// @Router /webhooks/{webhookid}/deliveries [get]
+func (a *api) listWebhookDeliveryHandler(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+
+	id, err := uuid.Parse(chi.URLParam(r, "webhookid"))
+	if err != nil {
+		a.errorResponse(w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	limit := defaultDeliveryLimit
+	if v := r.URL.Query().Get("limit"); v != "" {
+		limit, err = strconv.Atoi(v)
+		if err != nil || limit < 1 || limit > maxDeliveryLimit {
+			a.errorResponse(w, r, http.StatusBadRequest, errors.New("limit must be between 1 and 500"))
+			return
+		}
+	}
+
+	if _, err := a.webhookRepo.GetByID(ctx, id); err != nil {
+		a.errorResponse(w, r, errorStatus(err), err)
+		return
+	}
+
+	dd, err := a.webhookRepo.Deliveries(ctx, id, limit)
+	if err != nil {
+		a.errorResponse(w, r, http.StatusInternalServerError, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, dd)
+}
+
+// Redeliver godoc
+// @Summary Redeliver Webhook Delivery
+// @Description Queues a new delivery with the payload of an earlier one. Deliveries of a disabled webhook wait until it is re-enabled.
+// @Tags  Webhooks
+// @Produce json
+// @Param webhookid path string true "webhookid"
+// @Param deliveryid path string true "deliveryid"
+// @Param X-Tenant-ID header string false "tenant of the request"
+// @Success 202 {object} domain.WebhookDelivery
+// @Failure 400
+// @Failure 404
+// @Router /webhooks/{webhookid}/deliveries/{deliveryid}/redeliver [post]
+func (a *api) redeliverWebhookHandler(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+
+	id, err := uuid.Parse(chi.URLParam(r, "webhookid"))
+	if err != nil {
+		a.errorResponse(w, r, http.StatusBadRequest, err)
+		return
+	}
+	deliveryID, err := uuid.Parse(chi.URLParam(r, "deliveryid"))
+	if err != nil {
+		a.errorResponse(w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	dl, err := a.webhookRepo.Redeliver(ctx, id, deliveryID)
+	if err != nil {
+		a.errorResponse(w, r, errorStatus(err), err)
+		return
+	}
+
+	writeJSON(w, http.StatusAccepted, dl)
+}
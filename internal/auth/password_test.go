@@ -0,0 +1,59 @@
+package auth_test
+
+import (
+	"go-project-template/internal/auth"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testParams keep the tests fast, they are far too cheap for production
+var testParams = auth.Params{Memory: 1024, Iterations: 1, Parallelism: 1, SaltLength: 16, KeyLength: 32}
+
+func TestHasher_Verify(t *testing.T) {
+	t.Parallel()
+	hasher := auth.NewHasher(testParams)
+
+	hash, err := hasher.Hash("correct horse Battery 9")
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(hash, "$argon2id$v=19$m=1024,t=1,p=1$"), hash)
+
+	// Every hash has its own salt
+	other, err := hasher.Hash("correct horse Battery 9")
+	require.NoError(t, err)
+	assert.NotEqual(t, hash, other)
+
+	stronger := testParams
+	stronger.Iterations = 2
+
+	testCases := map[string]struct {
+		hasher     *auth.Hasher
+		password   string
+		hash       string
+		wantMatch  bool
+		wantRehash bool
+		wantErr    error
+	}{
+		"match":            {hasher, "correct horse Battery 9", hash, true, false, nil},
+		"mismatch":         {hasher, "correct horse Battery 8", hash, false, false, nil},
+		"other parameters": {auth.NewHasher(stronger), "correct horse Battery 9", hash, true, true, nil},
+		"wrong algorithm":  {hasher, "x", strings.Replace(hash, "argon2id", "argon2i", 1), false, false, auth.ErrMalformedHash},
+		"zero iterations":  {hasher, "x", strings.Replace(hash, "t=1", "t=0", 1), false, false, auth.ErrMalformedHash},
+		"truncated":        {hasher, "x", hash[:20], false, false, auth.ErrMalformedHash},
+	}
+
+	for scenario, tc := range testCases { //nolint:paralleltest
+		t.Run(scenario, func(t *testing.T) {
+			match, rehash, err := tc.hasher.Verify(tc.password, tc.hash)
+			if tc.wantErr != nil {
+				assert.ErrorIs(t, err, tc.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.wantMatch, match)
+			assert.Equal(t, tc.wantRehash, rehash)
+		})
+	}
+}
@@ -0,0 +1,64 @@
+package auth
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	validation "github.com/go-ozzo/ozzo-validation/v4"
+)
+
+// PasswordPolicy is what a new password has to look like
+type PasswordPolicy struct {
+	MinLength int
+	// MaxLength bounds the work of hashing a password
+	MaxLength int
+	// MinClasses is how many of lower case letters, upper case letters, digits
+	// and other characters the password has to mix
+	MinClasses int
+}
+
+func DefaultPasswordPolicy() PasswordPolicy {
+	return PasswordPolicy{MinLength: 12, MaxLength: 128, MinClasses: 3}
+}
+
+// Validate returns validation errors keyed by "password" if password breaks the
+// policy or contains the email of its user
+func (p PasswordPolicy) Validate(password string, email *string) error {
+	return validation.Errors{"password": p.check(password, email)}.Filter()
+}
+
+func (p PasswordPolicy) check(password string, email *string) error {
+	length := utf8.RuneCountInString(password)
+	if length < p.MinLength || length > p.MaxLength {
+		return validation.ErrLengthOutOfRange.SetParams(map[string]interface{}{"min": p.MinLength, "max": p.MaxLength})
+	}
+	if classes(password) < p.MinClasses {
+		return validation.NewError("validation_password_classes", "must mix lower case letters, upper case letters, digits and symbols")
+	}
+	if email != nil {
+		local, _, _ := strings.Cut(strings.ToLower(*email), "@")
+		if len(local) >= 3 && strings.Contains(strings.ToLower(password), local) {
+			return validation.NewError("validation_password_email", "must not contain the email")
+		}
+	}
+	return nil
+}
+
+// classes counts the character classes in s
+func classes(s string) int {
+	var lower, upper, digit, other int
+	for _, r := range s {
+		switch {
+		case unicode.IsLower(r):
+			lower = 1
+		case unicode.IsUpper(r):
+			upper = 1
+		case unicode.IsDigit(r):
+			digit = 1
+		default:
+			other = 1
+		}
+	}
+	return lower + upper + digit + other
+}
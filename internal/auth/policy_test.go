@@ -0,0 +1,38 @@
+package auth_test
+
+import (
+	"go-project-template/internal/auth"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPasswordPolicy_Validate(t *testing.T) {
+	t.Parallel()
+	policy := auth.DefaultPasswordPolicy()
+	email := "john.wick@mail.com"
+
+	testCases := map[string]struct {
+		password string
+		wantErr  bool
+	}{
+		"valid":          {"correct horse Battery 9", false},
+		"symbols":        {"correct-horse-battery-9", false},
+		"too short":      {"Sh0rt!", true},
+		"too long":       {"Aa1" + strings.Repeat("x", 126), true},
+		"two classes":    {"correcthorsebattery9", true},
+		"contains email": {"John.Wick-2024!", true},
+	}
+
+	for scenario, tc := range testCases { //nolint:paralleltest
+		t.Run(scenario, func(t *testing.T) {
+			err := policy.Validate(tc.password, &email)
+			if tc.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+		})
+	}
+}
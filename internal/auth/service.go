@@ -0,0 +1,172 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"go-project-template/internal/domain"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Config tunes the hashing, the password policy and the lockout
+type Config struct {
+	Params Params
+	Policy PasswordPolicy
+	// MaxAttempts failed logins in a row lock the credential for Lockout
+	MaxAttempts int
+	Lockout     time.Duration
+}
+
+func DefaultConfig() Config {
+	return Config{
+		Params:      DefaultParams(),
+		Policy:      DefaultPasswordPolicy(),
+		MaxAttempts: 5,
+		Lockout:     15 * time.Minute,
+	}
+}
+
+// Service sets passwords and logs users in with them
+type Service struct {
+	users       domain.UserRepository
+	credentials domain.CredentialRepository
+	hasher      *Hasher
+	cfg         Config
+	now         func() time.Time
+
+	// decoy is verified for unknown emails, so they take as long to reject as
+	// wrong passwords. It is hashed on the first use.
+	decoyOnce sync.Once
+	decoy     string
+}
+
+type Option func(*Service)
+
+// WithClock sets the clock the lockout is measured with
+func WithClock(now func() time.Time) Option {
+	return func(s *Service) {
+		s.now = now
+	}
+}
+
+func NewService(users domain.UserRepository, credentials domain.CredentialRepository, cfg Config, opts ...Option) *Service {
+	s := &Service{
+		users:       users,
+		credentials: credentials,
+		hasher:      NewHasher(cfg.Params),
+		cfg:         cfg,
+		now:         time.Now,
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// SetPassword checks password against the policy and stores its hash for the user
+func (s *Service) SetPassword(ctx context.Context, userUUID uuid.UUID, password string) error {
+	u, err := s.users.GetByID(ctx, userUUID)
+	if err != nil {
+		return err
+	}
+	if err := s.cfg.Policy.Validate(password, u.Email); err != nil {
+		return err
+	}
+
+	hash, err := s.hasher.Hash(password)
+	if err != nil {
+		return err
+	}
+	return s.credentials.SetPassword(ctx, userUUID, hash)
+}
+
+// Login returns the user with the email and password. Every failed attempt is
+// counted and too many lock the credential, a locked credential is refused even
+// with the right password. Hashes made with other parameters are replaced, and
+// invited users become active with their first login.
+func (s *Service) Login(ctx context.Context, email string, password string) (domain.User, error) {
+	c, err := s.credentials.FindByEmail(ctx, email)
+	if errors.Is(err, domain.ErrNotFound) || errors.Is(err, domain.ErrConflict) {
+		_, _, _ = s.hasher.Verify(password, s.decoyHash())
+		return domain.User{}, domain.ErrInvalidCredentials
+	}
+	if err != nil {
+		return domain.User{}, err
+	}
+	if c.Locked(s.now()) {
+		return domain.User{}, domain.ErrAccountLocked
+	}
+
+	match, rehash, err := s.hasher.Verify(password, c.Hash)
+	if err != nil {
+		return domain.User{}, err
+	}
+	if !match {
+		if err := s.credentials.RecordFailure(ctx, c.UserUUID, s.cfg.MaxAttempts, s.now().Add(s.cfg.Lockout)); err != nil {
+			return domain.User{}, err
+		}
+		return domain.User{}, domain.ErrInvalidCredentials
+	}
+
+	u, err := s.users.GetByID(ctx, c.UserUUID)
+	if err != nil {
+		return domain.User{}, err
+	}
+	if u.Status == domain.UserStatusSuspended || u.Status == domain.UserStatusDeactivated {
+		return domain.User{}, domain.ErrAccountDisabled
+	}
+
+	var newHash string
+	if rehash {
+		if newHash, err = s.hasher.Hash(password); err != nil {
+			return domain.User{}, err
+		}
+	}
+	if err := s.credentials.RecordSuccess(ctx, c.UserUUID, newHash); err != nil {
+		return domain.User{}, err
+	}
+
+	if u.Status == domain.UserStatusInvited {
+		activated, err := s.users.Transition(ctx, u.UUID, domain.StatusChange{Status: domain.UserStatusActive})
+		if err != nil {
+			return domain.User{}, err
+		}
+		u = *activated
+	}
+	return u, nil
+}
+
+// Refresh returns the user of a refresh token issued at issuedAt. Tokens issued
+// before the password last changed are refused, as are users that are no
+// longer active.
+func (s *Service) Refresh(ctx context.Context, userUUID uuid.UUID, issuedAt time.Time) (domain.User, error) {
+	c, err := s.credentials.Get(ctx, userUUID)
+	if errors.Is(err, domain.ErrNotFound) {
+		return domain.User{}, domain.ErrInvalidCredentials
+	}
+	if err != nil {
+		return domain.User{}, err
+	}
+	// Tokens only carry whole seconds
+	if c.PasswordChangedAt.Truncate(time.Second).After(issuedAt) {
+		return domain.User{}, domain.ErrInvalidCredentials
+	}
+
+	u, err := s.users.GetByID(ctx, userUUID)
+	if err != nil {
+		return domain.User{}, err
+	}
+	if u.Status != domain.UserStatusActive {
+		return domain.User{}, domain.ErrAccountDisabled
+	}
+	return u, nil
+}
+
+func (s *Service) decoyHash() string {
+	s.decoyOnce.Do(func() {
+		s.decoy, _ = s.hasher.Hash("decoy password")
+	})
+	return s.decoy
+}
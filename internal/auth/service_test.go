@@ -0,0 +1,186 @@
+package auth_test
+
+import (
+	"context"
+	"go-project-template/internal/auth"
+	"go-project-template/internal/domain"
+	"go-project-template/internal/repository"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	validation "github.com/go-ozzo/ozzo-validation/v4"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const password = "correct horse Battery 9"
+
+type serviceFixture struct {
+	ctx         context.Context
+	users       domain.UserRepository
+	credentials domain.CredentialRepository
+	service     *auth.Service
+
+	mu  sync.Mutex
+	now time.Time
+}
+
+func newServiceFixture(t *testing.T, params auth.Params) *serviceFixture {
+	t.Helper()
+	f := &serviceFixture{
+		ctx:   domain.WithTenant(context.Background(), domain.DefaultTenant),
+		users: repository.NewMemoryUserRepository(),
+		now:   time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+	}
+	f.credentials = repository.NewMemoryCredentialRepository(f.users)
+
+	cfg := auth.DefaultConfig()
+	cfg.Params = params
+	f.service = auth.NewService(f.users, f.credentials, cfg, auth.WithClock(f.clock))
+	return f
+}
+
+func (f *serviceFixture) clock() time.Time {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.now
+}
+
+func (f *serviceFixture) advance(d time.Duration) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.now = f.now.Add(d)
+}
+
+// user stores a user with a password
+func (f *serviceFixture) user(t *testing.T, email string, status domain.UserStatus) *domain.User {
+	t.Helper()
+	u := &domain.User{UUID: uuid.New(), FirstName: "John", LastName: "Wick", Email: &email, Status: status}
+	_, err := f.users.CreateOrUpdate(f.ctx, u)
+	require.NoError(t, err)
+	require.NoError(t, f.service.SetPassword(f.ctx, u.UUID, password))
+	return u
+}
+
+func TestService_Login(t *testing.T) {
+	t.Parallel()
+	f := newServiceFixture(t, testParams)
+	u := f.user(t, "john@mail.com", domain.UserStatusActive)
+
+	got, err := f.service.Login(f.ctx, "john@mail.com", password)
+	require.NoError(t, err)
+	assert.Equal(t, u.UUID, got.UUID)
+
+	_, err = f.service.Login(f.ctx, "john@mail.com", "wrong horse Battery 9")
+	assert.ErrorIs(t, err, domain.ErrInvalidCredentials)
+	_, err = f.service.Login(f.ctx, "nobody@mail.com", password)
+	assert.ErrorIs(t, err, domain.ErrInvalidCredentials)
+
+	// Other tenants do not see the password
+	_, err = f.service.Login(domain.WithTenant(context.Background(), "acme"), "john@mail.com", password)
+	assert.ErrorIs(t, err, domain.ErrInvalidCredentials)
+
+	c, err := f.credentials.Get(f.ctx, u.UUID)
+	require.NoError(t, err)
+	assert.NotNil(t, c.LastLoginAt)
+}
+
+func TestService_Lockout(t *testing.T) {
+	t.Parallel()
+	f := newServiceFixture(t, testParams)
+	f.user(t, "john@mail.com", domain.UserStatusActive)
+
+	for range auth.DefaultConfig().MaxAttempts {
+		_, err := f.service.Login(f.ctx, "john@mail.com", "wrong horse Battery 9")
+		assert.ErrorIs(t, err, domain.ErrInvalidCredentials)
+	}
+
+	// The right password does not help while the credential is locked
+	_, err := f.service.Login(f.ctx, "john@mail.com", password)
+	assert.ErrorIs(t, err, domain.ErrAccountLocked)
+
+	f.advance(auth.DefaultConfig().Lockout)
+	_, err = f.service.Login(f.ctx, "john@mail.com", password)
+	assert.NoError(t, err)
+}
+
+func TestService_Rehash(t *testing.T) {
+	t.Parallel()
+	f := newServiceFixture(t, testParams)
+	u := f.user(t, "john@mail.com", domain.UserStatusActive)
+
+	stronger := testParams
+	stronger.Iterations = 2
+	cfg := auth.DefaultConfig()
+	cfg.Params = stronger
+	service := auth.NewService(f.users, f.credentials, cfg)
+
+	_, err := service.Login(f.ctx, "john@mail.com", password)
+	require.NoError(t, err)
+
+	c, err := f.credentials.Get(f.ctx, u.UUID)
+	require.NoError(t, err)
+	assert.True(t, strings.Contains(c.Hash, "t=2"), c.Hash)
+
+	// The new hash still verifies
+	_, err = service.Login(f.ctx, "john@mail.com", password)
+	assert.NoError(t, err)
+}
+
+func TestService_Status(t *testing.T) {
+	t.Parallel()
+	f := newServiceFixture(t, testParams)
+
+	invited := f.user(t, "helen@mail.com", domain.UserStatusInvited)
+	got, err := f.service.Login(f.ctx, "helen@mail.com", password)
+	require.NoError(t, err)
+	assert.Equal(t, domain.UserStatusActive, got.Status)
+
+	_, err = f.users.Transition(f.ctx, invited.UUID, domain.StatusChange{Status: domain.UserStatusSuspended, Reason: "fraud"})
+	require.NoError(t, err)
+	_, err = f.service.Login(f.ctx, "helen@mail.com", password)
+	assert.ErrorIs(t, err, domain.ErrAccountDisabled)
+	_, err = f.service.Refresh(f.ctx, invited.UUID, time.Now().Add(time.Second))
+	assert.ErrorIs(t, err, domain.ErrAccountDisabled)
+}
+
+func TestService_Refresh(t *testing.T) {
+	t.Parallel()
+	f := newServiceFixture(t, testParams)
+	u := f.user(t, "john@mail.com", domain.UserStatusActive)
+
+	issuedAt := time.Now().Add(time.Second)
+	got, err := f.service.Refresh(f.ctx, u.UUID, issuedAt)
+	require.NoError(t, err)
+	assert.Equal(t, u.UUID, got.UUID)
+
+	// Changing the password revokes the tokens issued before
+	_, err = f.service.Refresh(f.ctx, u.UUID, time.Now().Add(-time.Hour))
+	assert.ErrorIs(t, err, domain.ErrInvalidCredentials)
+
+	require.NoError(t, f.credentials.Delete(f.ctx, u.UUID))
+	_, err = f.service.Refresh(f.ctx, u.UUID, issuedAt)
+	assert.ErrorIs(t, err, domain.ErrInvalidCredentials)
+}
+
+func TestService_SetPassword(t *testing.T) {
+	t.Parallel()
+	f := newServiceFixture(t, testParams)
+	u := f.user(t, "john@mail.com", domain.UserStatusActive)
+
+	var verr validation.Errors
+	err := f.service.SetPassword(f.ctx, u.UUID, "short")
+	require.ErrorAs(t, err, &verr)
+	assert.Contains(t, verr, "password")
+
+	// Another user with the email cannot get a password, logins would not know whose it is
+	other := &domain.User{UUID: uuid.New(), FirstName: "Jonathan", LastName: "Wick", Email: u.Email}
+	_, err = f.users.CreateOrUpdate(f.ctx, other)
+	require.NoError(t, err)
+	assert.ErrorIs(t, f.service.SetPassword(f.ctx, other.UUID, password), domain.ErrConflict)
+
+	assert.ErrorIs(t, f.service.SetPassword(f.ctx, uuid.New(), password), domain.ErrNotFound)
+}
@@ -0,0 +1,99 @@
+package cache_test
+
+import (
+	"context"
+	"go-project-template/internal/cache"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLRU(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	c := cache.NewLRU(2)
+
+	require.NoError(t, c.Set(ctx, "a", []byte("1"), time.Minute))
+	require.NoError(t, c.Set(ctx, "b", []byte("2"), time.Minute))
+
+	// Reading a makes b the least recently used
+	v, ok, err := c.Get(ctx, "a")
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, []byte("1"), v)
+
+	require.NoError(t, c.Set(ctx, "c", []byte("3"), time.Minute))
+	assert.Equal(t, 2, c.Len())
+
+	testCases := map[string]struct {
+		key  string
+		want bool
+	}{
+		"kept":    {"a", true},
+		"evicted": {"b", false},
+		"added":   {"c", true},
+	}
+
+	for scenario, tc := range testCases { //nolint:paralleltest
+		t.Run(scenario, func(t *testing.T) {
+			_, ok, err := c.Get(ctx, tc.key)
+			require.NoError(t, err)
+			assert.Equal(t, tc.want, ok)
+		})
+	}
+
+	require.NoError(t, c.Delete(ctx, "a", "missing"))
+	_, ok, _ = c.Get(ctx, "a")
+	assert.False(t, ok)
+
+	c.Purge()
+	assert.Equal(t, 0, c.Len())
+}
+
+func TestLRU_TTL(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	c := cache.NewLRU(10)
+
+	require.NoError(t, c.Set(ctx, "short", []byte("1"), 10*time.Millisecond))
+	require.NoError(t, c.Set(ctx, "long", []byte("2"), time.Minute))
+	time.Sleep(20 * time.Millisecond)
+
+	_, ok, _ := c.Get(ctx, "short")
+	assert.False(t, ok)
+	_, ok, _ = c.Get(ctx, "long")
+	assert.True(t, ok)
+	assert.Equal(t, 1, c.Len())
+}
+
+func TestLRU_Conditional(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	c := cache.NewLRU(10)
+
+	ok, err := c.Add(ctx, "a", []byte("lease"), time.Minute)
+	require.NoError(t, err)
+	assert.True(t, ok)
+	ok, err = c.Add(ctx, "a", []byte("other"), time.Minute)
+	require.NoError(t, err)
+	assert.False(t, ok, "a key that holds a value is not added again")
+
+	ok, err = c.Replace(ctx, "a", []byte("other"), []byte("1"), time.Minute)
+	require.NoError(t, err)
+	assert.False(t, ok, "a key that holds another value is not replaced")
+	ok, err = c.Replace(ctx, "a", []byte("lease"), []byte("1"), time.Minute)
+	require.NoError(t, err)
+	assert.True(t, ok)
+
+	v, _, err := c.Get(ctx, "a")
+	require.NoError(t, err)
+	assert.Equal(t, []byte("1"), v)
+
+	// A deleted key is not replaced
+	require.NoError(t, c.Delete(ctx, "a"))
+	ok, err = c.Replace(ctx, "a", []byte("1"), []byte("2"), time.Minute)
+	require.NoError(t, err)
+	assert.False(t, ok)
+}
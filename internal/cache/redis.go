@@ -0,0 +1,242 @@
+package cache
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const redisTimeout = 2 * time.Second
+
+// replaceScript sets KEYS[1] to ARGV[2] with a TTL of ARGV[3] milliseconds if
+// it still holds ARGV[1]
+const replaceScript = `if redis.call('GET', KEYS[1]) == ARGV[1] then redis.call('SET', KEYS[1], ARGV[2], 'PX', ARGV[3]) return 1 end return 0`
+
+// Redis is a [Store] on a server speaking the Redis protocol, shared by every
+// replica. It speaks just enough of the protocol for GET, SET, DEL and EVAL over
+// a single connection, which is opened on first use and again after any error.
+type Redis struct {
+	addr     string
+	user     *url.Userinfo
+	database int
+
+	mu   sync.Mutex
+	conn net.Conn
+	r    *bufio.Reader
+}
+
+// NewRedis returns a [Redis] store for a redis://[user:pass@]host:port[/db] URL
+func NewRedis(rawURL string) (*Redis, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme != "redis" {
+		return nil, fmt.Errorf("unsupported Redis url scheme %q", u.Scheme)
+	}
+
+	addr := u.Host
+	if u.Port() == "" {
+		addr = net.JoinHostPort(u.Hostname(), "6379")
+	}
+
+	database := 0
+	if path := strings.TrimPrefix(u.Path, "/"); path != "" {
+		if database, err = strconv.Atoi(path); err != nil {
+			return nil, fmt.Errorf("invalid Redis database %q", path)
+		}
+	}
+
+	return &Redis{addr: addr, user: u.User, database: database}, nil
+}
+
+func (c *Redis) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	reply, err := c.do(ctx, "GET", key)
+	if err != nil {
+		return nil, false, err
+	}
+	if reply == nil {
+		return nil, false, nil
+	}
+	return reply.([]byte), true, nil
+}
+
+func (c *Redis) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	_, err := c.do(ctx, "SET", key, string(value), "PX", strconv.FormatInt(ttl.Milliseconds(), 10))
+	return err
+}
+
+func (c *Redis) Add(ctx context.Context, key string, value []byte, ttl time.Duration) (bool, error) {
+	reply, err := c.do(ctx, "SET", key, string(value), "PX", strconv.FormatInt(ttl.Milliseconds(), 10), "NX")
+	if err != nil {
+		return false, err
+	}
+	// A key that is already set replies with null
+	return reply != nil, nil
+}
+
+func (c *Redis) Replace(ctx context.Context, key string, old, value []byte, ttl time.Duration) (bool, error) {
+	reply, err := c.do(ctx, "EVAL", replaceScript, "1", key, string(old), string(value), strconv.FormatInt(ttl.Milliseconds(), 10))
+	if err != nil {
+		return false, err
+	}
+	return reply == "1", nil
+}
+
+func (c *Redis) Delete(ctx context.Context, keys ...string) error {
+	if len(keys) == 0 {
+		return nil
+	}
+	_, err := c.do(ctx, "DEL", keys...)
+	return err
+}
+
+func (c *Redis) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.close()
+	return nil
+}
+
+// do sends a command and reads its reply, reconnecting first if needed
+func (c *Redis) do(ctx context.Context, cmd string, args ...string) (interface{}, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.conn == nil {
+		if err := c.connect(ctx); err != nil {
+			return nil, err
+		}
+	}
+
+	reply, err := c.roundTrip(ctx, cmd, args...)
+	var rerr redisError
+	if err != nil && !errors.As(err, &rerr) {
+		// The connection is in an unknown state, start over on the next command
+		c.close()
+	}
+	return reply, err
+}
+
+func (c *Redis) connect(ctx context.Context) error {
+	dialer := net.Dialer{Deadline: c.deadline(ctx)}
+	conn, err := dialer.DialContext(ctx, "tcp", c.addr)
+	if err != nil {
+		return err
+	}
+	c.conn = conn
+	c.r = bufio.NewReader(conn)
+
+	if err := c.handshake(ctx); err != nil {
+		c.close()
+		return err
+	}
+	return nil
+}
+
+func (c *Redis) handshake(ctx context.Context) error {
+	if c.user != nil {
+		args := []string{c.user.Username()}
+		if pass, ok := c.user.Password(); ok {
+			args = append(args, pass)
+			// Servers before Redis 6 only know the password
+			if args[0] == "" {
+				args = args[1:]
+			}
+		}
+		if _, err := c.roundTrip(ctx, "AUTH", args...); err != nil {
+			return err
+		}
+	}
+
+	if c.database != 0 {
+		if _, err := c.roundTrip(ctx, "SELECT", strconv.Itoa(c.database)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *Redis) close() {
+	if c.conn != nil {
+		_ = c.conn.Close()
+	}
+	c.conn = nil
+	c.r = nil
+}
+
+func (c *Redis) deadline(ctx context.Context) time.Time {
+	d := time.Now().Add(redisTimeout)
+	if cd, ok := ctx.Deadline(); ok && cd.Before(d) {
+		return cd
+	}
+	return d
+}
+
+func (c *Redis) roundTrip(ctx context.Context, cmd string, args ...string) (interface{}, error) {
+	if err := c.conn.SetDeadline(c.deadline(ctx)); err != nil {
+		return nil, err
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "*%d\r\n$%d\r\n%s\r\n", len(args)+1, len(cmd), cmd)
+	for _, arg := range args {
+		fmt.Fprintf(&b, "$%d\r\n%s\r\n", len(arg), arg)
+	}
+	if _, err := io.WriteString(c.conn, b.String()); err != nil {
+		return nil, err
+	}
+
+	return c.readReply()
+}
+
+// redisError is an error reply, the connection stays usable after it
+type redisError string
+
+func (e redisError) Error() string {
+	return "redis: " + string(e)
+}
+
+// readReply reads a reply: simple strings and integers as strings, bulk strings
+// as []byte and null as nil. None of the commands sent return arrays.
+func (c *Redis) readReply() (interface{}, error) {
+	line, err := c.r.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	line = strings.TrimRight(line, "\r\n")
+	if line == "" {
+		return nil, errors.New("empty Redis reply")
+	}
+
+	switch line[0] {
+	case '+', ':':
+		return line[1:], nil
+	case '-':
+		return nil, redisError(line[1:])
+	case '$':
+		n, err := strconv.Atoi(line[1:])
+		if err != nil {
+			return nil, err
+		}
+		if n < 0 {
+			return nil, nil
+		}
+		buf := make([]byte, n+2)
+		if _, err := io.ReadFull(c.r, buf); err != nil {
+			return nil, err
+		}
+		return buf[:n], nil
+	default:
+		return nil, fmt.Errorf("unexpected Redis reply %q", line)
+	}
+}
@@ -0,0 +1,217 @@
+package cache_test
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"go-project-template/internal/cache"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeRedis serves GET, SET, DEL, AUTH and SELECT from a map and records every
+// command. EVAL runs the compare-and-set script of Redis.Replace.
+type fakeRedis struct {
+	ln net.Listener
+
+	mu       sync.Mutex
+	values   map[string]string
+	commands []string
+}
+
+func newFakeRedis(t *testing.T) *fakeRedis {
+	t.Helper()
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = ln.Close() })
+
+	f := &fakeRedis{ln: ln, values: map[string]string{}}
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go f.serve(conn)
+		}
+	}()
+	return f
+}
+
+func (f *fakeRedis) serve(conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+
+	for {
+		args, err := readCommand(r)
+		if err != nil {
+			return
+		}
+
+		f.mu.Lock()
+		f.commands = append(f.commands, strings.Join(args, " "))
+		var reply string
+		switch strings.ToUpper(args[0]) {
+		case "GET":
+			if v, ok := f.values[args[1]]; ok {
+				reply = fmt.Sprintf("$%d\r\n%s\r\n", len(v), v)
+			} else {
+				reply = "$-1\r\n"
+			}
+		case "SET":
+			if _, ok := f.values[args[1]]; ok && strings.EqualFold(args[len(args)-1], "NX") {
+				reply = "$-1\r\n"
+				break
+			}
+			f.values[args[1]] = args[2]
+			reply = "+OK\r\n"
+		case "EVAL":
+			reply = ":0\r\n"
+			if v, ok := f.values[args[3]]; ok && v == args[4] {
+				f.values[args[3]] = args[5]
+				reply = ":1\r\n"
+			}
+		case "DEL":
+			n := 0
+			for _, k := range args[1:] {
+				if _, ok := f.values[k]; ok {
+					delete(f.values, k)
+					n++
+				}
+			}
+			reply = fmt.Sprintf(":%d\r\n", n)
+		case "AUTH", "SELECT":
+			reply = "+OK\r\n"
+		default:
+			reply = "-ERR unknown command\r\n"
+		}
+		f.mu.Unlock()
+
+		if _, err := io.WriteString(conn, reply); err != nil {
+			return
+		}
+	}
+}
+
+func readCommand(r *bufio.Reader) ([]string, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	n, err := strconv.Atoi(strings.TrimSpace(strings.TrimPrefix(line, "*")))
+	if err != nil {
+		return nil, err
+	}
+
+	args := make([]string, n)
+	for i := range args {
+		if _, err := r.ReadString('\n'); err != nil {
+			return nil, err
+		}
+		arg, err := r.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		args[i] = strings.TrimRight(arg, "\r\n")
+	}
+	return args, nil
+}
+
+func (f *fakeRedis) recorded() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]string(nil), f.commands...)
+}
+
+func TestRedis(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	server := newFakeRedis(t)
+
+	store, err := cache.NewRedis("redis://:secret@" + server.ln.Addr().String() + "/2")
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = store.Close() })
+
+	_, ok, err := store.Get(ctx, "user:1")
+	require.NoError(t, err)
+	assert.False(t, ok)
+
+	require.NoError(t, store.Set(ctx, "user:1", []byte(`{"first_name":"john"}`), 1500*time.Millisecond))
+	v, ok, err := store.Get(ctx, "user:1")
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, `{"first_name":"john"}`, string(v))
+
+	require.NoError(t, store.Delete(ctx, "user:1", "user:2"))
+	_, ok, err = store.Get(ctx, "user:1")
+	require.NoError(t, err)
+	assert.False(t, ok)
+
+	added, err := store.Add(ctx, "user:1", []byte("lease"), time.Second)
+	require.NoError(t, err)
+	assert.True(t, added)
+	added, err = store.Add(ctx, "user:1", []byte("lease"), time.Second)
+	require.NoError(t, err)
+	assert.False(t, added)
+
+	replaced, err := store.Replace(ctx, "user:1", []byte("other"), []byte("null"), time.Second)
+	require.NoError(t, err)
+	assert.False(t, replaced)
+	replaced, err = store.Replace(ctx, "user:1", []byte("lease"), []byte("null"), time.Second)
+	require.NoError(t, err)
+	assert.True(t, replaced)
+
+	recorded := server.recorded()
+	require.Len(t, recorded, 11)
+	// The script itself is left to the EVAL handler of the fake
+	for i, suffix := range []string{" 1 user:1 other null 1000", " 1 user:1 lease null 1000"} {
+		assert.True(t, strings.HasPrefix(recorded[9+i], "EVAL "), recorded[9+i])
+		assert.True(t, strings.HasSuffix(recorded[9+i], suffix), recorded[9+i])
+	}
+
+	assert.Equal(t, []string{
+		"AUTH secret",
+		"SELECT 2",
+		"GET user:1",
+		`SET user:1 {"first_name":"john"} PX 1500`,
+		"GET user:1",
+		"DEL user:1 user:2",
+		"GET user:1",
+		"SET user:1 lease PX 1000 NX",
+		"SET user:1 lease PX 1000 NX",
+	}, recorded[:9])
+}
+
+func TestNewRedis(t *testing.T) {
+	t.Parallel()
+
+	testCases := map[string]struct {
+		url string
+		err bool
+	}{
+		"default port":  {"redis://localhost", false},
+		"database":      {"redis://localhost:6380/3", false},
+		"scheme":        {"http://localhost:6379", true},
+		"database name": {"redis://localhost:6379/users", true},
+	}
+
+	for scenario, tc := range testCases { //nolint:paralleltest
+		t.Run(scenario, func(t *testing.T) {
+			_, err := cache.NewRedis(tc.url)
+			if tc.err {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+		})
+	}
+}
@@ -0,0 +1,277 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"go-project-template/internal/domain"
+	"go-project-template/internal/repository"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+	"golang.org/x/sync/singleflight"
+)
+
+// notFound is cached for users that do not exist
+var notFound = []byte("null")
+
+// A load holds a lease on its key while it reads the database, for up to
+// LoadTimeout. Evicting the key drops the lease, so a load that raced a write
+// finds it gone and caches nothing.
+const leasePrefix = "lease:"
+
+// Listener calls fn for every notification on a channel until ctx is done or it fails
+type Listener interface {
+	Listen(ctx context.Context, channel string, fn func(payload string)) error
+}
+
+type Config struct {
+	// TTL is how long a user is cached
+	TTL time.Duration
+	// NegativeTTL is how long a missing user is remembered
+	NegativeTTL time.Duration
+	// Prefix namespaces the keys in a shared store
+	Prefix string
+	// Channel carries the changes made by other replicas
+	Channel string
+	// RetryInterval is the wait before the listener reconnects
+	RetryInterval time.Duration
+	// LoadTimeout bounds a shared database read, which outlives the callers
+	// that gave up on it
+	LoadTimeout time.Duration
+}
+
+func DefaultConfig() Config {
+	return Config{
+		TTL:           5 * time.Minute,
+		NegativeTTL:   30 * time.Second,
+		Prefix:        "user:",
+		Channel:       "user_changes",
+		RetryInterval: 1 * time.Second,
+		LoadTimeout:   5 * time.Second,
+	}
+}
+
+// UserRepository reads users by ID through a [Store]. Concurrent misses for the
+// same user share a single database read, and users that do not exist are cached
+// too. Writes made through it evict the user right away, writes made elsewhere
+// are evicted when their notification arrives, see [UserRepository.Listen].
+// Lists and streams always go to the database.
+type UserRepository struct {
+	domain.UserRepository
+
+	store  Store
+	logger *zap.Logger
+	cfg    Config
+
+	group singleflight.Group
+}
+
+func NewUserRepository(next domain.UserRepository, store Store, logger *zap.Logger, cfg Config) *UserRepository {
+	return &UserRepository{UserRepository: next, store: store, logger: logger, cfg: cfg}
+}
+
+// key is scoped to the tenant, so a miss of one tenant never hides the user of another
+func (r *UserRepository) key(tenant string, id uuid.UUID) string {
+	return r.cfg.Prefix + tenant + ":" + id.String()
+}
+
+func (r *UserRepository) GetByID(ctx context.Context, id uuid.UUID) (domain.User, error) {
+	tenant, err := domain.TenantFromContext(ctx)
+	if err != nil {
+		return domain.User{}, err
+	}
+	key := r.key(tenant, id)
+
+	value, ok, err := r.store.Get(ctx, key)
+	if err != nil {
+		// The database can still answer, an unavailable cache only makes it slower
+		r.logger.Warn("user cache read failed", zap.String("key", key), zap.Error(err))
+	}
+	// Another load holds a lease, read the database without waiting for it
+	if ok && !strings.HasPrefix(string(value), leasePrefix) {
+		return decodeUser(value)
+	}
+
+	// The shared read is not cancelled when the first caller gives up, but it
+	// does not hang on a stalled database either
+	ch := r.group.DoChan(key, func() (interface{}, error) {
+		ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), r.cfg.LoadTimeout)
+		defer cancel()
+		return r.load(ctx, key, id)
+	})
+
+	select {
+	case <-ctx.Done():
+		return domain.User{}, ctx.Err()
+	case res := <-ch:
+		if res.Err != nil {
+			return domain.User{}, res.Err
+		}
+		return decodeUser(res.Val.([]byte))
+	}
+}
+
+// load reads the user from the database and caches the outcome unless the key
+// was evicted in the meantime
+func (r *UserRepository) load(ctx context.Context, key string, id uuid.UUID) ([]byte, error) {
+	lease := []byte(leasePrefix + uuid.NewString())
+	leased, err := r.store.Add(ctx, key, lease, r.cfg.LoadTimeout)
+	if err != nil {
+		r.logger.Warn("user cache lease failed", zap.String("key", key), zap.Error(err))
+	}
+
+	// A replica may still serve the row the write evicted, which would stay cached for the TTL
+	value, ttl := notFound, r.cfg.NegativeTTL
+	u, err := r.UserRepository.GetByID(repository.WithPrimary(ctx), id)
+	switch {
+	case errors.Is(err, domain.ErrNotFound):
+	case err != nil:
+		return nil, err
+	default:
+		if value, err = json.Marshal(u); err != nil {
+			return nil, err
+		}
+		ttl = r.cfg.TTL
+	}
+
+	if leased {
+		if _, err := r.store.Replace(ctx, key, lease, value, ttl); err != nil {
+			r.logger.Warn("user cache write failed", zap.String("user#uuid", id.String()), zap.Error(err))
+		}
+	}
+	return value, nil
+}
+
+func decodeUser(value []byte) (domain.User, error) {
+	var u *domain.User
+	if err := json.Unmarshal(value, &u); err != nil {
+		return domain.User{}, err
+	}
+	if u == nil {
+		return domain.User{}, domain.ErrNotFound
+	}
+	return *u, nil
+}
+
+// Invalidate evicts users of the tenant of ctx so the next read goes to the database
+func (r *UserRepository) Invalidate(ctx context.Context, ids ...uuid.UUID) {
+	tenant, err := domain.TenantFromContext(ctx)
+	if err != nil {
+		r.logger.Error("user cache eviction without a tenant", zap.Error(err))
+		return
+	}
+
+	keys := make([]string, len(ids))
+	for i, id := range ids {
+		keys[i] = r.key(tenant, id)
+		// Later reads must not join a read that started before the write
+		r.group.Forget(keys[i])
+	}
+
+	if err := r.store.Delete(ctx, keys...); err != nil {
+		r.logger.Error("user cache eviction failed", zap.Strings("keys", keys), zap.Error(err))
+	}
+}
+
+func (r *UserRepository) Create(ctx context.Context, u *domain.User) (*domain.User, error) {
+	res, err := r.UserRepository.Create(ctx, u)
+	if err == nil {
+		r.Invalidate(ctx, u.UUID)
+	}
+	return res, err
+}
+
+func (r *UserRepository) CreateOrUpdate(ctx context.Context, u *domain.User) (*domain.User, error) {
+	res, err := r.UserRepository.CreateOrUpdate(ctx, u)
+	if err == nil {
+		r.Invalidate(ctx, u.UUID)
+	}
+	return res, err
+}
+
+func (r *UserRepository) BatchCreateOrUpdate(ctx context.Context, uu []*domain.User, mode domain.BatchMode) ([]domain.BatchItemResult, error) {
+	results, err := r.UserRepository.BatchCreateOrUpdate(ctx, uu, mode)
+
+	var ids []uuid.UUID
+	for _, res := range results {
+		if res.Status == domain.BatchStatusOK {
+			ids = append(ids, res.UUID)
+		}
+	}
+	if len(ids) > 0 {
+		r.Invalidate(ctx, ids...)
+	}
+	return results, err
+}
+
+func (r *UserRepository) Delete(ctx context.Context, id uuid.UUID) error {
+	err := r.UserRepository.Delete(ctx, id)
+	if err == nil {
+		r.Invalidate(ctx, id)
+	}
+	return err
+}
+
+func (r *UserRepository) DeleteAll(ctx context.Context) ([]uuid.UUID, error) {
+	ids, err := r.UserRepository.DeleteAll(ctx)
+	if len(ids) > 0 {
+		r.Invalidate(ctx, ids...)
+	}
+	return ids, err
+}
+
+func (r *UserRepository) Backdate(ctx context.Context, uu []*domain.User) error {
+	err := r.UserRepository.Backdate(ctx, uu)
+	if err == nil && len(uu) > 0 {
+		ids := make([]uuid.UUID, len(uu))
+		for i, u := range uu {
+			ids[i] = u.UUID
+		}
+		r.Invalidate(ctx, ids...)
+	}
+	return err
+}
+
+func (r *UserRepository) Transition(ctx context.Context, id uuid.UUID, c domain.StatusChange) (*domain.User, error) {
+	res, err := r.UserRepository.Transition(ctx, id, c)
+	if err == nil {
+		r.Invalidate(ctx, id)
+	}
+	return res, err
+}
+
+// Listen evicts users changed by other replicas until ctx is done. Notifications
+// sent while the listener reconnects are lost, so an in-process store is purged
+// after every reconnect, a shared store relies on the TTL.
+func (r *UserRepository) Listen(ctx context.Context, listener Listener) {
+	for {
+		err := listener.Listen(ctx, r.cfg.Channel, func(payload string) {
+			var change struct {
+				UUID     uuid.UUID `json:"uuid"`
+				TenantID string    `json:"tenant_id"`
+			}
+			if err := json.Unmarshal([]byte(payload), &change); err != nil {
+				r.logger.Warn("invalid user change notification", zap.String("payload", payload), zap.Error(err))
+				return
+			}
+			r.Invalidate(domain.WithTenant(ctx, change.TenantID), change.UUID)
+		})
+		if ctx.Err() != nil {
+			return
+		}
+		r.logger.Error("user cache listener failed", zap.Error(err))
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(r.cfg.RetryInterval):
+		}
+
+		if p, ok := r.store.(Purger); ok {
+			p.Purge()
+		}
+	}
+}
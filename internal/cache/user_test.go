@@ -0,0 +1,302 @@
+package cache_test
+
+import (
+	"context"
+	"go-project-template/internal/cache"
+	"go-project-template/internal/domain"
+	"go-project-template/internal/repository"
+	"go-project-template/internal/repository/repositorytest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+// countingRepository counts the reads that reach the wrapped repository and
+// holds them until release is closed, if set
+type countingRepository struct {
+	domain.UserRepository
+	reads   atomic.Int32
+	release chan struct{}
+}
+
+func (c *countingRepository) GetByID(ctx context.Context, id uuid.UUID) (domain.User, error) {
+	c.reads.Add(1)
+	if c.release != nil {
+		<-c.release
+	}
+	return c.UserRepository.GetByID(ctx, id)
+}
+
+func newCachedRepository(t *testing.T) (*cache.UserRepository, *countingRepository) {
+	t.Helper()
+
+	next := &countingRepository{UserRepository: repository.NewMemoryUserRepository()}
+	return cache.NewUserRepository(next, cache.NewLRU(100), zap.NewNop(), cache.DefaultConfig()), next
+}
+
+func tenantContext(tenant string) context.Context {
+	return domain.WithTenant(context.Background(), tenant)
+}
+
+func newUser() *domain.User {
+	email := "jwick@mail.com"
+	return &domain.User{UUID: uuid.New(), FirstName: "John", LastName: "Wick", Email: &email}
+}
+
+func TestUserRepository_Conformance(t *testing.T) {
+	t.Parallel()
+	repositorytest.Run(t, func(t *testing.T) repositorytest.Repositories {
+		repo, _ := newCachedRepository(t)
+		return repositorytest.Repositories{Users: repo}
+	})
+}
+
+func TestUserRepository_ReadThrough(t *testing.T) {
+	t.Parallel()
+	ctx := tenantContext("acme")
+	repo, next := newCachedRepository(t)
+
+	u := newUser()
+	_, err := repo.CreateOrUpdate(ctx, u)
+	require.NoError(t, err)
+
+	for range 3 {
+		got, err := repo.GetByID(ctx, u.UUID)
+		require.NoError(t, err)
+		assert.Equal(t, "john", got.FirstName)
+	}
+	assert.Equal(t, int32(1), next.reads.Load())
+
+	// Missing users are cached too
+	missing := uuid.New()
+	for range 3 {
+		_, err := repo.GetByID(ctx, missing)
+		assert.ErrorIs(t, err, domain.ErrNotFound)
+	}
+	assert.Equal(t, int32(2), next.reads.Load())
+}
+
+func TestUserRepository_Tenants(t *testing.T) {
+	t.Parallel()
+	acme, globex := tenantContext("acme"), tenantContext("globex")
+	repo, next := newCachedRepository(t)
+
+	u := newUser()
+	_, err := repo.CreateOrUpdate(acme, u)
+	require.NoError(t, err)
+
+	// The miss of another tenant is cached apart from the user
+	for range 2 {
+		_, err = repo.GetByID(globex, u.UUID)
+		assert.ErrorIs(t, err, domain.ErrNotFound)
+	}
+	got, err := repo.GetByID(acme, u.UUID)
+	require.NoError(t, err)
+	assert.Equal(t, "acme", got.TenantID)
+	assert.Equal(t, int32(2), next.reads.Load())
+
+	_, err = repo.GetByID(context.Background(), u.UUID)
+	assert.ErrorIs(t, err, domain.ErrTenantRequired)
+}
+
+func TestUserRepository_Invalidation(t *testing.T) {
+	t.Parallel()
+	ctx := tenantContext("acme")
+
+	testCases := map[string]struct {
+		write func(repo domain.UserRepository, u *domain.User) error
+		err   error
+		name  string
+	}{
+		"update": {func(repo domain.UserRepository, u *domain.User) error {
+			u.FirstName = "Jonathan"
+			_, err := repo.CreateOrUpdate(ctx, u)
+			return err
+		}, nil, "jonathan"},
+		"batch": {func(repo domain.UserRepository, u *domain.User) error {
+			u.FirstName = "Jonathan"
+			_, err := repo.BatchCreateOrUpdate(ctx, []*domain.User{u}, domain.BatchModeAtomic)
+			return err
+		}, nil, "jonathan"},
+		"delete": {func(repo domain.UserRepository, u *domain.User) error {
+			return repo.Delete(ctx, u.UUID)
+		}, domain.ErrNotFound, ""},
+	}
+
+	for scenario, tc := range testCases { //nolint:paralleltest
+		t.Run(scenario, func(t *testing.T) {
+			repo, _ := newCachedRepository(t)
+
+			u := newUser()
+			_, err := repo.CreateOrUpdate(ctx, u)
+			require.NoError(t, err)
+			_, err = repo.GetByID(ctx, u.UUID)
+			require.NoError(t, err)
+
+			require.NoError(t, tc.write(repo, u))
+
+			got, err := repo.GetByID(ctx, u.UUID)
+			if tc.err != nil {
+				assert.ErrorIs(t, err, tc.err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.name, got.FirstName)
+		})
+	}
+}
+
+func TestUserRepository_Singleflight(t *testing.T) {
+	t.Parallel()
+	ctx := tenantContext("acme")
+	repo, next := newCachedRepository(t)
+
+	u := newUser()
+	_, err := repo.CreateOrUpdate(ctx, u)
+	require.NoError(t, err)
+
+	next.release = make(chan struct{})
+
+	var wg sync.WaitGroup
+	for range 10 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			got, err := repo.GetByID(ctx, u.UUID)
+			assert.NoError(t, err)
+			assert.Equal(t, u.UUID, got.UUID)
+		}()
+	}
+
+	// Give every reader the chance to join the read in flight
+	require.Eventually(t, func() bool { return next.reads.Load() == 1 }, time.Second, time.Millisecond)
+	time.Sleep(20 * time.Millisecond)
+	close(next.release)
+	wg.Wait()
+
+	assert.Equal(t, int32(1), next.reads.Load())
+}
+
+func TestUserRepository_StaleRead(t *testing.T) {
+	t.Parallel()
+	ctx := tenantContext("acme")
+	repo, next := newCachedRepository(t)
+
+	u := newUser()
+	_, err := repo.CreateOrUpdate(ctx, u)
+	require.NoError(t, err)
+
+	// A read that started before a write does not cache what it read
+	next.release = make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		_, err := repo.GetByID(ctx, u.UUID)
+		assert.NoError(t, err)
+	}()
+	require.Eventually(t, func() bool { return next.reads.Load() == 1 }, time.Second, time.Millisecond)
+
+	repo.Invalidate(ctx, u.UUID)
+	close(next.release)
+	<-done
+
+	_, err = repo.GetByID(ctx, u.UUID)
+	require.NoError(t, err)
+	assert.Equal(t, int32(2), next.reads.Load())
+}
+
+func TestUserRepository_UnrelatedEviction(t *testing.T) {
+	t.Parallel()
+	ctx := tenantContext("acme")
+	repo, next := newCachedRepository(t)
+
+	u := newUser()
+	_, err := repo.CreateOrUpdate(ctx, u)
+	require.NoError(t, err)
+
+	// Evicting another user while the read runs does not keep it from being cached
+	next.release = make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		_, err := repo.GetByID(ctx, u.UUID)
+		assert.NoError(t, err)
+	}()
+	require.Eventually(t, func() bool { return next.reads.Load() == 1 }, time.Second, time.Millisecond)
+
+	repo.Invalidate(ctx, uuid.New())
+	close(next.release)
+	<-done
+
+	_, err = repo.GetByID(ctx, u.UUID)
+	require.NoError(t, err)
+	assert.Equal(t, int32(1), next.reads.Load())
+}
+
+// stalledRepository reads users like a database that stopped answering
+type stalledRepository struct {
+	domain.UserRepository
+}
+
+func (stalledRepository) GetByID(ctx context.Context, _ uuid.UUID) (domain.User, error) {
+	<-ctx.Done()
+	return domain.User{}, ctx.Err()
+}
+
+func TestUserRepository_LoadTimeout(t *testing.T) {
+	t.Parallel()
+
+	cfg := cache.DefaultConfig()
+	cfg.LoadTimeout = 20 * time.Millisecond
+	repo := cache.NewUserRepository(stalledRepository{repository.NewMemoryUserRepository()}, cache.NewLRU(10), zap.NewNop(), cfg)
+
+	// The caller has no deadline, the shared read gives up on its own
+	_, err := repo.GetByID(tenantContext("acme"), uuid.New())
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+}
+
+// fakeListener delivers the notifications sent on its channel
+type fakeListener struct {
+	notifications chan string
+}
+
+func (f *fakeListener) Listen(ctx context.Context, _ string, fn func(string)) error {
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case payload := <-f.notifications:
+			fn(payload)
+		}
+	}
+}
+
+func TestUserRepository_Listen(t *testing.T) {
+	t.Parallel()
+	ctx, cancel := context.WithCancel(tenantContext("acme"))
+	defer cancel()
+
+	repo, next := newCachedRepository(t)
+	listener := &fakeListener{notifications: make(chan string)}
+	go repo.Listen(ctx, listener)
+
+	u := newUser()
+	_, err := repo.CreateOrUpdate(ctx, u)
+	require.NoError(t, err)
+	_, err = repo.GetByID(ctx, u.UUID)
+	require.NoError(t, err)
+
+	// Another replica changed the user
+	listener.notifications <- `{"op": "update", "uuid": "` + u.UUID.String() + `", "tenant_id": "acme"}`
+	listener.notifications <- `not json`
+
+	_, err = repo.GetByID(ctx, u.UUID)
+	require.NoError(t, err)
+	assert.Equal(t, int32(2), next.reads.Load())
+}
@@ -28,14 +28,23 @@ func APICmd(ctx context.Context) *cobra.Command {
 			logger := cmdutil.NewLogger("nuop_api")
 			defer func() { _ = logger.Sync() }()
 
-			db, err := cmdutil.NewDatabasePool(ctx)
+			db, err := cmdutil.NewDatabasePool(ctx, logger)
 			if err != nil {
 				fmt.Printf("DB Conn error %v", err)
 				return err
 			}
 			defer db.Close()
 
-			api := api.NewAPI(ctx, logger, db)
+			replicas, err := cmdutil.NewDatabaseReplicaPools(ctx, logger)
+			if err != nil {
+				fmt.Printf("DB Replica Conn error %v", err)
+				return err
+			}
+			for _, replica := range replicas {
+				defer replica.Close()
+			}
+
+			api := api.NewAPI(ctx, logger, db, replicas...)
 			srv := api.Server(port)
 
 			go func() { _ = srv.ListenAndServe() }()
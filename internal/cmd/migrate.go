@@ -0,0 +1,53 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"go-project-template/cmdutil"
+	"os"
+
+	"github.com/spf13/cobra"
+	"go.uber.org/zap"
+)
+
+func MigrateCmd(ctx context.Context) *cobra.Command {
+	var files []string
+
+	cmd := &cobra.Command{
+		Use:   "migrate",
+		Args:  cobra.ExactArgs(0),
+		Short: "Brings the database schema up to date.",
+		Long: `Applies the schema files to the database of DATABASE_CONNECTION_POOL_URL.
+
+Postgres only runs docker/provision/init.sql when it creates the database. The
+file only creates what is missing and adds the columns of later versions to
+existing tables, so applying it again upgrades a database provisioned by an
+older version. Add --file docker/provision/rls.sql to refresh the row-level
+security policies too.`,
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			db, err := cmdutil.NewDatabasePool(ctx, zap.NewNop())
+			if err != nil {
+				return err
+			}
+			defer db.Close()
+
+			for _, file := range files {
+				ddl, err := os.ReadFile(file)
+				if err != nil {
+					return err
+				}
+				// Without arguments the statements are sent at once over the simple protocol
+				if _, err := db.Exec(ctx, string(ddl)); err != nil {
+					return fmt.Errorf("applying %s: %w", file, err)
+				}
+				fmt.Fprintf(cmd.OutOrStdout(), "applied %s\n", file)
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().StringSliceVar(&files, "file", []string{"docker/provision/init.sql"}, "schema files applied in order")
+
+	return cmd
+}
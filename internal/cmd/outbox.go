@@ -0,0 +1,109 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"go-project-template/cmdutil"
+	"go-project-template/internal/outbox"
+	"go-project-template/internal/repository"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/spf13/cobra"
+	"go.uber.org/zap"
+)
+
+// outboxLockName identifies the advisory lock held by the leading relay
+const outboxLockName = "project.outbox_relay"
+
+func OutboxCmd(ctx context.Context) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "outbox",
+		Short: "Manages the outbox of domain events.",
+	}
+
+	cmd.AddCommand(outboxRelayCmd(ctx))
+
+	return cmd
+}
+
+func outboxRelayCmd(ctx context.Context) *cobra.Command {
+	cfg := outbox.DefaultConfig()
+	publisher := envOr("OUTBOX_PUBLISHER", "log")
+	webhookURL := os.Getenv("OUTBOX_WEBHOOK_URL")
+	natsURL := envOr("NATS_URL", "nats://localhost:4222")
+	subjectPrefix := envOr("OUTBOX_SUBJECT_PREFIX", "project")
+
+	cmd := &cobra.Command{
+		Use:          "relay",
+		Args:         cobra.ExactArgs(0),
+		Short:        "Publishes outbox events in order. Only one replica relays at a time.",
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			logger := cmdutil.NewLogger("project_outbox_relay")
+			defer func() { _ = logger.Sync() }()
+
+			var pub outbox.Publisher
+			switch publisher {
+			case "log":
+				pub = outbox.NewLogPublisher(logger)
+			case "webhook":
+				if webhookURL == "" {
+					return fmt.Errorf("--webhook-url is required for the webhook publisher")
+				}
+				pub = outbox.NewWebhookPublisher(webhookURL, &http.Client{Timeout: 10 * time.Second})
+			case "nats":
+				p, err := outbox.NewNATSPublisher(natsURL, subjectPrefix)
+				if err != nil {
+					return err
+				}
+				pub = p
+			default:
+				return fmt.Errorf("unknown publisher %q, expected log, webhook or nats", publisher)
+			}
+			defer pub.Close()
+
+			db, err := cmdutil.NewDatabasePool(ctx, logger)
+			if err != nil {
+				logger.Error("db connection error", zap.Error(err))
+				return err
+			}
+			defer db.Close()
+
+			r := outbox.New(
+				repository.NewOutboxRepository(db),
+				pub,
+				repository.NewAdvisoryLock(db, outboxLockName),
+				logger,
+				cfg,
+			)
+
+			logger.Info("started outbox relay", zap.String("publisher", publisher))
+
+			err = r.Run(ctx)
+
+			logger.Info("stopped outbox relay")
+
+			return err
+		},
+	}
+
+	cmd.Flags().StringVar(&publisher, "publisher", publisher, "where events are published: log, webhook or nats (env OUTBOX_PUBLISHER)")
+	cmd.Flags().StringVar(&webhookURL, "webhook-url", webhookURL, "URL events are POSTed to by the webhook publisher (env OUTBOX_WEBHOOK_URL)")
+	cmd.Flags().StringVar(&natsURL, "nats-url", natsURL, "server used by the nats publisher (env NATS_URL)")
+	cmd.Flags().StringVar(&subjectPrefix, "subject-prefix", subjectPrefix, "prefix of NATS subjects, events go to <prefix>.<type> (env OUTBOX_SUBJECT_PREFIX)")
+	cmd.Flags().IntVar(&cfg.BatchSize, "batch-size", cfg.BatchSize, "events read from the outbox at once")
+	cmd.Flags().DurationVar(&cfg.PollInterval, "poll-interval", cfg.PollInterval, "wait between polls when the outbox is empty")
+	cmd.Flags().DurationVar(&cfg.ElectionInterval, "election-interval", cfg.ElectionInterval, "how often a follower tries to become leader")
+	cmd.Flags().DurationVar(&cfg.Retention, "retention", cfg.Retention, "how long published events are kept, 0 keeps them forever")
+
+	return cmd
+}
+
+func envOr(key string, fallback string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return fallback
+}
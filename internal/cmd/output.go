@@ -0,0 +1,89 @@
+package cmd
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"go-project-template/internal/domain"
+	"go-project-template/internal/utils"
+	"io"
+	"strings"
+	"text/tabwriter"
+
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	outputTable = "table"
+	outputJSON  = "json"
+	outputYAML  = "yaml"
+)
+
+func validateOutput(output string) error {
+	switch output {
+	case outputTable, outputJSON, outputYAML:
+		return nil
+	}
+	return fmt.Errorf("unsupported output %q, expected table, json or yaml", output)
+}
+
+// printStructured writes v as indented JSON or YAML
+func printStructured(w io.Writer, output string, v interface{}) error {
+	if output == outputYAML {
+		enc := yaml.NewEncoder(w)
+		enc.SetIndent(2)
+		if err := enc.Encode(v); err != nil {
+			return err
+		}
+		return enc.Close()
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
+
+func printUser(w io.Writer, output string, u *domain.User) error {
+	if output != outputTable {
+		return printStructured(w, output, u)
+	}
+	return printUserTable(w, []domain.User{*u})
+}
+
+func printUserList(w io.Writer, output string, list *utils.PaginationResponse[domain.User]) error {
+	if output != outputTable {
+		return printStructured(w, output, list)
+	}
+
+	if err := printUserTable(w, list.Values); err != nil {
+		return err
+	}
+	_, err := fmt.Fprintf(w, "\npage %d of %d, %d users total\n", list.Page, list.TotalPages, list.TotalCount)
+	return err
+}
+
+func printUserTable(w io.Writer, uu []domain.User) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "UUID\tFIRST NAME\tLAST NAME\tEMAIL\tSTATUS")
+	for _, u := range uu {
+		email := ""
+		if u.Email != nil {
+			email = *u.Email
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", u.UUID, u.FirstName, u.LastName, email, u.Status)
+	}
+	return tw.Flush()
+}
+
+// confirm asks a yes/no question on out and reads the answer from in. Anything but y or yes is a no.
+func confirm(in io.Reader, out io.Writer, question string) (bool, error) {
+	fmt.Fprintf(out, "%s [y/N]: ", question)
+
+	answer, err := bufio.NewReader(in).ReadString('\n')
+	if err != nil && err != io.EOF {
+		return false, err
+	}
+
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	return answer == "y" || answer == "yes", nil
+}
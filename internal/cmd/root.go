@@ -53,8 +53,12 @@ func Execute(ctx context.Context) int {
 
 	rootCmd.PersistentFlags().BoolVarP(&profile, "profile", "p", false, "record CPU pprof")
 	rootCmd.AddCommand(APICmd(ctx))
-	// rootCmd.AddCommand(SchedulerCmd(ctx))
-	// rootCmd.AddCommand(WorkerCmd(ctx))
+	rootCmd.AddCommand(MigrateCmd(ctx))
+	rootCmd.AddCommand(OutboxCmd(ctx))
+	rootCmd.AddCommand(UsersCmd(ctx))
+	rootCmd.AddCommand(SchedulerCmd(ctx))
+	rootCmd.AddCommand(SeedCmd(ctx))
+	rootCmd.AddCommand(WorkerCmd(ctx))
 
 	go func() {
 		_ = http.ListenAndServe("localhost:6060", nil)
@@ -0,0 +1,116 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"go-project-template/cmdutil"
+	"go-project-template/internal/repository"
+	"go-project-template/internal/scheduler"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+	"go.uber.org/zap"
+)
+
+// schedulerLockName identifies the advisory lock held by the leading scheduler
+const schedulerLockName = "project.scheduler"
+
+func SchedulerCmd(ctx context.Context) *cobra.Command {
+	cfg := scheduler.DefaultConfig()
+
+	cmd := &cobra.Command{
+		Use:   "scheduler",
+		Args:  cobra.ExactArgs(0),
+		Short: "Enqueues jobs for periodic tasks. Only one replica leads at a time.",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			logger := cmdutil.NewLogger("project_scheduler")
+			defer func() { _ = logger.Sync() }()
+
+			tasks, err := scheduler.Tasks()
+			if err != nil {
+				return err
+			}
+
+			db, err := cmdutil.NewDatabasePool(ctx, logger)
+			if err != nil {
+				logger.Error("db connection error", zap.Error(err))
+				return err
+			}
+			defer db.Close()
+
+			s, err := scheduler.New(
+				repository.NewScheduleRepository(db),
+				repository.NewAdvisoryLock(db, schedulerLockName),
+				logger,
+				cfg,
+				tasks,
+			)
+			if err != nil {
+				return err
+			}
+
+			logger.Info("started scheduler", zap.Int("tasks", len(tasks)))
+
+			err = s.Run(ctx)
+
+			logger.Info("stopped scheduler")
+
+			return err
+		},
+	}
+
+	cmd.Flags().DurationVar(&cfg.Tick, "tick", cfg.Tick, "how often the leader checks for due tasks")
+	cmd.Flags().DurationVar(&cfg.ElectionInterval, "election-interval", cfg.ElectionInterval, "how often a follower tries to become leader")
+
+	cmd.AddCommand(schedulerListCmd(ctx))
+
+	return cmd
+}
+
+func schedulerListCmd(ctx context.Context) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:          "list",
+		Args:         cobra.ExactArgs(0),
+		Short:        "Lists scheduled tasks with their last and next run times.",
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			tasks, err := scheduler.Tasks()
+			if err != nil {
+				return err
+			}
+
+			db, err := cmdutil.NewDatabasePool(ctx, zap.NewNop())
+			if err != nil {
+				return err
+			}
+			defer db.Close()
+
+			lastRuns, err := repository.NewScheduleRepository(db).LastRuns(ctx)
+			if err != nil {
+				return err
+			}
+
+			now := time.Now()
+			tw := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 0, 2, ' ', 0)
+			fmt.Fprintln(tw, "NAME\tSCHEDULE\tJOB\tCATCH-UP\tJITTER\tLAST RUN\tNEXT RUN")
+			for _, t := range tasks {
+				last, lastCol := lastRuns[t.Name], "never"
+				if !last.IsZero() {
+					lastCol = last.Local().Format(time.RFC3339)
+				}
+
+				next := t.NextRun(last, now)
+				nextCol := next.Local().Format(time.RFC3339)
+				if next.Before(now) {
+					nextCol += " (missed)"
+				}
+
+				fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n", t.Name, t.Spec, t.JobType, t.CatchUp, t.Jitter, lastCol, nextCol)
+			}
+			return tw.Flush()
+		},
+	}
+
+	return cmd
+}
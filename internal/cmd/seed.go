@@ -0,0 +1,82 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"go-project-template/internal/seed"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+func SeedCmd(ctx context.Context) *cobra.Command {
+	var (
+		users     int
+		seedValue int64
+		profile   string
+		batchSize int
+		reset     bool
+	)
+
+	cmd := &cobra.Command{
+		Use:   "seed",
+		Args:  cobra.ExactArgs(0),
+		Short: "Fills the database with generated users.",
+		Long: `Fills the database with generated users for local development.
+
+The same --seed always generates the same users, so seeding again updates them in
+place instead of adding more. --reset deletes every user of the tenant first and
+is refused when ENV is production.
+
+UUIDs are unique across tenants, so seed every --tenant with a --seed of its own.`,
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx, err := tenantContext(ctx, cmd)
+			if err != nil {
+				return err
+			}
+
+			if users == 0 {
+				n, err := seed.Profile(profile).Users()
+				if err != nil {
+					return err
+				}
+				users = n
+			}
+			if users < 0 {
+				return errors.New("users must not be negative")
+			}
+			if batchSize <= 0 {
+				return errors.New("batch-size must be positive")
+			}
+
+			if reset && strings.EqualFold(os.Getenv("ENV"), "production") {
+				return errors.New("refusing to reset users in production")
+			}
+
+			repo, closeDB, err := openUserRepository(ctx)
+			if err != nil {
+				return err
+			}
+			defer closeDB()
+
+			s := seed.NewSeeder(repo, batchSize, cmd.OutOrStdout())
+			if reset {
+				if _, err := s.Reset(ctx); err != nil {
+					return err
+				}
+			}
+			return s.Seed(ctx, users, seedValue)
+		},
+	}
+
+	cmd.Flags().IntVar(&users, "users", 0, "number of users to generate, overrides the profile")
+	cmd.Flags().Int64Var(&seedValue, "seed", 42, "seed of the generated data")
+	cmd.Flags().StringVar(&profile, "profile", string(seed.ProfileSmall), "preset size: small (100), demo (1000) or load-test (100000)")
+	cmd.Flags().IntVar(&batchSize, "batch-size", 1000, "number of users written per transaction")
+	cmd.Flags().BoolVar(&reset, "reset", false, "delete every user of the tenant before seeding")
+	cmd.Flags().String("tenant", defaultTenant(), "tenant the users are generated for (default DEFAULT_TENANT or \"default\")")
+
+	return cmd
+}
@@ -0,0 +1,688 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"go-project-template/cmdutil"
+	"go-project-template/internal/domain"
+	"go-project-template/internal/repository"
+	"go-project-template/internal/userio"
+	"go-project-template/internal/utils"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/spf13/cobra"
+	"go.uber.org/zap"
+)
+
+func UsersCmd(ctx context.Context) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "users",
+		Args:  cobra.ExactArgs(0),
+		Short: "Manages users directly against the database.",
+	}
+
+	cmd.AddCommand(usersGetCmd(ctx))
+	cmd.AddCommand(usersListCmd(ctx))
+	cmd.AddCommand(usersCreateCmd(ctx))
+	cmd.AddCommand(usersUpdateCmd(ctx))
+	cmd.AddCommand(usersDeleteCmd(ctx))
+	cmd.AddCommand(usersImportCmd(ctx))
+	cmd.AddCommand(usersExportCmd(ctx))
+
+	cmd.PersistentFlags().String("tenant", defaultTenant(), tenantUsage)
+
+	return cmd
+}
+
+const tenantUsage = "tenant whose users are managed (default DEFAULT_TENANT or \"default\")"
+
+// defaultTenant returns DEFAULT_TENANT, or the tenant of the API when it is not set
+func defaultTenant() string {
+	if tenant, ok := os.LookupEnv("DEFAULT_TENANT"); ok {
+		return tenant
+	}
+	return domain.DefaultTenant
+}
+
+// tenantContext scopes ctx to the tenant of the --tenant flag
+func tenantContext(ctx context.Context, cmd *cobra.Command) (context.Context, error) {
+	tenant, err := cmd.Flags().GetString("tenant")
+	if err != nil {
+		return nil, err
+	}
+	if err := domain.ValidateTenantID(tenant); err != nil {
+		return nil, fmt.Errorf("tenant: %w", err)
+	}
+	return domain.WithTenant(ctx, tenant), nil
+}
+
+// openUserRepository connects to the database. The returned func closes the pool.
+// Nothing is logged, the output of the commands may be piped elsewhere.
+func openUserRepository(ctx context.Context) (domain.UserRepository, func(), error) {
+	db, err := cmdutil.NewDatabasePool(ctx, zap.NewNop())
+	if err != nil {
+		return nil, nil, err
+	}
+	return repository.NewUserRepository(repository.RLSConnectionFromEnv(db)), db.Close, nil
+}
+
+func usersGetCmd(ctx context.Context) *cobra.Command {
+	var output string
+
+	cmd := &cobra.Command{
+		Use:          "get <uuid>",
+		Args:         cobra.ExactArgs(1),
+		Short:        "Shows a single user.",
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx, err := tenantContext(ctx, cmd)
+			if err != nil {
+				return err
+			}
+
+			if err := validateOutput(output); err != nil {
+				return err
+			}
+
+			id, err := uuid.Parse(args[0])
+			if err != nil {
+				return err
+			}
+
+			repo, closeDB, err := openUserRepository(ctx)
+			if err != nil {
+				return err
+			}
+			defer closeDB()
+
+			u, err := repo.GetByID(ctx, id)
+			if err != nil {
+				return err
+			}
+			return printUser(cmd.OutOrStdout(), output, &u)
+		},
+	}
+
+	cmd.Flags().StringVarP(&output, "output", "o", outputTable, "output format: table, json or yaml")
+
+	return cmd
+}
+
+func usersListCmd(ctx context.Context) *cobra.Command {
+	var (
+		output   string
+		page     int
+		size     int
+		orderBy  string
+		orderDir string
+		status   string
+		filter   domain.UserFilter
+	)
+
+	cmd := &cobra.Command{
+		Use:          "list",
+		Args:         cobra.ExactArgs(0),
+		Short:        "Lists users a page at a time.",
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx, err := tenantContext(ctx, cmd)
+			if err != nil {
+				return err
+			}
+
+			if err := validateOutput(output); err != nil {
+				return err
+			}
+
+			if page < 1 {
+				return errors.New("page must be positive")
+			}
+			if size < 1 {
+				return errors.New("size must be positive")
+			}
+			filter.Status = domain.UserStatus(status)
+			if err := filter.Status.Validate(); err != nil {
+				return fmt.Errorf("status: %w", err)
+			}
+
+			pq := &utils.PaginationQuery{Page: page, Size: size}
+			pq.SetOrderBy(orderBy, orderDir)
+
+			repo, closeDB, err := openUserRepository(ctx)
+			if err != nil {
+				return err
+			}
+			defer closeDB()
+
+			list, err := repo.GetList(ctx, pq, filter)
+			if err != nil {
+				return err
+			}
+			return printUserList(cmd.OutOrStdout(), output, list)
+		},
+	}
+
+	cmd.Flags().StringVarP(&output, "output", "o", outputTable, "output format: table, json or yaml")
+	cmd.Flags().IntVar(&page, "page", 1, "page number")
+	cmd.Flags().IntVar(&size, "size", 10, "number of users per page")
+	cmd.Flags().StringVar(&orderBy, "order-by", "", "column to order by")
+	cmd.Flags().StringVar(&orderDir, "order-dir", "", "order direction: asc or desc")
+	cmd.Flags().StringVar(&filter.FirstName, "first-name", "", "only list users with this first name")
+	cmd.Flags().StringVar(&filter.LastName, "last-name", "", "only list users with this last name")
+	cmd.Flags().StringVar(&filter.Email, "email", "", "only list users with this email")
+	cmd.Flags().StringVar(&status, "status", "", "only list users with this status: active, invited, suspended or deactivated")
+
+	return cmd
+}
+
+func usersCreateCmd(ctx context.Context) *cobra.Command {
+	var (
+		output string
+		id     string
+		email  string
+		u      domain.User
+	)
+
+	cmd := &cobra.Command{
+		Use:          "create",
+		Args:         cobra.ExactArgs(0),
+		Short:        "Creates a user.",
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx, err := tenantContext(ctx, cmd)
+			if err != nil {
+				return err
+			}
+
+			if err := validateOutput(output); err != nil {
+				return err
+			}
+
+			u.UUID = uuid.New()
+			if id != "" {
+				parsed, err := uuid.Parse(id)
+				if err != nil {
+					return err
+				}
+				u.UUID = parsed
+			}
+			if email != "" {
+				u.Email = &email
+			}
+
+			repo, closeDB, err := openUserRepository(ctx)
+			if err != nil {
+				return err
+			}
+			defer closeDB()
+
+			created, err := repo.Create(ctx, &u)
+			if errors.Is(err, domain.ErrConflict) {
+				return fmt.Errorf("user %s already exists, use users update to change it", u.UUID)
+			}
+			if err != nil {
+				return err
+			}
+			return printUser(cmd.OutOrStdout(), output, created)
+		},
+	}
+
+	cmd.Flags().StringVarP(&output, "output", "o", outputTable, "output format: table, json or yaml")
+	cmd.Flags().StringVar(&id, "uuid", "", "uuid of the new user (default random)")
+	cmd.Flags().StringVar(&u.FirstName, "first-name", "", "first name")
+	cmd.Flags().StringVar(&u.LastName, "last-name", "", "last name")
+	cmd.Flags().StringVar(&email, "email", "", "email")
+
+	return cmd
+}
+
+func usersUpdateCmd(ctx context.Context) *cobra.Command {
+	var (
+		output    string
+		firstName string
+		lastName  string
+		email     string
+	)
+
+	cmd := &cobra.Command{
+		Use:          "update <uuid>",
+		Args:         cobra.ExactArgs(1),
+		Short:        "Updates the given fields of a user.",
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx, err := tenantContext(ctx, cmd)
+			if err != nil {
+				return err
+			}
+
+			if err := validateOutput(output); err != nil {
+				return err
+			}
+
+			id, err := uuid.Parse(args[0])
+			if err != nil {
+				return err
+			}
+
+			repo, closeDB, err := openUserRepository(ctx)
+			if err != nil {
+				return err
+			}
+			defer closeDB()
+
+			u, err := repo.GetByID(ctx, id)
+			if err != nil {
+				return err
+			}
+
+			if cmd.Flags().Changed("first-name") {
+				u.FirstName = firstName
+			}
+			if cmd.Flags().Changed("last-name") {
+				u.LastName = lastName
+			}
+			if cmd.Flags().Changed("email") {
+				u.Email = &email
+			}
+
+			updated, err := repo.CreateOrUpdate(ctx, &u)
+			if err != nil {
+				return err
+			}
+			return printUser(cmd.OutOrStdout(), output, updated)
+		},
+	}
+
+	cmd.Flags().StringVarP(&output, "output", "o", outputTable, "output format: table, json or yaml")
+	cmd.Flags().StringVar(&firstName, "first-name", "", "new first name")
+	cmd.Flags().StringVar(&lastName, "last-name", "", "new last name")
+	cmd.Flags().StringVar(&email, "email", "", "new email")
+
+	return cmd
+}
+
+func usersDeleteCmd(ctx context.Context) *cobra.Command {
+	var yes bool
+
+	cmd := &cobra.Command{
+		Use:          "delete <uuid>",
+		Args:         cobra.ExactArgs(1),
+		Short:        "Deletes a user after confirmation.",
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx, err := tenantContext(ctx, cmd)
+			if err != nil {
+				return err
+			}
+
+			id, err := uuid.Parse(args[0])
+			if err != nil {
+				return err
+			}
+
+			repo, closeDB, err := openUserRepository(ctx)
+			if err != nil {
+				return err
+			}
+			defer closeDB()
+
+			u, err := repo.GetByID(ctx, id)
+			if err != nil {
+				return err
+			}
+
+			if !yes {
+				if err := printUser(cmd.OutOrStdout(), outputTable, &u); err != nil {
+					return err
+				}
+
+				ok, err := confirm(cmd.InOrStdin(), cmd.OutOrStdout(), "Delete this user?")
+				if err != nil {
+					return err
+				}
+				if !ok {
+					fmt.Fprintln(cmd.OutOrStdout(), "aborted")
+					return nil
+				}
+			}
+
+			if err := repo.Delete(ctx, id); err != nil {
+				return err
+			}
+			fmt.Fprintf(cmd.OutOrStdout(), "deleted user %s\n", id)
+			return nil
+		},
+	}
+
+	cmd.Flags().BoolVarP(&yes, "yes", "y", false, "skip the confirmation prompt")
+
+	return cmd
+}
+
+func usersImportCmd(ctx context.Context) *cobra.Command {
+	var (
+		file       string
+		format     string
+		mode       string
+		mapping    map[string]string
+		batchSize  int
+		checkpoint string
+		dryRun     bool
+	)
+
+	cmd := &cobra.Command{
+		Use:   "import",
+		Args:  cobra.ExactArgs(0),
+		Short: "Imports users from a CSV or NDJSON file.",
+		Long: `Imports users from a CSV or NDJSON file in batches.
+
+Every row is validated first and invalid rows are reported with their line number.
+Rows without a uuid get one derived from the tenant and the email, so importing a
+file again updates its users instead of duplicating them. After each committed batch the last imported line is written to a checkpoint file,
+so an interrupted import picks up where it left off when run again.`,
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx, err := tenantContext(ctx, cmd)
+			if err != nil {
+				return err
+			}
+
+			f, err := userio.ParseFormat(format)
+			if err != nil {
+				return err
+			}
+
+			batchMode := domain.BatchMode(mode)
+			if err := batchMode.Validate(); err != nil {
+				return fmt.Errorf("mode: %w", err)
+			}
+
+			if batchSize <= 0 {
+				return errors.New("batch-size must be positive")
+			}
+
+			if checkpoint == "" {
+				checkpoint = file + ".checkpoint"
+			}
+
+			in, err := os.Open(file)
+			if err != nil {
+				return err
+			}
+			defer in.Close()
+
+			dec, err := userio.NewDecoder(in, f, mapping)
+			if err != nil {
+				return err
+			}
+
+			tenant, err := domain.TenantFromContext(ctx)
+			if err != nil {
+				return err
+			}
+
+			im := &userImporter{
+				tenant:    tenant,
+				mode:      batchMode,
+				batchSize: batchSize,
+				out:       cmd.OutOrStdout(),
+				dryRun:    dryRun,
+			}
+
+			if !dryRun {
+				im.resumeAfter, err = readCheckpoint(checkpoint)
+				if err != nil {
+					return err
+				}
+				if im.resumeAfter > 0 {
+					fmt.Fprintf(im.out, "resuming after line %d\n", im.resumeAfter)
+				}
+
+				repo, closeDB, err := openUserRepository(ctx)
+				if err != nil {
+					return err
+				}
+				defer closeDB()
+
+				im.repo = repo
+				im.checkpoint = checkpoint
+			}
+
+			if err := im.run(ctx, dec); err != nil {
+				return err
+			}
+
+			if !dryRun {
+				_ = os.Remove(checkpoint)
+			}
+
+			if im.invalid+im.failed > 0 {
+				return fmt.Errorf("%d invalid and %d failed rows were not imported", im.invalid, im.failed)
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVarP(&file, "file", "f", "", "path of the file to import")
+	cmd.Flags().StringVar(&format, "format", string(userio.FormatCSV), "input format: csv or ndjson")
+	cmd.Flags().StringVar(&mode, "mode", string(domain.BatchModeAtomic), "batch write mode: atomic stops at the first failing batch, partial skips failing rows")
+	cmd.Flags().StringToStringVar(&mapping, "map", nil, "map a user field to a source column, e.g. --map first_name=\"First Name\"")
+	cmd.Flags().IntVar(&batchSize, "batch-size", 500, "number of users written per transaction")
+	cmd.Flags().StringVar(&checkpoint, "checkpoint", "", "checkpoint file used to resume an import (default <file>.checkpoint)")
+	cmd.Flags().BoolVar(&dryRun, "dry-run", false, "validate the file without writing to the database")
+	_ = cmd.MarkFlagRequired("file")
+
+	return cmd
+}
+
+func usersExportCmd(ctx context.Context) *cobra.Command {
+	var (
+		file   string
+		format string
+		status string
+		filter domain.UserFilter
+	)
+
+	cmd := &cobra.Command{
+		Use:          "export",
+		Args:         cobra.ExactArgs(0),
+		Short:        "Exports users to a CSV or NDJSON file.",
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx, err := tenantContext(ctx, cmd)
+			if err != nil {
+				return err
+			}
+
+			f, err := userio.ParseFormat(format)
+			if err != nil {
+				return err
+			}
+			filter.Status = domain.UserStatus(status)
+			if err := filter.Status.Validate(); err != nil {
+				return fmt.Errorf("status: %w", err)
+			}
+
+			repo, closeDB, err := openUserRepository(ctx)
+			if err != nil {
+				return err
+			}
+			defer closeDB()
+
+			out := cmd.OutOrStdout()
+			if file != "-" {
+				fout, err := os.Create(file)
+				if err != nil {
+					return err
+				}
+				defer fout.Close()
+				out = fout
+			}
+
+			enc := userio.NewEncoder(out, f)
+			count := 0
+			err = repo.Stream(ctx, filter, func(u *domain.User) error {
+				count++
+				return enc.Encode(u)
+			})
+			if err != nil {
+				return err
+			}
+			if err := enc.Flush(); err != nil {
+				return err
+			}
+
+			if file != "-" {
+				fmt.Fprintf(cmd.ErrOrStderr(), "exported %d users to %s\n", count, file)
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVarP(&file, "file", "f", "-", "path of the file to write, - for stdout")
+	cmd.Flags().StringVar(&format, "format", string(userio.FormatCSV), "output format: csv or ndjson")
+	cmd.Flags().StringVar(&filter.FirstName, "first-name", "", "only export users with this first name")
+	cmd.Flags().StringVar(&filter.LastName, "last-name", "", "only export users with this last name")
+	cmd.Flags().StringVar(&filter.Email, "email", "", "only export users with this email")
+	cmd.Flags().StringVar(&status, "status", "", "only export users with this status: active, invited, suspended or deactivated")
+
+	return cmd
+}
+
+// userImporter groups decoded rows into batches and reports the outcome of every row
+type userImporter struct {
+	repo        domain.UserRepository
+	tenant      string
+	mode        domain.BatchMode
+	batchSize   int
+	out         io.Writer
+	dryRun      bool
+	checkpoint  string
+	resumeAfter int
+
+	batch []*domain.User
+	lines []int
+
+	imported int
+	invalid  int
+	failed   int
+}
+
+func (im *userImporter) run(ctx context.Context, dec *userio.Decoder) error {
+	for {
+		rec, err := dec.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return err
+		}
+
+		if rec.Line <= im.resumeAfter {
+			continue
+		}
+
+		if rec.Err == nil {
+			rec.Err = rec.User.Validate()
+		}
+		if rec.Err != nil {
+			im.invalid++
+			fmt.Fprintf(im.out, "line %d: invalid: %v\n", rec.Line, rec.Err)
+			continue
+		}
+
+		// Rows without a uuid get the same one on every run
+		if rec.User.UUID == uuid.Nil {
+			rec.User.UUID = userio.ImportUUID(im.tenant, *rec.User.Email)
+		}
+
+		im.batch = append(im.batch, rec.User)
+		im.lines = append(im.lines, rec.Line)
+		if len(im.batch) >= im.batchSize {
+			if err := im.flush(ctx); err != nil {
+				return err
+			}
+		}
+	}
+
+	if err := im.flush(ctx); err != nil {
+		return err
+	}
+
+	verb := "imported"
+	if im.dryRun {
+		verb = "validated"
+	}
+	fmt.Fprintf(im.out, "%s %d users, %d invalid, %d failed\n", verb, im.imported, im.invalid, im.failed)
+	return nil
+}
+
+func (im *userImporter) flush(ctx context.Context) error {
+	if len(im.batch) == 0 {
+		return nil
+	}
+	defer func() {
+		im.batch = im.batch[:0]
+		im.lines = im.lines[:0]
+	}()
+
+	if im.dryRun {
+		im.imported += len(im.batch)
+		return nil
+	}
+
+	results, err := im.repo.BatchCreateOrUpdate(ctx, im.batch, im.mode)
+	if err != nil && !errors.Is(err, domain.ErrBatchRejected) {
+		return err
+	}
+
+	for i, res := range results {
+		switch res.Status {
+		case domain.BatchStatusOK:
+			im.imported++
+		case domain.BatchStatusSkipped:
+			im.failed++
+		default:
+			im.failed++
+			fmt.Fprintf(im.out, "line %d: %s: %s\n", im.lines[i], res.Status, res.Error)
+		}
+	}
+
+	// A rejected atomic batch stops the import so it can be fixed and resumed
+	if err != nil {
+		return fmt.Errorf("batch ending at line %d: %w", im.lines[len(im.lines)-1], err)
+	}
+
+	return writeCheckpoint(im.checkpoint, im.lines[len(im.lines)-1])
+}
+
+// readCheckpoint returns the last imported line recorded in path, or 0 if there is none
+func readCheckpoint(path string) (int, error) {
+	b, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	line, err := strconv.Atoi(strings.TrimSpace(string(b)))
+	if err != nil {
+		return 0, fmt.Errorf("invalid checkpoint file %s: %w", path, err)
+	}
+	return line, nil
+}
+
+// writeCheckpoint records the last imported line, replacing the file atomically
+func writeCheckpoint(path string, line int) error {
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, []byte(strconv.Itoa(line)+"\n"), 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
@@ -0,0 +1,57 @@
+package cmd
+
+import (
+	"context"
+	"go-project-template/cmdutil"
+	"go-project-template/internal/domain"
+	"go-project-template/internal/repository"
+	"go-project-template/internal/worker"
+
+	"github.com/spf13/cobra"
+	"go.uber.org/zap"
+)
+
+func WorkerCmd(ctx context.Context) *cobra.Command {
+	cfg := worker.DefaultConfig()
+
+	cmd := &cobra.Command{
+		Use:   "worker",
+		Args:  cobra.ExactArgs(0),
+		Short: "Runs background jobs from the job queue.",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			logger := cmdutil.NewLogger("project_worker")
+			defer func() { _ = logger.Sync() }()
+
+			db, err := cmdutil.NewDatabasePool(ctx, logger)
+			if err != nil {
+				logger.Error("db connection error", zap.Error(err))
+				return err
+			}
+			defer db.Close()
+
+			userRepo := repository.NewUserRepository(repository.RLSConnectionFromEnv(db))
+			jobRepo := repository.NewJobRepository(db)
+
+			w := worker.New(jobRepo, logger, cfg)
+			w.Register(domain.JobTypeWelcomeEmail, worker.WelcomeEmailHandler(userRepo, logger))
+			w.Register(domain.JobTypeInvitationEmail, worker.InvitationEmailHandler(logger))
+			w.Register(worker.JobTypePurgeJobs, worker.PurgeJobsHandler(jobRepo, logger))
+
+			logger.Info("started worker", zap.Int("concurrency", cfg.Concurrency), zap.Strings("types", w.Types()))
+
+			err = w.Run(ctx)
+
+			logger.Info("stopped worker")
+
+			return err
+		},
+	}
+
+	cmd.Flags().IntVar(&cfg.Concurrency, "concurrency", cfg.Concurrency, "number of jobs processed at the same time")
+	cmd.Flags().DurationVar(&cfg.PollInterval, "poll-interval", cfg.PollInterval, "wait between polls when the queue is empty")
+	cmd.Flags().DurationVar(&cfg.VisibilityTimeout, "visibility-timeout", cfg.VisibilityTimeout, "how long a claimed job is hidden from other workers")
+	cmd.Flags().DurationVar(&cfg.BackoffBase, "backoff-base", cfg.BackoffBase, "delay before the first retry")
+	cmd.Flags().DurationVar(&cfg.BackoffMax, "backoff-max", cfg.BackoffMax, "maximum delay between retries")
+
+	return cmd
+}
@@ -0,0 +1,64 @@
+package domain
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+var (
+	// ErrInvalidCredentials will be returned if no user has the email and password of a login
+	ErrInvalidCredentials = errors.New("invalid email or password")
+	// ErrAccountLocked will be returned if a login is attempted while the credential is locked
+	ErrAccountLocked = errors.New("too many failed logins, try again later")
+	// ErrAccountDisabled will be returned if a suspended or deactivated user logs in
+	ErrAccountDisabled = errors.New("user is not active")
+	// ErrCredentialEmail will be returned if a password is set for a user without an email to log in with
+	ErrCredentialEmail = errors.New("user needs an email to log in with a password")
+)
+
+// Credential is the password of a user. It is never sent to clients.
+type Credential struct {
+	UserUUID uuid.UUID
+	TenantID string
+	// Hash is the encoded Argon2id hash with its parameters
+	Hash string
+	// FailedAttempts counts the failed logins since the last successful one or the last lockout
+	FailedAttempts int
+	LockedUntil    *time.Time
+	// PasswordChangedAt revokes the refresh tokens issued before it
+	PasswordChangedAt time.Time
+	LastLoginAt       *time.Time
+	CreatedAt         time.Time
+	UpdatedAt         time.Time
+}
+
+// Locked reports whether logins are refused at now
+func (c Credential) Locked(now time.Time) bool {
+	return c.LockedUntil != nil && now.Before(*c.LockedUntil)
+}
+
+// CredentialRepository stores the passwords of the users of a tenant. Every
+// call is scoped to the tenant of ctx, users of other tenants are reported as
+// [ErrNotFound].
+type CredentialRepository interface {
+	// SetPassword stores hash as the password of the user and unlocks it. It
+	// returns [ErrCredentialEmail] for users without an email and [ErrConflict]
+	// if another user of the tenant with the same email has a password.
+	SetPassword(ctx context.Context, userUUID uuid.UUID, hash string) error
+	Get(ctx context.Context, userUUID uuid.UUID) (Credential, error)
+	// FindByEmail returns the credential of the user with the email. It returns
+	// [ErrConflict] if users sharing the email have a password.
+	FindByEmail(ctx context.Context, email string) (Credential, error)
+	// Delete removes the password, the user cannot log in anymore
+	Delete(ctx context.Context, userUUID uuid.UUID) error
+
+	// RecordFailure counts a failed login. The maxAttempts-th failure in a row
+	// locks the credential until lockedUntil and starts counting again.
+	RecordFailure(ctx context.Context, userUUID uuid.UUID, maxAttempts int, lockedUntil time.Time) error
+	// RecordSuccess clears the failed logins and stamps the login. A non-empty
+	// rehash replaces the stored hash.
+	RecordSuccess(ctx context.Context, userUUID uuid.UUID, rehash string) error
+}
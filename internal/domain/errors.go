@@ -7,4 +7,10 @@ var (
 	ErrNotFound = errors.New("requested item was not found")
 	// ErrConflict will be returned if the item being persisted already exists
 	ErrConflict = errors.New("item already exists")
+	// ErrInProgress will be returned if a request with the same idempotency key is still being processed
+	ErrInProgress = errors.New("request with the same idempotency key is in progress")
+	// ErrKeyReuse will be returned if an idempotency key is reused with a different request
+	ErrKeyReuse = errors.New("idempotency key was used with a different request")
+	// ErrBatchRejected will be returned if an atomic batch write was rolled back
+	ErrBatchRejected = errors.New("batch was rejected")
 )
@@ -0,0 +1,142 @@
+package domain
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	EventUserCreated = "user.created"
+	EventUserUpdated = "user.updated"
+	EventUserDeleted = "user.deleted"
+	// EventUserStatusChanged is recorded when a user moves through its lifecycle
+	EventUserStatusChanged = "user.status_changed"
+)
+
+const AggregateUser = "user"
+
+// Event is a domain event recorded in the outbox in the same transaction as the
+// change it describes. IDs increase in commit order for a single aggregate.
+type Event struct {
+	ID            int64     `db:"id" json:"id"`
+	AggregateType string    `db:"aggregate_type" json:"aggregate_type"`
+	AggregateID   uuid.UUID `db:"aggregate_id" json:"aggregate_id"`
+	// TenantID is the tenant of the aggregate, empty for aggregates that belong to none
+	TenantID   string          `db:"tenant_id" json:"tenant_id,omitempty"`
+	Type       string          `db:"event_type" json:"type"`
+	Payload    json.RawMessage `db:"payload" json:"payload"`
+	OccurredAt time.Time       `db:"occurred_at" json:"occurred_at"`
+}
+
+// Decode returns the typed event recorded in the envelope
+func (e Event) Decode() (DomainEvent, error) {
+	meta := EventMeta{ID: e.ID, OccurredAt: e.OccurredAt}
+
+	switch e.Type {
+	case EventUserCreated:
+		ev := UserCreated{EventMeta: meta}
+		if err := json.Unmarshal(e.Payload, &ev.User); err != nil {
+			return nil, err
+		}
+		return ev, nil
+	case EventUserUpdated:
+		ev := UserUpdated{EventMeta: meta}
+		if err := json.Unmarshal(e.Payload, &ev.User); err != nil {
+			return nil, err
+		}
+		return ev, nil
+	case EventUserDeleted:
+		return UserDeleted{EventMeta: meta, UUID: e.AggregateID}, nil
+	case EventUserStatusChanged:
+		ev := UserStatusChanged{EventMeta: meta}
+		if err := json.Unmarshal(e.Payload, &ev); err != nil {
+			return nil, err
+		}
+		ev.EventMeta = meta
+		return ev, nil
+	default:
+		return nil, fmt.Errorf("unknown event type %q", e.Type)
+	}
+}
+
+// DomainEvent is implemented by every typed domain event
+type DomainEvent interface {
+	Metadata() EventMeta
+	EventType() string
+	AggregateID() uuid.UUID
+}
+
+// EventMeta is shared by every typed domain event. ID is the outbox ID the
+// event was recorded under.
+type EventMeta struct {
+	ID         int64     `json:"id"`
+	OccurredAt time.Time `json:"occurred_at"`
+}
+
+func (m EventMeta) Metadata() EventMeta { return m }
+
+type UserCreated struct {
+	EventMeta
+	User User `json:"user"`
+}
+
+func (UserCreated) EventType() string        { return EventUserCreated }
+func (e UserCreated) AggregateID() uuid.UUID { return e.User.UUID }
+
+type UserUpdated struct {
+	EventMeta
+	User User `json:"user"`
+}
+
+func (UserUpdated) EventType() string        { return EventUserUpdated }
+func (e UserUpdated) AggregateID() uuid.UUID { return e.User.UUID }
+
+type UserDeleted struct {
+	EventMeta
+	UUID uuid.UUID `json:"uuid"`
+}
+
+func (UserDeleted) EventType() string        { return EventUserDeleted }
+func (e UserDeleted) AggregateID() uuid.UUID { return e.UUID }
+
+// UserStatusChanged carries the user after the change, the status it left and why
+type UserStatusChanged struct {
+	EventMeta
+	User   User       `json:"user"`
+	From   UserStatus `json:"from"`
+	Reason string     `json:"reason,omitempty"`
+}
+
+func (UserStatusChanged) EventType() string        { return EventUserStatusChanged }
+func (e UserStatusChanged) AggregateID() uuid.UUID { return e.User.UUID }
+
+// EventPublisher hands domain events to in-process subscribers
+type EventPublisher interface {
+	Publish(ctx context.Context, events ...DomainEvent) error
+}
+
+// OutboxRepository gives the relay access to events that were not published yet
+type OutboxRepository interface {
+	// Pending returns up to limit unpublished events in ID order
+	Pending(ctx context.Context, limit int) ([]Event, error)
+	MarkPublished(ctx context.Context, id int64) error
+	// MarkFailed records a failed delivery attempt, the event stays pending
+	MarkFailed(ctx context.Context, id int64, cause error) error
+	// DeletePublished removes events published before the given time
+	DeletePublished(ctx context.Context, before time.Time) (int64, error)
+	// After returns up to limit events of an aggregate type with an ID above
+	// afterID in ID order, whether they were published or not
+	After(ctx context.Context, aggregateType string, afterID int64, limit int) ([]Event, error)
+	// LastID returns the highest event ID, or zero if the outbox is empty
+	LastID(ctx context.Context) (int64, error)
+	// Horizon returns the oldest transaction still running and the next one to
+	// start. IDs are taken when an event is written, not when it commits, so an
+	// event can show up after events with higher IDs. Once xmin passes the xmax
+	// of an earlier call, every event written before that call is visible or
+	// never will be.
+	Horizon(ctx context.Context) (xmin int64, xmax int64, err error)
+}
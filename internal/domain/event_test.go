@@ -0,0 +1,79 @@
+package domain_test
+
+import (
+	"encoding/json"
+	"go-project-template/internal/domain"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEvent_Decode(t *testing.T) {
+	t.Parallel()
+
+	id := uuid.New()
+	occurredAt := time.Now()
+	payload, err := json.Marshal(domain.User{UUID: id, FirstName: "john", LastName: "wick"})
+	require.NoError(t, err)
+
+	meta := domain.EventMeta{ID: 7, OccurredAt: occurredAt}
+	user := domain.User{UUID: id, FirstName: "john", LastName: "wick"}
+
+	testCases := map[string]struct {
+		eventType string
+		want      domain.DomainEvent
+		wantErr   bool
+	}{
+		"created": {domain.EventUserCreated, domain.UserCreated{EventMeta: meta, User: user}, false},
+		"updated": {domain.EventUserUpdated, domain.UserUpdated{EventMeta: meta, User: user}, false},
+		"deleted": {domain.EventUserDeleted, domain.UserDeleted{EventMeta: meta, UUID: id}, false},
+		"unknown": {"user.renamed", nil, true},
+	}
+
+	for scenario, tc := range testCases { //nolint:paralleltest
+		t.Run(scenario, func(t *testing.T) {
+			e := domain.Event{
+				ID:            7,
+				AggregateType: domain.AggregateUser,
+				AggregateID:   id,
+				Type:          tc.eventType,
+				Payload:       payload,
+				OccurredAt:    occurredAt,
+			}
+
+			got, err := e.Decode()
+			if tc.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.want, got)
+			assert.Equal(t, tc.eventType, got.EventType())
+			assert.Equal(t, id, got.AggregateID())
+			assert.Equal(t, meta, got.Metadata())
+		})
+	}
+}
+
+func TestEvent_DecodeStatusChanged(t *testing.T) {
+	t.Parallel()
+
+	id := uuid.New()
+	user := domain.User{UUID: id, FirstName: "john", LastName: "wick", Status: domain.UserStatusSuspended, StatusReason: "fraud"}
+	payload, err := json.Marshal(map[string]interface{}{"user": user, "from": domain.UserStatusActive, "reason": "fraud"})
+	require.NoError(t, err)
+
+	e := domain.Event{ID: 7, AggregateType: domain.AggregateUser, AggregateID: id, Type: domain.EventUserStatusChanged, Payload: payload}
+	got, err := e.Decode()
+	require.NoError(t, err)
+	assert.Equal(t, domain.UserStatusChanged{
+		EventMeta: domain.EventMeta{ID: 7},
+		User:      user,
+		From:      domain.UserStatusActive,
+		Reason:    "fraud",
+	}, got)
+	assert.Equal(t, id, got.AggregateID())
+}
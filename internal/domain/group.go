@@ -0,0 +1,66 @@
+package domain
+
+import (
+	"context"
+	"errors"
+	"go-project-template/internal/utils"
+	"time"
+
+	validation "github.com/go-ozzo/ozzo-validation/v4"
+	"github.com/google/uuid"
+)
+
+// ErrGroupCycle will be returned if adding a subgroup would make a group contain itself
+var ErrGroupCycle = errors.New("group cannot contain itself")
+
+// Group model
+// @Description Users and groups of a tenant that are granted permissions together
+type Group struct {
+	ID   uuid.UUID `json:"id" example:"5b8e2d4f-7a1c-4e3b-9d6f-1c3e5a7b9d2f"`
+	Name string    `json:"name" example:"Engineering"`
+	// TenantID, CreatedAt and UpdatedAt are set by the repository
+	TenantID  string    `json:"tenant_id,omitempty" example:"acme"`
+	CreatedAt time.Time `json:"created_at" example:"2024-01-01T00:00:00Z"`
+	UpdatedAt time.Time `json:"updated_at" example:"2024-01-01T00:00:00Z"`
+}
+
+func (g *Group) Validate() error {
+	return validation.ValidateStruct(g,
+		validation.Field(&g.Name, validation.Required, validation.Length(1, 200)),
+	)
+}
+
+// GroupRepository stores the groups of a tenant. A group has users and other
+// groups as members, a user is a nested member of every group that contains a
+// group it is a member of. Every call is scoped to the tenant of ctx, users and
+// groups of other tenants are reported as [ErrNotFound].
+type GroupRepository interface {
+	Create(ctx context.Context, g *Group) (*Group, error)
+	GetByID(ctx context.Context, id uuid.UUID) (*Group, error)
+	// Update renames the group
+	Update(ctx context.Context, g *Group) (*Group, error)
+	// Delete removes the group, its members and its place in other groups
+	Delete(ctx context.Context, id uuid.UUID) error
+	GetList(ctx context.Context, pq *utils.PaginationQuery) (*utils.PaginationResponse[Group], error)
+
+	// AddMembers adds the users to the group, users that already are members are
+	// left as they are. Nothing is added if one of them is not found.
+	AddMembers(ctx context.Context, groupID uuid.UUID, userIDs []uuid.UUID) error
+	// RemoveMembers removes the users from the group, users that are no members are ignored
+	RemoveMembers(ctx context.Context, groupID uuid.UUID, userIDs []uuid.UUID) error
+	// Members returns the users of the group ordered by UUID, with nested the
+	// users of its subgroups at any depth as well
+	Members(ctx context.Context, groupID uuid.UUID, nested bool, pq *utils.PaginationQuery) (*utils.PaginationResponse[User], error)
+
+	// AddSubgroups makes the groups members of the group. It returns
+	// [ErrGroupCycle] if the group would end up containing itself.
+	AddSubgroups(ctx context.Context, groupID uuid.UUID, childIDs []uuid.UUID) error
+	// RemoveSubgroups removes the groups from the group, groups that are no members are ignored
+	RemoveSubgroups(ctx context.Context, groupID uuid.UUID, childIDs []uuid.UUID) error
+	// Subgroups returns the groups that are direct members of the group, ordered by name
+	Subgroups(ctx context.Context, groupID uuid.UUID, pq *utils.PaginationQuery) (*utils.PaginationResponse[Group], error)
+
+	// UserGroups returns the groups the user is a member of ordered by name, with
+	// nested the groups containing them at any depth as well
+	UserGroups(ctx context.Context, userID uuid.UUID, nested bool, pq *utils.PaginationQuery) (*utils.PaginationResponse[Group], error)
+}
@@ -0,0 +1,37 @@
+package domain
+
+import (
+	"context"
+	"time"
+)
+
+// IdempotencyRecord stores the outcome of a request sent with an Idempotency-Key header
+type IdempotencyRecord struct {
+	Key         string    `db:"key"`
+	Fingerprint string    `db:"fingerprint"`
+	StatusCode  int       `db:"status_code"`
+	ContentType string    `db:"content_type"`
+	Body        []byte    `db:"body"`
+	CreatedAt   time.Time `db:"created_at"`
+	ExpiresAt   time.Time `db:"expires_at"`
+	// LockedUntil ends the lease of a request in progress, afterwards a retry
+	// with the same fingerprint takes the key over
+	LockedUntil time.Time `db:"locked_until"`
+}
+
+// Completed reports whether a response has been stored for the key.
+func (r *IdempotencyRecord) Completed() bool {
+	return r.StatusCode != 0
+}
+
+type IdempotencyRepository interface {
+	// Reserve claims the key for a new request for the duration of lease. If the
+	// key was already used with the same fingerprint and has completed, the
+	// stored record is returned.
+	Reserve(ctx context.Context, key string, fingerprint string, lease time.Duration, ttl time.Duration) (*IdempotencyRecord, error)
+	Complete(ctx context.Context, r *IdempotencyRecord) error
+	// Release deletes the reservation of r so the key can be used again, unless
+	// its lease ran out and a retry took the key over
+	Release(ctx context.Context, r *IdempotencyRecord) error
+	DeleteExpired(ctx context.Context) (int64, error)
+}
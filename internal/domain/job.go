@@ -0,0 +1,70 @@
+package domain
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type JobStatus string
+
+const (
+	JobStatusPending   JobStatus = "pending"
+	JobStatusRunning   JobStatus = "running"
+	JobStatusSucceeded JobStatus = "succeeded"
+	// JobStatusDead marks a job that ran out of attempts and will not be retried
+	JobStatusDead JobStatus = "dead"
+)
+
+const defaultJobMaxAttempts = 10
+
+// Job is a unit of background work picked up by the worker
+type Job struct {
+	ID          uuid.UUID       `db:"id" json:"id"`
+	Type        string          `db:"type" json:"type"`
+	Payload     json.RawMessage `db:"payload" json:"payload"`
+	Priority    int             `db:"priority" json:"priority"`
+	Status      JobStatus       `db:"status" json:"status"`
+	Attempts    int             `db:"attempts" json:"attempts"`
+	MaxAttempts int             `db:"max_attempts" json:"max_attempts"`
+	RunAt       time.Time       `db:"run_at" json:"run_at"`
+	LockedUntil *time.Time      `db:"locked_until" json:"locked_until,omitempty"`
+	LastError   *string         `db:"last_error" json:"last_error,omitempty"`
+	CreatedAt   time.Time       `db:"created_at" json:"created_at"`
+	UpdatedAt   time.Time       `db:"updated_at" json:"updated_at"`
+}
+
+// NewJob returns a pending job of the given type with payload encoded as JSON
+func NewJob(jobType string, payload interface{}) (*Job, error) {
+	b, err := json.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Job{
+		ID:          uuid.New(),
+		Type:        jobType,
+		Payload:     b,
+		Status:      JobStatusPending,
+		MaxAttempts: defaultJobMaxAttempts,
+		RunAt:       time.Now(),
+	}, nil
+}
+
+// JobRepository is a queue of jobs. Updates to a claimed job only succeed while
+// the caller still holds it, otherwise [ErrNotFound] is returned.
+type JobRepository interface {
+	Enqueue(ctx context.Context, j *Job) (*Job, error)
+	// Dequeue claims the most urgent runnable job of one of the given types for
+	// the visibility timeout. It returns [ErrNotFound] if no job is ready.
+	Dequeue(ctx context.Context, types []string, visibility time.Duration) (*Job, error)
+	Complete(ctx context.Context, j *Job) error
+	// Retry releases the job to be run again at runAt
+	Retry(ctx context.Context, j *Job, runAt time.Time, cause error) error
+	// Bury moves the job to the dead-letter state
+	Bury(ctx context.Context, j *Job, cause error) error
+	// DeleteSucceeded removes succeeded jobs last updated before the given time
+	DeleteSucceeded(ctx context.Context, before time.Time) (int64, error)
+}
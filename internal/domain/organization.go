@@ -0,0 +1,144 @@
+package domain
+
+import (
+	"context"
+	"errors"
+	"go-project-template/internal/utils"
+	"time"
+
+	validation "github.com/go-ozzo/ozzo-validation/v4"
+	"github.com/go-ozzo/ozzo-validation/v4/is"
+	"github.com/google/uuid"
+)
+
+// JobTypeInvitationEmail is the job that sends the invited address its token
+const JobTypeInvitationEmail = "organization.invitation_email"
+
+// InvitationEmailPayload is the payload of a [JobTypeInvitationEmail] job
+type InvitationEmailPayload struct {
+	InvitationID   uuid.UUID `json:"invitation_id"`
+	TenantID       string    `json:"tenant_id"`
+	OrganizationID uuid.UUID `json:"organization_id"`
+	Email          string    `json:"email"`
+	Token          string    `json:"token"`
+}
+
+var (
+	// ErrLastOwner will be returned if a change would leave an organization without an owner
+	ErrLastOwner = errors.New("organization must keep at least one owner")
+	// ErrInvitationInvalid will be returned if an invitation has expired or was already accepted
+	ErrInvitationInvalid = errors.New("invitation has expired or was already accepted")
+	// ErrInvitationEmail will be returned if an invitation is accepted by a user with another email
+	ErrInvitationEmail = errors.New("invitation was sent to another email")
+)
+
+// Role is what a member is allowed to do in an organization
+type Role string
+
+const (
+	// RoleOwner manages the organization and its members, every organization has one
+	RoleOwner  Role = "owner"
+	RoleAdmin  Role = "admin"
+	RoleMember Role = "member"
+)
+
+func (r Role) Validate() error {
+	return validation.Validate(string(r), validation.Required, validation.In(string(RoleOwner), string(RoleAdmin), string(RoleMember)))
+}
+
+// Organization model
+// @Description Group of users of a tenant
+type Organization struct {
+	ID   uuid.UUID `json:"id" example:"6a1f0c2e-8d4b-4f7a-9e3c-2b5d7f9a1c3e"`
+	Name string    `json:"name" example:"Acme Research"`
+	// TenantID, CreatedAt and UpdatedAt are set by the repository
+	TenantID  string    `json:"tenant_id,omitempty" example:"acme"`
+	CreatedAt time.Time `json:"created_at" example:"2024-01-01T00:00:00Z"`
+	UpdatedAt time.Time `json:"updated_at" example:"2024-01-01T00:00:00Z"`
+}
+
+func (o *Organization) Validate() error {
+	return validation.ValidateStruct(o,
+		validation.Field(&o.Name, validation.Required, validation.Length(1, 200)),
+	)
+}
+
+// Membership model
+// @Description Role of a user in an organization
+type Membership struct {
+	OrganizationID uuid.UUID `json:"organization_id" example:"6a1f0c2e-8d4b-4f7a-9e3c-2b5d7f9a1c3e"`
+	UserID         uuid.UUID `json:"user_uuid" example:"3fe82b1f-ab3d-40a1-8bd8-bccd4dd166f8"`
+	Role           Role      `json:"role" example:"member"`
+	CreatedAt      time.Time `json:"created_at" example:"2024-01-01T00:00:00Z"`
+	UpdatedAt      time.Time `json:"updated_at" example:"2024-01-01T00:00:00Z"`
+}
+
+func (m *Membership) Validate() error {
+	return validation.ValidateStruct(m,
+		validation.Field(&m.Role),
+	)
+}
+
+// User Organization model
+// @Description Organization of a user together with the role of the user in it
+type UserOrganization struct {
+	Organization
+	Role Role `json:"role" example:"owner"`
+}
+
+// Invitation model
+// @Description Invitation of an email address to join an organization
+type Invitation struct {
+	ID             uuid.UUID `json:"id" example:"9c2e4a6b-1d3f-4b5a-8c7e-0f2a4c6e8b1d"`
+	OrganizationID uuid.UUID `json:"organization_id" example:"6a1f0c2e-8d4b-4f7a-9e3c-2b5d7f9a1c3e"`
+	Email          string    `json:"email" example:"helen@mail.com"`
+	Role           Role      `json:"role" example:"member"`
+	// Token accepts the invitation. It is only set on the invitation returned by
+	// CreateInvitation and never serialized, the invited address gets it by email.
+	Token      string     `json:"-"`
+	ExpiresAt  time.Time  `json:"expires_at" example:"2024-01-08T00:00:00Z"`
+	AcceptedAt *time.Time `json:"accepted_at,omitempty"`
+	CreatedAt  time.Time  `json:"created_at" example:"2024-01-01T00:00:00Z"`
+}
+
+func (i *Invitation) Validate() error {
+	return validation.ValidateStruct(i,
+		validation.Field(&i.Email, validation.Required, is.EmailFormat),
+		validation.Field(&i.Role),
+	)
+}
+
+// OrganizationRepository stores the organizations of a tenant, their members and
+// invitations. Every call is scoped to the tenant of ctx and a member has to be
+// a user of that tenant, anything else is reported as [ErrNotFound].
+type OrganizationRepository interface {
+	// Create stores the organization with owner as its first owner
+	Create(ctx context.Context, o *Organization, owner uuid.UUID) (*Organization, error)
+	GetByID(ctx context.Context, id uuid.UUID) (*Organization, error)
+	// Update renames the organization
+	Update(ctx context.Context, o *Organization) (*Organization, error)
+	// Delete removes the organization together with its members and invitations
+	Delete(ctx context.Context, id uuid.UUID) error
+	GetList(ctx context.Context, pq *utils.PaginationQuery) (*utils.PaginationResponse[Organization], error)
+
+	// SetMember adds the user to the organization or changes its role. Demoting
+	// the last owner returns [ErrLastOwner].
+	SetMember(ctx context.Context, m *Membership) (*Membership, error)
+	// RemoveMember removes the user from the organization, the last owner cannot be removed
+	RemoveMember(ctx context.Context, orgID uuid.UUID, userID uuid.UUID) error
+	Members(ctx context.Context, orgID uuid.UUID, pq *utils.PaginationQuery) (*utils.PaginationResponse[Membership], error)
+	// UserOrganizations returns the organizations the user is a member of
+	UserOrganizations(ctx context.Context, userID uuid.UUID, pq *utils.PaginationQuery) (*utils.PaginationResponse[UserOrganization], error)
+
+	// CreateInvitation stores an invitation that expires after ttl and returns it
+	// with the token that accepts it. The Postgres implementation queues a
+	// [JobTypeInvitationEmail] job with the token in the same transaction.
+	CreateInvitation(ctx context.Context, inv *Invitation, ttl time.Duration) (*Invitation, error)
+	// Invitations returns the invitations of the organization that can still be accepted
+	Invitations(ctx context.Context, orgID uuid.UUID) ([]Invitation, error)
+	RevokeInvitation(ctx context.Context, orgID uuid.UUID, id uuid.UUID) error
+	// AcceptInvitation makes the user a member with the role of the invitation. The
+	// user needs the email the invitation was sent to, a user who already is a
+	// member keeps the role it has.
+	AcceptInvitation(ctx context.Context, token string, userID uuid.UUID) (*Membership, error)
+}
@@ -0,0 +1,15 @@
+package domain
+
+import (
+	"context"
+	"time"
+)
+
+// ScheduleRepository remembers when each scheduled task last fired
+type ScheduleRepository interface {
+	LastRuns(ctx context.Context) (map[string]time.Time, error)
+	// RecordRun stores scheduledAt as the last run of the task and enqueues j in
+	// the same transaction. It returns [ErrConflict] if a run at or after
+	// scheduledAt was already recorded, in which case nothing is enqueued.
+	RecordRun(ctx context.Context, task string, scheduledAt time.Time, j *Job) error
+}
@@ -0,0 +1,41 @@
+package domain
+
+import (
+	"context"
+	"errors"
+	"regexp"
+)
+
+// DefaultTenant is the tenant of requests that do not name one, unless the
+// deployment requires every request to
+const DefaultTenant = "default"
+
+// ErrTenantRequired will be returned if a tenant scoped operation runs without a tenant
+var ErrTenantRequired = errors.New("tenant is required")
+
+// tenantIDPattern keeps tenant IDs usable as a subdomain and in cache keys
+var tenantIDPattern = regexp.MustCompile(`^[a-z0-9]([a-z0-9-]{0,61}[a-z0-9])?$`)
+
+// ValidateTenantID checks that id is a lowercase DNS label
+func ValidateTenantID(id string) error {
+	if !tenantIDPattern.MatchString(id) {
+		return errors.New("tenant id must be a lowercase DNS label")
+	}
+	return nil
+}
+
+type tenantKey struct{}
+
+// WithTenant scopes the repository calls made with ctx to the tenant id
+func WithTenant(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, tenantKey{}, id)
+}
+
+// TenantFromContext returns the tenant of ctx, or [ErrTenantRequired] if it has none
+func TenantFromContext(ctx context.Context) (string, error) {
+	id, _ := ctx.Value(tenantKey{}).(string)
+	if id == "" {
+		return "", ErrTenantRequired
+	}
+	return id, nil
+}
@@ -4,18 +4,38 @@ import (
 	"context"
 	"go-project-template/internal/utils"
 	"strings"
+	"time"
 
 	validation "github.com/go-ozzo/ozzo-validation/v4"
 	"github.com/google/uuid"
 )
 
+// JobTypeWelcomeEmail is the job that greets a newly created user
+const JobTypeWelcomeEmail = "user.welcome_email"
+
+// WelcomeEmailPayload is the payload of a [JobTypeWelcomeEmail] job
+type WelcomeEmailPayload struct {
+	UserID   uuid.UUID `json:"user_id"`
+	TenantID string    `json:"tenant_id"`
+}
+
 // User base model
 // @Description User base model
 type User struct {
-	UUID      uuid.UUID `db:"uuid" json:"uuid,omitempty" example:"3fe82b1f-ab3d-40a1-8bd8-bccd4dd166f8"`
-	FirstName string    `db:"first_name" json:"first_name,omitempty" example:"John"`
-	LastName  string    `db:"last_name" json:"last_name,omitempty" example:"Wick"`
-	Email     *string   `db:"email" json:"email,omitempty" example:"johnwick@mail.com"`
+	UUID      uuid.UUID `db:"uuid" json:"uuid,omitempty" yaml:"uuid,omitempty" example:"3fe82b1f-ab3d-40a1-8bd8-bccd4dd166f8"`
+	FirstName string    `db:"first_name" json:"first_name,omitempty" yaml:"first_name,omitempty" example:"John"`
+	LastName  string    `db:"last_name" json:"last_name,omitempty" yaml:"last_name,omitempty" example:"Wick"`
+	Email     *string   `db:"email" json:"email,omitempty" yaml:"email,omitempty" example:"johnwick@mail.com"`
+	// Status is only read when the user is created, where it can be invited,
+	// every other value creates an active user. Afterwards it only changes
+	// through a [StatusChange], which also sets StatusReason.
+	Status       UserStatus `db:"status" json:"status,omitempty" yaml:"status,omitempty" example:"active"`
+	StatusReason string     `db:"status_reason" json:"status_reason,omitempty" yaml:"status_reason,omitempty" example:"chargeback fraud"`
+	// TenantID, CreatedAt and UpdatedAt are set by the repository, the tenant is
+	// the one of the request and updated_at only moves when a field changes
+	TenantID  string    `db:"tenant_id" json:"tenant_id,omitempty" yaml:"tenant_id,omitempty" example:"acme"`
+	CreatedAt time.Time `db:"created_at" json:"created_at" yaml:"created_at" example:"2024-01-01T00:00:00Z"`
+	UpdatedAt time.Time `db:"updated_at" json:"updated_at" yaml:"updated_at" example:"2024-01-01T00:00:00Z"`
 }
 
 func (u *User) NormalizedFirstName() string {
@@ -31,12 +51,79 @@ func (u *User) Validate() error {
 		validation.Field(&u.FirstName, validation.Required),
 		validation.Field(&u.LastName, validation.Required),
 		validation.Field(&u.Email, validation.Required),
+		validation.Field(&u.Status),
 	)
 }
 
+// InitialStatus returns the status a new user is created with
+func (u *User) InitialStatus() UserStatus {
+	if u.Status == UserStatusInvited {
+		return UserStatusInvited
+	}
+	return UserStatusActive
+}
+
+// UserFilter narrows a query to users matching every non-empty field
+type UserFilter struct {
+	FirstName string     `json:"first_name,omitempty"`
+	LastName  string     `json:"last_name,omitempty"`
+	Email     string     `json:"email,omitempty"`
+	Status    UserStatus `json:"status,omitempty"`
+}
+
+// BatchMode controls how a batch write handles items that fail
+type BatchMode string
+
+const (
+	// BatchModeAtomic writes every item or none of them
+	BatchModeAtomic BatchMode = "atomic"
+	// BatchModePartial writes every item that succeeds and reports the rest
+	BatchModePartial BatchMode = "partial"
+)
+
+func (m BatchMode) Validate() error {
+	return validation.Validate(string(m), validation.In(string(BatchModeAtomic), string(BatchModePartial)))
+}
+
+// BatchStatus is the outcome of a single item in a batch write
+type BatchStatus string
+
+const (
+	BatchStatusOK      BatchStatus = "ok"
+	BatchStatusInvalid BatchStatus = "invalid"
+	BatchStatusFailed  BatchStatus = "failed"
+	BatchStatusSkipped BatchStatus = "skipped"
+)
+
+// Batch Item Result Model
+// @Description Outcome of a single item in a batch write
+type BatchItemResult struct {
+	Index  int         `json:"index" example:"0"`
+	UUID   uuid.UUID   `json:"uuid,omitempty" example:"3fe82b1f-ab3d-40a1-8bd8-bccd4dd166f8"`
+	Status BatchStatus `json:"status" example:"ok"`
+	Error  string      `json:"error,omitempty"`
+}
+
 type UserRepository interface {
 	GetByID(ctx context.Context, uuid uuid.UUID) (User, error)
+	// Create stores a new user. It returns [ErrConflict] if the UUID is taken,
+	// by this tenant or another one.
+	Create(context.Context, *User) (*User, error)
 	CreateOrUpdate(context.Context, *User) (*User, error)
+	// BatchCreateOrUpdate upserts the users in a single transaction and reports
+	// the outcome of every item in input order. Users without a UUID are assigned one.
+	BatchCreateOrUpdate(ctx context.Context, uu []*User, mode BatchMode) ([]BatchItemResult, error)
 	Delete(ctx context.Context, uuid uuid.UUID) error
-	GetList(ctx context.Context, pq *utils.PaginationQuery) (*utils.PaginationResponse[User], error)
+	// DeleteAll deletes every user of the tenant and returns their UUIDs
+	DeleteAll(ctx context.Context) ([]uuid.UUID, error)
+	// Backdate sets created_at and updated_at of the users of the tenant to the
+	// values in uu. It records no events, it is meant for generated data.
+	Backdate(ctx context.Context, uu []*User) error
+	// Transition moves the user to the status of c. It returns an error wrapping
+	// [ErrIllegalTransition] if the current status cannot move there.
+	Transition(ctx context.Context, uuid uuid.UUID, c StatusChange) (*User, error)
+	GetList(ctx context.Context, pq *utils.PaginationQuery, f UserFilter) (*utils.PaginationResponse[User], error)
+	// Stream calls fn for every user matching the filter without loading them all
+	// into memory. Iteration stops at the first error returned by fn.
+	Stream(ctx context.Context, f UserFilter, fn func(*User) error) error
 }
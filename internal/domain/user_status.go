@@ -0,0 +1,74 @@
+package domain
+
+import (
+	"errors"
+	"fmt"
+
+	validation "github.com/go-ozzo/ozzo-validation/v4"
+)
+
+// ErrIllegalTransition will be returned if a user cannot move from its status to the requested one
+var ErrIllegalTransition = errors.New("illegal status transition")
+
+// UserStatus is where a user account is in its lifecycle
+type UserStatus string
+
+const (
+	// UserStatusInvited users were created on someone else's behalf and have not signed in yet
+	UserStatusInvited UserStatus = "invited"
+	// UserStatusActive users can use the service
+	UserStatusActive UserStatus = "active"
+	// UserStatusSuspended users are blocked until they are activated again
+	UserStatusSuspended UserStatus = "suspended"
+	// UserStatusDeactivated users are closed for good
+	UserStatusDeactivated UserStatus = "deactivated"
+)
+
+// userTransitions are the statuses a user can move to from each status
+var userTransitions = map[UserStatus][]UserStatus{
+	UserStatusInvited:   {UserStatusActive, UserStatusDeactivated},
+	UserStatusActive:    {UserStatusSuspended, UserStatusDeactivated},
+	UserStatusSuspended: {UserStatusActive, UserStatusDeactivated},
+}
+
+func (s UserStatus) Validate() error {
+	return validation.Validate(string(s), validation.In(
+		string(UserStatusInvited), string(UserStatusActive), string(UserStatusSuspended), string(UserStatusDeactivated),
+	))
+}
+
+// CanTransitionTo reports whether a user with status s can move to the status to
+func (s UserStatus) CanTransitionTo(to UserStatus) bool {
+	for _, next := range userTransitions[s] {
+		if next == to {
+			return true
+		}
+	}
+	return false
+}
+
+// Transition returns an error wrapping [ErrIllegalTransition] if s cannot move to the status to
+func (s UserStatus) Transition(to UserStatus) error {
+	if !s.CanTransitionTo(to) {
+		return fmt.Errorf("%w from %s to %s", ErrIllegalTransition, s, to)
+	}
+	return nil
+}
+
+// Status Change model
+// @Description Status a user moves to and why
+type StatusChange struct {
+	Status UserStatus `json:"status" example:"suspended"`
+	// Reason is required to suspend or deactivate a user
+	Reason string `json:"reason,omitempty" example:"chargeback fraud"`
+}
+
+func (c StatusChange) Validate() error {
+	return validation.ValidateStruct(&c,
+		validation.Field(&c.Status, validation.Required),
+		validation.Field(&c.Reason,
+			validation.When(c.Status == UserStatusSuspended || c.Status == UserStatusDeactivated, validation.Required),
+			validation.Length(0, 500),
+		),
+	)
+}
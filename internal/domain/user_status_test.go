@@ -0,0 +1,65 @@
+package domain_test
+
+import (
+	"go-project-template/internal/domain"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUserStatus_Transition(t *testing.T) {
+	t.Parallel()
+
+	testCases := map[string]struct {
+		from, to domain.UserStatus
+		wantErr  bool
+	}{
+		"invited to active":          {domain.UserStatusInvited, domain.UserStatusActive, false},
+		"invited to suspended":       {domain.UserStatusInvited, domain.UserStatusSuspended, true},
+		"active to suspended":        {domain.UserStatusActive, domain.UserStatusSuspended, false},
+		"active to active":           {domain.UserStatusActive, domain.UserStatusActive, true},
+		"active to invited":          {domain.UserStatusActive, domain.UserStatusInvited, true},
+		"suspended to active":        {domain.UserStatusSuspended, domain.UserStatusActive, false},
+		"suspended to deactivated":   {domain.UserStatusSuspended, domain.UserStatusDeactivated, false},
+		"deactivated to active":      {domain.UserStatusDeactivated, domain.UserStatusActive, true},
+		"deactivated to deactivated": {domain.UserStatusDeactivated, domain.UserStatusDeactivated, true},
+	}
+
+	for scenario, tc := range testCases { //nolint:paralleltest
+		t.Run(scenario, func(t *testing.T) {
+			err := tc.from.Transition(tc.to)
+			if tc.wantErr {
+				assert.ErrorIs(t, err, domain.ErrIllegalTransition)
+				return
+			}
+			assert.NoError(t, err)
+		})
+	}
+}
+
+func TestStatusChange_Validate(t *testing.T) {
+	t.Parallel()
+
+	testCases := map[string]struct {
+		change  domain.StatusChange
+		wantErr bool
+	}{
+		"activate without reason":   {domain.StatusChange{Status: domain.UserStatusActive}, false},
+		"suspend with reason":       {domain.StatusChange{Status: domain.UserStatusSuspended, Reason: "fraud"}, false},
+		"suspend without reason":    {domain.StatusChange{Status: domain.UserStatusSuspended}, true},
+		"deactivate without reason": {domain.StatusChange{Status: domain.UserStatusDeactivated}, true},
+		"unknown status":            {domain.StatusChange{Status: "banned", Reason: "fraud"}, true},
+		"missing status":            {domain.StatusChange{}, true},
+	}
+
+	for scenario, tc := range testCases { //nolint:paralleltest
+		t.Run(scenario, func(t *testing.T) {
+			err := tc.change.Validate()
+			if tc.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+		})
+	}
+}
@@ -0,0 +1,191 @@
+package domain
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/netip"
+	"net/url"
+	"strings"
+	"time"
+
+	validation "github.com/go-ozzo/ozzo-validation/v4"
+	"github.com/go-ozzo/ozzo-validation/v4/is"
+	"github.com/google/uuid"
+)
+
+// EventTypes lists every event a webhook can subscribe to
+var EventTypes = []string{EventUserCreated, EventUserUpdated, EventUserDeleted, EventUserStatusChanged}
+
+// Webhook model
+// @Description Subscription that receives signed callbacks for domain events
+type Webhook struct {
+	ID uuid.UUID `json:"id" example:"0b8f1c3e-5a8e-4c1e-9a51-7f1d2c3b4a5e"`
+	// TenantID is the tenant whose events the webhook receives, set from the request
+	TenantID string `json:"tenant_id" example:"acme"`
+	URL      string `json:"url" example:"https://example.com/hooks/users"`
+	// Secret signs every delivery. It is only returned when the webhook is created.
+	Secret string `json:"secret,omitempty" example:"whsec_3b1f..."`
+	// EventTypes filters the events delivered, an empty list receives every event
+	EventTypes []string `json:"event_types" example:"user.created,user.deleted"`
+	Active     bool     `json:"active" example:"true"`
+	// ConsecutiveFailures counts failed attempts since the last successful delivery
+	ConsecutiveFailures int        `json:"consecutive_failures" example:"0"`
+	DisabledAt          *time.Time `json:"disabled_at,omitempty"`
+	CreatedAt           time.Time  `json:"created_at"`
+	UpdatedAt           time.Time  `json:"updated_at"`
+}
+
+// Validate checks the webhook. Its URL has to name a public host, webhooks
+// must not make the server call its own network.
+func (w *Webhook) Validate() error {
+	return w.validate(validation.By(publicWebhookURL))
+}
+
+// ValidateAllowPrivate is [Webhook.Validate] for development setups whose
+// receivers run on loopback or private addresses
+func (w *Webhook) ValidateAllowPrivate() error {
+	return w.validate()
+}
+
+func (w *Webhook) validate(urlRules ...validation.Rule) error {
+	eventTypes := make([]interface{}, len(EventTypes))
+	for i, t := range EventTypes {
+		eventTypes[i] = t
+	}
+
+	return validation.ValidateStruct(w,
+		validation.Field(&w.URL, append([]validation.Rule{validation.Required, is.RequestURL}, urlRules...)...),
+		validation.Field(&w.EventTypes, validation.Each(validation.In(eventTypes...))),
+	)
+}
+
+// ErrWebhookHostNotPublic rejects webhook URLs of loopback, link-local and private hosts
+var ErrWebhookHostNotPublic = errors.New("must point to a public host")
+
+// nonPublicPrefixes are reserved ranges netip does not classify
+var nonPublicPrefixes = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),
+	netip.MustParsePrefix("100.64.0.0/10"),
+	netip.MustParsePrefix("192.0.0.0/24"),
+	netip.MustParsePrefix("198.18.0.0/15"),
+	netip.MustParsePrefix("240.0.0.0/4"),
+	netip.MustParsePrefix("64:ff9b::/96"),
+}
+
+// PublicAddr reports whether addr is a public unicast address, and not one of
+// the loopback, link-local, private or otherwise reserved ranges
+func PublicAddr(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	if !addr.IsGlobalUnicast() || addr.IsPrivate() {
+		return false
+	}
+	for _, p := range nonPublicPrefixes {
+		if p.Contains(addr) {
+			return false
+		}
+	}
+	return true
+}
+
+// publicWebhookURL rejects URLs naming localhost or a non-public address. Other
+// names are checked once they are resolved, when a delivery connects.
+func publicWebhookURL(value interface{}) error {
+	u, err := url.Parse(value.(string))
+	if err != nil {
+		return nil
+	}
+
+	host := strings.ToLower(strings.TrimSuffix(u.Hostname(), "."))
+	if host == "localhost" || strings.HasSuffix(host, ".localhost") {
+		return ErrWebhookHostNotPublic
+	}
+	if addr, err := netip.ParseAddr(host); err == nil && !PublicAddr(addr) {
+		return ErrWebhookHostNotPublic
+	}
+	return nil
+}
+
+// Subscribes reports whether events of the given type are delivered to the webhook
+func (w *Webhook) Subscribes(eventType string) bool {
+	if len(w.EventTypes) == 0 {
+		return true
+	}
+	for _, t := range w.EventTypes {
+		if t == eventType {
+			return true
+		}
+	}
+	return false
+}
+
+type DeliveryStatus string
+
+const (
+	DeliveryStatusPending   DeliveryStatus = "pending"
+	DeliveryStatusSucceeded DeliveryStatus = "succeeded"
+	// DeliveryStatusFailed marks a delivery that ran out of attempts
+	DeliveryStatusFailed DeliveryStatus = "failed"
+)
+
+// Webhook Delivery model
+// @Description A single event sent to a webhook and the outcome of its last attempt
+type WebhookDelivery struct {
+	ID            uuid.UUID       `json:"id" example:"5c1b9a0e-2f7d-4a8b-9c3e-1d2f3a4b5c6d"`
+	WebhookID     uuid.UUID       `json:"webhook_id" example:"0b8f1c3e-5a8e-4c1e-9a51-7f1d2c3b4a5e"`
+	EventID       int64           `json:"event_id" example:"42"`
+	EventType     string          `json:"event_type" example:"user.created"`
+	Payload       json.RawMessage `json:"payload" swaggertype:"object"`
+	Status        DeliveryStatus  `json:"status" example:"succeeded"`
+	Attempts      int             `json:"attempts" example:"1"`
+	NextAttemptAt time.Time       `json:"next_attempt_at"`
+	ResponseCode  *int            `json:"response_code,omitempty" example:"200"`
+	LastError     *string         `json:"last_error,omitempty"`
+	DurationMS    *int            `json:"duration_ms,omitempty" example:"87"`
+	CreatedAt     time.Time       `json:"created_at"`
+	DeliveredAt   *time.Time      `json:"delivered_at,omitempty"`
+	// Webhook is the subscription a claimed delivery is sent to. Only
+	// ClaimDeliveries sets it, its caller has no tenant to look the webhook up.
+	Webhook *Webhook `json:"-"`
+}
+
+// DeliveryAttempt is the outcome of sending a delivery once
+type DeliveryAttempt struct {
+	ResponseCode *int
+	Duration     time.Duration
+	Err          error
+}
+
+// WebhookRepository stores webhook subscriptions and their delivery log.
+// Create, GetByID, List, Update, Delete, Deliveries and Redeliver are scoped to
+// the tenant of the context. The methods the dispatcher calls work across
+// tenants and need none. Updates to a claimed delivery only succeed while the
+// caller still holds it, otherwise [ErrNotFound] is returned.
+type WebhookRepository interface {
+	Create(ctx context.Context, w *Webhook) (*Webhook, error)
+	GetByID(ctx context.Context, id uuid.UUID) (*Webhook, error)
+	List(ctx context.Context) ([]Webhook, error)
+	// Update changes the URL, event types and active flag. Activating a webhook
+	// clears its failure count.
+	Update(ctx context.Context, w *Webhook) (*Webhook, error)
+	Delete(ctx context.Context, id uuid.UUID) error
+	// Disable deactivates a webhook so no further deliveries are attempted
+	Disable(ctx context.Context, id uuid.UUID) error
+
+	// FanOut creates a delivery for every active webhook of the event's tenant
+	// subscribed to each of up to limit outbox events that were not fanned out
+	// yet. It returns how many events were processed.
+	FanOut(ctx context.Context, limit int) (int, error)
+	// ClaimDeliveries takes up to limit due deliveries of active webhooks, with
+	// their webhook, and hides them from other dispatchers for the lease duration
+	ClaimDeliveries(ctx context.Context, limit int, lease time.Duration) ([]WebhookDelivery, error)
+	// CompleteDelivery records a successful attempt and resets the webhook failure count
+	CompleteDelivery(ctx context.Context, d *WebhookDelivery, a DeliveryAttempt) error
+	// FailDelivery records a failed attempt. The delivery is retried at retryAt, or
+	// marked failed if retryAt is nil. It returns the webhook's consecutive failures.
+	FailDelivery(ctx context.Context, d *WebhookDelivery, a DeliveryAttempt, retryAt *time.Time) (int, error)
+	// Deliveries returns the most recent deliveries of a webhook, newest first
+	Deliveries(ctx context.Context, webhookID uuid.UUID, limit int) ([]WebhookDelivery, error)
+	// Redeliver queues a new delivery with the payload of an earlier one
+	Redeliver(ctx context.Context, webhookID uuid.UUID, deliveryID uuid.UUID) (*WebhookDelivery, error)
+}
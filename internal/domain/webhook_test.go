@@ -0,0 +1,57 @@
+package domain_test
+
+import (
+	"go-project-template/internal/domain"
+	"net/netip"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWebhook_Validate(t *testing.T) {
+	t.Parallel()
+
+	testCases := map[string]struct {
+		url     string
+		public  bool
+		private bool
+	}{
+		"public":        {"https://example.com/hooks", true, true},
+		"public ip":     {"https://93.184.216.34/hooks", true, true},
+		"localhost":     {"http://localhost:8080/hooks", false, true},
+		"loopback":      {"http://127.0.0.1/hooks", false, true},
+		"loopback ipv6": {"http://[::1]/hooks", false, true},
+		"metadata":      {"http://169.254.169.254/latest/meta-data", false, true},
+		"private":       {"http://10.0.0.5/hooks", false, true},
+		"shared":        {"http://100.64.0.1/hooks", false, true},
+		"mapped":        {"http://[::ffff:192.168.0.1]/hooks", false, true},
+		"not a url":     {"example", false, false},
+	}
+
+	for scenario, tc := range testCases { //nolint:paralleltest
+		t.Run(scenario, func(t *testing.T) {
+			w := &domain.Webhook{URL: tc.url}
+			assert.Equal(t, tc.public, w.Validate() == nil, "Validate")
+			assert.Equal(t, tc.private, w.ValidateAllowPrivate() == nil, "ValidateAllowPrivate")
+		})
+	}
+}
+
+func TestPublicAddr(t *testing.T) {
+	t.Parallel()
+
+	for addr, want := range map[string]bool{
+		"8.8.8.8":         true,
+		"2606:4700::1111": true,
+		"127.0.0.1":       false,
+		"0.0.0.0":         false,
+		"169.254.169.254": false,
+		"172.16.3.4":      false,
+		"192.168.1.1":     false,
+		"fe80::1":         false,
+		"fd00::1":         false,
+		"224.0.0.1":       false,
+	} {
+		assert.Equal(t, want, domain.PublicAddr(netip.MustParseAddr(addr)), addr)
+	}
+}
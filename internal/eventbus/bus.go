@@ -0,0 +1,181 @@
+// Package eventbus delivers domain events to in-process subscribers.
+//
+// Synchronous subscribers run in the publisher's goroutine in the order they
+// subscribed, so they see an event before Publish returns. Asynchronous
+// subscribers each get a queue drained by their own goroutine, so they receive
+// the events of a Publish call in order without slowing down the publisher.
+// Concurrent Publish calls are not ordered against each other. A failing or
+// panicking subscriber never keeps an event from the others.
+package eventbus
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"go-project-template/internal/domain"
+	"sync"
+
+	"go.uber.org/zap"
+)
+
+const defaultQueueSize = 1024
+
+// Handler reacts to a domain event
+type Handler func(ctx context.Context, e domain.DomainEvent) error
+
+type subscriber struct {
+	name    string
+	handler Handler
+	types   map[string]bool
+
+	// queue is nil for synchronous subscribers
+	queue chan delivery
+	done  chan struct{}
+}
+
+type delivery struct {
+	ctx   context.Context
+	event domain.DomainEvent
+}
+
+func (s *subscriber) wants(e domain.DomainEvent) bool {
+	return len(s.types) == 0 || s.types[e.EventType()]
+}
+
+type Bus struct {
+	logger *zap.Logger
+
+	// mu guards subs and closed, it is not held while events are delivered
+	mu     sync.Mutex
+	subs   []*subscriber
+	closed bool
+	// publishing counts the Publish calls in flight, Close waits for them
+	// before closing the queues
+	publishing sync.WaitGroup
+}
+
+func New(logger *zap.Logger) *Bus {
+	return &Bus{logger: logger}
+}
+
+// Subscribe registers a handler that runs before Publish returns. Without
+// types it receives every event.
+func (b *Bus) Subscribe(name string, h Handler, types ...string) {
+	b.add(&subscriber{name: name, handler: h, types: typeSet(types)})
+}
+
+// SubscribeAsync registers a handler that runs on its own goroutine. Its queue
+// holds up to 1024 events, beyond that Publish waits for it to catch up.
+func (b *Bus) SubscribeAsync(name string, h Handler, types ...string) {
+	s := &subscriber{
+		name:    name,
+		handler: h,
+		types:   typeSet(types),
+		queue:   make(chan delivery, defaultQueueSize),
+		done:    make(chan struct{}),
+	}
+	b.add(s)
+
+	go func() {
+		defer close(s.done)
+		for d := range s.queue {
+			if err := b.call(d.ctx, s, d.event); err != nil {
+				b.logger.Error("event subscriber failed", zap.String("subscriber", s.name), zap.String("event#type", d.event.EventType()), zap.Error(err))
+			}
+		}
+	}()
+}
+
+func typeSet(types []string) map[string]bool {
+	set := make(map[string]bool, len(types))
+	for _, t := range types {
+		set[t] = true
+	}
+	return set
+}
+
+func (b *Bus) add(s *subscriber) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	// Copy on write, Publish reads the slice it took without the lock
+	b.subs = append(b.subs[:len(b.subs):len(b.subs)], s)
+}
+
+// Publish delivers the events in order. It returns the errors of synchronous
+// subscribers, which are also logged. Errors of asynchronous subscribers are
+// only logged.
+func (b *Bus) Publish(ctx context.Context, events ...domain.DomainEvent) error {
+	b.mu.Lock()
+	if b.closed {
+		b.mu.Unlock()
+		return errors.New("event bus is closed")
+	}
+	// A full async queue blocks the send, so it happens without the lock
+	subs := b.subs
+	b.publishing.Add(1)
+	b.mu.Unlock()
+	defer b.publishing.Done()
+
+	var errs []error
+	for _, e := range events {
+		for _, s := range subs {
+			if !s.wants(e) {
+				continue
+			}
+
+			if s.queue != nil {
+				// Async handlers outlive the request that published the event
+				s.queue <- delivery{ctx: context.WithoutCancel(ctx), event: e}
+				continue
+			}
+
+			if err := b.call(ctx, s, e); err != nil {
+				b.logger.Error("event subscriber failed", zap.String("subscriber", s.name), zap.String("event#type", e.EventType()), zap.Error(err))
+				errs = append(errs, fmt.Errorf("%s: %w", s.name, err))
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// call runs a handler, turning a panic into an error
+func (b *Bus) call(ctx context.Context, s *subscriber, e domain.DomainEvent) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+	return s.handler(ctx, e)
+}
+
+// Close stops accepting events and waits for asynchronous subscribers to drain their queues
+func (b *Bus) Close() {
+	b.mu.Lock()
+	if b.closed {
+		b.mu.Unlock()
+		return
+	}
+	b.closed = true
+	subs := b.subs
+	b.mu.Unlock()
+
+	b.publishing.Wait()
+	for _, s := range subs {
+		if s.queue != nil {
+			close(s.queue)
+			<-s.done
+		}
+	}
+}
+
+// LogHandler logs every event at debug level, which is handy as an audit trail while developing
+func LogHandler(logger *zap.Logger) Handler {
+	return func(_ context.Context, e domain.DomainEvent) error {
+		logger.Debug("domain event",
+			zap.Int64("event#id", e.Metadata().ID),
+			zap.String("event#type", e.EventType()),
+			zap.String("aggregate#id", e.AggregateID().String()),
+		)
+		return nil
+	}
+}
@@ -0,0 +1,159 @@
+package eventbus_test
+
+import (
+	"context"
+	"errors"
+	"go-project-template/internal/domain"
+	"go-project-template/internal/eventbus"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+// recorder collects the IDs of the events a handler received
+type recorder struct {
+	mu  sync.Mutex
+	ids []int64
+}
+
+func (r *recorder) handle(_ context.Context, e domain.DomainEvent) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.ids = append(r.ids, e.Metadata().ID)
+	return nil
+}
+
+func (r *recorder) received() []int64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.ids
+}
+
+func created(id int64) domain.DomainEvent {
+	return domain.UserCreated{EventMeta: domain.EventMeta{ID: id}, User: domain.User{UUID: uuid.New()}}
+}
+
+func deleted(id int64) domain.DomainEvent {
+	return domain.UserDeleted{EventMeta: domain.EventMeta{ID: id}, UUID: uuid.New()}
+}
+
+func TestBus_Ordering(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	bus := eventbus.New(zap.NewNop())
+	syncRec, asyncRec := &recorder{}, &recorder{}
+	bus.Subscribe("sync", syncRec.handle)
+	bus.SubscribeAsync("async", asyncRec.handle)
+
+	require.NoError(t, bus.Publish(ctx, created(1), created(2)))
+	// Synchronous subscribers have seen the events once Publish returns
+	assert.Equal(t, []int64{1, 2}, syncRec.received())
+
+	var wg sync.WaitGroup
+	for i := int64(3); i <= 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			assert.NoError(t, bus.Publish(ctx, created(i)))
+		}()
+	}
+	wg.Wait()
+
+	bus.Close()
+	// Both kinds of subscribers see every concurrent publish
+	assert.Len(t, asyncRec.received(), 50)
+	assert.ElementsMatch(t, syncRec.received(), asyncRec.received())
+}
+
+func TestBus_PublishFromHandler(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	bus := eventbus.New(zap.NewNop())
+	rec := &recorder{}
+	bus.Subscribe("deleted", rec.handle, domain.EventUserDeleted)
+	// The bus is not locked while handlers run, so a handler can publish
+	bus.Subscribe("cascade", func(ctx context.Context, e domain.DomainEvent) error {
+		return bus.Publish(ctx, deleted(e.Metadata().ID+100))
+	}, domain.EventUserCreated)
+
+	done := make(chan error)
+	go func() { done <- bus.Publish(ctx, created(1)) }()
+
+	select {
+	case err := <-done:
+		require.NoError(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("publish from a handler did not return")
+	}
+	assert.Equal(t, []int64{101}, rec.received())
+}
+
+func TestBus_Filter(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	bus := eventbus.New(zap.NewNop())
+	all, onlyDeleted := &recorder{}, &recorder{}
+	bus.Subscribe("all", all.handle)
+	bus.SubscribeAsync("deleted", onlyDeleted.handle, domain.EventUserDeleted)
+
+	require.NoError(t, bus.Publish(ctx, created(1), deleted(2), created(3), deleted(4)))
+	bus.Close()
+
+	assert.Equal(t, []int64{1, 2, 3, 4}, all.received())
+	assert.Equal(t, []int64{2, 4}, onlyDeleted.received())
+}
+
+func TestBus_Isolation(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	errBoom := errors.New("boom")
+
+	bus := eventbus.New(zap.NewNop())
+	before, after, async := &recorder{}, &recorder{}, &recorder{}
+	bus.Subscribe("before", before.handle)
+	bus.Subscribe("failing", func(context.Context, domain.DomainEvent) error { return errBoom })
+	bus.Subscribe("panicking", func(context.Context, domain.DomainEvent) error { panic("boom") })
+	bus.SubscribeAsync("async panicking", func(context.Context, domain.DomainEvent) error { panic("boom") })
+	bus.Subscribe("after", after.handle)
+	bus.SubscribeAsync("async", async.handle)
+
+	err := bus.Publish(ctx, created(1), created(2))
+	assert.ErrorIs(t, err, errBoom)
+	assert.ErrorContains(t, err, "panicking: panic: boom")
+
+	bus.Close()
+	for _, r := range []*recorder{before, after, async} {
+		assert.Equal(t, []int64{1, 2}, r.received())
+	}
+
+	assert.Error(t, bus.Publish(ctx, created(3)), "a closed bus rejects events")
+}
+
+func TestBus_AsyncOutlivesRequest(t *testing.T) {
+	t.Parallel()
+
+	bus := eventbus.New(zap.NewNop())
+	ctxErr := make(chan error, 1)
+	release := make(chan struct{})
+	bus.SubscribeAsync("slow", func(ctx context.Context, _ domain.DomainEvent) error {
+		<-release
+		ctxErr <- ctx.Err()
+		return nil
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	require.NoError(t, bus.Publish(ctx, created(1)))
+	cancel()
+	close(release)
+
+	bus.Close()
+	assert.NoError(t, <-ctxErr)
+}
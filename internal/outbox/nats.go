@@ -0,0 +1,226 @@
+package outbox
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go-project-template/internal/domain"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const natsTimeout = 5 * time.Second
+
+// NATSPublisher publishes events to a NATS compatible server on the subject
+// <prefix>.<event type>. It speaks just enough of the client protocol to publish:
+// the connection runs in verbose mode so every message is acknowledged by the
+// server before Publish returns, and the event ID is sent in the Nats-Msg-Id
+// header so JetStream can drop redelivered events.
+type NATSPublisher struct {
+	addr   string
+	user   *url.Userinfo
+	prefix string
+
+	mu   sync.Mutex
+	conn net.Conn
+	r    *bufio.Reader
+}
+
+// NewNATSPublisher returns a [NATSPublisher] for a nats://[user:pass@]host:port URL.
+// The connection is opened on the first publish.
+func NewNATSPublisher(rawURL string, prefix string) (*NATSPublisher, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme != "nats" {
+		return nil, fmt.Errorf("unsupported NATS url scheme %q", u.Scheme)
+	}
+
+	addr := u.Host
+	if u.Port() == "" {
+		addr = net.JoinHostPort(u.Hostname(), "4222")
+	}
+
+	return &NATSPublisher{addr: addr, user: u.User, prefix: prefix}, nil
+}
+
+func (p *NATSPublisher) Publish(ctx context.Context, e domain.Event) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	body, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+
+	if p.conn == nil {
+		if err := p.connect(ctx); err != nil {
+			return err
+		}
+	}
+
+	if err := p.publish(ctx, p.subject(e), strconv.FormatInt(e.ID, 10), body); err != nil {
+		// The connection is in an unknown state, start over on the next publish
+		p.close()
+		return err
+	}
+	return nil
+}
+
+func (p *NATSPublisher) Close() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.close()
+	return nil
+}
+
+func (p *NATSPublisher) subject(e domain.Event) string {
+	if p.prefix == "" {
+		return e.Type
+	}
+	return p.prefix + "." + e.Type
+}
+
+func (p *NATSPublisher) close() {
+	if p.conn != nil {
+		_ = p.conn.Close()
+	}
+	p.conn = nil
+	p.r = nil
+}
+
+func (p *NATSPublisher) deadline(ctx context.Context) time.Time {
+	d := time.Now().Add(natsTimeout)
+	if cd, ok := ctx.Deadline(); ok && cd.Before(d) {
+		return cd
+	}
+	return d
+}
+
+func (p *NATSPublisher) connect(ctx context.Context) error {
+	dialer := net.Dialer{Deadline: p.deadline(ctx)}
+	conn, err := dialer.DialContext(ctx, "tcp", p.addr)
+	if err != nil {
+		return err
+	}
+	p.conn = conn
+	p.r = bufio.NewReader(conn)
+
+	if err := p.handshake(ctx); err != nil {
+		p.close()
+		return err
+	}
+	return nil
+}
+
+func (p *NATSPublisher) handshake(ctx context.Context) error {
+	if err := p.conn.SetDeadline(p.deadline(ctx)); err != nil {
+		return err
+	}
+
+	line, err := p.readLine()
+	if err != nil {
+		return err
+	}
+	if !strings.HasPrefix(line, "INFO ") {
+		return fmt.Errorf("unexpected NATS greeting %q", line)
+	}
+
+	var info struct {
+		Headers bool `json:"headers"`
+	}
+	if err := json.Unmarshal([]byte(strings.TrimPrefix(line, "INFO ")), &info); err != nil {
+		return err
+	}
+	if !info.Headers {
+		return errors.New("NATS server does not support headers")
+	}
+
+	opts := map[string]interface{}{
+		"verbose":  true,
+		"pedantic": false,
+		"headers":  true,
+		"name":     "project-outbox-relay",
+		"lang":     "go",
+		"protocol": 1,
+	}
+	if p.user != nil {
+		if pass, ok := p.user.Password(); ok {
+			opts["user"] = p.user.Username()
+			opts["pass"] = pass
+		} else {
+			opts["auth_token"] = p.user.Username()
+		}
+	}
+
+	b, err := json.Marshal(opts)
+	if err != nil {
+		return err
+	}
+	if _, err := fmt.Fprintf(p.conn, "CONNECT %s\r\nPING\r\n", b); err != nil {
+		return err
+	}
+
+	// Verbose mode acknowledges CONNECT, the PONG confirms the server accepted it
+	if err := p.awaitAck(); err != nil {
+		return err
+	}
+	return p.await("PONG")
+}
+
+func (p *NATSPublisher) publish(ctx context.Context, subject string, msgID string, body []byte) error {
+	if err := p.conn.SetDeadline(p.deadline(ctx)); err != nil {
+		return err
+	}
+
+	header := "NATS/1.0\r\nNats-Msg-Id: " + msgID + "\r\n\r\n"
+	if _, err := fmt.Fprintf(p.conn, "HPUB %s %d %d\r\n%s%s\r\n", subject, len(header), len(header)+len(body), header, body); err != nil {
+		return err
+	}
+	return p.awaitAck()
+}
+
+func (p *NATSPublisher) awaitAck() error {
+	return p.await("+OK")
+}
+
+// await reads until want, answering server pings on the way
+func (p *NATSPublisher) await(want string) error {
+	for {
+		line, err := p.readLine()
+		if err != nil {
+			return err
+		}
+
+		switch {
+		case line == want:
+			return nil
+		case line == "PING":
+			if _, err := p.conn.Write([]byte("PONG\r\n")); err != nil {
+				return err
+			}
+		case strings.HasPrefix(line, "-ERR"):
+			return fmt.Errorf("NATS server error: %s", strings.TrimSpace(strings.TrimPrefix(line, "-ERR")))
+		case strings.HasPrefix(line, "INFO "), line == "+OK", line == "PONG":
+			// Cluster updates and acknowledgements of earlier commands
+		default:
+			return fmt.Errorf("unexpected NATS message %q", line)
+		}
+	}
+}
+
+func (p *NATSPublisher) readLine() (string, error) {
+	line, err := p.r.ReadString('\n')
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
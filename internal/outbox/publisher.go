@@ -0,0 +1,87 @@
+package outbox
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"go-project-template/internal/domain"
+	"io"
+	"net/http"
+	"strconv"
+
+	"go.uber.org/zap"
+)
+
+// Publisher delivers an event to downstream consumers. Events may be delivered
+// more than once, consumers deduplicate on the event ID.
+type Publisher interface {
+	Publish(ctx context.Context, e domain.Event) error
+	Close() error
+}
+
+// LogPublisher writes events to the log. It is meant for development.
+type LogPublisher struct {
+	logger *zap.Logger
+}
+
+func NewLogPublisher(logger *zap.Logger) *LogPublisher {
+	return &LogPublisher{logger: logger}
+}
+
+func (p *LogPublisher) Publish(_ context.Context, e domain.Event) error {
+	p.logger.Info("published event",
+		zap.Int64("event#id", e.ID),
+		zap.String("event#type", e.Type),
+		zap.String("aggregate#id", e.AggregateID.String()),
+		zap.ByteString("payload", e.Payload),
+	)
+	return nil
+}
+
+func (p *LogPublisher) Close() error {
+	return nil
+}
+
+// WebhookPublisher POSTs every event as JSON to a single URL. Any response
+// other than 2xx is a failed delivery.
+type WebhookPublisher struct {
+	url    string
+	client *http.Client
+}
+
+func NewWebhookPublisher(url string, client *http.Client) *WebhookPublisher {
+	return &WebhookPublisher{url: url, client: client}
+}
+
+func (p *WebhookPublisher) Publish(ctx context.Context, e domain.Event) error {
+	body, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Event-ID", strconv.FormatInt(e.ID, 10))
+	req.Header.Set("X-Event-Type", e.Type)
+
+	res, err := p.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(res.Body, 1<<16))
+
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return fmt.Errorf("webhook responded with %s", res.Status)
+	}
+	return nil
+}
+
+func (p *WebhookPublisher) Close() error {
+	p.client.CloseIdleConnections()
+	return nil
+}
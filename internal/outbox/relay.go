@@ -0,0 +1,181 @@
+// Package outbox relays domain events recorded in the outbox table to a publisher.
+//
+// A single elected relay publishes events in ID order and only marks an event
+// published after the publisher accepted it. A failed event blocks the ones after
+// it until it goes through, so consumers see events of an aggregate in order and
+// at least once.
+package outbox
+
+import (
+	"context"
+	"errors"
+	"go-project-template/internal/domain"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// Lock elects the leader among relay replicas
+type Lock interface {
+	TryLock(ctx context.Context) (bool, error)
+	Held(ctx context.Context) bool
+	Unlock(ctx context.Context) error
+}
+
+type Config struct {
+	// BatchSize is how many events are read from the outbox at once
+	BatchSize int
+	// PollInterval is the wait between polls when the outbox is empty
+	PollInterval time.Duration
+	// ElectionInterval is how often a follower tries to become leader
+	ElectionInterval time.Duration
+	// BackoffBase and BackoffMax bound the wait after a failed delivery
+	BackoffBase time.Duration
+	BackoffMax  time.Duration
+	// Retention is how long published events are kept, zero keeps them forever
+	Retention time.Duration
+}
+
+func DefaultConfig() Config {
+	return Config{
+		BatchSize:        100,
+		PollInterval:     500 * time.Millisecond,
+		ElectionInterval: 10 * time.Second,
+		BackoffBase:      1 * time.Second,
+		BackoffMax:       1 * time.Minute,
+		Retention:        7 * 24 * time.Hour,
+	}
+}
+
+const purgeInterval = 1 * time.Hour
+
+type Relay struct {
+	repo      domain.OutboxRepository
+	publisher Publisher
+	lock      Lock
+	logger    *zap.Logger
+	cfg       Config
+}
+
+func New(repo domain.OutboxRepository, publisher Publisher, lock Lock, logger *zap.Logger, cfg Config) *Relay {
+	return &Relay{
+		repo:      repo,
+		publisher: publisher,
+		lock:      lock,
+		logger:    logger,
+		cfg:       cfg,
+	}
+}
+
+// Run campaigns for leadership and relays events while leading, until ctx is done
+func (r *Relay) Run(ctx context.Context) error {
+	for {
+		leading, err := r.lock.TryLock(ctx)
+		if err != nil && ctx.Err() == nil {
+			r.logger.Error("leader election failed", zap.Error(err))
+		}
+
+		if leading {
+			r.logger.Info("acquired relay leadership")
+			err := r.lead(ctx)
+			if uerr := r.lock.Unlock(context.WithoutCancel(ctx)); uerr != nil {
+				r.logger.Error("failed to release relay leadership", zap.Error(uerr))
+			}
+			if err != nil {
+				r.logger.Error("lost relay leadership", zap.Error(err))
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(r.cfg.ElectionInterval):
+		}
+	}
+}
+
+var errLostLeadership = errors.New("leader lock connection lost")
+
+func (r *Relay) lead(ctx context.Context) error {
+	failures := 0
+	lastPurge := time.Time{}
+
+	for {
+		if time.Since(lastPurge) >= purgeInterval {
+			r.purge(ctx)
+			lastPurge = time.Now()
+		}
+
+		n, err := r.RelayBatch(ctx)
+
+		wait := time.Duration(0)
+		switch {
+		case err != nil && ctx.Err() == nil:
+			failures++
+			wait = r.backoff(failures)
+			r.logger.Error("failed to relay events", zap.Error(err), zap.Duration("retry_in", wait))
+		case n < r.cfg.BatchSize:
+			failures = 0
+			wait = r.cfg.PollInterval
+		default:
+			failures = 0
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(wait):
+		}
+
+		if !r.lock.Held(ctx) {
+			return errLostLeadership
+		}
+	}
+}
+
+// RelayBatch publishes the next batch of pending events in order. It stops at the
+// first event that cannot be published and returns how many were published.
+func (r *Relay) RelayBatch(ctx context.Context) (int, error) {
+	events, err := r.repo.Pending(ctx, r.cfg.BatchSize)
+	if err != nil {
+		return 0, err
+	}
+
+	for i, e := range events {
+		if err := r.publisher.Publish(ctx, e); err != nil {
+			if merr := r.repo.MarkFailed(context.WithoutCancel(ctx), e.ID, err); merr != nil {
+				r.logger.Error("failed to record delivery failure", zap.Int64("event#id", e.ID), zap.Error(merr))
+			}
+			return i, err
+		}
+
+		// If this fails the event is published again, which at-least-once allows
+		if err := r.repo.MarkPublished(ctx, e.ID); err != nil {
+			return i, err
+		}
+	}
+	return len(events), nil
+}
+
+func (r *Relay) purge(ctx context.Context) {
+	if r.cfg.Retention <= 0 {
+		return
+	}
+
+	n, err := r.repo.DeletePublished(ctx, time.Now().Add(-r.cfg.Retention))
+	if err != nil {
+		r.logger.Error("failed to purge published events", zap.Error(err))
+		return
+	}
+	if n > 0 {
+		r.logger.Info("purged published events", zap.Int64("count", n))
+	}
+}
+
+func (r *Relay) backoff(failures int) time.Duration {
+	d := r.cfg.BackoffBase
+	for i := 1; i < failures && d < r.cfg.BackoffMax; i++ {
+		d *= 2
+	}
+	return min(d, r.cfg.BackoffMax)
+}
@@ -0,0 +1,261 @@
+package outbox_test
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go-project-template/internal/domain"
+	"go-project-template/internal/outbox"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+type fakeOutboxRepository struct {
+	mu        sync.Mutex
+	events    []domain.Event
+	published map[int64]bool
+	failures  map[int64]int
+}
+
+func newFakeOutboxRepository(n int) *fakeOutboxRepository {
+	f := &fakeOutboxRepository{published: map[int64]bool{}, failures: map[int64]int{}}
+	for i := 1; i <= n; i++ {
+		f.events = append(f.events, domain.Event{
+			ID:            int64(i),
+			AggregateType: domain.AggregateUser,
+			AggregateID:   uuid.New(),
+			Type:          domain.EventUserCreated,
+			Payload:       json.RawMessage(`{}`),
+			OccurredAt:    time.Now(),
+		})
+	}
+	return f
+}
+
+func (f *fakeOutboxRepository) Pending(_ context.Context, limit int) ([]domain.Event, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	var ee []domain.Event
+	for _, e := range f.events {
+		if !f.published[e.ID] && len(ee) < limit {
+			ee = append(ee, e)
+		}
+	}
+	return ee, nil
+}
+
+func (f *fakeOutboxRepository) MarkPublished(_ context.Context, id int64) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.published[id] = true
+	return nil
+}
+
+func (f *fakeOutboxRepository) MarkFailed(_ context.Context, id int64, _ error) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.failures[id]++
+	return nil
+}
+
+func (f *fakeOutboxRepository) DeletePublished(_ context.Context, _ time.Time) (int64, error) {
+	return 0, nil
+}
+
+func (f *fakeOutboxRepository) After(_ context.Context, _ string, _ int64, _ int) ([]domain.Event, error) {
+	return nil, nil
+}
+
+func (f *fakeOutboxRepository) LastID(_ context.Context) (int64, error) {
+	return int64(len(f.events)), nil
+}
+
+func (f *fakeOutboxRepository) Horizon(_ context.Context) (int64, int64, error) {
+	return 1, 1, nil
+}
+
+// fakePublisher records published event IDs and fails the ones listed in failOn
+type fakePublisher struct {
+	mu     sync.Mutex
+	ids    []int64
+	failOn map[int64]bool
+}
+
+func (p *fakePublisher) Publish(_ context.Context, e domain.Event) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.failOn[e.ID] {
+		return errors.New("unavailable")
+	}
+	p.ids = append(p.ids, e.ID)
+	return nil
+}
+
+func (p *fakePublisher) Close() error { return nil }
+
+func TestRelay_RelayBatch(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	cfg := outbox.DefaultConfig()
+	cfg.BatchSize = 3
+
+	repo := newFakeOutboxRepository(5)
+	pub := &fakePublisher{failOn: map[int64]bool{}}
+	r := outbox.New(repo, pub, nil, zap.NewNop(), cfg)
+
+	n, err := r.RelayBatch(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 3, n)
+
+	// A failing event blocks the events after it
+	pub.failOn[5] = true
+	n, err = r.RelayBatch(ctx)
+	assert.Error(t, err)
+	assert.Equal(t, 1, n)
+	assert.Equal(t, 1, repo.failures[5])
+
+	pub.failOn[5] = false
+	n, err = r.RelayBatch(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 1, n)
+
+	assert.Equal(t, []int64{1, 2, 3, 4, 5}, pub.ids)
+}
+
+type fakeLock struct{}
+
+func (fakeLock) TryLock(_ context.Context) (bool, error) { return true, nil }
+func (fakeLock) Held(_ context.Context) bool             { return true }
+func (fakeLock) Unlock(_ context.Context) error          { return nil }
+
+func TestRelay_Run(t *testing.T) {
+	t.Parallel()
+
+	cfg := outbox.DefaultConfig()
+	cfg.BatchSize = 2
+	cfg.PollInterval = 10 * time.Millisecond
+
+	repo := newFakeOutboxRepository(5)
+	pub := &fakePublisher{}
+	r := outbox.New(repo, pub, fakeLock{}, zap.NewNop(), cfg)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	require.NoError(t, r.Run(ctx))
+
+	assert.Equal(t, []int64{1, 2, 3, 4, 5}, pub.ids)
+}
+
+func TestWebhookPublisher(t *testing.T) {
+	t.Parallel()
+
+	testCases := map[string]struct {
+		status int
+		err    bool
+	}{
+		"accepted": {http.StatusAccepted, false},
+		"rejected": {http.StatusServiceUnavailable, true},
+	}
+
+	for scenario, tc := range testCases { //nolint:paralleltest
+		t.Run(scenario, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				assert.Equal(t, "7", r.Header.Get("X-Event-ID"))
+				assert.Equal(t, domain.EventUserDeleted, r.Header.Get("X-Event-Type"))
+
+				var e domain.Event
+				assert.NoError(t, json.NewDecoder(r.Body).Decode(&e))
+				assert.Equal(t, int64(7), e.ID)
+				w.WriteHeader(tc.status)
+			}))
+			defer srv.Close()
+
+			p := outbox.NewWebhookPublisher(srv.URL, srv.Client())
+			err := p.Publish(context.Background(), domain.Event{ID: 7, Type: domain.EventUserDeleted, Payload: json.RawMessage(`{}`)})
+			if tc.err {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+		})
+	}
+}
+
+// serveNATS accepts a single connection and plays the server side of the protocol
+func serveNATS(t *testing.T, ln net.Listener, published chan<- string) {
+	t.Helper()
+
+	conn, err := ln.Accept()
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+
+	r := bufio.NewReader(conn)
+	_, _ = conn.Write([]byte("INFO {\"server_id\":\"test\",\"headers\":true}\r\n"))
+
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		line = strings.TrimRight(line, "\r\n")
+
+		switch {
+		case strings.HasPrefix(line, "CONNECT "):
+			_, _ = conn.Write([]byte("+OK\r\n"))
+		case line == "PING":
+			_, _ = conn.Write([]byte("PONG\r\n"))
+		case strings.HasPrefix(line, "HPUB "):
+			var subject string
+			var hdrLen, totalLen int
+			if _, err := fmt.Sscanf(line, "HPUB %s %d %d", &subject, &hdrLen, &totalLen); !assert.NoError(t, err) {
+				return
+			}
+
+			msg := make([]byte, totalLen+2)
+			if _, err := io.ReadFull(r, msg); !assert.NoError(t, err) {
+				return
+			}
+
+			assert.Contains(t, string(msg[:hdrLen]), "Nats-Msg-Id: 3")
+			published <- subject
+			_, _ = conn.Write([]byte("PING\r\n+OK\r\n"))
+		}
+	}
+}
+
+func TestNATSPublisher(t *testing.T) {
+	t.Parallel()
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer ln.Close()
+
+	published := make(chan string, 1)
+	go serveNATS(t, ln, published)
+
+	p, err := outbox.NewNATSPublisher("nats://"+ln.Addr().String(), "project")
+	require.NoError(t, err)
+	defer p.Close()
+
+	err = p.Publish(context.Background(), domain.Event{ID: 3, Type: domain.EventUserCreated, Payload: json.RawMessage(`{}`)})
+	require.NoError(t, err)
+	assert.Equal(t, "project.user.created", <-published)
+}
@@ -0,0 +1,91 @@
+package repository
+
+import (
+	"context"
+	"hash/fnv"
+	"sync"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// AdvisoryLock is a session level Postgres advisory lock. The lock lives as long
+// as the connection it was taken on, so a connection is held for as long as the
+// lock is. Session locks do not survive pgbouncer in transaction pooling mode,
+// use a direct connection string for processes that elect a leader.
+type AdvisoryLock struct {
+	pool *pgxpool.Pool
+	key  int64
+
+	mu   sync.Mutex
+	conn *pgxpool.Conn
+}
+
+// NewAdvisoryLock returns an [AdvisoryLock] keyed by a hash of name.
+func NewAdvisoryLock(pool *pgxpool.Pool, name string) *AdvisoryLock {
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(name))
+	return &AdvisoryLock{pool: pool, key: int64(h.Sum64())}
+}
+
+// TryLock takes the lock without waiting. It reports whether the lock is held.
+func (l *AdvisoryLock) TryLock(ctx context.Context) (bool, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.conn != nil {
+		return true, nil
+	}
+
+	conn, err := l.pool.Acquire(ctx)
+	if err != nil {
+		return false, err
+	}
+
+	var locked bool
+	if err := conn.QueryRow(ctx, "SELECT pg_try_advisory_lock($1)", l.key).Scan(&locked); err != nil {
+		conn.Release()
+		return false, err
+	}
+	if !locked {
+		conn.Release()
+		return false, nil
+	}
+
+	l.conn = conn
+	return true, nil
+}
+
+// Held checks that the connection holding the lock is still alive
+func (l *AdvisoryLock) Held(ctx context.Context) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.conn == nil {
+		return false
+	}
+	if err := l.conn.Ping(ctx); err != nil {
+		// The lock went away with the session, drop the broken connection
+		_ = l.conn.Conn().Close(ctx)
+		l.conn.Release()
+		l.conn = nil
+		return false
+	}
+	return true
+}
+
+// Unlock releases the lock and its connection
+func (l *AdvisoryLock) Unlock(ctx context.Context) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.conn == nil {
+		return nil
+	}
+	defer func() {
+		l.conn.Release()
+		l.conn = nil
+	}()
+
+	_, err := l.conn.Exec(ctx, "SELECT pg_advisory_unlock($1)", l.key)
+	return err
+}
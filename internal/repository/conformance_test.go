@@ -0,0 +1,46 @@
+package repository_test
+
+import (
+	"context"
+	"go-project-template/internal/repository"
+	"go-project-template/internal/repository/repositorytest"
+	"go-project-template/internal/testhelper"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestPostgres_Conformance(t *testing.T) {
+	t.Parallel()
+	repositorytest.Run(t, func(t *testing.T) repositorytest.Repositories {
+		t.Helper()
+		ctx := context.Background()
+		conn := testhelper.NewTestPgxConn(t)
+
+		tx, err := conn.Begin(ctx)
+		require.NoError(t, err)
+		t.Cleanup(func() { _ = tx.Rollback(ctx) })
+
+		return repositorytest.Repositories{
+			Users:         repository.NewUserRepository(tx),
+			Organizations: repository.NewOrganizationRepository(tx),
+			Groups:        repository.NewGroupRepository(tx),
+			Credentials:   repository.NewCredentialRepository(tx),
+		}
+	})
+}
+
+// The in-memory repositories back the API tests, which run without a database,
+// so they have to behave like the Postgres ones
+func TestMemory_Conformance(t *testing.T) {
+	t.Parallel()
+	repositorytest.Run(t, func(*testing.T) repositorytest.Repositories {
+		users := repository.NewMemoryUserRepository()
+		return repositorytest.Repositories{
+			Users:         users,
+			Organizations: repository.NewMemoryOrganizationRepository(users),
+			Groups:        repository.NewMemoryGroupRepository(users),
+			Credentials:   repository.NewMemoryCredentialRepository(users),
+		}
+	})
+}
@@ -13,6 +13,7 @@ type Connection interface {
 	Exec(context.Context, string, ...interface{}) (pgconn.CommandTag, error)
 	Query(context.Context, string, ...interface{}) (pgx.Rows, error)
 	QueryRow(context.Context, string, ...interface{}) pgx.Row
+	Begin(context.Context) (pgx.Tx, error)
 }
 
 func spanWithQuery(ctx context.Context, tracer trace.Tracer, query string) (context.Context, trace.Span) {
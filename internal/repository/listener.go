@@ -0,0 +1,44 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// Listener receives Postgres notifications. Like [AdvisoryLock] it needs a
+// session of its own, so it does not work through pgbouncer in transaction mode.
+type Listener struct {
+	pool *pgxpool.Pool
+}
+
+// NewListener returns a [Listener] that takes its connection from pool.
+func NewListener(pool *pgxpool.Pool) *Listener {
+	return &Listener{pool: pool}
+}
+
+// Listen calls fn with the payload of every notification sent on channel until
+// ctx is done or the connection fails. The connection is closed on return.
+func (l *Listener) Listen(ctx context.Context, channel string, fn func(payload string)) error {
+	pc, err := l.pool.Acquire(ctx)
+	if err != nil {
+		return err
+	}
+
+	// The connection leaves the pool so its LISTEN state never leaks to other users
+	conn := pc.Hijack()
+	defer conn.Close(context.WithoutCancel(ctx))
+
+	if _, err := conn.Exec(ctx, "LISTEN "+pgx.Identifier{channel}.Sanitize()); err != nil {
+		return err
+	}
+
+	for {
+		n, err := conn.WaitForNotification(ctx)
+		if err != nil {
+			return err
+		}
+		fn(n.Payload)
+	}
+}
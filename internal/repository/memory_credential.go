@@ -0,0 +1,195 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"go-project-template/internal/domain"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// errEnough stops the email lookup once a second user is found
+var errEnough = errors.New("enough users")
+
+// memoryCredentialRepository keeps credentials in a map. It mirrors
+// postgresCredentialRepository: users are looked up in the user repository, so
+// users of other tenants are not found and deleted users lose their password.
+type memoryCredentialRepository struct {
+	users domain.UserRepository
+
+	mu          sync.Mutex
+	credentials map[uuid.UUID]domain.Credential
+	now         func() time.Time
+}
+
+type MemoryCredentialRepositoryOption func(*memoryCredentialRepository)
+
+// WithCredentialClock sets the clock used for timestamps, tests use it to get stable timestamps
+func WithCredentialClock(now func() time.Time) MemoryCredentialRepositoryOption {
+	return func(m *memoryCredentialRepository) {
+		m.now = now
+	}
+}
+
+// NewMemoryCredentialRepository returns a new in-memory [CredentialRepository]
+// for the users of the given repository, for tests and demos.
+func NewMemoryCredentialRepository(users domain.UserRepository, opts ...MemoryCredentialRepositoryOption) domain.CredentialRepository {
+	m := &memoryCredentialRepository{
+		users:       users,
+		credentials: map[uuid.UUID]domain.Credential{},
+		now:         time.Now,
+	}
+	for _, opt := range opts {
+		opt(m)
+	}
+	return m
+}
+
+// timestamp returns the current time at the microsecond precision of Postgres
+func (m *memoryCredentialRepository) timestamp() time.Time {
+	return m.now().UTC().Truncate(time.Microsecond)
+}
+
+// credential returns the credential of the user if the user is still there. The caller holds the lock.
+func (m *memoryCredentialRepository) credential(ctx context.Context, userUUID uuid.UUID) (domain.Credential, error) {
+	if _, err := m.users.GetByID(ctx, userUUID); err != nil {
+		return domain.Credential{}, err
+	}
+	c, ok := m.credentials[userUUID]
+	if !ok {
+		return domain.Credential{}, domain.ErrNotFound
+	}
+	return c, nil
+}
+
+// withEmail returns the credentials of the users with the email, at most two.
+// The caller holds the lock.
+func (m *memoryCredentialRepository) withEmail(ctx context.Context, email string) ([]domain.Credential, error) {
+	var found []domain.Credential
+	err := m.users.Stream(ctx, domain.UserFilter{Email: email}, func(u *domain.User) error {
+		if c, ok := m.credentials[u.UUID]; ok {
+			found = append(found, c)
+		}
+		if len(found) == 2 {
+			return errEnough
+		}
+		return nil
+	})
+	if err != nil && !errors.Is(err, errEnough) {
+		return nil, err
+	}
+	return found, nil
+}
+
+func (m *memoryCredentialRepository) SetPassword(ctx context.Context, userUUID uuid.UUID, hash string) error {
+	tenant, err := domain.TenantFromContext(ctx)
+	if err != nil {
+		return err
+	}
+	u, err := m.users.GetByID(ctx, userUUID)
+	if err != nil {
+		return err
+	}
+	if u.Email == nil {
+		return domain.ErrCredentialEmail
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	others, err := m.withEmail(ctx, *u.Email)
+	if err != nil {
+		return err
+	}
+	for _, c := range others {
+		if c.UserUUID != userUUID {
+			return domain.ErrConflict
+		}
+	}
+
+	now := m.timestamp()
+	c, ok := m.credentials[userUUID]
+	if !ok {
+		c = domain.Credential{UserUUID: userUUID, TenantID: tenant, CreatedAt: now}
+	}
+	c.Hash, c.FailedAttempts, c.LockedUntil = hash, 0, nil
+	c.PasswordChangedAt, c.UpdatedAt = now, now
+	m.credentials[userUUID] = c
+	return nil
+}
+
+func (m *memoryCredentialRepository) Get(ctx context.Context, userUUID uuid.UUID) (domain.Credential, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.credential(ctx, userUUID)
+}
+
+func (m *memoryCredentialRepository) FindByEmail(ctx context.Context, email string) (domain.Credential, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	found, err := m.withEmail(ctx, email)
+	if err != nil {
+		return domain.Credential{}, err
+	}
+	switch len(found) {
+	case 0:
+		return domain.Credential{}, domain.ErrNotFound
+	case 1:
+		return found[0], nil
+	default:
+		return domain.Credential{}, domain.ErrConflict
+	}
+}
+
+func (m *memoryCredentialRepository) Delete(ctx context.Context, userUUID uuid.UUID) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	_, err := m.credential(ctx, userUUID)
+	if errors.Is(err, domain.ErrNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	delete(m.credentials, userUUID)
+	return nil
+}
+
+func (m *memoryCredentialRepository) RecordFailure(ctx context.Context, userUUID uuid.UUID, maxAttempts int, lockedUntil time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	c, err := m.credential(ctx, userUUID)
+	if err != nil {
+		return err
+	}
+	c.FailedAttempts++
+	if c.FailedAttempts >= maxAttempts {
+		until := lockedUntil.UTC().Truncate(time.Microsecond)
+		c.FailedAttempts, c.LockedUntil = 0, &until
+	}
+	c.UpdatedAt = m.timestamp()
+	m.credentials[userUUID] = c
+	return nil
+}
+
+func (m *memoryCredentialRepository) RecordSuccess(ctx context.Context, userUUID uuid.UUID, rehash string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	c, err := m.credential(ctx, userUUID)
+	if err != nil {
+		return err
+	}
+	now := m.timestamp()
+	c.FailedAttempts, c.LockedUntil, c.LastLoginAt, c.UpdatedAt = 0, nil, &now, now
+	if rehash != "" {
+		c.Hash = rehash
+	}
+	m.credentials[userUUID] = c
+	return nil
+}
@@ -0,0 +1,440 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"go-project-template/internal/domain"
+	"go-project-template/internal/utils"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// memoryGroupRepository keeps groups and their members in maps. It mirrors
+// postgresGroupRepository: users are looked up in the user repository, so users
+// of other tenants are not found and deleted users drop out of every group.
+type memoryGroupRepository struct {
+	users domain.UserRepository
+
+	mu     sync.RWMutex
+	groups map[uuid.UUID]domain.Group
+	// members and children are the direct members of a group by its id
+	members  map[uuid.UUID]map[uuid.UUID]bool
+	children map[uuid.UUID]map[uuid.UUID]bool
+	now      func() time.Time
+}
+
+type MemoryGroupRepositoryOption func(*memoryGroupRepository)
+
+// WithGroupClock sets the clock used for timestamps, tests use it to get stable timestamps
+func WithGroupClock(now func() time.Time) MemoryGroupRepositoryOption {
+	return func(m *memoryGroupRepository) {
+		m.now = now
+	}
+}
+
+// NewMemoryGroupRepository returns a new in-memory [GroupRepository] whose
+// members are users of the given repository, for tests and demos.
+func NewMemoryGroupRepository(users domain.UserRepository, opts ...MemoryGroupRepositoryOption) domain.GroupRepository {
+	m := &memoryGroupRepository{
+		users:    users,
+		groups:   map[uuid.UUID]domain.Group{},
+		members:  map[uuid.UUID]map[uuid.UUID]bool{},
+		children: map[uuid.UUID]map[uuid.UUID]bool{},
+		now:      time.Now,
+	}
+	for _, opt := range opts {
+		opt(m)
+	}
+	return m
+}
+
+// timestamp returns the current time at the microsecond precision of Postgres
+func (m *memoryGroupRepository) timestamp() time.Time {
+	return m.now().UTC().Truncate(time.Microsecond)
+}
+
+// group returns the group if it belongs to tenant. The caller holds the lock.
+func (m *memoryGroupRepository) group(tenant string, id uuid.UUID) (domain.Group, error) {
+	g, ok := m.groups[id]
+	if !ok || g.TenantID != tenant {
+		return domain.Group{}, domain.ErrNotFound
+	}
+	return g, nil
+}
+
+// walk returns start and every group reached from it through next, the way the
+// recursive queries of the Postgres repository do. The caller holds the lock.
+func (m *memoryGroupRepository) walk(start []uuid.UUID, next func(uuid.UUID) []uuid.UUID) map[uuid.UUID]bool {
+	seen := map[uuid.UUID]bool{}
+	queue := append([]uuid.UUID(nil), start...)
+	for len(queue) > 0 {
+		id := queue[0]
+		queue = queue[1:]
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+		queue = append(queue, next(id)...)
+	}
+	return seen
+}
+
+// subgroupsOf returns the direct subgroups of id. The caller holds the lock.
+func (m *memoryGroupRepository) subgroupsOf(id uuid.UUID) []uuid.UUID {
+	var ids []uuid.UUID
+	for child := range m.children[id] {
+		ids = append(ids, child)
+	}
+	return ids
+}
+
+// parentsOf returns the groups id is a direct member of. The caller holds the lock.
+func (m *memoryGroupRepository) parentsOf(id uuid.UUID) []uuid.UUID {
+	var ids []uuid.UUID
+	for parent, children := range m.children {
+		if children[id] {
+			ids = append(ids, parent)
+		}
+	}
+	return ids
+}
+
+// sortGroups orders groups by name like the Postgres repository does
+func sortGroups(gg []domain.Group) {
+	sort.Slice(gg, func(i, j int) bool {
+		if c := strings.Compare(gg[i].Name, gg[j].Name); c != 0 {
+			return c < 0
+		}
+		return compareUUIDs(gg[i].ID, gg[j].ID) < 0
+	})
+}
+
+func (m *memoryGroupRepository) Create(ctx context.Context, g *domain.Group) (*domain.Group, error) {
+	tenant, err := domain.TenantFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := g.Validate(); err != nil {
+		return nil, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if g.ID == uuid.Nil {
+		g.ID = uuid.New()
+	}
+	if _, ok := m.groups[g.ID]; ok {
+		return nil, domain.ErrConflict
+	}
+
+	now := m.timestamp()
+	stored := domain.Group{ID: g.ID, Name: g.Name, TenantID: tenant, CreatedAt: now, UpdatedAt: now}
+	m.groups[g.ID] = stored
+	return &stored, nil
+}
+
+func (m *memoryGroupRepository) GetByID(ctx context.Context, id uuid.UUID) (*domain.Group, error) {
+	tenant, err := domain.TenantFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	g, err := m.group(tenant, id)
+	if err != nil {
+		return nil, err
+	}
+	return &g, nil
+}
+
+func (m *memoryGroupRepository) Update(ctx context.Context, g *domain.Group) (*domain.Group, error) {
+	tenant, err := domain.TenantFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := g.Validate(); err != nil {
+		return nil, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	stored, err := m.group(tenant, g.ID)
+	if err != nil {
+		return nil, err
+	}
+	if stored.Name != g.Name {
+		stored.Name = g.Name
+		stored.UpdatedAt = m.timestamp()
+	}
+	m.groups[g.ID] = stored
+	return &stored, nil
+}
+
+func (m *memoryGroupRepository) Delete(ctx context.Context, id uuid.UUID) error {
+	tenant, err := domain.TenantFromContext(ctx)
+	if err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, err := m.group(tenant, id); err != nil {
+		return err
+	}
+	delete(m.groups, id)
+	delete(m.members, id)
+	delete(m.children, id)
+	for _, children := range m.children {
+		delete(children, id)
+	}
+	return nil
+}
+
+func (m *memoryGroupRepository) GetList(ctx context.Context, pq *utils.PaginationQuery) (*utils.PaginationResponse[domain.Group], error) {
+	tenant, err := domain.TenantFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	m.mu.RLock()
+	var gg []domain.Group
+	for _, g := range m.groups {
+		if g.TenantID == tenant {
+			gg = append(gg, g)
+		}
+	}
+	m.mu.RUnlock()
+
+	// Groups order by the same columns as organizations
+	column, desc := organizationOrder(pq)
+	sort.Slice(gg, func(i, j int) bool {
+		if c := compareGroups(gg[i], gg[j], column, desc); c != 0 {
+			return c < 0
+		}
+		return compareUUIDs(gg[i].ID, gg[j].ID) < 0
+	})
+	return paginate(gg, pq), nil
+}
+
+func (m *memoryGroupRepository) AddMembers(ctx context.Context, groupID uuid.UUID, userIDs []uuid.UUID) error {
+	tenant, err := domain.TenantFromContext(ctx)
+	if err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, err := m.group(tenant, groupID); err != nil {
+		return err
+	}
+	for _, id := range userIDs {
+		if _, err := m.users.GetByID(ctx, id); err != nil {
+			return err
+		}
+	}
+
+	if m.members[groupID] == nil {
+		m.members[groupID] = map[uuid.UUID]bool{}
+	}
+	for _, id := range userIDs {
+		m.members[groupID][id] = true
+	}
+	return nil
+}
+
+func (m *memoryGroupRepository) RemoveMembers(ctx context.Context, groupID uuid.UUID, userIDs []uuid.UUID) error {
+	tenant, err := domain.TenantFromContext(ctx)
+	if err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, err := m.group(tenant, groupID); err != nil {
+		return err
+	}
+	for _, id := range userIDs {
+		delete(m.members[groupID], id)
+	}
+	return nil
+}
+
+func (m *memoryGroupRepository) Members(ctx context.Context, groupID uuid.UUID, nested bool, pq *utils.PaginationQuery) (*utils.PaginationResponse[domain.User], error) {
+	tenant, err := domain.TenantFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	if _, err := m.group(tenant, groupID); err != nil {
+		return nil, err
+	}
+
+	tree := map[uuid.UUID]bool{groupID: true}
+	if nested {
+		tree = m.walk([]uuid.UUID{groupID}, m.subgroupsOf)
+	}
+
+	// A user in several groups of the tree is returned once
+	seen := map[uuid.UUID]bool{}
+	var uu []domain.User
+	for id := range tree {
+		for userID := range m.members[id] {
+			if seen[userID] {
+				continue
+			}
+			seen[userID] = true
+
+			u, err := m.users.GetByID(ctx, userID)
+			if errors.Is(err, domain.ErrNotFound) {
+				continue
+			}
+			if err != nil {
+				return nil, err
+			}
+			uu = append(uu, u)
+		}
+	}
+
+	sort.Slice(uu, func(i, j int) bool { return compareUUIDs(uu[i].UUID, uu[j].UUID) < 0 })
+	return paginate(uu, pq), nil
+}
+
+func (m *memoryGroupRepository) AddSubgroups(ctx context.Context, groupID uuid.UUID, childIDs []uuid.UUID) error {
+	tenant, err := domain.TenantFromContext(ctx)
+	if err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, id := range append([]uuid.UUID{groupID}, childIDs...) {
+		if _, err := m.group(tenant, id); err != nil {
+			return err
+		}
+	}
+
+	// A child that is the group or one of the groups containing it closes a cycle
+	ancestors := m.walk([]uuid.UUID{groupID}, m.parentsOf)
+	for _, id := range childIDs {
+		if ancestors[id] {
+			return domain.ErrGroupCycle
+		}
+	}
+
+	if m.children[groupID] == nil {
+		m.children[groupID] = map[uuid.UUID]bool{}
+	}
+	for _, id := range childIDs {
+		m.children[groupID][id] = true
+	}
+	return nil
+}
+
+func (m *memoryGroupRepository) RemoveSubgroups(ctx context.Context, groupID uuid.UUID, childIDs []uuid.UUID) error {
+	tenant, err := domain.TenantFromContext(ctx)
+	if err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, err := m.group(tenant, groupID); err != nil {
+		return err
+	}
+	for _, id := range childIDs {
+		delete(m.children[groupID], id)
+	}
+	return nil
+}
+
+func (m *memoryGroupRepository) Subgroups(ctx context.Context, groupID uuid.UUID, pq *utils.PaginationQuery) (*utils.PaginationResponse[domain.Group], error) {
+	tenant, err := domain.TenantFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	m.mu.RLock()
+	if _, err := m.group(tenant, groupID); err != nil {
+		m.mu.RUnlock()
+		return nil, err
+	}
+	var gg []domain.Group
+	for _, id := range m.subgroupsOf(groupID) {
+		gg = append(gg, m.groups[id])
+	}
+	m.mu.RUnlock()
+
+	sortGroups(gg)
+	return paginate(gg, pq), nil
+}
+
+func (m *memoryGroupRepository) UserGroups(ctx context.Context, userID uuid.UUID, nested bool, pq *utils.PaginationQuery) (*utils.PaginationResponse[domain.Group], error) {
+	tenant, err := domain.TenantFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := m.users.GetByID(ctx, userID); err != nil {
+		return nil, err
+	}
+
+	m.mu.RLock()
+	var direct []uuid.UUID
+	for id, members := range m.members {
+		if members[userID] {
+			direct = append(direct, id)
+		}
+	}
+
+	tree := map[uuid.UUID]bool{}
+	for _, id := range direct {
+		tree[id] = true
+	}
+	if nested {
+		tree = m.walk(direct, m.parentsOf)
+	}
+
+	var gg []domain.Group
+	for id := range tree {
+		if g, ok := m.groups[id]; ok && g.TenantID == tenant {
+			gg = append(gg, g)
+		}
+	}
+	m.mu.RUnlock()
+
+	sortGroups(gg)
+	return paginate(gg, pq), nil
+}
+
+// compareGroups orders groups by a column like Postgres does
+func compareGroups(a, b domain.Group, column string, desc bool) int {
+	var c int
+	switch column {
+	case "name":
+		c = strings.Compare(a.Name, b.Name)
+	case "created_at":
+		c = a.CreatedAt.Compare(b.CreatedAt)
+	default:
+		c = compareUUIDs(a.ID, b.ID)
+	}
+
+	if desc {
+		return -c
+	}
+	return c
+}
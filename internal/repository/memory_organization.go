@@ -0,0 +1,542 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"go-project-template/internal/domain"
+	"go-project-template/internal/utils"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// memoryOrganizationRepository keeps organizations, memberships and invitations
+// in maps. It mirrors postgresOrganizationRepository: members are looked up in
+// the user repository, so users of other tenants are not found, and the
+// memberships of deleted users are ignored like the cascading foreign key drops them.
+type memoryOrganizationRepository struct {
+	users domain.UserRepository
+
+	mu          sync.RWMutex
+	orgs        map[uuid.UUID]domain.Organization
+	members     map[uuid.UUID]map[uuid.UUID]domain.Membership
+	invitations map[uuid.UUID]storedInvitation
+	now         func() time.Time
+}
+
+// storedInvitation is an invitation with the hash of its token, the token itself is not kept
+type storedInvitation struct {
+	domain.Invitation
+	tokenHash string
+}
+
+type MemoryOrganizationRepositoryOption func(*memoryOrganizationRepository)
+
+// WithOrganizationClock sets the clock used for timestamps and expiry, tests use it to get stable timestamps
+func WithOrganizationClock(now func() time.Time) MemoryOrganizationRepositoryOption {
+	return func(m *memoryOrganizationRepository) {
+		m.now = now
+	}
+}
+
+// NewMemoryOrganizationRepository returns a new in-memory [OrganizationRepository]
+// whose members are users of the given repository, for tests and demos.
+func NewMemoryOrganizationRepository(users domain.UserRepository, opts ...MemoryOrganizationRepositoryOption) domain.OrganizationRepository {
+	m := &memoryOrganizationRepository{
+		users:       users,
+		orgs:        map[uuid.UUID]domain.Organization{},
+		members:     map[uuid.UUID]map[uuid.UUID]domain.Membership{},
+		invitations: map[uuid.UUID]storedInvitation{},
+		now:         time.Now,
+	}
+	for _, opt := range opts {
+		opt(m)
+	}
+	return m
+}
+
+// timestamp returns the current time at the microsecond precision of Postgres
+func (m *memoryOrganizationRepository) timestamp() time.Time {
+	return m.now().UTC().Truncate(time.Microsecond)
+}
+
+// userExists reports whether the user belongs to the tenant of ctx
+func (m *memoryOrganizationRepository) userExists(ctx context.Context, id uuid.UUID) (bool, error) {
+	_, err := m.users.GetByID(ctx, id)
+	if errors.Is(err, domain.ErrNotFound) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// org returns the organization if it belongs to tenant. The caller holds the lock.
+func (m *memoryOrganizationRepository) org(tenant string, id uuid.UUID) (domain.Organization, error) {
+	o, ok := m.orgs[id]
+	if !ok || o.TenantID != tenant {
+		return domain.Organization{}, domain.ErrNotFound
+	}
+	return o, nil
+}
+
+// liveMembers returns the memberships of the organization whose user still
+// exists. The caller holds the lock.
+func (m *memoryOrganizationRepository) liveMembers(ctx context.Context, orgID uuid.UUID) ([]domain.Membership, error) {
+	var mm []domain.Membership
+	for _, ms := range m.members[orgID] {
+		ok, err := m.userExists(ctx, ms.UserID)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			mm = append(mm, ms)
+		}
+	}
+	return mm, nil
+}
+
+// isLastOwner reports whether userID is the only owner of the organization. The caller holds the lock.
+func (m *memoryOrganizationRepository) isLastOwner(ctx context.Context, orgID uuid.UUID, userID uuid.UUID) (bool, error) {
+	mm, err := m.liveMembers(ctx, orgID)
+	if err != nil {
+		return false, err
+	}
+
+	owners, isOwner := 0, false
+	for _, ms := range mm {
+		if ms.Role == domain.RoleOwner {
+			owners++
+			isOwner = isOwner || ms.UserID == userID
+		}
+	}
+	return isOwner && owners == 1, nil
+}
+
+func (m *memoryOrganizationRepository) Create(ctx context.Context, o *domain.Organization, owner uuid.UUID) (*domain.Organization, error) {
+	tenant, err := domain.TenantFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := o.Validate(); err != nil {
+		return nil, err
+	}
+
+	ok, err := m.userExists(ctx, owner)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, domain.ErrNotFound
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if o.ID == uuid.Nil {
+		o.ID = uuid.New()
+	}
+	if _, ok := m.orgs[o.ID]; ok {
+		return nil, domain.ErrConflict
+	}
+
+	now := m.timestamp()
+	stored := domain.Organization{ID: o.ID, Name: o.Name, TenantID: tenant, CreatedAt: now, UpdatedAt: now}
+	m.orgs[o.ID] = stored
+	m.members[o.ID] = map[uuid.UUID]domain.Membership{
+		owner: {OrganizationID: o.ID, UserID: owner, Role: domain.RoleOwner, CreatedAt: now, UpdatedAt: now},
+	}
+	return &stored, nil
+}
+
+func (m *memoryOrganizationRepository) GetByID(ctx context.Context, id uuid.UUID) (*domain.Organization, error) {
+	tenant, err := domain.TenantFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	o, err := m.org(tenant, id)
+	if err != nil {
+		return nil, err
+	}
+	return &o, nil
+}
+
+func (m *memoryOrganizationRepository) Update(ctx context.Context, o *domain.Organization) (*domain.Organization, error) {
+	tenant, err := domain.TenantFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := o.Validate(); err != nil {
+		return nil, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	stored, err := m.org(tenant, o.ID)
+	if err != nil {
+		return nil, err
+	}
+	if stored.Name != o.Name {
+		stored.Name = o.Name
+		stored.UpdatedAt = m.timestamp()
+	}
+	m.orgs[o.ID] = stored
+	return &stored, nil
+}
+
+func (m *memoryOrganizationRepository) Delete(ctx context.Context, id uuid.UUID) error {
+	tenant, err := domain.TenantFromContext(ctx)
+	if err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, err := m.org(tenant, id); err != nil {
+		return err
+	}
+	delete(m.orgs, id)
+	delete(m.members, id)
+	for invID, inv := range m.invitations {
+		if inv.OrganizationID == id {
+			delete(m.invitations, invID)
+		}
+	}
+	return nil
+}
+
+func (m *memoryOrganizationRepository) GetList(ctx context.Context, pq *utils.PaginationQuery) (*utils.PaginationResponse[domain.Organization], error) {
+	tenant, err := domain.TenantFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	m.mu.RLock()
+	var oo []domain.Organization
+	for _, o := range m.orgs {
+		if o.TenantID == tenant {
+			oo = append(oo, o)
+		}
+	}
+	m.mu.RUnlock()
+
+	column, desc := organizationOrder(pq)
+	sort.Slice(oo, func(i, j int) bool {
+		if c := compareOrganizations(oo[i], oo[j], column, desc); c != 0 {
+			return c < 0
+		}
+		return compareUUIDs(oo[i].ID, oo[j].ID) < 0
+	})
+	return paginate(oo, pq), nil
+}
+
+func (m *memoryOrganizationRepository) SetMember(ctx context.Context, ms *domain.Membership) (*domain.Membership, error) {
+	tenant, err := domain.TenantFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := ms.Validate(); err != nil {
+		return nil, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, err := m.org(tenant, ms.OrganizationID); err != nil {
+		return nil, err
+	}
+	ok, err := m.userExists(ctx, ms.UserID)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, domain.ErrNotFound
+	}
+
+	if ms.Role != domain.RoleOwner {
+		last, err := m.isLastOwner(ctx, ms.OrganizationID, ms.UserID)
+		if err != nil {
+			return nil, err
+		}
+		if last {
+			return nil, domain.ErrLastOwner
+		}
+	}
+
+	now := m.timestamp()
+	stored, ok := m.members[ms.OrganizationID][ms.UserID]
+	if !ok {
+		stored = domain.Membership{OrganizationID: ms.OrganizationID, UserID: ms.UserID, CreatedAt: now, UpdatedAt: now}
+	}
+	if stored.Role != ms.Role {
+		stored.Role = ms.Role
+		stored.UpdatedAt = now
+	}
+	m.members[ms.OrganizationID][ms.UserID] = stored
+	return &stored, nil
+}
+
+func (m *memoryOrganizationRepository) RemoveMember(ctx context.Context, orgID uuid.UUID, userID uuid.UUID) error {
+	tenant, err := domain.TenantFromContext(ctx)
+	if err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, err := m.org(tenant, orgID); err != nil {
+		return err
+	}
+	if _, ok := m.members[orgID][userID]; !ok {
+		return domain.ErrNotFound
+	}
+
+	last, err := m.isLastOwner(ctx, orgID, userID)
+	if err != nil {
+		return err
+	}
+	if last {
+		return domain.ErrLastOwner
+	}
+
+	delete(m.members[orgID], userID)
+	return nil
+}
+
+func (m *memoryOrganizationRepository) Members(ctx context.Context, orgID uuid.UUID, pq *utils.PaginationQuery) (*utils.PaginationResponse[domain.Membership], error) {
+	tenant, err := domain.TenantFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	if _, err := m.org(tenant, orgID); err != nil {
+		return nil, err
+	}
+	mm, err := m.liveMembers(ctx, orgID)
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(mm, func(i, j int) bool {
+		if !mm[i].CreatedAt.Equal(mm[j].CreatedAt) {
+			return mm[i].CreatedAt.Before(mm[j].CreatedAt)
+		}
+		return compareUUIDs(mm[i].UserID, mm[j].UserID) < 0
+	})
+	return paginate(mm, pq), nil
+}
+
+func (m *memoryOrganizationRepository) UserOrganizations(ctx context.Context, userID uuid.UUID, pq *utils.PaginationQuery) (*utils.PaginationResponse[domain.UserOrganization], error) {
+	tenant, err := domain.TenantFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	ok, err := m.userExists(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, domain.ErrNotFound
+	}
+
+	m.mu.RLock()
+	var uo []domain.UserOrganization
+	for id, members := range m.members {
+		if ms, ok := members[userID]; ok && m.orgs[id].TenantID == tenant {
+			uo = append(uo, domain.UserOrganization{Organization: m.orgs[id], Role: ms.Role})
+		}
+	}
+	m.mu.RUnlock()
+
+	sort.Slice(uo, func(i, j int) bool {
+		if c := strings.Compare(uo[i].Name, uo[j].Name); c != 0 {
+			return c < 0
+		}
+		return compareUUIDs(uo[i].ID, uo[j].ID) < 0
+	})
+	return paginate(uo, pq), nil
+}
+
+func (m *memoryOrganizationRepository) CreateInvitation(ctx context.Context, inv *domain.Invitation, ttl time.Duration) (*domain.Invitation, error) {
+	tenant, err := domain.TenantFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := inv.Validate(); err != nil {
+		return nil, err
+	}
+
+	token, err := newInvitationToken()
+	if err != nil {
+		return nil, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, err := m.org(tenant, inv.OrganizationID); err != nil {
+		return nil, err
+	}
+
+	now := m.timestamp()
+	stored := storedInvitation{
+		Invitation: domain.Invitation{
+			ID:             uuid.New(),
+			OrganizationID: inv.OrganizationID,
+			Email:          strings.ToLower(inv.Email),
+			Role:           inv.Role,
+			ExpiresAt:      now.Add(ttl),
+			CreatedAt:      now,
+		},
+		tokenHash: hashInvitationToken(token),
+	}
+	m.invitations[stored.ID] = stored
+
+	created := stored.Invitation
+	created.Token = token
+	return &created, nil
+}
+
+func (m *memoryOrganizationRepository) Invitations(ctx context.Context, orgID uuid.UUID) ([]domain.Invitation, error) {
+	tenant, err := domain.TenantFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	if _, err := m.org(tenant, orgID); err != nil {
+		return nil, err
+	}
+
+	now := m.now()
+	ii := []domain.Invitation{}
+	for _, inv := range m.invitations {
+		if inv.OrganizationID == orgID && inv.AcceptedAt == nil && inv.ExpiresAt.After(now) {
+			ii = append(ii, inv.Invitation)
+		}
+	}
+	sort.Slice(ii, func(i, j int) bool {
+		if !ii[i].CreatedAt.Equal(ii[j].CreatedAt) {
+			return ii[i].CreatedAt.Before(ii[j].CreatedAt)
+		}
+		return compareUUIDs(ii[i].ID, ii[j].ID) < 0
+	})
+	return ii, nil
+}
+
+func (m *memoryOrganizationRepository) RevokeInvitation(ctx context.Context, orgID uuid.UUID, id uuid.UUID) error {
+	tenant, err := domain.TenantFromContext(ctx)
+	if err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, err := m.org(tenant, orgID); err != nil {
+		return err
+	}
+	if inv, ok := m.invitations[id]; !ok || inv.OrganizationID != orgID {
+		return domain.ErrNotFound
+	}
+	delete(m.invitations, id)
+	return nil
+}
+
+func (m *memoryOrganizationRepository) AcceptInvitation(ctx context.Context, token string, userID uuid.UUID) (*domain.Membership, error) {
+	tenant, err := domain.TenantFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	u, err := m.users.GetByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	hash := hashInvitationToken(token)
+	var inv storedInvitation
+	found := false
+	for _, stored := range m.invitations {
+		if stored.tokenHash == hash {
+			inv, found = stored, true
+			break
+		}
+	}
+	if !found {
+		return nil, domain.ErrNotFound
+	}
+	if _, err := m.org(tenant, inv.OrganizationID); err != nil {
+		return nil, err
+	}
+
+	now := m.timestamp()
+	if inv.AcceptedAt != nil || !inv.ExpiresAt.After(now) {
+		return nil, domain.ErrInvitationInvalid
+	}
+	if u.Email == nil || !strings.EqualFold(*u.Email, inv.Email) {
+		return nil, domain.ErrInvitationEmail
+	}
+
+	ms, ok := m.members[inv.OrganizationID][userID]
+	if !ok {
+		ms = domain.Membership{OrganizationID: inv.OrganizationID, UserID: userID, Role: inv.Role, CreatedAt: now, UpdatedAt: now}
+		m.members[inv.OrganizationID][userID] = ms
+	}
+
+	inv.AcceptedAt = &now
+	m.invitations[inv.ID] = inv
+	return &ms, nil
+}
+
+// paginate returns the page of sorted items selected by pq
+func paginate[T any](items []T, pq *utils.PaginationQuery) *utils.PaginationResponse[T] {
+	if len(items) == 0 {
+		return utils.DefaultPaginationResponse[T](pq)
+	}
+
+	count := len(items)
+	start := min(pq.GetOffset(), count)
+	end := min(start+pq.GetLimit(), count)
+
+	var page []T
+	if start < end {
+		page = items[start:end]
+	}
+	return utils.PaginatedResponse(count, pq, page)
+}
+
+// compareOrganizations orders organizations by a column like Postgres does
+func compareOrganizations(a, b domain.Organization, column string, desc bool) int {
+	var c int
+	switch column {
+	case "name":
+		c = strings.Compare(a.Name, b.Name)
+	case "created_at":
+		c = a.CreatedAt.Compare(b.CreatedAt)
+	default:
+		c = compareUUIDs(a.ID, b.ID)
+	}
+
+	if desc {
+		return -c
+	}
+	return c
+}
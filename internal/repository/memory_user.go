@@ -0,0 +1,413 @@
+package repository
+
+import (
+	"bytes"
+	"context"
+	"go-project-template/internal/domain"
+	"go-project-template/internal/utils"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// memoryUserRepository keeps users in a map. It mirrors postgresUserRepository:
+// names are stored lowercased, filters match exactly and nothing is shared with
+// the caller's structs.
+type memoryUserRepository struct {
+	mu    sync.RWMutex
+	users map[uuid.UUID]domain.User
+	now   func() time.Time
+}
+
+type MemoryUserRepositoryOption func(*memoryUserRepository)
+
+// WithClock sets the clock used for created_at and updated_at, tests use it to get stable timestamps
+func WithClock(now func() time.Time) MemoryUserRepositoryOption {
+	return func(m *memoryUserRepository) {
+		m.now = now
+	}
+}
+
+// NewMemoryUserRepository returns a new in-memory [UserRepository] for tests and demos.
+func NewMemoryUserRepository(opts ...MemoryUserRepositoryOption) domain.UserRepository {
+	m := &memoryUserRepository{users: map[uuid.UUID]domain.User{}, now: time.Now}
+	for _, opt := range opts {
+		opt(m)
+	}
+	return m
+}
+
+// storedUser returns the copy of u that is kept in the map
+func storedUser(tenant string, u *domain.User) domain.User {
+	s := domain.User{
+		UUID:      u.UUID,
+		TenantID:  tenant,
+		FirstName: u.NormalizedFirstName(),
+		LastName:  u.NormalizedLastName(),
+	}
+	if u.Email != nil {
+		email := *u.Email
+		s.Email = &email
+	}
+	return s
+}
+
+// store saves u like the upsert query does: a user of another tenant is a
+// conflict, created_at and the status are kept and updated_at only moves when a
+// field changed. Timestamps are truncated to the microsecond precision of
+// Postgres and copied back to u with the tenant and status. The caller holds the
+// lock.
+func (m *memoryUserRepository) store(tenant string, u *domain.User) error {
+	s := storedUser(tenant, u)
+	now := m.now().UTC().Truncate(time.Microsecond)
+	s.CreatedAt, s.UpdatedAt = now, now
+	s.Status = u.InitialStatus()
+
+	if old, ok := m.users[u.UUID]; ok {
+		if old.TenantID != tenant {
+			return domain.ErrConflict
+		}
+		s.CreatedAt = old.CreatedAt
+		s.Status, s.StatusReason = old.Status, old.StatusReason
+		if sameFields(old, s) {
+			s.UpdatedAt = old.UpdatedAt
+		}
+	}
+
+	m.users[u.UUID] = s
+	u.TenantID, u.CreatedAt, u.UpdatedAt = s.TenantID, s.CreatedAt, s.UpdatedAt
+	u.Status, u.StatusReason = s.Status, s.StatusReason
+	return nil
+}
+
+func sameFields(a, b domain.User) bool {
+	if a.FirstName != b.FirstName || a.LastName != b.LastName {
+		return false
+	}
+	if a.Email == nil || b.Email == nil {
+		return a.Email == b.Email
+	}
+	return *a.Email == *b.Email
+}
+
+// copyUser returns u without sharing its email with the map
+func copyUser(u domain.User) domain.User {
+	if u.Email != nil {
+		email := *u.Email
+		u.Email = &email
+	}
+	return u
+}
+
+func (m *memoryUserRepository) GetByID(ctx context.Context, uuid uuid.UUID) (domain.User, error) {
+	tenant, err := domain.TenantFromContext(ctx)
+	if err != nil {
+		return domain.User{}, err
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	u, ok := m.users[uuid]
+	if !ok || u.TenantID != tenant {
+		return domain.User{}, domain.ErrNotFound
+	}
+	return copyUser(u), nil
+}
+
+func (m *memoryUserRepository) Create(ctx context.Context, u *domain.User) (*domain.User, error) {
+	tenant, err := domain.TenantFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := u.Validate(); err != nil {
+		return nil, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.users[u.UUID]; ok {
+		return nil, domain.ErrConflict
+	}
+	if err := m.store(tenant, u); err != nil {
+		return nil, err
+	}
+
+	return u, nil
+}
+
+func (m *memoryUserRepository) CreateOrUpdate(ctx context.Context, u *domain.User) (*domain.User, error) {
+	tenant, err := domain.TenantFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := u.Validate(); err != nil {
+		return nil, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if err := m.store(tenant, u); err != nil {
+		return nil, err
+	}
+
+	return u, nil
+}
+
+func (m *memoryUserRepository) BatchCreateOrUpdate(ctx context.Context, uu []*domain.User, mode domain.BatchMode) ([]domain.BatchItemResult, error) {
+	tenant, err := domain.TenantFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]domain.BatchItemResult, len(uu))
+
+	invalid := false
+	for i, u := range uu {
+		if u.UUID == uuid.Nil {
+			u.UUID = uuid.New()
+		}
+		results[i] = domain.BatchItemResult{Index: i, UUID: u.UUID}
+
+		if err := u.Validate(); err != nil {
+			results[i].Status = domain.BatchStatusInvalid
+			results[i].Error = err.Error()
+			invalid = true
+		}
+	}
+
+	if invalid && mode == domain.BatchModeAtomic {
+		skipPending(results)
+		return results, domain.ErrBatchRejected
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	// An atomic batch is checked before anything is stored, like the rolled back transaction
+	if mode == domain.BatchModeAtomic {
+		for i, u := range uu {
+			if old, ok := m.users[u.UUID]; ok && old.TenantID != tenant {
+				results[i].Status = domain.BatchStatusFailed
+				results[i].Error = domain.ErrConflict.Error()
+				skipPending(results)
+				return results, domain.ErrBatchRejected
+			}
+		}
+	}
+
+	for i, u := range uu {
+		if results[i].Status != "" {
+			continue
+		}
+		if err := m.store(tenant, u); err != nil {
+			results[i].Status = domain.BatchStatusFailed
+			results[i].Error = err.Error()
+			continue
+		}
+		results[i].Status = domain.BatchStatusOK
+	}
+	return results, nil
+}
+
+func (m *memoryUserRepository) Delete(ctx context.Context, uuid uuid.UUID) error {
+	tenant, err := domain.TenantFromContext(ctx)
+	if err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if u, ok := m.users[uuid]; ok && u.TenantID == tenant {
+		delete(m.users, uuid)
+	}
+	return nil
+}
+
+func (m *memoryUserRepository) DeleteAll(ctx context.Context) ([]uuid.UUID, error) {
+	tenant, err := domain.TenantFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var ids []uuid.UUID
+	for id, u := range m.users {
+		if u.TenantID == tenant {
+			delete(m.users, id)
+			ids = append(ids, id)
+		}
+	}
+	return ids, nil
+}
+
+func (m *memoryUserRepository) Backdate(ctx context.Context, uu []*domain.User) error {
+	tenant, err := domain.TenantFromContext(ctx)
+	if err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, u := range uu {
+		s, ok := m.users[u.UUID]
+		if !ok || s.TenantID != tenant {
+			continue
+		}
+		s.CreatedAt = u.CreatedAt.UTC().Truncate(time.Microsecond)
+		s.UpdatedAt = u.UpdatedAt.UTC().Truncate(time.Microsecond)
+		m.users[u.UUID] = s
+	}
+	return nil
+}
+
+func (m *memoryUserRepository) Transition(ctx context.Context, uuid uuid.UUID, c domain.StatusChange) (*domain.User, error) {
+	tenant, err := domain.TenantFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := c.Validate(); err != nil {
+		return nil, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	u, ok := m.users[uuid]
+	if !ok || u.TenantID != tenant {
+		return nil, domain.ErrNotFound
+	}
+	if err := u.Status.Transition(c.Status); err != nil {
+		return nil, err
+	}
+
+	u.Status, u.StatusReason = c.Status, c.Reason
+	u.UpdatedAt = m.now().UTC().Truncate(time.Microsecond)
+	m.users[uuid] = u
+
+	u = copyUser(u)
+	return &u, nil
+}
+
+func (m *memoryUserRepository) GetList(ctx context.Context, pq *utils.PaginationQuery, f domain.UserFilter) (*utils.PaginationResponse[domain.User], error) {
+	tenant, err := domain.TenantFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	uu := m.filter(tenant, f)
+	if len(uu) == 0 {
+		return utils.DefaultPaginationResponse[domain.User](pq), nil
+	}
+
+	column, desc := userOrder(pq)
+	sort.SliceStable(uu, func(i, j int) bool {
+		if c := compareUsers(uu[i], uu[j], column, desc); c != 0 {
+			return c < 0
+		}
+		return compareUUIDs(uu[i].UUID, uu[j].UUID) < 0
+	})
+
+	count := len(uu)
+	start := min(pq.GetOffset(), count)
+	end := min(start+pq.GetLimit(), count)
+
+	var page []domain.User
+	if start < end {
+		page = uu[start:end]
+	}
+	return utils.PaginatedResponse(count, pq, page), nil
+}
+
+func (m *memoryUserRepository) Stream(ctx context.Context, f domain.UserFilter, fn func(*domain.User) error) error {
+	tenant, err := domain.TenantFromContext(ctx)
+	if err != nil {
+		return err
+	}
+
+	uu := m.filter(tenant, f)
+	sort.Slice(uu, func(i, j int) bool {
+		return compareUUIDs(uu[i].UUID, uu[j].UUID) < 0
+	})
+
+	for i := range uu {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := fn(&uu[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// filter returns copies of the users of the tenant matching every non-empty field of f
+func (m *memoryUserRepository) filter(tenant string, f domain.UserFilter) []domain.User {
+	firstName, lastName := strings.ToLower(f.FirstName), strings.ToLower(f.LastName)
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var uu []domain.User
+	for _, u := range m.users {
+		if u.TenantID != tenant {
+			continue
+		}
+		if firstName != "" && u.FirstName != firstName {
+			continue
+		}
+		if lastName != "" && u.LastName != lastName {
+			continue
+		}
+		if f.Email != "" && (u.Email == nil || *u.Email != f.Email) {
+			continue
+		}
+		if f.Status != "" && u.Status != f.Status {
+			continue
+		}
+		uu = append(uu, copyUser(u))
+	}
+	return uu
+}
+
+// compareUsers orders users by a column like Postgres does, NULL emails sort
+// after every other value in ascending order
+func compareUsers(a, b domain.User, column string, desc bool) int {
+	var c int
+	switch column {
+	case "first_name":
+		c = strings.Compare(a.FirstName, b.FirstName)
+	case "last_name":
+		c = strings.Compare(a.LastName, b.LastName)
+	case "email":
+		switch {
+		case a.Email == nil && b.Email == nil:
+			c = 0
+		case a.Email == nil:
+			c = 1
+		case b.Email == nil:
+			c = -1
+		default:
+			c = strings.Compare(*a.Email, *b.Email)
+		}
+	default:
+		c = compareUUIDs(a.UUID, b.UUID)
+	}
+
+	if desc {
+		return -c
+	}
+	return c
+}
+
+// compareUUIDs orders UUIDs by their bytes, which is how Postgres orders them
+func compareUUIDs(a, b uuid.UUID) int {
+	return bytes.Compare(a[:], b[:])
+}
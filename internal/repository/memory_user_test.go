@@ -0,0 +1,40 @@
+package repository_test
+
+import (
+	"go-project-template/internal/domain"
+	"go-project-template/internal/repository"
+	"sync"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMemoryUser_Concurrency(t *testing.T) {
+	t.Parallel()
+	ctx := tenantContext()
+	repo := repository.NewMemoryUserRepository()
+
+	email := "concurrent@mail.com"
+	var wg sync.WaitGroup
+	for range 20 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			u := &domain.User{UUID: uuid.New(), FirstName: "Ada", LastName: "Concurrent", Email: &email}
+			_, err := repo.CreateOrUpdate(ctx, u)
+			assert.NoError(t, err)
+			_, err = repo.GetByID(ctx, u.UUID)
+			assert.NoError(t, err)
+		}()
+	}
+	wg.Wait()
+
+	count := 0
+	require.NoError(t, repo.Stream(ctx, domain.UserFilter{LastName: "concurrent"}, func(*domain.User) error {
+		count++
+		return nil
+	}))
+	assert.Equal(t, 20, count)
+}
@@ -0,0 +1,203 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"go-project-template/internal/domain"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+const credentialColumns = `user_uuid, tenant_id, password_hash, failed_attempts, locked_until, password_changed_at, last_login_at, created_at, updated_at`
+
+type postgresCredentialRepository struct {
+	conn Connection
+}
+
+// NewCredentialRepository returns a new [CredentialRepository].
+func NewCredentialRepository(conn Connection) domain.CredentialRepository {
+	return &postgresCredentialRepository{conn: conn}
+}
+
+func scanCredential(row pgx.Row) (domain.Credential, error) {
+	var c domain.Credential
+	err := row.Scan(
+		&c.UserUUID,
+		&c.TenantID,
+		&c.Hash,
+		&c.FailedAttempts,
+		&c.LockedUntil,
+		&c.PasswordChangedAt,
+		&c.LastLoginAt,
+		&c.CreatedAt,
+		&c.UpdatedAt,
+	)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return domain.Credential{}, domain.ErrNotFound
+	}
+	return c, err
+}
+
+func (p *postgresCredentialRepository) SetPassword(ctx context.Context, userUUID uuid.UUID, hash string) error {
+	tenant, err := domain.TenantFromContext(ctx)
+	if err != nil {
+		return err
+	}
+
+	tx, err := begin(ctx, p.conn)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	var email *string
+	err = tx.QueryRow(ctx, `SELECT email FROM users WHERE tenant_id = $1 AND uuid = $2`, tenant, userUUID).Scan(&email)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return domain.ErrNotFound
+	}
+	if err != nil {
+		return err
+	}
+	if email == nil {
+		return domain.ErrCredentialEmail
+	}
+
+	// Serializes passwords set for the same email, so two users sharing it
+	// cannot both get one
+	if _, err := tx.Exec(ctx, `SELECT pg_advisory_xact_lock(hashtext('credentials/' || $1 || '/' || $2))`, tenant, *email); err != nil {
+		return err
+	}
+
+	var taken bool
+	err = tx.QueryRow(ctx, `
+		SELECT EXISTS (
+			SELECT 1 FROM credentials
+			WHERE tenant_id = $1 AND user_uuid <> $2
+				AND user_uuid IN (SELECT uuid FROM users WHERE tenant_id = $1 AND email = $3)
+		)`, tenant, userUUID, *email).Scan(&taken)
+	if err != nil {
+		return err
+	}
+	if taken {
+		return domain.ErrConflict
+	}
+
+	query := `
+		INSERT INTO credentials (user_uuid, tenant_id, password_hash)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (user_uuid) DO UPDATE
+		SET password_hash = EXCLUDED.password_hash, failed_attempts = 0, locked_until = NULL,
+			password_changed_at = statement_timestamp(), updated_at = statement_timestamp()`
+
+	if _, err := tx.Exec(ctx, query, userUUID, tenant, hash); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+func (p *postgresCredentialRepository) Get(ctx context.Context, userUUID uuid.UUID) (domain.Credential, error) {
+	tenant, err := domain.TenantFromContext(ctx)
+	if err != nil {
+		return domain.Credential{}, err
+	}
+
+	query := `SELECT ` + credentialColumns + ` FROM credentials WHERE tenant_id = $1 AND user_uuid = $2`
+	return scanCredential(p.conn.QueryRow(ctx, query, tenant, userUUID))
+}
+
+func (p *postgresCredentialRepository) FindByEmail(ctx context.Context, email string) (domain.Credential, error) {
+	tenant, err := domain.TenantFromContext(ctx)
+	if err != nil {
+		return domain.Credential{}, err
+	}
+
+	query := `
+		SELECT ` + credentialColumns + ` FROM credentials
+		WHERE tenant_id = $1 AND user_uuid IN (SELECT uuid FROM users WHERE tenant_id = $1 AND email = $2)
+		LIMIT 2`
+
+	rows, err := p.conn.Query(ctx, query, tenant, email)
+	if err != nil {
+		return domain.Credential{}, err
+	}
+	defer rows.Close()
+
+	var found []domain.Credential
+	for rows.Next() {
+		c, err := scanCredential(rows)
+		if err != nil {
+			return domain.Credential{}, err
+		}
+		found = append(found, c)
+	}
+	if err := rows.Err(); err != nil {
+		return domain.Credential{}, err
+	}
+
+	switch len(found) {
+	case 0:
+		return domain.Credential{}, domain.ErrNotFound
+	case 1:
+		return found[0], nil
+	default:
+		return domain.Credential{}, domain.ErrConflict
+	}
+}
+
+func (p *postgresCredentialRepository) Delete(ctx context.Context, userUUID uuid.UUID) error {
+	tenant, err := domain.TenantFromContext(ctx)
+	if err != nil {
+		return err
+	}
+
+	_, err = p.conn.Exec(ctx, `DELETE FROM credentials WHERE tenant_id = $1 AND user_uuid = $2`, tenant, userUUID)
+	return err
+}
+
+func (p *postgresCredentialRepository) RecordFailure(ctx context.Context, userUUID uuid.UUID, maxAttempts int, lockedUntil time.Time) error {
+	tenant, err := domain.TenantFromContext(ctx)
+	if err != nil {
+		return err
+	}
+
+	// The counter is read and written by one statement, so concurrent failures are all counted
+	query := `
+		UPDATE credentials
+		SET failed_attempts = CASE WHEN failed_attempts + 1 >= $3 THEN 0 ELSE failed_attempts + 1 END,
+			locked_until = CASE WHEN failed_attempts + 1 >= $3 THEN $4 ELSE locked_until END,
+			updated_at = statement_timestamp()
+		WHERE tenant_id = $1 AND user_uuid = $2`
+
+	tag, err := p.conn.Exec(ctx, query, tenant, userUUID, maxAttempts, lockedUntil)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return domain.ErrNotFound
+	}
+	return nil
+}
+
+func (p *postgresCredentialRepository) RecordSuccess(ctx context.Context, userUUID uuid.UUID, rehash string) error {
+	tenant, err := domain.TenantFromContext(ctx)
+	if err != nil {
+		return err
+	}
+
+	query := `
+		UPDATE credentials
+		SET failed_attempts = 0, locked_until = NULL, last_login_at = statement_timestamp(),
+			password_hash = COALESCE(NULLIF($3::text, ''), password_hash), updated_at = statement_timestamp()
+		WHERE tenant_id = $1 AND user_uuid = $2`
+
+	tag, err := p.conn.Exec(ctx, query, tenant, userUUID, rehash)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return domain.ErrNotFound
+	}
+	return nil
+}
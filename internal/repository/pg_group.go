@@ -0,0 +1,452 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"go-project-template/internal/domain"
+	"go-project-template/internal/utils"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+const groupColumns = `id, tenant_id, name, created_at, updated_at`
+
+type postgresGroupRepository struct {
+	conn Connection
+}
+
+// NewGroupRepository returns a new [GroupRepository].
+func NewGroupRepository(conn Connection) domain.GroupRepository {
+	return &postgresGroupRepository{conn: conn}
+}
+
+func scanGroup(row pgx.Row) (*domain.Group, error) {
+	g := &domain.Group{}
+	if err := row.Scan(&g.ID, &g.TenantID, &g.Name, &g.CreatedAt, &g.UpdatedAt); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, domain.ErrNotFound
+		}
+		return nil, err
+	}
+	return g, nil
+}
+
+// groupTree is a recursive CTE named tree with the id of the group of the tenant
+// in $1 with the id $2, and with nested the ids of its subgroups at any depth.
+// UNION drops the rows seen before, so the walk ends even on a cycle.
+func groupTree(nested bool) string {
+	query := `
+		WITH RECURSIVE tree (id) AS (
+			SELECT id FROM groups WHERE tenant_id = $1 AND id = $2`
+	if nested {
+		query += `
+			UNION
+			SELECT s.child_id FROM group_subgroups s JOIN tree t ON s.group_id = t.id`
+	}
+	return query + `
+		)`
+}
+
+// userGroupTree is a recursive CTE named tree with the ids of the groups the
+// user $2 is a member of, and with nested the groups containing them at any depth
+func userGroupTree(nested bool) string {
+	query := `
+		WITH RECURSIVE tree (id) AS (
+			SELECT group_id FROM group_members WHERE user_uuid = $2`
+	if nested {
+		query += `
+			UNION
+			SELECT s.group_id FROM group_subgroups s JOIN tree t ON s.child_id = t.id`
+	}
+	return query + `
+		)`
+}
+
+// distinctUUIDs returns ids without duplicates
+func distinctUUIDs(ids []uuid.UUID) []uuid.UUID {
+	seen := make(map[uuid.UUID]bool, len(ids))
+	out := make([]uuid.UUID, 0, len(ids))
+	for _, id := range ids {
+		if !seen[id] {
+			seen[id] = true
+			out = append(out, id)
+		}
+	}
+	return out
+}
+
+// lockGroups serializes changes to the subgroups of the tenant for the rest of
+// tx. Two additions that only form a cycle together cannot both pass the check.
+func lockGroups(ctx context.Context, tx pgx.Tx, tenant string) error {
+	_, err := tx.Exec(ctx, `SELECT pg_advisory_xact_lock(hashtext('group_subgroups/' || $1))`, tenant)
+	return err
+}
+
+// countGroups returns how many of the ids are groups of the tenant
+func countGroups(ctx context.Context, conn Connection, tenant string, ids []uuid.UUID) (int, error) {
+	var count int
+	err := conn.QueryRow(ctx, `SELECT count(id) FROM groups WHERE tenant_id = $1 AND id = ANY($2::uuid[])`, tenant, ids).Scan(&count)
+	return count, err
+}
+
+func (p *postgresGroupRepository) Create(ctx context.Context, g *domain.Group) (*domain.Group, error) {
+	tenant, err := domain.TenantFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := g.Validate(); err != nil {
+		return nil, err
+	}
+	if g.ID == uuid.Nil {
+		g.ID = uuid.New()
+	}
+
+	query := `
+		INSERT INTO groups (id, tenant_id, name)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (id) DO NOTHING
+		RETURNING ` + groupColumns
+
+	created, err := scanGroup(p.conn.QueryRow(ctx, query, g.ID, tenant, g.Name))
+	if errors.Is(err, domain.ErrNotFound) {
+		return nil, domain.ErrConflict
+	}
+	return created, err
+}
+
+func (p *postgresGroupRepository) GetByID(ctx context.Context, id uuid.UUID) (*domain.Group, error) {
+	tenant, err := domain.TenantFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	query := `SELECT ` + groupColumns + ` FROM groups WHERE tenant_id = $1 AND id = $2`
+	return scanGroup(p.conn.QueryRow(ctx, query, tenant, id))
+}
+
+func (p *postgresGroupRepository) Update(ctx context.Context, g *domain.Group) (*domain.Group, error) {
+	tenant, err := domain.TenantFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := g.Validate(); err != nil {
+		return nil, err
+	}
+
+	query := `
+		UPDATE groups
+		SET name = $3,
+			updated_at = CASE WHEN name IS DISTINCT FROM $3 THEN statement_timestamp() ELSE updated_at END
+		WHERE tenant_id = $1 AND id = $2
+		RETURNING ` + groupColumns
+
+	return scanGroup(p.conn.QueryRow(ctx, query, tenant, g.ID, g.Name))
+}
+
+func (p *postgresGroupRepository) Delete(ctx context.Context, id uuid.UUID) error {
+	tenant, err := domain.TenantFromContext(ctx)
+	if err != nil {
+		return err
+	}
+
+	// Members and subgroup edges go with it through ON DELETE CASCADE
+	tag, err := p.conn.Exec(ctx, `DELETE FROM groups WHERE tenant_id = $1 AND id = $2`, tenant, id)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return domain.ErrNotFound
+	}
+	return nil
+}
+
+func (p *postgresGroupRepository) GetList(ctx context.Context, pq *utils.PaginationQuery) (*utils.PaginationResponse[domain.Group], error) {
+	tenant, err := domain.TenantFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var count int
+	if err := p.conn.QueryRow(ctx, `SELECT count(id) FROM groups WHERE tenant_id = $1`, tenant).Scan(&count); err != nil {
+		return nil, err
+	}
+	if count == 0 {
+		return utils.DefaultPaginationResponse[domain.Group](pq), nil
+	}
+
+	// Groups order by the same columns as organizations
+	column, desc := organizationOrder(pq)
+	dir := "ASC"
+	if desc {
+		dir = "DESC"
+	}
+
+	query := fmt.Sprintf("SELECT %s FROM groups WHERE tenant_id = $1 ORDER BY %s %s, id OFFSET $2 LIMIT $3", groupColumns, column, dir)
+	gg, err := p.fetch(ctx, query, tenant, pq.GetOffset(), pq.GetLimit())
+	if err != nil {
+		return nil, err
+	}
+	return utils.PaginatedResponse(count, pq, gg), nil
+}
+
+func (p *postgresGroupRepository) fetch(ctx context.Context, query string, args ...interface{}) ([]domain.Group, error) {
+	rows, err := p.conn.Query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var gg []domain.Group
+	for rows.Next() {
+		g, err := scanGroup(rows)
+		if err != nil {
+			return nil, err
+		}
+		gg = append(gg, *g)
+	}
+	return gg, rows.Err()
+}
+
+func (p *postgresGroupRepository) AddMembers(ctx context.Context, groupID uuid.UUID, userIDs []uuid.UUID) error {
+	tenant, err := domain.TenantFromContext(ctx)
+	if err != nil {
+		return err
+	}
+	userIDs = distinctUUIDs(userIDs)
+
+	tx, err := begin(ctx, p.conn)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	n, err := countGroups(ctx, tx, tenant, []uuid.UUID{groupID})
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return domain.ErrNotFound
+	}
+
+	// Only users of the tenant are inserted, so fewer rows than ids means one was not found
+	query := `
+		WITH added AS (
+			INSERT INTO group_members (group_id, user_uuid)
+			SELECT $1::uuid, uuid FROM users WHERE tenant_id = $2 AND uuid = ANY($3::uuid[])
+			ON CONFLICT (group_id, user_uuid) DO NOTHING
+		)
+		SELECT count(uuid) FROM users WHERE tenant_id = $2 AND uuid = ANY($3::uuid[])`
+
+	var found int
+	if err := tx.QueryRow(ctx, query, groupID, tenant, userIDs).Scan(&found); err != nil {
+		return err
+	}
+	if found != len(userIDs) {
+		return domain.ErrNotFound
+	}
+	return tx.Commit(ctx)
+}
+
+func (p *postgresGroupRepository) RemoveMembers(ctx context.Context, groupID uuid.UUID, userIDs []uuid.UUID) error {
+	tenant, err := domain.TenantFromContext(ctx)
+	if err != nil {
+		return err
+	}
+	if _, err := p.GetByID(ctx, groupID); err != nil {
+		return err
+	}
+
+	query := `
+		DELETE FROM group_members m
+		USING groups g
+		WHERE g.id = m.group_id AND g.tenant_id = $1 AND m.group_id = $2 AND m.user_uuid = ANY($3::uuid[])`
+
+	_, err = p.conn.Exec(ctx, query, tenant, groupID, userIDs)
+	return err
+}
+
+func (p *postgresGroupRepository) Members(ctx context.Context, groupID uuid.UUID, nested bool, pq *utils.PaginationQuery) (*utils.PaginationResponse[domain.User], error) {
+	tenant, err := domain.TenantFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := p.GetByID(ctx, groupID); err != nil {
+		return nil, err
+	}
+
+	// A user in several groups of the tree is returned once
+	members := groupTree(nested) + `
+		SELECT %s FROM users u
+		WHERE u.tenant_id = $1 AND EXISTS (
+			SELECT 1 FROM group_members m JOIN tree t ON t.id = m.group_id WHERE m.user_uuid = u.uuid
+		)`
+
+	var count int
+	if err := p.conn.QueryRow(ctx, fmt.Sprintf(members, "count(u.uuid)"), tenant, groupID).Scan(&count); err != nil {
+		return nil, err
+	}
+	if count == 0 {
+		return utils.DefaultPaginationResponse[domain.User](pq), nil
+	}
+
+	rows, err := p.conn.Query(ctx, fmt.Sprintf(members, userColumns)+` ORDER BY u.uuid OFFSET $3 LIMIT $4`, tenant, groupID, pq.GetOffset(), pq.GetLimit())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var uu []domain.User
+	for rows.Next() {
+		var u domain.User
+		if err := scanUser(rows, &u); err != nil {
+			return nil, err
+		}
+		uu = append(uu, u)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return utils.PaginatedResponse(count, pq, uu), nil
+}
+
+func (p *postgresGroupRepository) AddSubgroups(ctx context.Context, groupID uuid.UUID, childIDs []uuid.UUID) error {
+	tenant, err := domain.TenantFromContext(ctx)
+	if err != nil {
+		return err
+	}
+	childIDs = distinctUUIDs(childIDs)
+
+	tx, err := begin(ctx, p.conn)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	if err := lockGroups(ctx, tx, tenant); err != nil {
+		return err
+	}
+
+	// The group is counted once when it is one of the children as well
+	ids := distinctUUIDs(append([]uuid.UUID{groupID}, childIDs...))
+	n, err := countGroups(ctx, tx, tenant, ids)
+	if err != nil {
+		return err
+	}
+	if n != len(ids) {
+		return domain.ErrNotFound
+	}
+
+	// A child that is the group or one of the groups containing it closes a cycle
+	query := `
+		WITH RECURSIVE ancestors (id) AS (
+			SELECT $1::uuid
+			UNION
+			SELECT s.group_id FROM group_subgroups s JOIN ancestors a ON s.child_id = a.id
+		)
+		SELECT EXISTS (SELECT 1 FROM ancestors WHERE id = ANY($2::uuid[]))`
+
+	var cycle bool
+	if err := tx.QueryRow(ctx, query, groupID, childIDs).Scan(&cycle); err != nil {
+		return err
+	}
+	if cycle {
+		return domain.ErrGroupCycle
+	}
+
+	_, err = tx.Exec(ctx, `
+		INSERT INTO group_subgroups (group_id, child_id)
+		SELECT $1::uuid, unnest($2::uuid[])
+		ON CONFLICT (group_id, child_id) DO NOTHING`, groupID, childIDs)
+	if err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+func (p *postgresGroupRepository) RemoveSubgroups(ctx context.Context, groupID uuid.UUID, childIDs []uuid.UUID) error {
+	tenant, err := domain.TenantFromContext(ctx)
+	if err != nil {
+		return err
+	}
+	if _, err := p.GetByID(ctx, groupID); err != nil {
+		return err
+	}
+
+	query := `
+		DELETE FROM group_subgroups s
+		USING groups g
+		WHERE g.id = s.group_id AND g.tenant_id = $1 AND s.group_id = $2 AND s.child_id = ANY($3::uuid[])`
+
+	_, err = p.conn.Exec(ctx, query, tenant, groupID, childIDs)
+	return err
+}
+
+func (p *postgresGroupRepository) Subgroups(ctx context.Context, groupID uuid.UUID, pq *utils.PaginationQuery) (*utils.PaginationResponse[domain.Group], error) {
+	tenant, err := domain.TenantFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := p.GetByID(ctx, groupID); err != nil {
+		return nil, err
+	}
+
+	var count int
+	err = p.conn.QueryRow(ctx, `
+		SELECT count(g.id)
+		FROM group_subgroups s JOIN groups g ON g.id = s.child_id
+		WHERE g.tenant_id = $1 AND s.group_id = $2`, tenant, groupID).Scan(&count)
+	if err != nil {
+		return nil, err
+	}
+	if count == 0 {
+		return utils.DefaultPaginationResponse[domain.Group](pq), nil
+	}
+
+	query := `
+		SELECT g.id, g.tenant_id, g.name, g.created_at, g.updated_at
+		FROM group_subgroups s JOIN groups g ON g.id = s.child_id
+		WHERE g.tenant_id = $1 AND s.group_id = $2
+		ORDER BY g.name, g.id
+		OFFSET $3 LIMIT $4`
+
+	gg, err := p.fetch(ctx, query, tenant, groupID, pq.GetOffset(), pq.GetLimit())
+	if err != nil {
+		return nil, err
+	}
+	return utils.PaginatedResponse(count, pq, gg), nil
+}
+
+func (p *postgresGroupRepository) UserGroups(ctx context.Context, userID uuid.UUID, nested bool, pq *utils.PaginationQuery) (*utils.PaginationResponse[domain.Group], error) {
+	tenant, err := domain.TenantFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var users int
+	if err := p.conn.QueryRow(ctx, `SELECT count(uuid) FROM users WHERE tenant_id = $1 AND uuid = $2`, tenant, userID).Scan(&users); err != nil {
+		return nil, err
+	}
+	if users == 0 {
+		return nil, domain.ErrNotFound
+	}
+
+	groups := userGroupTree(nested) + `
+		SELECT %s FROM groups WHERE tenant_id = $1 AND id IN (SELECT id FROM tree)`
+
+	var count int
+	if err := p.conn.QueryRow(ctx, fmt.Sprintf(groups, "count(id)"), tenant, userID).Scan(&count); err != nil {
+		return nil, err
+	}
+	if count == 0 {
+		return utils.DefaultPaginationResponse[domain.Group](pq), nil
+	}
+
+	gg, err := p.fetch(ctx, fmt.Sprintf(groups, groupColumns)+` ORDER BY name, id OFFSET $3 LIMIT $4`, tenant, userID, pq.GetOffset(), pq.GetLimit())
+	if err != nil {
+		return nil, err
+	}
+	return utils.PaginatedResponse(count, pq, gg), nil
+}
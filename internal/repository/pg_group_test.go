@@ -0,0 +1,48 @@
+package repository_test
+
+import (
+	"errors"
+	"go-project-template/internal/domain"
+	"go-project-template/internal/repository"
+	"go-project-template/internal/testhelper"
+	"sync"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestPostgresGroup_CycleRace nests two groups in each other at once, only one
+// of them may win or the groups would contain themselves
+func TestPostgresGroup_CycleRace(t *testing.T) {
+	t.Parallel()
+	ctx := tenantContext()
+	groups := repository.NewGroupRepository(testhelper.NewTestPool(t))
+
+	a, err := groups.Create(ctx, &domain.Group{Name: "A"})
+	require.NoError(t, err)
+	b, err := groups.Create(ctx, &domain.Group{Name: "B"})
+	require.NoError(t, err)
+
+	errs := make([]error, 2)
+	var wg sync.WaitGroup
+	for i, edge := range [][2]uuid.UUID{{a.ID, b.ID}, {b.ID, a.ID}} {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs[i] = groups.AddSubgroups(ctx, edge[0], []uuid.UUID{edge[1]})
+		}()
+	}
+	wg.Wait()
+
+	cycles := 0
+	for _, err := range errs {
+		if errors.Is(err, domain.ErrGroupCycle) {
+			cycles++
+		} else {
+			assert.NoError(t, err)
+		}
+	}
+	assert.Equal(t, 1, cycles)
+}
@@ -0,0 +1,115 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"go-project-template/internal/domain"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+type postgresIdempotencyRepository struct {
+	conn Connection
+}
+
+// NewIdempotencyRepository returns a new [IdempotencyRepository].
+func NewIdempotencyRepository(conn Connection) domain.IdempotencyRepository {
+	return &postgresIdempotencyRepository{conn: conn}
+}
+
+func (p *postgresIdempotencyRepository) Reserve(ctx context.Context, key string, fingerprint string, lease time.Duration, ttl time.Duration) (*domain.IdempotencyRecord, error) {
+	// Expired keys are taken over as if they never existed, as are keys of the
+	// same request whose lease ran out before it completed
+	query := `
+		INSERT INTO idempotency_keys (key, fingerprint, expires_at, locked_until)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT(key) DO UPDATE
+		SET fingerprint = EXCLUDED.fingerprint,
+			status_code = NULL,
+			content_type = NULL,
+			body = NULL,
+			created_at = now(),
+			expires_at = EXCLUDED.expires_at,
+			locked_until = EXCLUDED.locked_until
+		WHERE idempotency_keys.expires_at < now()
+			OR (idempotency_keys.status_code IS NULL
+				AND idempotency_keys.fingerprint = EXCLUDED.fingerprint
+				AND COALESCE(idempotency_keys.locked_until, idempotency_keys.created_at) < now())
+		RETURNING key, fingerprint, created_at, expires_at, locked_until`
+
+	now := time.Now()
+	rec := &domain.IdempotencyRecord{}
+	err := p.conn.QueryRow(ctx, query, key, fingerprint, now.Add(ttl), now.Add(lease)).Scan(
+		&rec.Key,
+		&rec.Fingerprint,
+		&rec.CreatedAt,
+		&rec.ExpiresAt,
+		&rec.LockedUntil,
+	)
+	if err == nil {
+		return rec, nil
+	}
+	if !errors.Is(err, pgx.ErrNoRows) {
+		return nil, err
+	}
+
+	query = `
+		SELECT key, fingerprint, COALESCE(status_code, 0), COALESCE(content_type, ''), COALESCE(body, ''::bytea), created_at, expires_at, COALESCE(locked_until, created_at)
+		FROM idempotency_keys
+		WHERE key = $1`
+
+	if err := p.conn.QueryRow(ctx, query, key).Scan(
+		&rec.Key,
+		&rec.Fingerprint,
+		&rec.StatusCode,
+		&rec.ContentType,
+		&rec.Body,
+		&rec.CreatedAt,
+		&rec.ExpiresAt,
+		&rec.LockedUntil,
+	); err != nil {
+		return nil, err
+	}
+
+	if rec.Fingerprint != fingerprint {
+		return nil, domain.ErrKeyReuse
+	}
+	if !rec.Completed() {
+		return nil, domain.ErrInProgress
+	}
+	return rec, nil
+}
+
+func (p *postgresIdempotencyRepository) Complete(ctx context.Context, r *domain.IdempotencyRecord) error {
+	// Only the request holding the lease stores its response, a request whose
+	// lease ran out may have been taken over by a retry
+	query := `
+		UPDATE idempotency_keys
+		SET status_code = $2, content_type = $3, body = $4
+		WHERE key = $1 AND status_code IS NULL AND locked_until = $5`
+
+	tag, err := p.conn.Exec(ctx, query, r.Key, r.StatusCode, r.ContentType, r.Body, r.LockedUntil)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return domain.ErrNotFound
+	}
+	return nil
+}
+
+func (p *postgresIdempotencyRepository) Release(ctx context.Context, r *domain.IdempotencyRecord) error {
+	query := `DELETE FROM idempotency_keys WHERE key = $1 AND status_code IS NULL AND locked_until = $2`
+	_, err := p.conn.Exec(ctx, query, r.Key, r.LockedUntil)
+	return err
+}
+
+func (p *postgresIdempotencyRepository) DeleteExpired(ctx context.Context) (int64, error) {
+	query := `DELETE FROM idempotency_keys WHERE expires_at < now()`
+	tag, err := p.conn.Exec(ctx, query)
+	if err != nil {
+		return 0, err
+	}
+	return tag.RowsAffected(), nil
+}
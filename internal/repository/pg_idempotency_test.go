@@ -0,0 +1,149 @@
+package repository_test
+
+import (
+	"context"
+	"go-project-template/internal/domain"
+	"go-project-template/internal/repository"
+	"go-project-template/internal/testhelper"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func NewTestPostgresIdempotency(t *testing.T) domain.IdempotencyRepository {
+	t.Helper()
+
+	ctx := context.Background()
+	conn := testhelper.NewTestPgxConn(t)
+
+	tx, err := conn.Begin(ctx)
+	require.NoError(t, err)
+
+	repo := repository.NewIdempotencyRepository(tx)
+
+	t.Cleanup(func() {
+		_ = tx.Rollback(ctx)
+	})
+
+	return repo
+}
+
+func TestPostgresIdempotency_Reserve(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	repo := NewTestPostgresIdempotency(t)
+
+	key := uuid.New().String()
+
+	rec, err := repo.Reserve(ctx, key, "fingerprint", time.Minute, time.Hour)
+	require.NoError(t, err)
+	assert.False(t, rec.Completed())
+
+	// Duplicate while the first request is still in flight
+	_, err = repo.Reserve(ctx, key, "fingerprint", time.Minute, time.Hour)
+	assert.Equal(t, domain.ErrInProgress, err)
+
+	// Same key with a different request
+	_, err = repo.Reserve(ctx, key, "other", time.Minute, time.Hour)
+	assert.Equal(t, domain.ErrKeyReuse, err)
+
+	rec.StatusCode = 201
+	rec.ContentType = "application/json"
+	rec.Body = []byte(`{"uuid":"x"}`)
+	require.NoError(t, repo.Complete(ctx, rec))
+
+	replay, err := repo.Reserve(ctx, key, "fingerprint", time.Minute, time.Hour)
+	require.NoError(t, err)
+	assert.True(t, replay.Completed())
+	assert.Equal(t, 201, replay.StatusCode)
+	assert.Equal(t, rec.Body, replay.Body)
+}
+
+func TestPostgresIdempotency_Release(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	repo := NewTestPostgresIdempotency(t)
+
+	key := uuid.New().String()
+
+	reserved, err := repo.Reserve(ctx, key, "fingerprint", time.Minute, time.Hour)
+	require.NoError(t, err)
+	require.NoError(t, repo.Release(ctx, reserved))
+
+	rec, err := repo.Reserve(ctx, key, "fingerprint", time.Minute, time.Hour)
+	require.NoError(t, err)
+	assert.False(t, rec.Completed())
+}
+
+func TestPostgresIdempotency_ReleaseStaleLease(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	repo := NewTestPostgresIdempotency(t)
+
+	key := uuid.New().String()
+
+	// The first request outlives its lease and a retry takes the key over
+	stale, err := repo.Reserve(ctx, key, "fingerprint", -time.Minute, time.Hour)
+	require.NoError(t, err)
+	_, err = repo.Reserve(ctx, key, "fingerprint", time.Minute, time.Hour)
+	require.NoError(t, err)
+
+	// Releasing the stale reservation leaves the one of the retry in place
+	require.NoError(t, repo.Release(ctx, stale))
+	_, err = repo.Reserve(ctx, key, "fingerprint", time.Minute, time.Hour)
+	assert.Equal(t, domain.ErrInProgress, err)
+}
+
+func TestPostgresIdempotency_Expired(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	repo := NewTestPostgresIdempotency(t)
+
+	key := uuid.New().String()
+
+	_, err := repo.Reserve(ctx, key, "fingerprint", time.Minute, -time.Minute)
+	require.NoError(t, err)
+
+	// An expired key is taken over by the next request
+	rec, err := repo.Reserve(ctx, key, "other", time.Minute, time.Hour)
+	require.NoError(t, err)
+	assert.Equal(t, "other", rec.Fingerprint)
+
+	n, err := repo.DeleteExpired(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, int64(0), n)
+}
+
+func TestPostgresIdempotency_Lease(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	repo := NewTestPostgresIdempotency(t)
+
+	key := uuid.New().String()
+
+	// A request that never completed loses the key once its lease runs out
+	crashed, err := repo.Reserve(ctx, key, "fingerprint", -time.Minute, time.Hour)
+	require.NoError(t, err)
+
+	_, err = repo.Reserve(ctx, key, "other", time.Minute, time.Hour)
+	assert.Equal(t, domain.ErrKeyReuse, err)
+
+	retry, err := repo.Reserve(ctx, key, "fingerprint", time.Minute, time.Hour)
+	require.NoError(t, err)
+	assert.False(t, retry.Completed())
+	assert.True(t, retry.LockedUntil.After(crashed.LockedUntil))
+
+	// The retry holds the lease now, the crashed request cannot complete
+	crashed.StatusCode = 201
+	assert.Equal(t, domain.ErrNotFound, repo.Complete(ctx, crashed))
+
+	retry.StatusCode = 201
+	require.NoError(t, repo.Complete(ctx, retry))
+
+	_, err = repo.Reserve(ctx, key, "fingerprint", time.Minute, time.Hour)
+	require.NoError(t, err)
+}
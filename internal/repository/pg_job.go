@@ -0,0 +1,127 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"go-project-template/internal/domain"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+const jobColumns = `id, type, payload, priority, status, attempts, max_attempts, run_at, locked_until, last_error, created_at, updated_at`
+
+type postgresJobRepository struct {
+	conn Connection
+}
+
+// NewJobRepository returns a new [JobRepository].
+func NewJobRepository(conn Connection) domain.JobRepository {
+	return &postgresJobRepository{conn: conn}
+}
+
+func scanJob(row pgx.Row) (*domain.Job, error) {
+	j := &domain.Job{}
+	if err := row.Scan(
+		&j.ID,
+		&j.Type,
+		&j.Payload,
+		&j.Priority,
+		&j.Status,
+		&j.Attempts,
+		&j.MaxAttempts,
+		&j.RunAt,
+		&j.LockedUntil,
+		&j.LastError,
+		&j.CreatedAt,
+		&j.UpdatedAt,
+	); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, domain.ErrNotFound
+		}
+		return nil, err
+	}
+	return j, nil
+}
+
+func (p *postgresJobRepository) Enqueue(ctx context.Context, j *domain.Job) (*domain.Job, error) {
+	query := `
+		INSERT INTO jobs (id, type, payload, priority, max_attempts, run_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING ` + jobColumns
+
+	return scanJob(p.conn.QueryRow(ctx, query, j.ID, j.Type, j.Payload, j.Priority, j.MaxAttempts, j.RunAt))
+}
+
+func (p *postgresJobRepository) Dequeue(ctx context.Context, types []string, visibility time.Duration) (*domain.Job, error) {
+	// SKIP LOCKED lets concurrent workers claim different rows without blocking each other.
+	// Running jobs whose lock expired are considered abandoned and are claimed again.
+	query := `
+		UPDATE jobs
+		SET status = 'running',
+			attempts = attempts + 1,
+			locked_until = now() + $2 * interval '1 millisecond',
+			updated_at = now()
+		WHERE id = (
+			SELECT id
+			FROM jobs
+			WHERE type = ANY($1)
+				AND ((status = 'pending' AND run_at <= now()) OR (status = 'running' AND locked_until < now()))
+			ORDER BY priority DESC, run_at
+			LIMIT 1
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING ` + jobColumns
+
+	return scanJob(p.conn.QueryRow(ctx, query, types, visibility.Milliseconds()))
+}
+
+// finish updates a claimed job. The attempt count acts as a fencing token so a
+// worker whose lock expired cannot overwrite the outcome of the worker that took over.
+func (p *postgresJobRepository) finish(ctx context.Context, j *domain.Job, query string, args ...interface{}) error {
+	args = append([]interface{}{j.ID, j.Attempts}, args...)
+	tag, err := p.conn.Exec(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return domain.ErrNotFound
+	}
+	return nil
+}
+
+func (p *postgresJobRepository) Complete(ctx context.Context, j *domain.Job) error {
+	query := `
+		UPDATE jobs
+		SET status = 'succeeded', locked_until = NULL, updated_at = now()
+		WHERE id = $1 AND attempts = $2 AND status = 'running'`
+
+	return p.finish(ctx, j, query)
+}
+
+func (p *postgresJobRepository) Retry(ctx context.Context, j *domain.Job, runAt time.Time, cause error) error {
+	query := `
+		UPDATE jobs
+		SET status = 'pending', run_at = $3, last_error = $4, locked_until = NULL, updated_at = now()
+		WHERE id = $1 AND attempts = $2 AND status = 'running'`
+
+	return p.finish(ctx, j, query, runAt, cause.Error())
+}
+
+func (p *postgresJobRepository) Bury(ctx context.Context, j *domain.Job, cause error) error {
+	query := `
+		UPDATE jobs
+		SET status = 'dead', last_error = $3, locked_until = NULL, updated_at = now()
+		WHERE id = $1 AND attempts = $2 AND status = 'running'`
+
+	return p.finish(ctx, j, query, cause.Error())
+}
+
+func (p *postgresJobRepository) DeleteSucceeded(ctx context.Context, before time.Time) (int64, error) {
+	query := `DELETE FROM jobs WHERE status = 'succeeded' AND updated_at < $1`
+	tag, err := p.conn.Exec(ctx, query, before)
+	if err != nil {
+		return 0, err
+	}
+	return tag.RowsAffected(), nil
+}
@@ -0,0 +1,124 @@
+package repository_test
+
+import (
+	"context"
+	"errors"
+	"go-project-template/internal/domain"
+	"go-project-template/internal/repository"
+	"go-project-template/internal/testhelper"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func NewTestPostgresJob(t *testing.T) domain.JobRepository {
+	t.Helper()
+
+	ctx := context.Background()
+	conn := testhelper.NewTestPgxConn(t)
+
+	tx, err := conn.Begin(ctx)
+	require.NoError(t, err)
+
+	repo := repository.NewJobRepository(tx)
+
+	t.Cleanup(func() {
+		_ = tx.Rollback(ctx)
+	})
+
+	return repo
+}
+
+func TestPostgresJob_Lifecycle(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	repo := NewTestPostgresJob(t)
+
+	types := []string{"test.lifecycle"}
+
+	low, err := domain.NewJob(types[0], map[string]int{"n": 1})
+	require.NoError(t, err)
+	_, err = repo.Enqueue(ctx, low)
+	require.NoError(t, err)
+
+	high, err := domain.NewJob(types[0], map[string]int{"n": 2})
+	require.NoError(t, err)
+	high.Priority = 10
+	_, err = repo.Enqueue(ctx, high)
+	require.NoError(t, err)
+
+	// Higher priority first
+	j, err := repo.Dequeue(ctx, types, time.Minute)
+	require.NoError(t, err)
+	assert.Equal(t, high.ID, j.ID)
+	assert.Equal(t, domain.JobStatusRunning, j.Status)
+	assert.Equal(t, 1, j.Attempts)
+	require.NoError(t, repo.Complete(ctx, j))
+
+	// A retried job is not runnable before its run_at
+	j, err = repo.Dequeue(ctx, types, time.Minute)
+	require.NoError(t, err)
+	assert.Equal(t, low.ID, j.ID)
+	require.NoError(t, repo.Retry(ctx, j, time.Now().Add(time.Hour), errors.New("boom")))
+
+	_, err = repo.Dequeue(ctx, types, time.Minute)
+	assert.Equal(t, domain.ErrNotFound, err)
+
+	// A stale attempt can no longer finish the job
+	stale := *j
+	stale.Attempts = 0
+	assert.Equal(t, domain.ErrNotFound, repo.Complete(ctx, &stale))
+}
+
+func TestPostgresJob_VisibilityTimeout(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	repo := NewTestPostgresJob(t)
+
+	types := []string{"test.visibility"}
+
+	job, err := domain.NewJob(types[0], nil)
+	require.NoError(t, err)
+	_, err = repo.Enqueue(ctx, job)
+	require.NoError(t, err)
+
+	first, err := repo.Dequeue(ctx, types, -time.Second)
+	require.NoError(t, err)
+
+	// The lock already expired so the job is claimed again
+	second, err := repo.Dequeue(ctx, types, time.Minute)
+	require.NoError(t, err)
+	assert.Equal(t, first.ID, second.ID)
+	assert.Equal(t, 2, second.Attempts)
+
+	assert.Equal(t, domain.ErrNotFound, repo.Complete(ctx, first))
+	require.NoError(t, repo.Bury(ctx, second, errors.New("dead")))
+}
+
+func TestPostgresSchedule_RecordRun(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	conn := testhelper.NewTestPgxConn(t)
+
+	tx, err := conn.Begin(ctx)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = tx.Rollback(ctx) })
+
+	repo := repository.NewScheduleRepository(tx)
+	at := time.Now().Truncate(time.Second)
+
+	j, err := domain.NewJob("test.scheduled", nil)
+	require.NoError(t, err)
+	require.NoError(t, repo.RecordRun(ctx, "test-task", at, j))
+
+	// The same run can only be recorded once
+	dup, err := domain.NewJob("test.scheduled", nil)
+	require.NoError(t, err)
+	assert.Equal(t, domain.ErrConflict, repo.RecordRun(ctx, "test-task", at, dup))
+
+	runs, err := repo.LastRuns(ctx)
+	require.NoError(t, err)
+	assert.True(t, at.Equal(runs["test-task"]))
+}
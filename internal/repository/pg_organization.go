@@ -0,0 +1,600 @@
+package repository
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"go-project-template/internal/domain"
+	"go-project-template/internal/utils"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+const (
+	organizationColumns = `id, tenant_id, name, created_at, updated_at`
+	membershipColumns   = `organization_id, user_uuid, role, created_at, updated_at`
+	invitationColumns   = `id, organization_id, email, role, expires_at, accepted_at, created_at`
+)
+
+type postgresOrganizationRepository struct {
+	conn Connection
+}
+
+// NewOrganizationRepository returns a new [OrganizationRepository].
+func NewOrganizationRepository(conn Connection) domain.OrganizationRepository {
+	return &postgresOrganizationRepository{conn: conn}
+}
+
+func scanOrganization(row pgx.Row) (*domain.Organization, error) {
+	o := &domain.Organization{}
+	if err := row.Scan(&o.ID, &o.TenantID, &o.Name, &o.CreatedAt, &o.UpdatedAt); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, domain.ErrNotFound
+		}
+		return nil, err
+	}
+	return o, nil
+}
+
+func scanMembership(row pgx.Row) (*domain.Membership, error) {
+	m := &domain.Membership{}
+	if err := row.Scan(&m.OrganizationID, &m.UserID, &m.Role, &m.CreatedAt, &m.UpdatedAt); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, domain.ErrNotFound
+		}
+		return nil, err
+	}
+	return m, nil
+}
+
+func scanInvitation(row pgx.Row) (*domain.Invitation, error) {
+	i := &domain.Invitation{}
+	if err := row.Scan(&i.ID, &i.OrganizationID, &i.Email, &i.Role, &i.ExpiresAt, &i.AcceptedAt, &i.CreatedAt); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, domain.ErrNotFound
+		}
+		return nil, err
+	}
+	return i, nil
+}
+
+// newInvitationToken returns a random token that accepts an invitation
+func newInvitationToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return "inv_" + hex.EncodeToString(b), nil
+}
+
+// hashInvitationToken returns what is stored of a token, so a leaked table
+// accepts no invitation
+func hashInvitationToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// lockOrganization locks the organization of the tenant for the rest of tx, so
+// changes to its members are applied one at a time
+func lockOrganization(ctx context.Context, tx pgx.Tx, tenant string, id uuid.UUID) error {
+	var locked uuid.UUID
+	err := tx.QueryRow(ctx, `SELECT id FROM organizations WHERE tenant_id = $1 AND id = $2 FOR UPDATE`, tenant, id).Scan(&locked)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return domain.ErrNotFound
+	}
+	return err
+}
+
+// isLastOwner reports whether the user is the only owner of the locked organization
+func isLastOwner(ctx context.Context, tx pgx.Tx, orgID uuid.UUID, userID uuid.UUID) (bool, error) {
+	query := `
+		SELECT count(*) = 1 AND bool_or(user_uuid = $2)
+		FROM memberships
+		WHERE organization_id = $1 AND role = $3`
+
+	var last *bool
+	if err := tx.QueryRow(ctx, query, orgID, userID, domain.RoleOwner).Scan(&last); err != nil {
+		return false, err
+	}
+	return last != nil && *last, nil
+}
+
+func (p *postgresOrganizationRepository) Create(ctx context.Context, o *domain.Organization, owner uuid.UUID) (*domain.Organization, error) {
+	tenant, err := domain.TenantFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := o.Validate(); err != nil {
+		return nil, err
+	}
+	if o.ID == uuid.Nil {
+		o.ID = uuid.New()
+	}
+
+	tx, err := begin(ctx, p.conn)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	query := `
+		INSERT INTO organizations (id, tenant_id, name)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (id) DO NOTHING
+		RETURNING ` + organizationColumns
+
+	created, err := scanOrganization(tx.QueryRow(ctx, query, o.ID, tenant, o.Name))
+	if errors.Is(err, domain.ErrNotFound) {
+		return nil, domain.ErrConflict
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// Only a user of the tenant can own the organization
+	tag, err := tx.Exec(ctx, `
+		INSERT INTO memberships (organization_id, user_uuid, role)
+		SELECT $1::uuid, uuid, $4::text FROM users WHERE tenant_id = $2 AND uuid = $3`,
+		o.ID, tenant, owner, domain.RoleOwner)
+	if err != nil {
+		return nil, err
+	}
+	if tag.RowsAffected() == 0 {
+		return nil, domain.ErrNotFound
+	}
+
+	return created, tx.Commit(ctx)
+}
+
+func (p *postgresOrganizationRepository) GetByID(ctx context.Context, id uuid.UUID) (*domain.Organization, error) {
+	tenant, err := domain.TenantFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	query := `SELECT ` + organizationColumns + ` FROM organizations WHERE tenant_id = $1 AND id = $2`
+	return scanOrganization(p.conn.QueryRow(ctx, query, tenant, id))
+}
+
+func (p *postgresOrganizationRepository) Update(ctx context.Context, o *domain.Organization) (*domain.Organization, error) {
+	tenant, err := domain.TenantFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := o.Validate(); err != nil {
+		return nil, err
+	}
+
+	query := `
+		UPDATE organizations
+		SET name = $3,
+			updated_at = CASE WHEN name IS DISTINCT FROM $3 THEN statement_timestamp() ELSE updated_at END
+		WHERE tenant_id = $1 AND id = $2
+		RETURNING ` + organizationColumns
+
+	return scanOrganization(p.conn.QueryRow(ctx, query, tenant, o.ID, o.Name))
+}
+
+func (p *postgresOrganizationRepository) Delete(ctx context.Context, id uuid.UUID) error {
+	tenant, err := domain.TenantFromContext(ctx)
+	if err != nil {
+		return err
+	}
+
+	// Members and invitations go with it through ON DELETE CASCADE
+	tag, err := p.conn.Exec(ctx, `DELETE FROM organizations WHERE tenant_id = $1 AND id = $2`, tenant, id)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return domain.ErrNotFound
+	}
+	return nil
+}
+
+func (p *postgresOrganizationRepository) GetList(ctx context.Context, pq *utils.PaginationQuery) (*utils.PaginationResponse[domain.Organization], error) {
+	tenant, err := domain.TenantFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var count int
+	if err := p.conn.QueryRow(ctx, `SELECT count(id) FROM organizations WHERE tenant_id = $1`, tenant).Scan(&count); err != nil {
+		return nil, err
+	}
+	if count == 0 {
+		return utils.DefaultPaginationResponse[domain.Organization](pq), nil
+	}
+
+	column, desc := organizationOrder(pq)
+	dir := "ASC"
+	if desc {
+		dir = "DESC"
+	}
+
+	// id breaks ties so pages do not overlap
+	query := fmt.Sprintf("SELECT %s FROM organizations WHERE tenant_id = $1 ORDER BY %s %s, id OFFSET $2 LIMIT $3", organizationColumns, column, dir)
+	rows, err := p.conn.Query(ctx, query, tenant, pq.GetOffset(), pq.GetLimit())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var oo []domain.Organization
+	for rows.Next() {
+		o, err := scanOrganization(rows)
+		if err != nil {
+			return nil, err
+		}
+		oo = append(oo, *o)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return utils.PaginatedResponse(count, pq, oo), nil
+}
+
+func (p *postgresOrganizationRepository) SetMember(ctx context.Context, m *domain.Membership) (*domain.Membership, error) {
+	tenant, err := domain.TenantFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := m.Validate(); err != nil {
+		return nil, err
+	}
+
+	tx, err := begin(ctx, p.conn)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	if err := lockOrganization(ctx, tx, tenant, m.OrganizationID); err != nil {
+		return nil, err
+	}
+
+	if m.Role != domain.RoleOwner {
+		last, err := isLastOwner(ctx, tx, m.OrganizationID, m.UserID)
+		if err != nil {
+			return nil, err
+		}
+		if last {
+			return nil, domain.ErrLastOwner
+		}
+	}
+
+	query := `
+		INSERT INTO memberships (organization_id, user_uuid, role)
+		SELECT $1::uuid, uuid, $4::text FROM users WHERE tenant_id = $2 AND uuid = $3
+		ON CONFLICT (organization_id, user_uuid) DO UPDATE
+		SET role = $4,
+			updated_at = CASE WHEN memberships.role IS DISTINCT FROM $4 THEN statement_timestamp() ELSE memberships.updated_at END
+		RETURNING ` + membershipColumns
+
+	stored, err := scanMembership(tx.QueryRow(ctx, query, m.OrganizationID, tenant, m.UserID, m.Role))
+	if err != nil {
+		return nil, err
+	}
+	return stored, tx.Commit(ctx)
+}
+
+func (p *postgresOrganizationRepository) RemoveMember(ctx context.Context, orgID uuid.UUID, userID uuid.UUID) error {
+	tenant, err := domain.TenantFromContext(ctx)
+	if err != nil {
+		return err
+	}
+
+	tx, err := begin(ctx, p.conn)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	if err := lockOrganization(ctx, tx, tenant, orgID); err != nil {
+		return err
+	}
+
+	last, err := isLastOwner(ctx, tx, orgID, userID)
+	if err != nil {
+		return err
+	}
+	if last {
+		return domain.ErrLastOwner
+	}
+
+	tag, err := tx.Exec(ctx, `DELETE FROM memberships WHERE organization_id = $1 AND user_uuid = $2`, orgID, userID)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return domain.ErrNotFound
+	}
+	return tx.Commit(ctx)
+}
+
+func (p *postgresOrganizationRepository) Members(ctx context.Context, orgID uuid.UUID, pq *utils.PaginationQuery) (*utils.PaginationResponse[domain.Membership], error) {
+	tenant, err := domain.TenantFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// The organization is counted as well, so an unknown one is told from one without members
+	var orgs, count int
+	err = p.conn.QueryRow(ctx, `
+		SELECT count(DISTINCT o.id), count(m.user_uuid)
+		FROM organizations o LEFT JOIN memberships m ON m.organization_id = o.id
+		WHERE o.tenant_id = $1 AND o.id = $2`, tenant, orgID).Scan(&orgs, &count)
+	if err != nil {
+		return nil, err
+	}
+	if orgs == 0 {
+		return nil, domain.ErrNotFound
+	}
+	if count == 0 {
+		return utils.DefaultPaginationResponse[domain.Membership](pq), nil
+	}
+
+	query := `
+		SELECT ` + membershipColumns + ` FROM memberships
+		WHERE organization_id = $1
+		ORDER BY created_at, user_uuid
+		OFFSET $2 LIMIT $3`
+
+	rows, err := p.conn.Query(ctx, query, orgID, pq.GetOffset(), pq.GetLimit())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var mm []domain.Membership
+	for rows.Next() {
+		m, err := scanMembership(rows)
+		if err != nil {
+			return nil, err
+		}
+		mm = append(mm, *m)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return utils.PaginatedResponse(count, pq, mm), nil
+}
+
+func (p *postgresOrganizationRepository) UserOrganizations(ctx context.Context, userID uuid.UUID, pq *utils.PaginationQuery) (*utils.PaginationResponse[domain.UserOrganization], error) {
+	tenant, err := domain.TenantFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var users, count int
+	err = p.conn.QueryRow(ctx, `
+		SELECT count(DISTINCT u.uuid), count(m.organization_id)
+		FROM users u LEFT JOIN memberships m ON m.user_uuid = u.uuid
+		WHERE u.tenant_id = $1 AND u.uuid = $2`, tenant, userID).Scan(&users, &count)
+	if err != nil {
+		return nil, err
+	}
+	if users == 0 {
+		return nil, domain.ErrNotFound
+	}
+	if count == 0 {
+		return utils.DefaultPaginationResponse[domain.UserOrganization](pq), nil
+	}
+
+	query := `
+		SELECT o.id, o.tenant_id, o.name, o.created_at, o.updated_at, m.role
+		FROM memberships m JOIN organizations o ON o.id = m.organization_id
+		WHERE o.tenant_id = $1 AND m.user_uuid = $2
+		ORDER BY o.name, o.id
+		OFFSET $3 LIMIT $4`
+
+	rows, err := p.conn.Query(ctx, query, tenant, userID, pq.GetOffset(), pq.GetLimit())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var uo []domain.UserOrganization
+	for rows.Next() {
+		var o domain.UserOrganization
+		if err := rows.Scan(&o.ID, &o.TenantID, &o.Name, &o.CreatedAt, &o.UpdatedAt, &o.Role); err != nil {
+			return nil, err
+		}
+		uo = append(uo, o)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return utils.PaginatedResponse(count, pq, uo), nil
+}
+
+func (p *postgresOrganizationRepository) CreateInvitation(ctx context.Context, inv *domain.Invitation, ttl time.Duration) (*domain.Invitation, error) {
+	tenant, err := domain.TenantFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := inv.Validate(); err != nil {
+		return nil, err
+	}
+
+	token, err := newInvitationToken()
+	if err != nil {
+		return nil, err
+	}
+
+	tx, err := begin(ctx, p.conn)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	query := `
+		INSERT INTO invitations (id, organization_id, email, role, token_hash, expires_at)
+		SELECT $1::uuid, id, $4::text, $5::text, $6::text, statement_timestamp() + make_interval(secs => $7)
+		FROM organizations WHERE tenant_id = $2 AND id = $3
+		RETURNING ` + invitationColumns
+
+	created, err := scanInvitation(tx.QueryRow(ctx, query,
+		uuid.New(), tenant, inv.OrganizationID, strings.ToLower(inv.Email), inv.Role, hashInvitationToken(token), ttl.Seconds()))
+	if err != nil {
+		return nil, err
+	}
+
+	// Only the email job carries the token, the invitation stores its hash
+	j, err := domain.NewJob(domain.JobTypeInvitationEmail, domain.InvitationEmailPayload{
+		InvitationID:   created.ID,
+		TenantID:       tenant,
+		OrganizationID: created.OrganizationID,
+		Email:          created.Email,
+		Token:          token,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if _, err := NewJobRepository(tx).Enqueue(ctx, j); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, err
+	}
+
+	created.Token = token
+	return created, nil
+}
+
+func (p *postgresOrganizationRepository) Invitations(ctx context.Context, orgID uuid.UUID) ([]domain.Invitation, error) {
+	if _, err := p.GetByID(ctx, orgID); err != nil {
+		return nil, err
+	}
+
+	query := `
+		SELECT ` + invitationColumns + ` FROM invitations
+		WHERE organization_id = $1 AND accepted_at IS NULL AND expires_at > statement_timestamp()
+		ORDER BY created_at, id`
+
+	rows, err := p.conn.Query(ctx, query, orgID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ii := []domain.Invitation{}
+	for rows.Next() {
+		i, err := scanInvitation(rows)
+		if err != nil {
+			return nil, err
+		}
+		ii = append(ii, *i)
+	}
+	return ii, rows.Err()
+}
+
+func (p *postgresOrganizationRepository) RevokeInvitation(ctx context.Context, orgID uuid.UUID, id uuid.UUID) error {
+	tenant, err := domain.TenantFromContext(ctx)
+	if err != nil {
+		return err
+	}
+
+	query := `
+		DELETE FROM invitations i
+		USING organizations o
+		WHERE o.id = i.organization_id AND o.tenant_id = $1 AND i.organization_id = $2 AND i.id = $3`
+
+	tag, err := p.conn.Exec(ctx, query, tenant, orgID, id)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return domain.ErrNotFound
+	}
+	return nil
+}
+
+func (p *postgresOrganizationRepository) AcceptInvitation(ctx context.Context, token string, userID uuid.UUID) (*domain.Membership, error) {
+	tenant, err := domain.TenantFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	tx, err := begin(ctx, p.conn)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	// Locked so two requests cannot accept the invitation at the same time
+	var (
+		inv   domain.Invitation
+		valid bool
+	)
+	err = tx.QueryRow(ctx, `
+		SELECT i.id, i.organization_id, i.email, i.role, i.accepted_at IS NULL AND i.expires_at > statement_timestamp()
+		FROM invitations i JOIN organizations o ON o.id = i.organization_id
+		WHERE o.tenant_id = $1 AND i.token_hash = $2
+		FOR UPDATE OF i`, tenant, hashInvitationToken(token)).Scan(&inv.ID, &inv.OrganizationID, &inv.Email, &inv.Role, &valid)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, domain.ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !valid {
+		return nil, domain.ErrInvitationInvalid
+	}
+
+	var email *string
+	err = tx.QueryRow(ctx, `SELECT email FROM users WHERE tenant_id = $1 AND uuid = $2`, tenant, userID).Scan(&email)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, domain.ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if email == nil || !strings.EqualFold(*email, inv.Email) {
+		return nil, domain.ErrInvitationEmail
+	}
+
+	// A member keeps its role, the update only makes the row come back
+	query := `
+		INSERT INTO memberships (organization_id, user_uuid, role)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (organization_id, user_uuid) DO UPDATE SET role = memberships.role
+		RETURNING ` + membershipColumns
+
+	m, err := scanMembership(tx.QueryRow(ctx, query, inv.OrganizationID, userID, inv.Role))
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := tx.Exec(ctx, `UPDATE invitations SET accepted_at = statement_timestamp() WHERE id = $1`, inv.ID); err != nil {
+		return nil, err
+	}
+	return m, tx.Commit(ctx)
+}
+
+// organizationOrderColumns are the columns organizations can be ordered by
+var organizationOrderColumns = map[string]bool{
+	"id":         true,
+	"name":       true,
+	"created_at": true,
+}
+
+// organizationOrder returns the column and direction requested by the
+// pagination query, unknown columns order by id
+func organizationOrder(pq *utils.PaginationQuery) (string, bool) {
+	column, dir, _ := strings.Cut(pq.GetOrderBy(), " ")
+	if !organizationOrderColumns[column] {
+		column = "id"
+	}
+	return column, strings.EqualFold(dir, "DESC")
+}
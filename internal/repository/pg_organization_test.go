@@ -0,0 +1,88 @@
+package repository_test
+
+import (
+	"encoding/json"
+	"errors"
+	"go-project-template/internal/domain"
+	"go-project-template/internal/repository"
+	"go-project-template/internal/testhelper"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestPostgresOrganization_LastOwnerRace demotes both owners of an organization
+// at once, the lock on the organization lets only one of them step down
+func TestPostgresOrganization_LastOwnerRace(t *testing.T) {
+	t.Parallel()
+	ctx := tenantContext()
+	pool := testhelper.NewTestPool(t)
+	users, orgs := repository.NewUserRepository(pool), repository.NewOrganizationRepository(pool)
+
+	john, helen := testhelper.NewUser(), testhelper.NewUser()
+	for _, u := range []*domain.User{john, helen} {
+		_, err := users.CreateOrUpdate(ctx, u)
+		require.NoError(t, err)
+	}
+	o, err := orgs.Create(ctx, &domain.Organization{Name: "Acme"}, john.UUID)
+	require.NoError(t, err)
+	_, err = orgs.SetMember(ctx, &domain.Membership{OrganizationID: o.ID, UserID: helen.UUID, Role: domain.RoleOwner})
+	require.NoError(t, err)
+
+	errs := make([]error, 2)
+	var wg sync.WaitGroup
+	for i, u := range []*domain.User{john, helen} {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, errs[i] = orgs.SetMember(ctx, &domain.Membership{OrganizationID: o.ID, UserID: u.UUID, Role: domain.RoleMember})
+		}()
+	}
+	wg.Wait()
+
+	lastOwner := 0
+	for _, err := range errs {
+		if errors.Is(err, domain.ErrLastOwner) {
+			lastOwner++
+		} else {
+			assert.NoError(t, err)
+		}
+	}
+	assert.Equal(t, 1, lastOwner)
+}
+
+func TestPostgresOrganization_InvitationEmail(t *testing.T) {
+	t.Parallel()
+	ctx := tenantContext()
+	conn := testhelper.NewTestPgxConn(t)
+
+	tx, err := conn.Begin(ctx)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = tx.Rollback(ctx) })
+	users, orgs, jobs := repository.NewUserRepository(tx), repository.NewOrganizationRepository(tx), repository.NewJobRepository(tx)
+
+	john := testhelper.NewUser()
+	_, err = users.CreateOrUpdate(ctx, john)
+	require.NoError(t, err)
+	o, err := orgs.Create(ctx, &domain.Organization{Name: "Acme"}, john.UUID)
+	require.NoError(t, err)
+
+	inv, err := orgs.CreateInvitation(ctx, &domain.Invitation{OrganizationID: o.ID, Email: "Helen@mail.com", Role: domain.RoleMember}, time.Hour)
+	require.NoError(t, err)
+
+	// The token leaves with the email job queued alongside the invitation
+	j, err := jobs.Dequeue(ctx, []string{domain.JobTypeInvitationEmail}, time.Minute)
+	require.NoError(t, err)
+	var p domain.InvitationEmailPayload
+	require.NoError(t, json.Unmarshal(j.Payload, &p))
+	assert.Equal(t, domain.InvitationEmailPayload{
+		InvitationID:   inv.ID,
+		TenantID:       testTenant,
+		OrganizationID: o.ID,
+		Email:          "helen@mail.com",
+		Token:          inv.Token,
+	}, p)
+}
@@ -0,0 +1,118 @@
+package repository
+
+import (
+	"context"
+	"go-project-template/internal/domain"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+type postgresOutboxRepository struct {
+	conn Connection
+}
+
+// NewOutboxRepository returns a new [OutboxRepository].
+func NewOutboxRepository(conn Connection) domain.OutboxRepository {
+	return &postgresOutboxRepository{conn: conn}
+}
+
+const eventColumns = `id, aggregate_type, aggregate_id, COALESCE(tenant_id, ''), event_type, payload, occurred_at`
+
+func scanEvent(row pgx.Row) (domain.Event, error) {
+	var e domain.Event
+	err := row.Scan(
+		&e.ID,
+		&e.AggregateType,
+		&e.AggregateID,
+		&e.TenantID,
+		&e.Type,
+		&e.Payload,
+		&e.OccurredAt,
+	)
+	return e, err
+}
+
+func (p *postgresOutboxRepository) fetch(ctx context.Context, query string, args ...interface{}) ([]domain.Event, error) {
+	rows, err := p.conn.Query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ee []domain.Event
+	for rows.Next() {
+		e, err := scanEvent(rows)
+		if err != nil {
+			return nil, err
+		}
+		ee = append(ee, e)
+	}
+	return ee, rows.Err()
+}
+
+func (p *postgresOutboxRepository) Pending(ctx context.Context, limit int) ([]domain.Event, error) {
+	query := `
+		SELECT ` + eventColumns + `
+		FROM outbox
+		WHERE published_at IS NULL
+		ORDER BY id
+		LIMIT $1`
+
+	return p.fetch(ctx, query, limit)
+}
+
+func (p *postgresOutboxRepository) MarkPublished(ctx context.Context, id int64) error {
+	query := `UPDATE outbox SET published_at = now(), attempts = attempts + 1 WHERE id = $1 AND published_at IS NULL`
+	tag, err := p.conn.Exec(ctx, query, id)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return domain.ErrNotFound
+	}
+	return nil
+}
+
+func (p *postgresOutboxRepository) MarkFailed(ctx context.Context, id int64, cause error) error {
+	query := `UPDATE outbox SET attempts = attempts + 1, last_error = $2 WHERE id = $1 AND published_at IS NULL`
+	_, err := p.conn.Exec(ctx, query, id, cause.Error())
+	return err
+}
+
+func (p *postgresOutboxRepository) DeletePublished(ctx context.Context, before time.Time) (int64, error) {
+	// Events still waiting to be fanned out to webhooks are kept
+	query := `DELETE FROM outbox WHERE published_at < $1 AND fanned_out_at IS NOT NULL`
+	tag, err := p.conn.Exec(ctx, query, before)
+	if err != nil {
+		return 0, err
+	}
+	return tag.RowsAffected(), nil
+}
+
+func (p *postgresOutboxRepository) After(ctx context.Context, aggregateType string, afterID int64, limit int) ([]domain.Event, error) {
+	query := `
+		SELECT ` + eventColumns + `
+		FROM outbox
+		WHERE aggregate_type = $1 AND id > $2
+		ORDER BY id
+		LIMIT $3`
+
+	return p.fetch(ctx, query, aggregateType, afterID, limit)
+}
+
+func (p *postgresOutboxRepository) Horizon(ctx context.Context) (int64, int64, error) {
+	query := `
+		SELECT pg_snapshot_xmin(s)::text::bigint, pg_snapshot_xmax(s)::text::bigint
+		FROM pg_current_snapshot() AS s`
+
+	var xmin, xmax int64
+	err := p.conn.QueryRow(ctx, query).Scan(&xmin, &xmax)
+	return xmin, xmax, err
+}
+
+func (p *postgresOutboxRepository) LastID(ctx context.Context) (int64, error) {
+	var id int64
+	err := p.conn.QueryRow(ctx, `SELECT COALESCE(max(id), 0) FROM outbox`).Scan(&id)
+	return id, err
+}
@@ -0,0 +1,64 @@
+package repository_test
+
+import (
+	"encoding/json"
+	"go-project-template/internal/domain"
+	"go-project-template/internal/repository"
+	"go-project-template/internal/testhelper"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPostgresOutbox_UserEvents(t *testing.T) {
+	t.Parallel()
+	ctx := tenantContext()
+	conn := testhelper.NewTestPgxConn(t)
+
+	tx, err := conn.Begin(ctx)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = tx.Rollback(ctx) })
+
+	users := repository.NewUserRepository(tx)
+	outbox := repository.NewOutboxRepository(tx)
+
+	email := "outbox@mail.com"
+	u := &domain.User{UUID: uuid.New(), FirstName: "Ada", LastName: "Outbox", Email: &email}
+	_, err = users.CreateOrUpdate(ctx, u)
+	require.NoError(t, err)
+
+	u.FirstName = "Grace"
+	_, err = users.CreateOrUpdate(ctx, u)
+	require.NoError(t, err)
+
+	require.NoError(t, users.Delete(ctx, u.UUID))
+	// Deleting a missing user records nothing
+	require.NoError(t, users.Delete(ctx, u.UUID))
+
+	pending, err := outbox.Pending(ctx, 10000)
+	require.NoError(t, err)
+
+	var events []domain.Event
+	for _, e := range pending {
+		if e.AggregateID == u.UUID {
+			events = append(events, e)
+		}
+	}
+
+	require.Len(t, events, 3)
+	assert.Equal(t, domain.EventUserCreated, events[0].Type)
+	assert.Equal(t, domain.EventUserUpdated, events[1].Type)
+	assert.Equal(t, domain.EventUserDeleted, events[2].Type)
+	for _, e := range events {
+		assert.Equal(t, testTenant, e.TenantID)
+	}
+
+	var updated domain.User
+	require.NoError(t, json.Unmarshal(events[1].Payload, &updated))
+	assert.Equal(t, "grace", updated.FirstName)
+
+	require.NoError(t, outbox.MarkPublished(ctx, events[0].ID))
+	assert.Equal(t, domain.ErrNotFound, outbox.MarkPublished(ctx, events[0].ID))
+}
@@ -0,0 +1,65 @@
+package repository
+
+import (
+	"context"
+	"go-project-template/internal/domain"
+	"time"
+)
+
+type postgresScheduleRepository struct {
+	conn Connection
+}
+
+// NewScheduleRepository returns a new [ScheduleRepository].
+func NewScheduleRepository(conn Connection) domain.ScheduleRepository {
+	return &postgresScheduleRepository{conn: conn}
+}
+
+func (p *postgresScheduleRepository) LastRuns(ctx context.Context) (map[string]time.Time, error) {
+	rows, err := p.conn.Query(ctx, `SELECT name, last_run_at FROM scheduled_tasks`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	runs := map[string]time.Time{}
+	for rows.Next() {
+		var name string
+		var at time.Time
+		if err := rows.Scan(&name, &at); err != nil {
+			return nil, err
+		}
+		runs[name] = at
+	}
+	return runs, rows.Err()
+}
+
+func (p *postgresScheduleRepository) RecordRun(ctx context.Context, task string, scheduledAt time.Time, j *domain.Job) error {
+	tx, err := begin(ctx, p.conn)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	// Only move last_run_at forward so a run is never fired twice
+	query := `
+		INSERT INTO scheduled_tasks (name, last_run_at)
+		VALUES ($1, $2)
+		ON CONFLICT(name) DO UPDATE
+		SET last_run_at = EXCLUDED.last_run_at, updated_at = now()
+		WHERE scheduled_tasks.last_run_at < EXCLUDED.last_run_at`
+
+	tag, err := tx.Exec(ctx, query, task, scheduledAt)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return domain.ErrConflict
+	}
+
+	if _, err := NewJobRepository(tx).Enqueue(ctx, j); err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
@@ -2,19 +2,197 @@ package repository
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"go-project-template/internal/domain"
 	"go-project-template/internal/utils"
+	"strings"
+	"time"
 
 	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"go.uber.org/zap"
 )
 
+// upsertUserQuery writes the user and its outbox event in a single statement.
+// xmax is zero only for freshly inserted rows, which tells creates from updates.
+// A created user with an email gets a [domain.JobTypeWelcomeEmail] job, whose
+// payload is a [domain.WelcomeEmailPayload].
+// updated_at only moves when a field actually changes, to the time of the
+// statement so updates within one transaction are told apart. The event
+// payload is the stored row. The status is only written on insert, it changes
+// through Transition afterwards. A user of another tenant is left alone and no
+// row comes back.
+const upsertUserQuery = `
+	WITH upserted AS (
+		INSERT INTO users (uuid, tenant_id, first_name, last_name, email, status)
+		VALUES ($1, $2, $3, $4, $5, $9)
+		ON CONFLICT(uuid) DO UPDATE
+		SET first_name = $3, last_name = $4, email = $5,
+			updated_at = CASE
+				WHEN (users.first_name, users.last_name, users.email) IS DISTINCT FROM ($3, $4, $5) THEN statement_timestamp()
+				ELSE users.updated_at
+			END
+		WHERE users.tenant_id = $2
+		RETURNING ` + userColumns + `, (xmax = 0) AS inserted
+	), welcome AS (
+		INSERT INTO jobs (type, payload)
+		SELECT $10::text, jsonb_build_object('user_id', uuid, 'tenant_id', tenant_id)
+		FROM upserted
+		WHERE inserted AND email IS NOT NULL
+	)
+	INSERT INTO outbox (aggregate_type, aggregate_id, tenant_id, event_type, payload)
+	SELECT $6::text, uuid, tenant_id, CASE WHEN inserted THEN $7::text ELSE $8::text END, to_jsonb(upserted) - 'inserted'
+	FROM upserted
+	RETURNING ` + eventColumns
+
+// insertUserQuery is upsertUserQuery without the update, a taken UUID leaves no
+// row to return
+const insertUserQuery = `
+	WITH inserted AS (
+		INSERT INTO users (uuid, tenant_id, first_name, last_name, email, status)
+		VALUES ($1, $2, $3, $4, $5, $8)
+		ON CONFLICT(uuid) DO NOTHING
+		RETURNING ` + userColumns + `
+	), welcome AS (
+		INSERT INTO jobs (type, payload)
+		SELECT $9::text, jsonb_build_object('user_id', uuid, 'tenant_id', tenant_id)
+		FROM inserted
+		WHERE email IS NOT NULL
+	)
+	INSERT INTO outbox (aggregate_type, aggregate_id, tenant_id, event_type, payload)
+	SELECT $6::text, uuid, tenant_id, $7::text, to_jsonb(inserted)
+	FROM inserted
+	RETURNING ` + eventColumns
+
+// insufficientPrivilege is the SQLSTATE of a row-level security violation
+const insufficientPrivilege = "42501"
+
+// SQLSTATE classes of errors caused by the values of a row
+const (
+	dataException                = "22"
+	integrityConstraintViolation = "23"
+)
+
+const userColumns = "uuid, tenant_id, first_name, last_name, email, status, status_reason, created_at, updated_at"
+
 type postgresUserRepository struct {
-	conn Connection
+	conn      Connection
+	publisher domain.EventPublisher
+	logger    *zap.Logger
+}
+
+// UserRepositoryOption configures the repository returned by [NewUserRepository]
+type UserRepositoryOption func(*postgresUserRepository)
+
+// WithEventPublisher publishes the event recorded by every successful write.
+// Events are published once the repository's own statement or transaction
+// succeeds, on a caller's transaction that is before the caller commits.
+func WithEventPublisher(publisher domain.EventPublisher) UserRepositoryOption {
+	return func(p *postgresUserRepository) {
+		p.publisher = publisher
+	}
+}
+
+// WithLogger sets the logger for events that cannot be published
+func WithLogger(logger *zap.Logger) UserRepositoryOption {
+	return func(p *postgresUserRepository) {
+		p.logger = logger
+	}
 }
 
 // NewUserRepository returns a new [UserRepository].
-func NewUserRepository(conn Connection) domain.UserRepository {
-	return &postgresUserRepository{conn: conn}
+func NewUserRepository(conn Connection, opts ...UserRepositoryOption) domain.UserRepository {
+	p := &postgresUserRepository{conn: conn, logger: zap.NewNop()}
+	for _, opt := range opts {
+		opt(p)
+	}
+	return p
+}
+
+// publish hands recorded events to the publisher. The write already succeeded,
+// so subscriber failures are left to the publisher to report.
+func (p *postgresUserRepository) publish(ctx context.Context, ee []domain.Event) {
+	if p.publisher == nil || len(ee) == 0 {
+		return
+	}
+
+	events := make([]domain.DomainEvent, 0, len(ee))
+	for _, e := range ee {
+		de, err := e.Decode()
+		if err != nil {
+			// The outbox still has the event, only in-process subscribers miss it
+			p.logger.Error("failed to decode recorded event", zap.Int64("event#id", e.ID), zap.String("event#type", e.Type), zap.Error(err))
+			continue
+		}
+		events = append(events, de)
+	}
+	_ = p.publisher.Publish(ctx, events...)
+}
+
+// upsertUserArgs returns the arguments of upsertUserQuery for u
+func upsertUserArgs(tenant string, u *domain.User) []interface{} {
+	return []interface{}{
+		u.UUID,
+		tenant,
+		u.NormalizedFirstName(),
+		u.NormalizedLastName(),
+		u.Email,
+		domain.AggregateUser,
+		domain.EventUserCreated,
+		domain.EventUserUpdated,
+		u.InitialStatus(),
+		domain.JobTypeWelcomeEmail,
+	}
+}
+
+// scanUpsert reads the event recorded by upsertUserQuery. No event means the
+// user belongs to another tenant, which is reported as a conflict, as is the
+// row-level security violation raised instead when the policies are enabled.
+func scanUpsert(row pgx.Row) (domain.Event, error) {
+	e, err := scanEvent(row)
+	var pgErr *pgconn.PgError
+	if errors.Is(err, pgx.ErrNoRows) || (errors.As(err, &pgErr) && pgErr.Code == insufficientPrivilege) {
+		return e, domain.ErrConflict
+	}
+	return e, err
+}
+
+// itemFailed reports whether err was caused by the user itself, like a conflict
+// or a value the database refuses. Any other error fails the whole batch.
+func itemFailed(err error) bool {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		return strings.HasPrefix(pgErr.Code, dataException) || strings.HasPrefix(pgErr.Code, integrityConstraintViolation)
+	}
+	return errors.Is(err, domain.ErrConflict)
+}
+
+// stampUser copies the tenant, status and timestamps of the stored row recorded in e to u
+func stampUser(u *domain.User, e domain.Event) error {
+	var stored domain.User
+	if err := json.Unmarshal(e.Payload, &stored); err != nil {
+		return err
+	}
+	u.TenantID, u.CreatedAt, u.UpdatedAt = stored.TenantID, stored.CreatedAt, stored.UpdatedAt
+	u.Status, u.StatusReason = stored.Status, stored.StatusReason
+	return nil
+}
+
+func scanUser(row pgx.Row, u *domain.User) error {
+	return row.Scan(
+		&u.UUID,
+		&u.TenantID,
+		&u.FirstName,
+		&u.LastName,
+		&u.Email,
+		&u.Status,
+		&u.StatusReason,
+		&u.CreatedAt,
+		&u.UpdatedAt,
+	)
 }
 
 func (p *postgresUserRepository) fetch(ctx context.Context, query string, args ...interface{}) ([]domain.User, error) {
@@ -27,26 +205,27 @@ func (p *postgresUserRepository) fetch(ctx context.Context, query string, args .
 	var uu []domain.User
 	for rows.Next() {
 		var u domain.User
-		if err := rows.Scan(
-			&u.UUID,
-			&u.FirstName,
-			&u.LastName,
-			&u.Email,
-		); err != nil {
+		if err := scanUser(rows, &u); err != nil {
 			return nil, err
 		}
 		uu = append(uu, u)
 	}
-	return uu, nil
+	return uu, rows.Err()
 }
 
 func (p *postgresUserRepository) GetByID(ctx context.Context, uuid uuid.UUID) (domain.User, error) {
+	tenant, err := domain.TenantFromContext(ctx)
+	if err != nil {
+		return domain.User{}, err
+	}
+
+	ctx = readFromReplica(ctx)
 	query := `
-		SELECT *
+		SELECT ` + userColumns + `
 		FROM users
-		WHERE uuid = $1`
+		WHERE tenant_id = $1 AND uuid = $2`
 
-	srs, err := p.fetch(ctx, query, uuid)
+	srs, err := p.fetch(ctx, query, tenant, uuid)
 
 	if err != nil {
 		return domain.User{}, err
@@ -57,40 +236,351 @@ func (p *postgresUserRepository) GetByID(ctx context.Context, uuid uuid.UUID) (d
 	return srs[0], nil
 }
 
-func (p *postgresUserRepository) CreateOrUpdate(ctx context.Context, u *domain.User) (*domain.User, error) {
+func (p *postgresUserRepository) Create(ctx context.Context, u *domain.User) (*domain.User, error) {
+	tenant, err := domain.TenantFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
 	if err := u.Validate(); err != nil {
 		return nil, err
 	}
 
-	query := `
-		INSERT INTO users (uuid, first_name, last_name, email)
-		VALUES ($1, $2, $3, $4)
-		ON CONFLICT(uuid) DO UPDATE
-		SET first_name = $2, last_name = $3, email = $4;
-		`
-
-	_, err := p.conn.Exec(
-		ctx,
-		query,
+	args := []interface{}{
 		u.UUID,
+		tenant,
 		u.NormalizedFirstName(),
 		u.NormalizedLastName(),
-		&u.Email,
-	)
+		u.Email,
+		domain.AggregateUser,
+		domain.EventUserCreated,
+		u.InitialStatus(),
+		domain.JobTypeWelcomeEmail,
+	}
+	e, err := scanUpsert(p.conn.QueryRow(ctx, insertUserQuery, args...))
+	if err != nil {
+		return nil, err
+	}
+	if err := stampUser(u, e); err != nil {
+		return nil, err
+	}
+
+	p.publish(ctx, []domain.Event{e})
+
+	return u, nil
+}
+
+func (p *postgresUserRepository) CreateOrUpdate(ctx context.Context, u *domain.User) (*domain.User, error) {
+	tenant, err := domain.TenantFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := u.Validate(); err != nil {
+		return nil, err
+	}
+
+	e, err := scanUpsert(p.conn.QueryRow(ctx, upsertUserQuery, upsertUserArgs(tenant, u)...))
+	if err != nil {
+		return nil, err
+	}
+	if err := stampUser(u, e); err != nil {
+		return nil, err
+	}
+
+	p.publish(ctx, []domain.Event{e})
+
+	return u, nil
+}
+
+func (p *postgresUserRepository) BatchCreateOrUpdate(ctx context.Context, uu []*domain.User, mode domain.BatchMode) ([]domain.BatchItemResult, error) {
+	tenant, err := domain.TenantFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]domain.BatchItemResult, len(uu))
+
+	invalid := false
+	for i, u := range uu {
+		if u.UUID == uuid.Nil {
+			u.UUID = uuid.New()
+		}
+		results[i] = domain.BatchItemResult{Index: i, UUID: u.UUID}
+
+		if err := u.Validate(); err != nil {
+			results[i].Status = domain.BatchStatusInvalid
+			results[i].Error = err.Error()
+			invalid = true
+		}
+	}
+
+	if invalid && mode == domain.BatchModeAtomic {
+		skipPending(results)
+		return results, domain.ErrBatchRejected
+	}
+
+	tx, err := begin(ctx, p.conn)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	var events []domain.Event
+	if mode == domain.BatchModeAtomic {
+		events, err = p.batchUpsertAtomic(ctx, tx, tenant, uu, results)
+	} else {
+		events, err = p.batchUpsertPartial(ctx, tx, tenant, uu, results)
+	}
+	if err != nil {
+		return results, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, err
+	}
+
+	p.publish(ctx, events)
+	return results, nil
+}
+
+// batchUpsertAtomic sends every upsert in one round trip and stops at the first failure
+func (p *postgresUserRepository) batchUpsertAtomic(ctx context.Context, tx pgx.Tx, tenant string, uu []*domain.User, results []domain.BatchItemResult) ([]domain.Event, error) {
+	batch := &pgx.Batch{}
+	for _, u := range uu {
+		batch.Queue(upsertUserQuery, upsertUserArgs(tenant, u)...)
+	}
+
+	br := tx.SendBatch(ctx, batch)
+	defer br.Close()
 
-	return u, err
+	events := make([]domain.Event, 0, len(uu))
+	for i := range uu {
+		e, err := scanUpsert(br.QueryRow())
+		if err != nil && !itemFailed(err) {
+			return nil, err
+		}
+		if err != nil {
+			results[i].Status = domain.BatchStatusFailed
+			results[i].Error = err.Error()
+			skipPending(results)
+			return nil, domain.ErrBatchRejected
+		}
+		if err := stampUser(uu[i], e); err != nil {
+			return nil, err
+		}
+		results[i].Status = domain.BatchStatusOK
+		events = append(events, e)
+	}
+	return events, br.Close()
+}
+
+// batchUpsertPartial wraps every upsert in a savepoint so a failing row does not abort the transaction
+func (p *postgresUserRepository) batchUpsertPartial(ctx context.Context, tx pgx.Tx, tenant string, uu []*domain.User, results []domain.BatchItemResult) ([]domain.Event, error) {
+	var events []domain.Event
+	for i, u := range uu {
+		if results[i].Status != "" {
+			continue
+		}
+
+		sp, err := tx.Begin(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		e, err := scanUpsert(sp.QueryRow(ctx, upsertUserQuery, upsertUserArgs(tenant, u)...))
+		if err != nil && !itemFailed(err) {
+			return nil, err
+		}
+		if err != nil {
+			_ = sp.Rollback(ctx)
+			results[i].Status = domain.BatchStatusFailed
+			results[i].Error = err.Error()
+			continue
+		}
+
+		if err := sp.Commit(ctx); err != nil {
+			return nil, err
+		}
+		if err := stampUser(u, e); err != nil {
+			return nil, err
+		}
+		results[i].Status = domain.BatchStatusOK
+		events = append(events, e)
+	}
+	return events, nil
+}
+
+// skipPending marks every item without an outcome as skipped
+func skipPending(results []domain.BatchItemResult) {
+	for i := range results {
+		if results[i].Status == "" || results[i].Status == domain.BatchStatusOK {
+			results[i].Status = domain.BatchStatusSkipped
+		}
+	}
 }
 
 func (p *postgresUserRepository) Delete(ctx context.Context, uuid uuid.UUID) error {
-	query := `DELETE FROM users WHERE uuid = $1`
-	_, err := p.conn.Exec(ctx, query, uuid)
+	tenant, err := domain.TenantFromContext(ctx)
+	if err != nil {
+		return err
+	}
+
+	// The event is only recorded if a user was actually deleted
+	query := `
+		WITH deleted AS (
+			DELETE FROM users WHERE tenant_id = $1 AND uuid = $2
+			RETURNING uuid, tenant_id
+		)
+		INSERT INTO outbox (aggregate_type, aggregate_id, tenant_id, event_type, payload)
+		SELECT $3::text, uuid, tenant_id, $4::text, jsonb_build_object('uuid', uuid)
+		FROM deleted
+		RETURNING ` + eventColumns
+
+	e, err := scanEvent(p.conn.QueryRow(ctx, query, tenant, uuid, domain.AggregateUser, domain.EventUserDeleted))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	p.publish(ctx, []domain.Event{e})
+	return nil
+}
+
+func (p *postgresUserRepository) DeleteAll(ctx context.Context) ([]uuid.UUID, error) {
+	tenant, err := domain.TenantFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	query := `
+		WITH deleted AS (
+			DELETE FROM users WHERE tenant_id = $1
+			RETURNING uuid, tenant_id
+		)
+		INSERT INTO outbox (aggregate_type, aggregate_id, tenant_id, event_type, payload)
+		SELECT $2::text, uuid, tenant_id, $3::text, jsonb_build_object('uuid', uuid)
+		FROM deleted
+		RETURNING ` + eventColumns
+
+	rows, err := p.conn.Query(ctx, query, tenant, domain.AggregateUser, domain.EventUserDeleted)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ee []domain.Event
+	for rows.Next() {
+		e, err := scanEvent(rows)
+		if err != nil {
+			return nil, err
+		}
+		ee = append(ee, e)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	ids := make([]uuid.UUID, len(ee))
+	for i, e := range ee {
+		ids[i] = e.AggregateID
+	}
+	p.publish(ctx, ee)
+	return ids, nil
+}
+
+func (p *postgresUserRepository) Backdate(ctx context.Context, uu []*domain.User) error {
+	tenant, err := domain.TenantFromContext(ctx)
+	if err != nil {
+		return err
+	}
+
+	ids := make([]uuid.UUID, len(uu))
+	created := make([]time.Time, len(uu))
+	updated := make([]time.Time, len(uu))
+	for i, u := range uu {
+		ids[i], created[i], updated[i] = u.UUID, u.CreatedAt, u.UpdatedAt
+	}
+
+	query := `
+		UPDATE users SET created_at = t.created_at, updated_at = t.updated_at
+		FROM unnest($2::uuid[], $3::timestamptz[], $4::timestamptz[]) AS t(uuid, created_at, updated_at)
+		WHERE users.tenant_id = $1 AND users.uuid = t.uuid`
+
+	_, err = p.conn.Exec(ctx, query, tenant, ids, created, updated)
 	return err
 }
 
-func (p *postgresUserRepository) GetList(ctx context.Context, pq *utils.PaginationQuery) (*utils.PaginationResponse[domain.User], error) {
+func (p *postgresUserRepository) Transition(ctx context.Context, id uuid.UUID, c domain.StatusChange) (*domain.User, error) {
+	tenant, err := domain.TenantFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := c.Validate(); err != nil {
+		return nil, err
+	}
+
+	tx, err := begin(ctx, p.conn)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	// Locked so concurrent transitions are checked against the status the other one left
+	var from domain.UserStatus
+	err = tx.QueryRow(ctx, `SELECT status FROM users WHERE tenant_id = $1 AND uuid = $2 FOR UPDATE`, tenant, id).Scan(&from)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, domain.ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := from.Transition(c.Status); err != nil {
+		return nil, err
+	}
+
+	query := `
+		WITH updated AS (
+			UPDATE users
+			SET status = $3, status_reason = $4, updated_at = statement_timestamp()
+			WHERE tenant_id = $1 AND uuid = $2
+			RETURNING ` + userColumns + `
+		)
+		INSERT INTO outbox (aggregate_type, aggregate_id, tenant_id, event_type, payload)
+		SELECT $5::text, uuid, tenant_id, $6::text, jsonb_build_object('user', to_jsonb(updated), 'from', $7::text, 'reason', $4::text)
+		FROM updated
+		RETURNING ` + eventColumns
+
+	e, err := scanEvent(tx.QueryRow(ctx, query, tenant, id, c.Status, c.Reason, domain.AggregateUser, domain.EventUserStatusChanged, from))
+	if err != nil {
+		return nil, err
+	}
+	de, err := e.Decode()
+	if err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return nil, err
+	}
+
+	p.publish(ctx, []domain.Event{e})
+
+	u := de.(domain.UserStatusChanged).User
+	return &u, nil
+}
+
+func (p *postgresUserRepository) GetList(ctx context.Context, pq *utils.PaginationQuery, f domain.UserFilter) (*utils.PaginationResponse[domain.User], error) {
+	tenant, err := domain.TenantFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx = pinReplica(readFromReplica(ctx))
+	where, args := userFilterClause(tenant, f)
+
 	var count int
-	countQuery := "SELECT count(uuid) FROM users;"
-	if err := p.conn.QueryRow(ctx, countQuery).Scan(&count); err != nil {
+	countQuery := "SELECT count(uuid) FROM users" + where
+	if err := p.conn.QueryRow(ctx, countQuery, args...).Scan(&count); err != nil {
 		return nil, err
 	}
 
@@ -98,26 +588,88 @@ func (p *postgresUserRepository) GetList(ctx context.Context, pq *utils.Paginati
 		return utils.DefaultPaginationResponse[domain.User](pq), nil
 	}
 
-	query := "SELECT * FROM users ORDER BY $1::text OFFSET $2 LIMIT $3"
-	rows, err := p.conn.Query(ctx, query, pq.GetOrderBy(), pq.GetOffset(), pq.GetLimit())
+	column, desc := userOrder(pq)
+	dir := "ASC"
+	if desc {
+		dir = "DESC"
+	}
+
+	n := len(args)
+	// uuid breaks ties so pages do not overlap
+	query := fmt.Sprintf("SELECT %s FROM users%s ORDER BY %s %s, uuid OFFSET $%d LIMIT $%d", userColumns, where, column, dir, n+1, n+2)
+	args = append(args, pq.GetOffset(), pq.GetLimit())
+	uu, err := p.fetch(ctx, query, args...)
 	if err != nil {
 		return nil, err
 	}
+
+	return utils.PaginatedResponse(count, pq, uu), nil
+}
+
+func (p *postgresUserRepository) Stream(ctx context.Context, f domain.UserFilter, fn func(*domain.User) error) error {
+	tenant, err := domain.TenantFromContext(ctx)
+	if err != nil {
+		return err
+	}
+
+	ctx = readFromReplica(ctx)
+	where, args := userFilterClause(tenant, f)
+	query := "SELECT " + userColumns + " FROM users" + where + " ORDER BY uuid"
+
+	// pgx reads rows off the connection as they are scanned, so memory stays bounded
+	rows, err := p.conn.Query(ctx, query, args...)
+	if err != nil {
+		return err
+	}
 	defer rows.Close()
 
-	var uu []domain.User
+	var u domain.User
 	for rows.Next() {
-		var u domain.User
-		if err := rows.Scan(
-			&u.UUID,
-			&u.FirstName,
-			&u.LastName,
-			&u.Email,
-		); err != nil {
-			return nil, err
+		if err := scanUser(rows, &u); err != nil {
+			return err
+		}
+		if err := fn(&u); err != nil {
+			return err
 		}
-		uu = append(uu, u)
 	}
+	return rows.Err()
+}
 
-	return utils.PaginatedResponse(count, pq, uu), nil
+// userOrderColumns are the columns users can be ordered by
+var userOrderColumns = map[string]bool{
+	"uuid":       true,
+	"first_name": true,
+	"last_name":  true,
+	"email":      true,
+}
+
+// userOrder returns the column and direction requested by the pagination query.
+// Unknown columns, including the default "id", order by uuid.
+func userOrder(pq *utils.PaginationQuery) (string, bool) {
+	column, dir, _ := strings.Cut(pq.GetOrderBy(), " ")
+	if !userOrderColumns[column] {
+		column = "uuid"
+	}
+	return column, strings.EqualFold(dir, "DESC")
+}
+
+// userFilterClause builds a WHERE clause and its arguments from the tenant and
+// the non-empty filter fields
+func userFilterClause(tenant string, f domain.UserFilter) (string, []interface{}) {
+	conds := []string{"tenant_id = $1"}
+	args := []interface{}{tenant}
+
+	add := func(column string, value string) {
+		if value == "" {
+			return
+		}
+		args = append(args, value)
+		conds = append(conds, fmt.Sprintf("%s = $%d", column, len(args)))
+	}
+	add("first_name", strings.ToLower(f.FirstName))
+	add("last_name", strings.ToLower(f.LastName))
+	add("email", f.Email)
+	add("status", string(f.Status))
+
+	return " WHERE " + strings.Join(conds, " AND "), args
 }
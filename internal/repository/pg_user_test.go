@@ -2,12 +2,19 @@ package repository_test
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"go-project-template/internal/domain"
 	"go-project-template/internal/repository"
 	"go-project-template/internal/testhelper"
+	"go-project-template/internal/utils"
+	"strings"
 	"testing"
 
 	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -18,11 +25,21 @@ const (
 	user_get_uuid    = "3fe82b1f-ab3d-40a1-8bd8-bccd4dd166f8"
 )
 
-func NewTestPostgresUser(t *testing.T) domain.UserRepository {
+// testTenant owns the users of the fixtures
+const testTenant = "acme"
+
+func tenantContext() context.Context {
+	return domain.WithTenant(context.Background(), testTenant)
+}
+
+// NewTestPostgresUser returns a repository on a transaction of a test schema
+// that is loaded with the given fixture files
+func NewTestPostgresUser(t *testing.T, fixtures ...string) domain.UserRepository {
 	t.Helper()
 
 	ctx := context.Background()
 	conn := testhelper.NewTestPgxConn(t)
+	testhelper.LoadFixtures(t, conn, fixtures...)
 
 	tx, err := conn.Begin(ctx)
 	require.NoError(t, err)
@@ -38,7 +55,7 @@ func NewTestPostgresUser(t *testing.T) domain.UserRepository {
 
 func TestPostgresUser_Create(t *testing.T) {
 	t.Parallel()
-	ctx := context.Background()
+	ctx := tenantContext()
 	repo := NewTestPostgresUser(t)
 
 	parsedUserId, err := uuid.Parse(user_create_uuid)
@@ -50,8 +67,8 @@ func TestPostgresUser_Create(t *testing.T) {
 		have *domain.User
 		err  bool
 	}{
-		"valid":        {&domain.User{UUID: parsedUserId}, false},
-		"invalid uuid": {&domain.User{UUID: uuid.New()}, true},
+		"valid":        {testhelper.NewUser(func(u *domain.User) { u.UUID = parsedUserId }), false},
+		"invalid user": {testhelper.NewUser(func(u *domain.User) { u.LastName = "" }), true},
 	}
 
 	for scenario, tc := range testCases { //nolint:paralleltest
@@ -63,23 +80,81 @@ func TestPostgresUser_Create(t *testing.T) {
 				return
 			}
 
-			assert.NotEqual(t, 0, tc.have.UUID)
+			assert.NoError(t, err)
+			got, err := repo.GetByID(ctx, parsedUserId)
+			require.NoError(t, err)
+			assert.Equal(t, tc.have.NormalizedFirstName(), got.FirstName)
+		})
+	}
+}
+
+func TestPostgresUser_WelcomeEmail(t *testing.T) {
+	t.Parallel()
+	ctx := tenantContext()
+	conn := testhelper.NewTestPgxConn(t)
+
+	tx, err := conn.Begin(ctx)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = tx.Rollback(ctx) })
+	repo := repository.NewUserRepository(tx)
+
+	existing, err := repo.Create(ctx, testhelper.NewUser())
+	require.NoError(t, err)
+
+	testCases := map[string]struct {
+		write func(u *domain.User) error
+		have  *domain.User
+		want  int
+	}{
+		"create": {func(u *domain.User) error {
+			_, err := repo.Create(ctx, u)
+			return err
+		}, testhelper.NewUser(), 1},
+		"upsert": {func(u *domain.User) error {
+			_, err := repo.CreateOrUpdate(ctx, u)
+			return err
+		}, testhelper.NewUser(), 1},
+		"batch": {func(u *domain.User) error {
+			_, err := repo.BatchCreateOrUpdate(ctx, []*domain.User{u}, domain.BatchModeAtomic)
+			return err
+		}, testhelper.NewUser(), 1},
+		// The job of the existing user was queued when it was created
+		"update": {func(u *domain.User) error {
+			_, err := repo.CreateOrUpdate(ctx, u)
+			return err
+		}, testhelper.NewUser(func(u *domain.User) { u.UUID = existing.UUID }), 1},
+		"without email": {func(u *domain.User) error {
+			_, err := repo.Create(ctx, u)
+			return err
+		}, testhelper.NewUser(func(u *domain.User) { u.Email = nil }), 0},
+	}
+
+	for scenario, tc := range testCases { //nolint:paralleltest
+		t.Run(scenario, func(t *testing.T) {
+			require.NoError(t, tc.write(tc.have))
+
+			var n int
+			err := tx.QueryRow(ctx,
+				"SELECT count(*) FROM jobs WHERE type = $1 AND payload = jsonb_build_object('user_id', $2::uuid, 'tenant_id', $3::text)",
+				domain.JobTypeWelcomeEmail, tc.have.UUID, testTenant,
+			).Scan(&n)
+			require.NoError(t, err)
+			assert.Equal(t, tc.want, n)
 		})
 	}
 }
 
 func TestPostgresUser_GetByID(t *testing.T) {
 	t.Parallel()
-	ctx := context.Background()
-	repo := NewTestPostgresUser(t)
+	ctx := tenantContext()
+	repo := NewTestPostgresUser(t, "testdata/fixtures/users.yaml")
 
 	parsedUserId, err := uuid.Parse(user_get_uuid)
 	if err != nil {
-		t.Error("error parsing uuid from env", err)
+		t.Error("error parsing uuid", err)
 	}
 
-	user := &domain.User{UUID: parsedUserId, FirstName: "john", LastName: "wick"}
-	// require.NoError(t, repo.CreateOrUpdate(ctx, user))
+	user := &domain.User{UUID: parsedUserId, TenantID: testTenant, FirstName: "john", LastName: "wick"}
 
 	testCases := map[string]struct {
 		id   uuid.UUID
@@ -101,8 +176,241 @@ func TestPostgresUser_GetByID(t *testing.T) {
 
 			assert.NoError(t, err)
 			assert.Equal(t, tc.want.UUID, dev.UUID)
+			assert.Equal(t, tc.want.TenantID, dev.TenantID)
 			assert.Equal(t, tc.want.FirstName, dev.FirstName)
 			assert.Equal(t, tc.want.LastName, dev.LastName)
 		})
 	}
 }
+
+func TestPostgresUser_BatchCreateOrUpdate(t *testing.T) {
+	t.Parallel()
+	ctx := tenantContext()
+	repo := NewTestPostgresUser(t)
+
+	email := "jwick@mail.com"
+	newBatch := func() []*domain.User {
+		return []*domain.User{
+			{FirstName: "John", LastName: "Wick", Email: &email},
+			{FirstName: "Winston"},
+			{FirstName: "Helen", LastName: "Wick", Email: &email},
+		}
+	}
+
+	testCases := map[string]struct {
+		mode domain.BatchMode
+		want []domain.BatchStatus
+		err  error
+	}{
+		"atomic":  {domain.BatchModeAtomic, []domain.BatchStatus{domain.BatchStatusSkipped, domain.BatchStatusInvalid, domain.BatchStatusSkipped}, domain.ErrBatchRejected},
+		"partial": {domain.BatchModePartial, []domain.BatchStatus{domain.BatchStatusOK, domain.BatchStatusInvalid, domain.BatchStatusOK}, nil},
+	}
+
+	for scenario, tc := range testCases { //nolint:paralleltest
+		t.Run(scenario, func(t *testing.T) {
+			uu := newBatch()
+			results, err := repo.BatchCreateOrUpdate(ctx, uu, tc.mode)
+			assert.Equal(t, tc.err, err)
+			require.Len(t, results, len(tc.want))
+
+			for i, res := range results {
+				assert.Equal(t, i, res.Index)
+				assert.Equal(t, tc.want[i], res.Status)
+				assert.Equal(t, uu[i].UUID, res.UUID)
+				assert.NotEqual(t, uuid.Nil, res.UUID)
+			}
+		})
+	}
+}
+
+// failingConn hands out transactions whose statements all fail with err
+type failingConn struct {
+	fakeConn
+	err error
+}
+
+func (c *failingConn) Begin(context.Context) (pgx.Tx, error) {
+	return &failingTx{err: c.err}, nil
+}
+
+type failingTx struct {
+	pgx.Tx
+	err error
+}
+
+func (tx *failingTx) Begin(context.Context) (pgx.Tx, error)                    { return tx, nil }
+func (tx *failingTx) QueryRow(context.Context, string, ...interface{}) pgx.Row { return errRow{tx.err} }
+func (tx *failingTx) SendBatch(context.Context, *pgx.Batch) pgx.BatchResults {
+	return failingBatch{err: tx.err}
+}
+func (tx *failingTx) Commit(context.Context) error   { return nil }
+func (tx *failingTx) Rollback(context.Context) error { return nil }
+
+type failingBatch struct {
+	pgx.BatchResults
+	err error
+}
+
+func (b failingBatch) QueryRow() pgx.Row { return errRow{b.err} }
+func (b failingBatch) Close() error      { return nil }
+
+type errRow struct{ err error }
+
+func (r errRow) Scan(...interface{}) error { return r.err }
+
+func TestPostgresUser_BatchFailure(t *testing.T) {
+	t.Parallel()
+
+	email := "jwick@mail.com"
+	testCases := map[string]struct {
+		err      error
+		rejected bool
+	}{
+		"conflict":   {&pgconn.PgError{Code: "23505"}, true},
+		"bad value":  {&pgconn.PgError{Code: "22001"}, true},
+		"canceled":   {&pgconn.PgError{Code: "57014"}, false},
+		"connection": {errors.New("conn closed"), false},
+	}
+
+	for scenario, tc := range testCases {
+		for _, mode := range []domain.BatchMode{domain.BatchModeAtomic, domain.BatchModePartial} {
+			t.Run(scenario+"/"+string(mode), func(t *testing.T) {
+				t.Parallel()
+				repo := repository.NewUserRepository(&failingConn{err: tc.err})
+				uu := []*domain.User{{FirstName: "John", LastName: "Wick", Email: &email}}
+
+				// Failures of the database fail the batch, not the user
+				results, err := repo.BatchCreateOrUpdate(tenantContext(), uu, mode)
+				switch {
+				case !tc.rejected:
+					assert.ErrorIs(t, err, tc.err)
+				case mode == domain.BatchModeAtomic:
+					assert.ErrorIs(t, err, domain.ErrBatchRejected)
+					require.Len(t, results, 1)
+					assert.Equal(t, domain.BatchStatusFailed, results[0].Status)
+				default:
+					require.NoError(t, err)
+					require.Len(t, results, 1)
+					assert.Equal(t, domain.BatchStatusFailed, results[0].Status)
+				}
+			})
+		}
+	}
+}
+
+func TestPostgresUser_Stream(t *testing.T) {
+	t.Parallel()
+	ctx := tenantContext()
+	repo := NewTestPostgresUser(t)
+
+	email := "stream@mail.com"
+	for _, name := range []string{"Ada", "Grace", "Ada"} {
+		_, err := repo.CreateOrUpdate(ctx, &domain.User{UUID: uuid.New(), FirstName: name, LastName: "Stream", Email: &email})
+		require.NoError(t, err)
+	}
+
+	testCases := map[string]struct {
+		filter domain.UserFilter
+		want   int
+	}{
+		"first name": {domain.UserFilter{FirstName: "Ada", LastName: "stream"}, 2},
+		"no match":   {domain.UserFilter{FirstName: "Linus", LastName: "stream"}, 0},
+	}
+
+	for scenario, tc := range testCases { //nolint:paralleltest
+		t.Run(scenario, func(t *testing.T) {
+			count := 0
+			err := repo.Stream(ctx, tc.filter, func(u *domain.User) error {
+				assert.Equal(t, "stream", u.LastName)
+				count++
+				return nil
+			})
+			require.NoError(t, err)
+			assert.Equal(t, tc.want, count)
+		})
+	}
+}
+
+func TestPostgresUser_GetList(t *testing.T) {
+	t.Parallel()
+	ctx := tenantContext()
+	repo := NewTestPostgresUser(t)
+
+	email := "list@mail.com"
+	for _, name := range []string{"Ada", "Grace", "Ada"} {
+		_, err := repo.CreateOrUpdate(ctx, &domain.User{UUID: uuid.New(), FirstName: name, LastName: "Listed", Email: &email})
+		require.NoError(t, err)
+	}
+
+	pq := &utils.PaginationQuery{Page: 1, Size: 10}
+	pq.SetOrderBy("first_name", "asc")
+
+	list, err := repo.GetList(ctx, pq, domain.UserFilter{FirstName: "ada", LastName: "Listed"})
+	require.NoError(t, err)
+	assert.Equal(t, 2, list.TotalCount)
+	assert.Len(t, list.Values, 2)
+
+	list, err = repo.GetList(ctx, pq, domain.UserFilter{FirstName: "linus", LastName: "Listed"})
+	require.NoError(t, err)
+	assert.Equal(t, 0, list.TotalCount)
+	assert.Empty(t, list.Values)
+}
+
+// BenchmarkPostgresUser_ExecModes compares the query exec modes on the user queries:
+//
+//	go test ./internal/repository -run '^$' -bench ExecModes
+func BenchmarkPostgresUser_ExecModes(b *testing.B) {
+	ctx := tenantContext()
+	modes := []pgx.QueryExecMode{
+		pgx.QueryExecModeSimpleProtocol,
+		pgx.QueryExecModeCacheStatement,
+		pgx.QueryExecModeCacheDescribe,
+		pgx.QueryExecModeDescribeExec,
+		pgx.QueryExecModeExec,
+	}
+
+	for _, mode := range modes {
+		b.Run(strings.ReplaceAll(mode.String(), " ", "_"), func(b *testing.B) {
+			pool := testhelper.NewTestPool(b, func(config *pgxpool.Config) {
+				config.ConnConfig.DefaultQueryExecMode = mode
+			})
+			repo := repository.NewUserRepository(pool)
+
+			uu := make([]*domain.User, 100)
+			for i := range uu {
+				email := fmt.Sprintf("bench%d@mail.com", i)
+				uu[i] = &domain.User{UUID: uuid.New(), FirstName: "Bench", LastName: "Mark", Email: &email}
+			}
+			_, err := repo.BatchCreateOrUpdate(ctx, uu, domain.BatchModeAtomic)
+			require.NoError(b, err)
+
+			b.Run("GetByID", func(b *testing.B) {
+				for i := 0; i < b.N; i++ {
+					if _, err := repo.GetByID(ctx, uu[i%len(uu)].UUID); err != nil {
+						b.Fatal(err)
+					}
+				}
+			})
+
+			b.Run("GetList", func(b *testing.B) {
+				pq := &utils.PaginationQuery{Page: 1, Size: 20}
+				pq.SetOrderBy("email", "asc")
+				for i := 0; i < b.N; i++ {
+					if _, err := repo.GetList(ctx, pq, domain.UserFilter{LastName: "mark"}); err != nil {
+						b.Fatal(err)
+					}
+				}
+			})
+
+			b.Run("CreateOrUpdate", func(b *testing.B) {
+				for i := 0; i < b.N; i++ {
+					u := *uu[i%len(uu)]
+					u.FirstName = fmt.Sprintf("Bench%d", i)
+					if _, err := repo.CreateOrUpdate(ctx, &u); err != nil {
+						b.Fatal(err)
+					}
+				}
+			})
+		})
+	}
+}
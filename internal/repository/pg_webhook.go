@@ -0,0 +1,414 @@
+package repository
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"go-project-template/internal/domain"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+const (
+	webhookColumns  = `id, tenant_id, url, secret, event_types, active, consecutive_failures, disabled_at, created_at, updated_at`
+	deliveryColumns = `id, webhook_id, event_id, event_type, payload, status, attempts, next_attempt_at, response_code, last_error, duration_ms, created_at, delivered_at`
+)
+
+type postgresWebhookRepository struct {
+	conn         Connection
+	allowPrivate bool
+}
+
+// WebhookRepositoryOption configures the repository returned by [NewWebhookRepository]
+type WebhookRepositoryOption func(*postgresWebhookRepository)
+
+// WithPrivateWebhookURLs accepts webhook URLs of loopback and private hosts,
+// which are refused by default
+func WithPrivateWebhookURLs() WebhookRepositoryOption {
+	return func(p *postgresWebhookRepository) {
+		p.allowPrivate = true
+	}
+}
+
+// NewWebhookRepository returns a new [WebhookRepository].
+func NewWebhookRepository(conn Connection, opts ...WebhookRepositoryOption) domain.WebhookRepository {
+	p := &postgresWebhookRepository{conn: conn}
+	for _, opt := range opts {
+		opt(p)
+	}
+	return p
+}
+
+func (p *postgresWebhookRepository) validate(w *domain.Webhook) error {
+	if p.allowPrivate {
+		return w.ValidateAllowPrivate()
+	}
+	return w.Validate()
+}
+
+func scanWebhook(row pgx.Row) (*domain.Webhook, error) {
+	w := &domain.Webhook{}
+	if err := row.Scan(
+		&w.ID,
+		&w.TenantID,
+		&w.URL,
+		&w.Secret,
+		&w.EventTypes,
+		&w.Active,
+		&w.ConsecutiveFailures,
+		&w.DisabledAt,
+		&w.CreatedAt,
+		&w.UpdatedAt,
+	); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, domain.ErrNotFound
+		}
+		return nil, err
+	}
+	return w, nil
+}
+
+func scanDelivery(row pgx.Row) (*domain.WebhookDelivery, error) {
+	d := &domain.WebhookDelivery{}
+	if err := scanDeliveryInto(row, d); err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+// scanClaimedDelivery scans a delivery followed by the tenant, URL and secret of its webhook
+func scanClaimedDelivery(row pgx.Row) (*domain.WebhookDelivery, error) {
+	d := &domain.WebhookDelivery{Webhook: &domain.Webhook{Active: true}}
+	if err := scanDeliveryInto(row, d, &d.Webhook.TenantID, &d.Webhook.URL, &d.Webhook.Secret); err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+func scanDeliveryInto(row pgx.Row, d *domain.WebhookDelivery, extra ...interface{}) error {
+	dest := []interface{}{
+		&d.ID,
+		&d.WebhookID,
+		&d.EventID,
+		&d.EventType,
+		&d.Payload,
+		&d.Status,
+		&d.Attempts,
+		&d.NextAttemptAt,
+		&d.ResponseCode,
+		&d.LastError,
+		&d.DurationMS,
+		&d.CreatedAt,
+		&d.DeliveredAt,
+	}
+	if err := row.Scan(append(dest, extra...)...); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return domain.ErrNotFound
+		}
+		return err
+	}
+	if d.Webhook != nil {
+		d.Webhook.ID = d.WebhookID
+	}
+	return nil
+}
+
+func (p *postgresWebhookRepository) fetchDeliveries(ctx context.Context, scan func(pgx.Row) (*domain.WebhookDelivery, error), query string, args ...interface{}) ([]domain.WebhookDelivery, error) {
+	rows, err := p.conn.Query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var dd []domain.WebhookDelivery
+	for rows.Next() {
+		d, err := scan(rows)
+		if err != nil {
+			return nil, err
+		}
+		dd = append(dd, *d)
+	}
+	return dd, rows.Err()
+}
+
+// newWebhookSecret returns a random signing secret
+func newWebhookSecret() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return "whsec_" + hex.EncodeToString(b), nil
+}
+
+// webhookEventTypes avoids writing NULL for a webhook subscribed to every event
+func webhookEventTypes(w *domain.Webhook) []string {
+	if w.EventTypes == nil {
+		return []string{}
+	}
+	return w.EventTypes
+}
+
+func (p *postgresWebhookRepository) Create(ctx context.Context, w *domain.Webhook) (*domain.Webhook, error) {
+	tenant, err := domain.TenantFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := p.validate(w); err != nil {
+		return nil, err
+	}
+
+	if w.ID == uuid.Nil {
+		w.ID = uuid.New()
+	}
+	if w.Secret == "" {
+		secret, err := newWebhookSecret()
+		if err != nil {
+			return nil, err
+		}
+		w.Secret = secret
+	}
+
+	query := `
+		INSERT INTO webhooks (id, tenant_id, url, secret, event_types)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING ` + webhookColumns
+
+	return scanWebhook(p.conn.QueryRow(ctx, query, w.ID, tenant, w.URL, w.Secret, webhookEventTypes(w)))
+}
+
+func (p *postgresWebhookRepository) GetByID(ctx context.Context, id uuid.UUID) (*domain.Webhook, error) {
+	tenant, err := domain.TenantFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	query := `SELECT ` + webhookColumns + ` FROM webhooks WHERE id = $1 AND tenant_id = $2`
+	return scanWebhook(p.conn.QueryRow(ctx, query, id, tenant))
+}
+
+func (p *postgresWebhookRepository) List(ctx context.Context) ([]domain.Webhook, error) {
+	tenant, err := domain.TenantFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	query := `SELECT ` + webhookColumns + ` FROM webhooks WHERE tenant_id = $1 ORDER BY created_at`
+
+	rows, err := p.conn.Query(ctx, query, tenant)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ww := []domain.Webhook{}
+	for rows.Next() {
+		w, err := scanWebhook(rows)
+		if err != nil {
+			return nil, err
+		}
+		ww = append(ww, *w)
+	}
+	return ww, rows.Err()
+}
+
+func (p *postgresWebhookRepository) Update(ctx context.Context, w *domain.Webhook) (*domain.Webhook, error) {
+	tenant, err := domain.TenantFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := p.validate(w); err != nil {
+		return nil, err
+	}
+
+	// Column references on the right hand side see the values before the update
+	query := `
+		UPDATE webhooks
+		SET url = $2,
+			event_types = $3,
+			active = $4::boolean,
+			consecutive_failures = CASE WHEN $4::boolean AND NOT active THEN 0 ELSE consecutive_failures END,
+			disabled_at = CASE WHEN $4::boolean THEN NULL ELSE COALESCE(disabled_at, now()) END,
+			updated_at = now()
+		WHERE id = $1 AND tenant_id = $5
+		RETURNING ` + webhookColumns
+
+	return scanWebhook(p.conn.QueryRow(ctx, query, w.ID, w.URL, webhookEventTypes(w), w.Active, tenant))
+}
+
+func (p *postgresWebhookRepository) Delete(ctx context.Context, id uuid.UUID) error {
+	tenant, err := domain.TenantFromContext(ctx)
+	if err != nil {
+		return err
+	}
+
+	tag, err := p.conn.Exec(ctx, `DELETE FROM webhooks WHERE id = $1 AND tenant_id = $2`, id, tenant)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return domain.ErrNotFound
+	}
+	return nil
+}
+
+func (p *postgresWebhookRepository) Disable(ctx context.Context, id uuid.UUID) error {
+	query := `UPDATE webhooks SET active = false, disabled_at = now(), updated_at = now() WHERE id = $1 AND active`
+	_, err := p.conn.Exec(ctx, query, id)
+	return err
+}
+
+func (p *postgresWebhookRepository) FanOut(ctx context.Context, limit int) (int, error) {
+	// Locking the events lets several API replicas fan out concurrently without
+	// creating a delivery twice. The delivery payload is the event envelope, and
+	// events only reach the webhooks of their own tenant.
+	query := `
+		WITH events AS (
+			SELECT id, aggregate_type, aggregate_id, tenant_id, event_type, payload, occurred_at
+			FROM outbox
+			WHERE fanned_out_at IS NULL
+			ORDER BY id
+			LIMIT $1
+			FOR UPDATE SKIP LOCKED
+		), marked AS (
+			UPDATE outbox SET fanned_out_at = now()
+			FROM events
+			WHERE outbox.id = events.id
+			RETURNING outbox.id
+		), deliveries AS (
+			INSERT INTO webhook_deliveries (webhook_id, event_id, event_type, payload)
+			SELECT w.id, e.id, e.event_type, jsonb_build_object(
+				'id', e.id,
+				'aggregate_type', e.aggregate_type,
+				'aggregate_id', e.aggregate_id,
+				'type', e.event_type,
+				'payload', e.payload,
+				'occurred_at', e.occurred_at
+			)
+			FROM events e
+			JOIN webhooks w ON w.tenant_id = e.tenant_id AND w.active AND (cardinality(w.event_types) = 0 OR e.event_type = ANY(w.event_types))
+		)
+		SELECT count(*) FROM marked`
+
+	var n int
+	err := p.conn.QueryRow(ctx, query, limit).Scan(&n)
+	return n, err
+}
+
+func (p *postgresWebhookRepository) ClaimDeliveries(ctx context.Context, limit int, lease time.Duration) ([]domain.WebhookDelivery, error) {
+	// Pushing next_attempt_at past the lease hides the delivery from other
+	// dispatchers, and brings it back if this one dies mid-attempt. The webhook
+	// is returned along, the dispatcher has no tenant to look it up with.
+	query := `
+		UPDATE webhook_deliveries d
+		SET attempts = d.attempts + 1,
+			next_attempt_at = now() + $2 * interval '1 millisecond'
+		FROM webhooks w
+		WHERE w.id = d.webhook_id AND d.id IN (
+			SELECT due.id
+			FROM webhook_deliveries due
+			JOIN webhooks hook ON hook.id = due.webhook_id
+			WHERE due.status = 'pending' AND due.next_attempt_at <= now() AND hook.active
+			ORDER BY due.next_attempt_at
+			LIMIT $1
+			FOR UPDATE OF due SKIP LOCKED
+		)
+		RETURNING d.` + strings.ReplaceAll(deliveryColumns, ", ", ", d.") + `, w.tenant_id, w.url, w.secret`
+
+	return p.fetchDeliveries(ctx, scanClaimedDelivery, query, limit, lease.Milliseconds())
+}
+
+func (p *postgresWebhookRepository) CompleteDelivery(ctx context.Context, d *domain.WebhookDelivery, a domain.DeliveryAttempt) error {
+	// The attempt count fences off dispatchers whose lease expired
+	query := `
+		WITH delivered AS (
+			UPDATE webhook_deliveries
+			SET status = 'succeeded', response_code = $3, duration_ms = $4, last_error = NULL, delivered_at = now()
+			WHERE id = $1 AND attempts = $2 AND status = 'pending'
+			RETURNING webhook_id
+		)
+		UPDATE webhooks
+		SET consecutive_failures = 0
+		FROM delivered
+		WHERE webhooks.id = delivered.webhook_id`
+
+	tag, err := p.conn.Exec(ctx, query, d.ID, d.Attempts, a.ResponseCode, a.Duration.Milliseconds())
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return domain.ErrNotFound
+	}
+	return nil
+}
+
+func (p *postgresWebhookRepository) FailDelivery(ctx context.Context, d *domain.WebhookDelivery, a domain.DeliveryAttempt, retryAt *time.Time) (int, error) {
+	query := `
+		WITH failed AS (
+			UPDATE webhook_deliveries
+			SET status = CASE WHEN $5::timestamptz IS NULL THEN 'failed' ELSE 'pending' END,
+				next_attempt_at = COALESCE($5::timestamptz, next_attempt_at),
+				response_code = $3,
+				last_error = $4,
+				duration_ms = $6
+			WHERE id = $1 AND attempts = $2 AND status = 'pending'
+			RETURNING webhook_id
+		)
+		UPDATE webhooks
+		SET consecutive_failures = consecutive_failures + 1, updated_at = now()
+		FROM failed
+		WHERE webhooks.id = failed.webhook_id
+		RETURNING webhooks.consecutive_failures`
+
+	var cause *string
+	if a.Err != nil {
+		msg := a.Err.Error()
+		cause = &msg
+	}
+
+	var failures int
+	err := p.conn.QueryRow(ctx, query, d.ID, d.Attempts, a.ResponseCode, cause, retryAt, a.Duration.Milliseconds()).Scan(&failures)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return 0, domain.ErrNotFound
+	}
+	return failures, err
+}
+
+func (p *postgresWebhookRepository) Deliveries(ctx context.Context, webhookID uuid.UUID, limit int) ([]domain.WebhookDelivery, error) {
+	tenant, err := domain.TenantFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	query := `
+		SELECT ` + deliveryColumns + `
+		FROM webhook_deliveries
+		WHERE webhook_id = $1 AND webhook_id IN (SELECT id FROM webhooks WHERE tenant_id = $3)
+		ORDER BY created_at DESC
+		LIMIT $2`
+
+	dd, err := p.fetchDeliveries(ctx, scanDelivery, query, webhookID, limit, tenant)
+	if dd == nil && err == nil {
+		dd = []domain.WebhookDelivery{}
+	}
+	return dd, err
+}
+
+func (p *postgresWebhookRepository) Redeliver(ctx context.Context, webhookID uuid.UUID, deliveryID uuid.UUID) (*domain.WebhookDelivery, error) {
+	tenant, err := domain.TenantFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	query := `
+		INSERT INTO webhook_deliveries (webhook_id, event_id, event_type, payload)
+		SELECT webhook_id, event_id, event_type, payload
+		FROM webhook_deliveries
+		WHERE id = $2 AND webhook_id = $1 AND webhook_id IN (SELECT id FROM webhooks WHERE tenant_id = $3)
+		RETURNING ` + deliveryColumns
+
+	return scanDelivery(p.conn.QueryRow(ctx, query, webhookID, deliveryID, tenant))
+}
@@ -0,0 +1,177 @@
+package repository_test
+
+import (
+	"context"
+	"errors"
+	"go-project-template/internal/domain"
+	"go-project-template/internal/repository"
+	"go-project-template/internal/testhelper"
+	"go-project-template/internal/webhook"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestPostgresWebhook_Deliveries(t *testing.T) {
+	t.Parallel()
+	ctx := domain.WithTenant(context.Background(), "webhooks")
+	conn := testhelper.NewTestPgxConn(t)
+
+	tx, err := conn.Begin(ctx)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = tx.Rollback(ctx) })
+
+	repo := repository.NewWebhookRepository(tx)
+	users := repository.NewUserRepository(tx)
+
+	// Fan out everything recorded before this test so only its own event is left
+	for {
+		n, err := repo.FanOut(ctx, 1000)
+		require.NoError(t, err)
+		if n == 0 {
+			break
+		}
+	}
+
+	deletes, err := repo.Create(ctx, &domain.Webhook{URL: "https://example.com/deletes", EventTypes: []string{domain.EventUserDeleted}})
+	require.NoError(t, err)
+	all, err := repo.Create(ctx, &domain.Webhook{URL: "https://example.com/all"})
+	require.NoError(t, err)
+	assert.NotEmpty(t, all.Secret)
+	assert.Equal(t, "webhooks", all.TenantID)
+
+	// The webhook of another tenant neither sees nor receives the events of this one
+	otherCtx := domain.WithTenant(context.Background(), "other-webhooks")
+	other, err := repo.Create(otherCtx, &domain.Webhook{URL: "https://example.com/other"})
+	require.NoError(t, err)
+	_, err = repo.GetByID(otherCtx, all.ID)
+	assert.Equal(t, domain.ErrNotFound, err)
+
+	email := "webhook@mail.com"
+	_, err = users.CreateOrUpdate(ctx, &domain.User{UUID: uuid.New(), FirstName: "Ada", LastName: "Hook", Email: &email})
+	require.NoError(t, err)
+
+	n, err := repo.FanOut(ctx, 1000)
+	require.NoError(t, err)
+	assert.Equal(t, 1, n)
+
+	// Only the webhook subscribed to every event gets the create
+	dd, err := repo.Deliveries(ctx, deletes.ID, 10)
+	require.NoError(t, err)
+	assert.Empty(t, dd)
+	dd, err = repo.Deliveries(otherCtx, other.ID, 10)
+	require.NoError(t, err)
+	assert.Empty(t, dd)
+
+	claimed, err := repo.ClaimDeliveries(ctx, 1000, time.Minute)
+	require.NoError(t, err)
+	var d *domain.WebhookDelivery
+	for i := range claimed {
+		if claimed[i].WebhookID == all.ID {
+			d = &claimed[i]
+		}
+	}
+	require.NotNil(t, d)
+	assert.Equal(t, domain.EventUserCreated, d.EventType)
+	assert.Equal(t, 1, d.Attempts)
+	require.NotNil(t, d.Webhook)
+	assert.Equal(t, "webhooks", d.Webhook.TenantID)
+	assert.Equal(t, all.URL, d.Webhook.URL)
+	assert.Equal(t, all.Secret, d.Webhook.Secret)
+
+	code := http.StatusBadGateway
+	retryAt := time.Now().Add(time.Hour)
+	failures, err := repo.FailDelivery(ctx, d, domain.DeliveryAttempt{ResponseCode: &code, Err: errors.New("bad gateway")}, &retryAt)
+	require.NoError(t, err)
+	assert.Equal(t, 1, failures)
+
+	// A stale attempt can no longer record an outcome
+	stale := *d
+	stale.Attempts = 0
+	assert.Equal(t, domain.ErrNotFound, repo.CompleteDelivery(ctx, &stale, domain.DeliveryAttempt{}))
+
+	redelivery, err := repo.Redeliver(ctx, all.ID, d.ID)
+	require.NoError(t, err)
+	assert.Equal(t, domain.DeliveryStatusPending, redelivery.Status)
+	assert.JSONEq(t, string(d.Payload), string(redelivery.Payload))
+
+	dd, err = repo.Deliveries(ctx, all.ID, 10)
+	require.NoError(t, err)
+	require.Len(t, dd, 2)
+	require.NotNil(t, dd[1].ResponseCode)
+	assert.Equal(t, code, *dd[1].ResponseCode)
+
+	require.NoError(t, repo.Disable(ctx, all.ID))
+	all.Active = true
+	all, err = repo.Update(ctx, all)
+	require.NoError(t, err)
+	assert.True(t, all.Active)
+	assert.Equal(t, 0, all.ConsecutiveFailures)
+}
+
+func TestPostgresWebhook_Dispatch(t *testing.T) {
+	t.Parallel()
+	ctx := domain.WithTenant(context.Background(), "webhook-dispatch")
+	conn := testhelper.NewTestPgxConn(t)
+
+	tx, err := conn.Begin(ctx)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = tx.Rollback(ctx) })
+
+	repo := repository.NewWebhookRepository(tx, repository.WithPrivateWebhookURLs())
+	users := repository.NewUserRepository(tx)
+
+	// Fan out and claim everything recorded before this test so only its own delivery is due
+	for {
+		n, err := repo.FanOut(ctx, 1000)
+		require.NoError(t, err)
+		if n == 0 {
+			break
+		}
+	}
+	_, err = repo.ClaimDeliveries(ctx, 1000, time.Hour)
+	require.NoError(t, err)
+
+	received := make(chan string, 1)
+	var hook *domain.Webhook
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		assert.NoError(t, err)
+
+		ts, err := strconv.ParseInt(r.Header.Get(webhook.TimestampHeader), 10, 64)
+		assert.NoError(t, err)
+		assert.Equal(t, webhook.Sign(hook.Secret, time.Unix(ts, 0), body), r.Header.Get(webhook.SignatureHeader))
+		received <- r.Header.Get(webhook.EventHeader)
+	}))
+	defer srv.Close()
+
+	hook, err = repo.Create(ctx, &domain.Webhook{URL: srv.URL, EventTypes: []string{domain.EventUserCreated}})
+	require.NoError(t, err)
+
+	email := "dispatch@mail.com"
+	_, err = users.CreateOrUpdate(ctx, &domain.User{UUID: uuid.New(), FirstName: "Ada", LastName: "Dispatch", Email: &email})
+	require.NoError(t, err)
+	_, err = repo.FanOut(ctx, 1000)
+	require.NoError(t, err)
+
+	// The dispatcher runs without a tenant, as it does in the worker
+	cfg := webhook.DefaultConfig()
+	cfg.BatchSize = 1
+	sent, err := webhook.NewDispatcher(repo, srv.Client(), zap.NewNop(), cfg).DispatchOnce(context.Background())
+	require.NoError(t, err)
+	assert.True(t, sent)
+	assert.Equal(t, domain.EventUserCreated, <-received)
+
+	dd, err := repo.Deliveries(ctx, hook.ID, 10)
+	require.NoError(t, err)
+	require.Len(t, dd, 1)
+	assert.Equal(t, domain.DeliveryStatusSucceeded, dd[0].Status)
+}
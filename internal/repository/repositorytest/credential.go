@@ -0,0 +1,176 @@
+package repositorytest
+
+import (
+	"context"
+	"go-project-template/internal/domain"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// credentialRepository runs the conformance suite against the repositories
+// returned by newRepos, which is called once per subtest. The credential
+// repository has to find its users in the returned user repository.
+func credentialRepository(t *testing.T, newRepos func(t *testing.T) (domain.UserRepository, domain.CredentialRepository)) {
+	t.Helper()
+
+	t.Run("SetPassword", func(t *testing.T) { testSetPassword(t, newCredentialFixture(t, newRepos)) })
+	t.Run("FindByEmail", func(t *testing.T) { testFindByEmail(t, newCredentialFixture(t, newRepos)) })
+	t.Run("Attempts", func(t *testing.T) { testAttempts(t, newCredentialFixture(t, newRepos)) })
+	t.Run("Tenancy", func(t *testing.T) { testCredentialTenancy(t, newCredentialFixture(t, newRepos)) })
+}
+
+// credentialFixture is a tenant of its own with the repositories of a subtest
+type credentialFixture struct {
+	t           *testing.T
+	ctx         context.Context
+	users       domain.UserRepository
+	credentials domain.CredentialRepository
+}
+
+func newCredentialFixture(t *testing.T, newRepos func(t *testing.T) (domain.UserRepository, domain.CredentialRepository)) *credentialFixture {
+	t.Helper()
+	users, credentials := newRepos(t)
+	return &credentialFixture{t: t, ctx: tenantContext(), users: users, credentials: credentials}
+}
+
+// user stores a user of the fixture tenant with the email firstName@mail.com
+func (f *credentialFixture) user(firstName string) *domain.User {
+	f.t.Helper()
+	u := newUser(firstName, uniqueLastName())
+	_, err := f.users.CreateOrUpdate(f.ctx, u)
+	require.NoError(f.t, err)
+	return u
+}
+
+func testSetPassword(t *testing.T, f *credentialFixture) {
+	t.Helper()
+	u := f.user("John")
+
+	_, err := f.credentials.Get(f.ctx, u.UUID)
+	assert.ErrorIs(t, err, domain.ErrNotFound)
+
+	require.NoError(t, f.credentials.SetPassword(f.ctx, u.UUID, "hash-1"))
+	c, err := f.credentials.Get(f.ctx, u.UUID)
+	require.NoError(t, err)
+	assert.Equal(t, u.UUID, c.UserUUID)
+	assert.Equal(t, u.TenantID, c.TenantID)
+	assert.Equal(t, "hash-1", c.Hash)
+	assert.False(t, c.PasswordChangedAt.IsZero())
+	assert.Nil(t, c.LastLoginAt)
+
+	// A new password replaces the old one and unlocks the credential
+	require.NoError(t, f.credentials.RecordFailure(f.ctx, u.UUID, 1, time.Now().Add(time.Hour)))
+	require.NoError(t, f.credentials.SetPassword(f.ctx, u.UUID, "hash-2"))
+	c, err = f.credentials.Get(f.ctx, u.UUID)
+	require.NoError(t, err)
+	assert.Equal(t, "hash-2", c.Hash)
+	assert.Nil(t, c.LockedUntil)
+
+	assert.ErrorIs(t, f.credentials.SetPassword(f.ctx, uuid.New(), "hash"), domain.ErrNotFound)
+
+	// Deleting the password or the user removes the credential
+	require.NoError(t, f.credentials.Delete(f.ctx, u.UUID))
+	_, err = f.credentials.Get(f.ctx, u.UUID)
+	assert.ErrorIs(t, err, domain.ErrNotFound)
+	require.NoError(t, f.credentials.Delete(f.ctx, u.UUID))
+
+	require.NoError(t, f.credentials.SetPassword(f.ctx, u.UUID, "hash-3"))
+	require.NoError(t, f.users.Delete(f.ctx, u.UUID))
+	_, err = f.credentials.Get(f.ctx, u.UUID)
+	assert.ErrorIs(t, err, domain.ErrNotFound)
+}
+
+func testFindByEmail(t *testing.T, f *credentialFixture) {
+	t.Helper()
+	john, helen := f.user("John"), f.user("Helen")
+	require.NoError(t, f.credentials.SetPassword(f.ctx, john.UUID, "hash-john"))
+
+	c, err := f.credentials.FindByEmail(f.ctx, "John@mail.com")
+	require.NoError(t, err)
+	assert.Equal(t, john.UUID, c.UserUUID)
+	assert.Equal(t, "hash-john", c.Hash)
+
+	// Users without a password are not found
+	_, err = f.credentials.FindByEmail(f.ctx, *helen.Email)
+	assert.ErrorIs(t, err, domain.ErrNotFound)
+	_, err = f.credentials.FindByEmail(f.ctx, "nobody@mail.com")
+	assert.ErrorIs(t, err, domain.ErrNotFound)
+
+	// A second user with the email cannot get a password
+	twin := f.user("John")
+	assert.ErrorIs(t, f.credentials.SetPassword(f.ctx, twin.UUID, "hash-twin"), domain.ErrConflict)
+	require.NoError(t, f.credentials.SetPassword(f.ctx, john.UUID, "hash-john-2"))
+}
+
+func testAttempts(t *testing.T, f *credentialFixture) {
+	t.Helper()
+	u := f.user("John")
+	require.NoError(t, f.credentials.SetPassword(f.ctx, u.UUID, "hash"))
+	lockedUntil := time.Now().Add(15 * time.Minute).UTC().Truncate(time.Second)
+
+	require.NoError(t, f.credentials.RecordFailure(f.ctx, u.UUID, 3, lockedUntil))
+	require.NoError(t, f.credentials.RecordFailure(f.ctx, u.UUID, 3, lockedUntil))
+	c, err := f.credentials.Get(f.ctx, u.UUID)
+	require.NoError(t, err)
+	assert.Equal(t, 2, c.FailedAttempts)
+	assert.Nil(t, c.LockedUntil)
+
+	// The third failure locks the credential and restarts the count
+	require.NoError(t, f.credentials.RecordFailure(f.ctx, u.UUID, 3, lockedUntil))
+	c, err = f.credentials.Get(f.ctx, u.UUID)
+	require.NoError(t, err)
+	assert.Equal(t, 0, c.FailedAttempts)
+	require.NotNil(t, c.LockedUntil)
+	assert.True(t, lockedUntil.Equal(*c.LockedUntil), "locked until %s, want %s", c.LockedUntil, lockedUntil)
+	assert.True(t, c.Locked(time.Now()))
+
+	// A success clears the lock, an empty rehash keeps the hash
+	require.NoError(t, f.credentials.RecordFailure(f.ctx, u.UUID, 3, lockedUntil))
+	require.NoError(t, f.credentials.RecordSuccess(f.ctx, u.UUID, ""))
+	c, err = f.credentials.Get(f.ctx, u.UUID)
+	require.NoError(t, err)
+	assert.Equal(t, 0, c.FailedAttempts)
+	assert.Nil(t, c.LockedUntil)
+	assert.NotNil(t, c.LastLoginAt)
+	assert.Equal(t, "hash", c.Hash)
+
+	require.NoError(t, f.credentials.RecordSuccess(f.ctx, u.UUID, "rehash"))
+	c, err = f.credentials.Get(f.ctx, u.UUID)
+	require.NoError(t, err)
+	assert.Equal(t, "rehash", c.Hash)
+
+	assert.ErrorIs(t, f.credentials.RecordFailure(f.ctx, uuid.New(), 3, lockedUntil), domain.ErrNotFound)
+	assert.ErrorIs(t, f.credentials.RecordSuccess(f.ctx, uuid.New(), ""), domain.ErrNotFound)
+}
+
+func testCredentialTenancy(t *testing.T, f *credentialFixture) {
+	t.Helper()
+	other := tenantContext()
+	u := f.user("John")
+	require.NoError(t, f.credentials.SetPassword(f.ctx, u.UUID, "hash"))
+
+	// Nothing of the credential is visible to another tenant
+	_, err := f.credentials.Get(other, u.UUID)
+	assert.ErrorIs(t, err, domain.ErrNotFound)
+	_, err = f.credentials.FindByEmail(other, *u.Email)
+	assert.ErrorIs(t, err, domain.ErrNotFound)
+
+	// Nor can it change or delete it
+	assert.ErrorIs(t, f.credentials.SetPassword(other, u.UUID, "other"), domain.ErrNotFound)
+	assert.ErrorIs(t, f.credentials.RecordFailure(other, u.UUID, 1, time.Now().Add(time.Hour)), domain.ErrNotFound)
+	assert.ErrorIs(t, f.credentials.RecordSuccess(other, u.UUID, "other"), domain.ErrNotFound)
+	require.NoError(t, f.credentials.Delete(other, u.UUID))
+
+	c, err := f.credentials.Get(f.ctx, u.UUID)
+	require.NoError(t, err)
+	assert.Equal(t, "hash", c.Hash)
+	assert.Nil(t, c.LockedUntil)
+
+	// Every operation needs a tenant
+	_, err = f.credentials.Get(context.Background(), u.UUID)
+	assert.ErrorIs(t, err, domain.ErrTenantRequired)
+}
@@ -0,0 +1,283 @@
+package repositorytest
+
+import (
+	"context"
+	"go-project-template/internal/domain"
+	"go-project-template/internal/utils"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// groupRepository runs the conformance suite against the repositories returned
+// by newRepos, which is called once per subtest. The group repository has to
+// find its members in the returned user repository.
+func groupRepository(t *testing.T, newRepos func(t *testing.T) (domain.UserRepository, domain.GroupRepository)) {
+	t.Helper()
+
+	t.Run("CRUD", func(t *testing.T) { testGroupCRUD(t, newGroupFixture(t, newRepos)) })
+	t.Run("Members", func(t *testing.T) { testGroupMembers(t, newGroupFixture(t, newRepos)) })
+	t.Run("Nested", func(t *testing.T) { testGroupNested(t, newGroupFixture(t, newRepos)) })
+	t.Run("Cycles", func(t *testing.T) { testGroupCycles(t, newGroupFixture(t, newRepos)) })
+	t.Run("Tenancy", func(t *testing.T) { testGroupTenancy(t, newGroupFixture(t, newRepos)) })
+}
+
+// groupFixture is a tenant of its own with the repositories of a subtest
+type groupFixture struct {
+	t      *testing.T
+	ctx    context.Context
+	users  domain.UserRepository
+	groups domain.GroupRepository
+}
+
+func newGroupFixture(t *testing.T, newRepos func(t *testing.T) (domain.UserRepository, domain.GroupRepository)) *groupFixture {
+	t.Helper()
+	users, groups := newRepos(t)
+	return &groupFixture{t: t, ctx: tenantContext(), users: users, groups: groups}
+}
+
+// user stores a user of the fixture tenant
+func (f *groupFixture) user(firstName string) *domain.User {
+	f.t.Helper()
+	u := newUser(firstName, uniqueLastName())
+	_, err := f.users.CreateOrUpdate(f.ctx, u)
+	require.NoError(f.t, err)
+	return u
+}
+
+func (f *groupFixture) group(name string) *domain.Group {
+	f.t.Helper()
+	g, err := f.groups.Create(f.ctx, &domain.Group{Name: name})
+	require.NoError(f.t, err)
+	return g
+}
+
+// members returns the UUIDs of the members of the group in order
+func (f *groupFixture) members(groupID uuid.UUID, nested bool) []uuid.UUID {
+	f.t.Helper()
+	list, err := f.groups.Members(f.ctx, groupID, nested, &utils.PaginationQuery{Page: 1, Size: 100})
+	require.NoError(f.t, err)
+	require.Equal(f.t, len(list.Values), list.TotalCount)
+
+	ids := []uuid.UUID{}
+	for _, u := range list.Values {
+		ids = append(ids, u.UUID)
+	}
+	return ids
+}
+
+// userGroups returns the names of the groups of the user in order
+func (f *groupFixture) userGroups(userID uuid.UUID, nested bool) []string {
+	f.t.Helper()
+	list, err := f.groups.UserGroups(f.ctx, userID, nested, &utils.PaginationQuery{Page: 1, Size: 100})
+	require.NoError(f.t, err)
+	require.Equal(f.t, len(list.Values), list.TotalCount)
+
+	names := []string{}
+	for _, g := range list.Values {
+		names = append(names, g.Name)
+	}
+	return names
+}
+
+// sortedUUIDs returns the UUIDs of the users in the order members returns them
+func sortedUUIDs(uu ...*domain.User) []uuid.UUID {
+	ids := make([]uuid.UUID, len(uu))
+	for i, u := range uu {
+		ids[i] = u.UUID
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i].String() < ids[j].String() })
+	return ids
+}
+
+func testGroupCRUD(t *testing.T, f *groupFixture) {
+	t.Helper()
+	tenant, _ := domain.TenantFromContext(f.ctx)
+
+	g := f.group("Engineering")
+	assert.NotEqual(t, uuid.Nil, g.ID)
+	assert.Equal(t, tenant, g.TenantID)
+
+	_, err := f.groups.Create(f.ctx, &domain.Group{ID: g.ID, Name: "Sales"})
+	assert.ErrorIs(t, err, domain.ErrConflict)
+	_, err = f.groups.Create(f.ctx, &domain.Group{})
+	assert.Error(t, err)
+
+	time.Sleep(2 * time.Millisecond)
+	renamed, err := f.groups.Update(f.ctx, &domain.Group{ID: g.ID, Name: "Platform"})
+	require.NoError(t, err)
+	assert.Equal(t, "Platform", renamed.Name)
+	assert.True(t, renamed.UpdatedAt.After(g.UpdatedAt))
+
+	_, err = f.groups.Update(f.ctx, &domain.Group{ID: uuid.New(), Name: "Sales"})
+	assert.ErrorIs(t, err, domain.ErrNotFound)
+
+	f.group("Design")
+	list, err := f.groups.GetList(f.ctx, &utils.PaginationQuery{Page: 1, Size: 10, OrderBy: "name ASC"})
+	require.NoError(t, err)
+	require.Equal(t, 2, list.TotalCount)
+	assert.Equal(t, "Design", list.Values[0].Name)
+	assert.Equal(t, "Platform", list.Values[1].Name)
+
+	require.NoError(t, f.groups.Delete(f.ctx, g.ID))
+	_, err = f.groups.GetByID(f.ctx, g.ID)
+	assert.ErrorIs(t, err, domain.ErrNotFound)
+	assert.ErrorIs(t, f.groups.Delete(f.ctx, g.ID), domain.ErrNotFound)
+}
+
+func testGroupMembers(t *testing.T, f *groupFixture) {
+	t.Helper()
+	g := f.group("Engineering")
+	john, helen, ada := f.user("John"), f.user("Helen"), f.user("Ada")
+
+	// Adding is idempotent and duplicates in one call are fine
+	require.NoError(t, f.groups.AddMembers(f.ctx, g.ID, []uuid.UUID{john.UUID, helen.UUID, john.UUID}))
+	require.NoError(t, f.groups.AddMembers(f.ctx, g.ID, []uuid.UUID{helen.UUID}))
+	assert.Equal(t, sortedUUIDs(john, helen), f.members(g.ID, false))
+
+	// One unknown user adds none of them
+	err := f.groups.AddMembers(f.ctx, g.ID, []uuid.UUID{ada.UUID, uuid.New()})
+	assert.ErrorIs(t, err, domain.ErrNotFound)
+	assert.Equal(t, sortedUUIDs(john, helen), f.members(g.ID, false))
+
+	err = f.groups.AddMembers(f.ctx, uuid.New(), []uuid.UUID{ada.UUID})
+	assert.ErrorIs(t, err, domain.ErrNotFound)
+
+	// Users that are no members are ignored when removing
+	require.NoError(t, f.groups.RemoveMembers(f.ctx, g.ID, []uuid.UUID{john.UUID, ada.UUID}))
+	assert.Equal(t, []uuid.UUID{helen.UUID}, f.members(g.ID, false))
+
+	// A deleted user leaves its groups
+	require.NoError(t, f.users.Delete(f.ctx, helen.UUID))
+	assert.Empty(t, f.members(g.ID, false))
+
+	_, err = f.groups.Members(f.ctx, uuid.New(), false, &utils.PaginationQuery{Page: 1, Size: 10})
+	assert.ErrorIs(t, err, domain.ErrNotFound)
+	_, err = f.groups.UserGroups(f.ctx, helen.UUID, false, &utils.PaginationQuery{Page: 1, Size: 10})
+	assert.ErrorIs(t, err, domain.ErrNotFound)
+}
+
+func testGroupNested(t *testing.T, f *groupFixture) {
+	t.Helper()
+
+	// company contains engineering, which contains backend. Backend is in
+	// company directly as well, so there are two paths to it.
+	company, engineering, backend := f.group("Company"), f.group("Engineering"), f.group("Backend")
+	require.NoError(t, f.groups.AddSubgroups(f.ctx, company.ID, []uuid.UUID{engineering.ID, backend.ID}))
+	require.NoError(t, f.groups.AddSubgroups(f.ctx, engineering.ID, []uuid.UUID{backend.ID}))
+
+	ceo, cto, dev := f.user("Ceo"), f.user("Cto"), f.user("Dev")
+	require.NoError(t, f.groups.AddMembers(f.ctx, company.ID, []uuid.UUID{ceo.UUID, dev.UUID}))
+	require.NoError(t, f.groups.AddMembers(f.ctx, engineering.ID, []uuid.UUID{cto.UUID}))
+	require.NoError(t, f.groups.AddMembers(f.ctx, backend.ID, []uuid.UUID{dev.UUID}))
+
+	// Every user is returned once however many paths lead to it
+	assert.Equal(t, sortedUUIDs(ceo, dev), f.members(company.ID, false))
+	assert.Equal(t, sortedUUIDs(ceo, cto, dev), f.members(company.ID, true))
+	assert.Equal(t, sortedUUIDs(cto, dev), f.members(engineering.ID, true))
+	assert.Equal(t, []uuid.UUID{dev.UUID}, f.members(backend.ID, true))
+
+	assert.Equal(t, []string{"Backend", "Company"}, f.userGroups(dev.UUID, false))
+	assert.Equal(t, []string{"Backend", "Company", "Engineering"}, f.userGroups(dev.UUID, true))
+	assert.Equal(t, []string{"Company", "Engineering"}, f.userGroups(cto.UUID, true))
+	assert.Equal(t, []string{"Company"}, f.userGroups(ceo.UUID, true))
+
+	subgroups, err := f.groups.Subgroups(f.ctx, company.ID, &utils.PaginationQuery{Page: 1, Size: 10})
+	require.NoError(t, err)
+	require.Equal(t, 2, subgroups.TotalCount)
+	assert.Equal(t, "Backend", subgroups.Values[0].Name)
+	assert.Equal(t, "Engineering", subgroups.Values[1].Name)
+
+	// Pages of nested members do not overlap
+	page, err := f.groups.Members(f.ctx, company.ID, true, &utils.PaginationQuery{Page: 2, Size: 2})
+	require.NoError(t, err)
+	assert.Equal(t, 3, page.TotalCount)
+	require.Len(t, page.Values, 1)
+	assert.Equal(t, sortedUUIDs(ceo, cto, dev)[2], page.Values[0].UUID)
+
+	// Removing a subgroup or deleting a group cuts its members off
+	require.NoError(t, f.groups.RemoveSubgroups(f.ctx, company.ID, []uuid.UUID{backend.ID}))
+	assert.Equal(t, sortedUUIDs(ceo, cto, dev), f.members(company.ID, true))
+	require.NoError(t, f.groups.Delete(f.ctx, engineering.ID))
+	assert.Equal(t, sortedUUIDs(ceo, dev), f.members(company.ID, true))
+	assert.Equal(t, []string{"Backend", "Company"}, f.userGroups(dev.UUID, true))
+	assert.Equal(t, []string{}, f.userGroups(cto.UUID, true))
+}
+
+func testGroupCycles(t *testing.T, f *groupFixture) {
+	t.Helper()
+	a, b, c := f.group("A"), f.group("B"), f.group("C")
+	require.NoError(t, f.groups.AddSubgroups(f.ctx, a.ID, []uuid.UUID{b.ID}))
+	require.NoError(t, f.groups.AddSubgroups(f.ctx, b.ID, []uuid.UUID{c.ID}))
+
+	testCases := map[string]struct {
+		group    uuid.UUID
+		children []uuid.UUID
+		err      error
+	}{
+		"itself":          {a.ID, []uuid.UUID{a.ID}, domain.ErrGroupCycle},
+		"parent":          {b.ID, []uuid.UUID{a.ID}, domain.ErrGroupCycle},
+		"ancestor":        {c.ID, []uuid.UUID{a.ID}, domain.ErrGroupCycle},
+		"one of several":  {c.ID, []uuid.UUID{f.group("D").ID, b.ID}, domain.ErrGroupCycle},
+		"existing edge":   {a.ID, []uuid.UUID{b.ID}, nil},
+		"shortcut":        {a.ID, []uuid.UUID{c.ID}, nil},
+		"unknown child":   {a.ID, []uuid.UUID{uuid.New()}, domain.ErrNotFound},
+		"unknown parent":  {uuid.New(), []uuid.UUID{a.ID}, domain.ErrNotFound},
+		"no children":     {a.ID, nil, nil},
+		"duplicate child": {b.ID, []uuid.UUID{c.ID, c.ID}, nil},
+	}
+
+	for scenario, tc := range testCases { //nolint:paralleltest
+		t.Run(scenario, func(t *testing.T) {
+			err := f.groups.AddSubgroups(f.ctx, tc.group, tc.children)
+			if tc.err == nil {
+				assert.NoError(t, err)
+				return
+			}
+			assert.ErrorIs(t, err, tc.err)
+		})
+	}
+
+	// The rejected edges were not added, so nothing contains itself
+	subgroups, err := f.groups.Subgroups(f.ctx, c.ID, &utils.PaginationQuery{Page: 1, Size: 10})
+	require.NoError(t, err)
+	assert.Equal(t, 0, subgroups.TotalCount)
+
+	// Once the edge is gone the other direction is allowed
+	require.NoError(t, f.groups.RemoveSubgroups(f.ctx, a.ID, []uuid.UUID{b.ID, c.ID}))
+	assert.NoError(t, f.groups.AddSubgroups(f.ctx, c.ID, []uuid.UUID{a.ID}))
+}
+
+func testGroupTenancy(t *testing.T, f *groupFixture) {
+	t.Helper()
+	g := f.group("Engineering")
+	john := f.user("John")
+
+	other := &groupFixture{t: t, ctx: tenantContext(), users: f.users, groups: f.groups}
+	foreign := other.group("Engineering")
+	helen := other.user("Helen")
+
+	_, err := other.groups.GetByID(other.ctx, g.ID)
+	assert.ErrorIs(t, err, domain.ErrNotFound)
+	assert.ErrorIs(t, other.groups.Delete(other.ctx, g.ID), domain.ErrNotFound)
+
+	// Users and groups of another tenant cannot be added
+	assert.ErrorIs(t, f.groups.AddMembers(f.ctx, g.ID, []uuid.UUID{helen.UUID}), domain.ErrNotFound)
+	assert.ErrorIs(t, f.groups.AddSubgroups(f.ctx, g.ID, []uuid.UUID{foreign.ID}), domain.ErrNotFound)
+	assert.ErrorIs(t, other.groups.AddMembers(other.ctx, g.ID, []uuid.UUID{helen.UUID}), domain.ErrNotFound)
+
+	require.NoError(t, f.groups.AddMembers(f.ctx, g.ID, []uuid.UUID{john.UUID}))
+	_, err = other.groups.Members(other.ctx, g.ID, true, &utils.PaginationQuery{Page: 1, Size: 10})
+	assert.ErrorIs(t, err, domain.ErrNotFound)
+	_, err = other.groups.UserGroups(other.ctx, john.UUID, true, &utils.PaginationQuery{Page: 1, Size: 10})
+	assert.ErrorIs(t, err, domain.ErrNotFound)
+
+	list, err := other.groups.GetList(other.ctx, &utils.PaginationQuery{Page: 1, Size: 10})
+	require.NoError(t, err)
+	require.Equal(t, 1, list.TotalCount)
+	assert.Equal(t, foreign.ID, list.Values[0].ID)
+}
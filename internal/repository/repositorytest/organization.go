@@ -0,0 +1,394 @@
+package repositorytest
+
+import (
+	"context"
+	"go-project-template/internal/domain"
+	"go-project-template/internal/utils"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// invitationTTL is long enough for no invitation to expire during a test
+const invitationTTL = time.Hour
+
+// organizationRepository runs the conformance suite against the repositories
+// returned by newRepos, which is called once per subtest. The organization
+// repository has to find its members in the returned user repository.
+func organizationRepository(t *testing.T, newRepos func(t *testing.T) (domain.UserRepository, domain.OrganizationRepository)) {
+	t.Helper()
+
+	t.Run("Create", func(t *testing.T) { testOrganizationCreate(t, newOrganizationFixture(t, newRepos)) })
+	t.Run("Update", func(t *testing.T) { testOrganizationUpdate(t, newOrganizationFixture(t, newRepos)) })
+	t.Run("Delete", func(t *testing.T) { testOrganizationDelete(t, newOrganizationFixture(t, newRepos)) })
+	t.Run("GetList", func(t *testing.T) { testOrganizationGetList(t, newOrganizationFixture(t, newRepos)) })
+	t.Run("Members", func(t *testing.T) { testOrganizationMembers(t, newOrganizationFixture(t, newRepos)) })
+	t.Run("LastOwner", func(t *testing.T) { testOrganizationLastOwner(t, newOrganizationFixture(t, newRepos)) })
+	t.Run("UserOrganizations", func(t *testing.T) { testUserOrganizations(t, newOrganizationFixture(t, newRepos)) })
+	t.Run("Invitations", func(t *testing.T) { testOrganizationInvitations(t, newOrganizationFixture(t, newRepos)) })
+	t.Run("Tenancy", func(t *testing.T) { testOrganizationTenancy(t, newOrganizationFixture(t, newRepos)) })
+}
+
+// organizationFixture is a tenant of its own with the repositories of a subtest
+type organizationFixture struct {
+	t     *testing.T
+	ctx   context.Context
+	users domain.UserRepository
+	orgs  domain.OrganizationRepository
+}
+
+func newOrganizationFixture(t *testing.T, newRepos func(t *testing.T) (domain.UserRepository, domain.OrganizationRepository)) *organizationFixture {
+	t.Helper()
+	users, orgs := newRepos(t)
+	return &organizationFixture{t: t, ctx: tenantContext(), users: users, orgs: orgs}
+}
+
+// user stores a user of the fixture tenant
+func (f *organizationFixture) user(firstName string) *domain.User {
+	f.t.Helper()
+	u := newUser(firstName, uniqueLastName())
+	_, err := f.users.CreateOrUpdate(f.ctx, u)
+	require.NoError(f.t, err)
+	return u
+}
+
+// org stores an organization owned by owner
+func (f *organizationFixture) org(name string, owner *domain.User) *domain.Organization {
+	f.t.Helper()
+	o, err := f.orgs.Create(f.ctx, &domain.Organization{Name: name}, owner.UUID)
+	require.NoError(f.t, err)
+	return o
+}
+
+// roles returns the role of every member of the organization by user
+func (f *organizationFixture) roles(orgID uuid.UUID) map[uuid.UUID]domain.Role {
+	f.t.Helper()
+	list, err := f.orgs.Members(f.ctx, orgID, &utils.PaginationQuery{Page: 1, Size: 100})
+	require.NoError(f.t, err)
+
+	roles := map[uuid.UUID]domain.Role{}
+	for _, m := range list.Values {
+		roles[m.UserID] = m.Role
+	}
+	return roles
+}
+
+func testOrganizationCreate(t *testing.T, f *organizationFixture) {
+	t.Helper()
+	tenant, _ := domain.TenantFromContext(f.ctx)
+	owner := f.user("John")
+
+	o := f.org("Acme Research", owner)
+	assert.NotEqual(t, uuid.Nil, o.ID)
+	assert.Equal(t, tenant, o.TenantID)
+	assert.False(t, o.CreatedAt.IsZero())
+
+	got, err := f.orgs.GetByID(f.ctx, o.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "Acme Research", got.Name)
+	assert.True(t, o.CreatedAt.Equal(got.CreatedAt))
+
+	// The creator is its first owner
+	assert.Equal(t, map[uuid.UUID]domain.Role{owner.UUID: domain.RoleOwner}, f.roles(o.ID))
+
+	testCases := map[string]struct {
+		org   *domain.Organization
+		owner uuid.UUID
+		err   error
+	}{
+		"missing name":  {&domain.Organization{}, owner.UUID, nil},
+		"missing owner": {&domain.Organization{Name: "Acme"}, uuid.New(), domain.ErrNotFound},
+		"taken id":      {&domain.Organization{ID: o.ID, Name: "Acme"}, owner.UUID, domain.ErrConflict},
+	}
+
+	for scenario, tc := range testCases { //nolint:paralleltest
+		t.Run(scenario, func(t *testing.T) {
+			_, err := f.orgs.Create(f.ctx, tc.org, tc.owner)
+			if tc.err == nil {
+				assert.Error(t, err)
+				return
+			}
+			assert.ErrorIs(t, err, tc.err)
+		})
+	}
+
+	// A failed create leaves nothing behind
+	list, err := f.orgs.GetList(f.ctx, &utils.PaginationQuery{Page: 1, Size: 10})
+	require.NoError(t, err)
+	assert.Equal(t, 1, list.TotalCount)
+}
+
+func testOrganizationUpdate(t *testing.T, f *organizationFixture) {
+	t.Helper()
+	o := f.org("Acme", f.user("John"))
+
+	// Renaming to the same name does not touch the organization
+	same, err := f.orgs.Update(f.ctx, &domain.Organization{ID: o.ID, Name: "Acme"})
+	require.NoError(t, err)
+	assert.True(t, o.UpdatedAt.Equal(same.UpdatedAt))
+
+	time.Sleep(2 * time.Millisecond)
+	renamed, err := f.orgs.Update(f.ctx, &domain.Organization{ID: o.ID, Name: "Acme Research"})
+	require.NoError(t, err)
+	assert.Equal(t, "Acme Research", renamed.Name)
+	assert.True(t, o.CreatedAt.Equal(renamed.CreatedAt))
+	assert.True(t, renamed.UpdatedAt.After(o.UpdatedAt))
+
+	_, err = f.orgs.Update(f.ctx, &domain.Organization{ID: o.ID})
+	assert.Error(t, err)
+	_, err = f.orgs.Update(f.ctx, &domain.Organization{ID: uuid.New(), Name: "Acme"})
+	assert.ErrorIs(t, err, domain.ErrNotFound)
+}
+
+func testOrganizationDelete(t *testing.T, f *organizationFixture) {
+	t.Helper()
+	owner := f.user("John")
+	o := f.org("Acme", owner)
+
+	require.NoError(t, f.orgs.Delete(f.ctx, o.ID))
+	_, err := f.orgs.GetByID(f.ctx, o.ID)
+	assert.ErrorIs(t, err, domain.ErrNotFound)
+	assert.ErrorIs(t, f.orgs.Delete(f.ctx, o.ID), domain.ErrNotFound)
+
+	// Its members go with it
+	_, err = f.orgs.Members(f.ctx, o.ID, &utils.PaginationQuery{Page: 1, Size: 10})
+	assert.ErrorIs(t, err, domain.ErrNotFound)
+	list, err := f.orgs.UserOrganizations(f.ctx, owner.UUID, &utils.PaginationQuery{Page: 1, Size: 10})
+	require.NoError(t, err)
+	assert.Zero(t, list.TotalCount)
+}
+
+func testOrganizationGetList(t *testing.T, f *organizationFixture) {
+	t.Helper()
+	owner := f.user("John")
+	for _, name := range []string{"Beta", "Alpha", "Gamma"} {
+		f.org(name, owner)
+	}
+
+	testCases := map[string]struct {
+		pq   *utils.PaginationQuery
+		want []string
+	}{
+		"first page":  {&utils.PaginationQuery{Page: 1, Size: 2, OrderBy: "name ASC"}, []string{"Alpha", "Beta"}},
+		"second page": {&utils.PaginationQuery{Page: 2, Size: 2, OrderBy: "name ASC"}, []string{"Gamma"}},
+		"descending":  {&utils.PaginationQuery{Page: 1, Size: 3, OrderBy: "name DESC"}, []string{"Gamma", "Beta", "Alpha"}},
+	}
+
+	for scenario, tc := range testCases { //nolint:paralleltest
+		t.Run(scenario, func(t *testing.T) {
+			list, err := f.orgs.GetList(f.ctx, tc.pq)
+			require.NoError(t, err)
+			assert.Equal(t, 3, list.TotalCount)
+
+			names := make([]string, len(list.Values))
+			for i, o := range list.Values {
+				names[i] = o.Name
+			}
+			assert.Equal(t, tc.want, names)
+		})
+	}
+}
+
+func testOrganizationMembers(t *testing.T, f *organizationFixture) {
+	t.Helper()
+	owner, helen, winston := f.user("John"), f.user("Helen"), f.user("Winston")
+	o := f.org("Acme", owner)
+
+	m, err := f.orgs.SetMember(f.ctx, &domain.Membership{OrganizationID: o.ID, UserID: helen.UUID, Role: domain.RoleMember})
+	require.NoError(t, err)
+	assert.Equal(t, domain.RoleMember, m.Role)
+	assert.False(t, m.CreatedAt.IsZero())
+
+	// Setting a member again changes its role
+	_, err = f.orgs.SetMember(f.ctx, &domain.Membership{OrganizationID: o.ID, UserID: helen.UUID, Role: domain.RoleAdmin})
+	require.NoError(t, err)
+	_, err = f.orgs.SetMember(f.ctx, &domain.Membership{OrganizationID: o.ID, UserID: winston.UUID, Role: domain.RoleMember})
+	require.NoError(t, err)
+
+	assert.Equal(t, map[uuid.UUID]domain.Role{
+		owner.UUID:   domain.RoleOwner,
+		helen.UUID:   domain.RoleAdmin,
+		winston.UUID: domain.RoleMember,
+	}, f.roles(o.ID))
+
+	page, err := f.orgs.Members(f.ctx, o.ID, &utils.PaginationQuery{Page: 2, Size: 2})
+	require.NoError(t, err)
+	assert.Equal(t, 3, page.TotalCount)
+	assert.Len(t, page.Values, 1)
+
+	testCases := map[string]struct {
+		m   *domain.Membership
+		err error
+	}{
+		"invalid role":         {&domain.Membership{OrganizationID: o.ID, UserID: helen.UUID, Role: "guest"}, nil},
+		"missing user":         {&domain.Membership{OrganizationID: o.ID, UserID: uuid.New(), Role: domain.RoleMember}, domain.ErrNotFound},
+		"missing organization": {&domain.Membership{OrganizationID: uuid.New(), UserID: helen.UUID, Role: domain.RoleMember}, domain.ErrNotFound},
+	}
+
+	for scenario, tc := range testCases { //nolint:paralleltest
+		t.Run(scenario, func(t *testing.T) {
+			_, err := f.orgs.SetMember(f.ctx, tc.m)
+			if tc.err == nil {
+				assert.Error(t, err)
+				return
+			}
+			assert.ErrorIs(t, err, tc.err)
+		})
+	}
+
+	require.NoError(t, f.orgs.RemoveMember(f.ctx, o.ID, winston.UUID))
+	assert.ErrorIs(t, f.orgs.RemoveMember(f.ctx, o.ID, winston.UUID), domain.ErrNotFound)
+
+	// Deleting a user ends its memberships
+	require.NoError(t, f.users.Delete(f.ctx, helen.UUID))
+	assert.Equal(t, map[uuid.UUID]domain.Role{owner.UUID: domain.RoleOwner}, f.roles(o.ID))
+}
+
+func testOrganizationLastOwner(t *testing.T, f *organizationFixture) {
+	t.Helper()
+	owner, helen := f.user("John"), f.user("Helen")
+	o := f.org("Acme", owner)
+
+	_, err := f.orgs.SetMember(f.ctx, &domain.Membership{OrganizationID: o.ID, UserID: owner.UUID, Role: domain.RoleAdmin})
+	assert.ErrorIs(t, err, domain.ErrLastOwner)
+	assert.ErrorIs(t, f.orgs.RemoveMember(f.ctx, o.ID, owner.UUID), domain.ErrLastOwner)
+
+	// With a second owner the first one can step down
+	_, err = f.orgs.SetMember(f.ctx, &domain.Membership{OrganizationID: o.ID, UserID: helen.UUID, Role: domain.RoleOwner})
+	require.NoError(t, err)
+	_, err = f.orgs.SetMember(f.ctx, &domain.Membership{OrganizationID: o.ID, UserID: owner.UUID, Role: domain.RoleMember})
+	require.NoError(t, err)
+	require.NoError(t, f.orgs.RemoveMember(f.ctx, o.ID, owner.UUID))
+
+	assert.ErrorIs(t, f.orgs.RemoveMember(f.ctx, o.ID, helen.UUID), domain.ErrLastOwner)
+	assert.Equal(t, map[uuid.UUID]domain.Role{helen.UUID: domain.RoleOwner}, f.roles(o.ID))
+}
+
+func testUserOrganizations(t *testing.T, f *organizationFixture) {
+	t.Helper()
+	john, helen := f.user("John"), f.user("Helen")
+	beta := f.org("Beta", john)
+	alpha := f.org("Alpha", helen)
+	f.org("Gamma", helen)
+
+	_, err := f.orgs.SetMember(f.ctx, &domain.Membership{OrganizationID: alpha.ID, UserID: john.UUID, Role: domain.RoleAdmin})
+	require.NoError(t, err)
+
+	list, err := f.orgs.UserOrganizations(f.ctx, john.UUID, &utils.PaginationQuery{Page: 1, Size: 10})
+	require.NoError(t, err)
+	require.Equal(t, 2, list.TotalCount)
+
+	// Ordered by name, each with the role of the user
+	assert.Equal(t, alpha.ID, list.Values[0].ID)
+	assert.Equal(t, domain.RoleAdmin, list.Values[0].Role)
+	assert.Equal(t, beta.ID, list.Values[1].ID)
+	assert.Equal(t, domain.RoleOwner, list.Values[1].Role)
+
+	_, err = f.orgs.UserOrganizations(f.ctx, uuid.New(), &utils.PaginationQuery{Page: 1, Size: 10})
+	assert.ErrorIs(t, err, domain.ErrNotFound)
+}
+
+func testOrganizationInvitations(t *testing.T, f *organizationFixture) {
+	t.Helper()
+	owner, helen, winston := f.user("John"), f.user("Helen"), f.user("Winston")
+	o := f.org("Acme", owner)
+
+	invite := func(email string, role domain.Role, ttl time.Duration) *domain.Invitation {
+		t.Helper()
+		inv, err := f.orgs.CreateInvitation(f.ctx, &domain.Invitation{OrganizationID: o.ID, Email: email, Role: role}, ttl)
+		require.NoError(t, err)
+		return inv
+	}
+
+	inv := invite("HELEN@mail.com", domain.RoleAdmin, invitationTTL)
+	assert.NotEmpty(t, inv.Token)
+	assert.Equal(t, "helen@mail.com", inv.Email)
+	assert.WithinDuration(t, inv.CreatedAt.Add(invitationTTL), inv.ExpiresAt, time.Second)
+
+	pending, err := f.orgs.Invitations(f.ctx, o.ID)
+	require.NoError(t, err)
+	require.Len(t, pending, 1)
+	assert.Equal(t, inv.ID, pending[0].ID)
+	assert.Empty(t, pending[0].Token, "the token is only returned on creation")
+
+	// Only the user the invitation was sent to can accept it
+	_, err = f.orgs.AcceptInvitation(f.ctx, inv.Token, winston.UUID)
+	assert.ErrorIs(t, err, domain.ErrInvitationEmail)
+
+	m, err := f.orgs.AcceptInvitation(f.ctx, inv.Token, helen.UUID)
+	require.NoError(t, err)
+	assert.Equal(t, domain.RoleAdmin, m.Role)
+	assert.Equal(t, domain.RoleAdmin, f.roles(o.ID)[helen.UUID])
+
+	_, err = f.orgs.AcceptInvitation(f.ctx, inv.Token, helen.UUID)
+	assert.ErrorIs(t, err, domain.ErrInvitationInvalid)
+	pending, err = f.orgs.Invitations(f.ctx, o.ID)
+	require.NoError(t, err)
+	assert.Empty(t, pending)
+
+	// A member keeps its role
+	again := invite("helen@mail.com", domain.RoleMember, invitationTTL)
+	_, err = f.orgs.AcceptInvitation(f.ctx, again.Token, helen.UUID)
+	require.NoError(t, err)
+	assert.Equal(t, domain.RoleAdmin, f.roles(o.ID)[helen.UUID])
+
+	expired := invite("winston@mail.com", domain.RoleMember, -time.Minute)
+	_, err = f.orgs.AcceptInvitation(f.ctx, expired.Token, winston.UUID)
+	assert.ErrorIs(t, err, domain.ErrInvitationInvalid)
+
+	revoked := invite("winston@mail.com", domain.RoleMember, invitationTTL)
+	require.NoError(t, f.orgs.RevokeInvitation(f.ctx, o.ID, revoked.ID))
+	assert.ErrorIs(t, f.orgs.RevokeInvitation(f.ctx, o.ID, revoked.ID), domain.ErrNotFound)
+	_, err = f.orgs.AcceptInvitation(f.ctx, revoked.Token, winston.UUID)
+	assert.ErrorIs(t, err, domain.ErrNotFound)
+
+	_, err = f.orgs.CreateInvitation(f.ctx, &domain.Invitation{OrganizationID: o.ID, Email: "not-an-email", Role: domain.RoleMember}, invitationTTL)
+	assert.Error(t, err)
+	_, err = f.orgs.CreateInvitation(f.ctx, &domain.Invitation{OrganizationID: uuid.New(), Email: "helen@mail.com", Role: domain.RoleMember}, invitationTTL)
+	assert.ErrorIs(t, err, domain.ErrNotFound)
+}
+
+func testOrganizationTenancy(t *testing.T, f *organizationFixture) {
+	t.Helper()
+	owner := f.user("John")
+	o := f.org("Acme", owner)
+	inv, err := f.orgs.CreateInvitation(f.ctx, &domain.Invitation{OrganizationID: o.ID, Email: "john@mail.com", Role: domain.RoleMember}, invitationTTL)
+	require.NoError(t, err)
+
+	// Nothing of the organization is visible to another tenant
+	other := tenantContext()
+	_, err = f.orgs.GetByID(other, o.ID)
+	assert.ErrorIs(t, err, domain.ErrNotFound)
+
+	list, err := f.orgs.GetList(other, &utils.PaginationQuery{Page: 1, Size: 10})
+	require.NoError(t, err)
+	assert.Zero(t, list.TotalCount)
+
+	_, err = f.orgs.Members(other, o.ID, &utils.PaginationQuery{Page: 1, Size: 10})
+	assert.ErrorIs(t, err, domain.ErrNotFound)
+	_, err = f.orgs.Update(other, &domain.Organization{ID: o.ID, Name: "Mallory"})
+	assert.ErrorIs(t, err, domain.ErrNotFound)
+	assert.ErrorIs(t, f.orgs.Delete(other, o.ID), domain.ErrNotFound)
+
+	// Nor can its users join it
+	mallory := newUser("Mallory", uniqueLastName())
+	_, err = f.users.CreateOrUpdate(other, mallory)
+	require.NoError(t, err)
+	_, err = f.orgs.SetMember(f.ctx, &domain.Membership{OrganizationID: o.ID, UserID: mallory.UUID, Role: domain.RoleMember})
+	assert.ErrorIs(t, err, domain.ErrNotFound)
+	_, err = f.orgs.AcceptInvitation(other, inv.Token, mallory.UUID)
+	assert.ErrorIs(t, err, domain.ErrNotFound)
+	_, err = f.orgs.Create(f.ctx, &domain.Organization{Name: "Acme"}, mallory.UUID)
+	assert.ErrorIs(t, err, domain.ErrNotFound)
+
+	assert.Equal(t, map[uuid.UUID]domain.Role{owner.UUID: domain.RoleOwner}, f.roles(o.ID))
+
+	// Every operation needs a tenant
+	_, err = f.orgs.GetByID(context.Background(), o.ID)
+	assert.ErrorIs(t, err, domain.ErrTenantRequired)
+	_, err = f.orgs.Create(context.Background(), &domain.Organization{Name: "Acme"}, owner.UUID)
+	assert.ErrorIs(t, err, domain.ErrTenantRequired)
+}
@@ -0,0 +1,55 @@
+// Package repositorytest holds conformance suites that every implementation of
+// a domain repository has to pass.
+package repositorytest
+
+import (
+	"go-project-template/internal/domain"
+	"testing"
+)
+
+// Repositories are the repositories of one store. The organization, group and
+// credential repositories find their users in Users.
+type Repositories struct {
+	Users         domain.UserRepository
+	Organizations domain.OrganizationRepository
+	Groups        domain.GroupRepository
+	Credentials   domain.CredentialRepository
+}
+
+// Run runs the conformance suites against the repositories returned by
+// newRepos, which is called once per subtest. The suites of repositories the
+// store leaves nil are skipped.
+func Run(t *testing.T, newRepos func(t *testing.T) Repositories) {
+	t.Helper()
+	store := newRepos(t)
+
+	t.Run("User", func(t *testing.T) {
+		userRepository(t, func(t *testing.T) domain.UserRepository {
+			return newRepos(t).Users
+		})
+	})
+	if store.Organizations != nil {
+		t.Run("Organization", func(t *testing.T) {
+			organizationRepository(t, func(t *testing.T) (domain.UserRepository, domain.OrganizationRepository) {
+				r := newRepos(t)
+				return r.Users, r.Organizations
+			})
+		})
+	}
+	if store.Groups != nil {
+		t.Run("Group", func(t *testing.T) {
+			groupRepository(t, func(t *testing.T) (domain.UserRepository, domain.GroupRepository) {
+				r := newRepos(t)
+				return r.Users, r.Groups
+			})
+		})
+	}
+	if store.Credentials != nil {
+		t.Run("Credential", func(t *testing.T) {
+			credentialRepository(t, func(t *testing.T) (domain.UserRepository, domain.CredentialRepository) {
+				r := newRepos(t)
+				return r.Users, r.Credentials
+			})
+		})
+	}
+}
@@ -0,0 +1,523 @@
+package repositorytest
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"go-project-template/internal/domain"
+	"go-project-template/internal/utils"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// userRepository runs the conformance suite against repositories returned by
+// newRepo, which is called once per subtest. Repositories may share data, every
+// subtest works on users with a last name of its own.
+func userRepository(t *testing.T, newRepo func(t *testing.T) domain.UserRepository) {
+	t.Helper()
+
+	t.Run("Create", func(t *testing.T) { testCreate(t, newRepo(t)) })
+	t.Run("CreateOrUpdate", func(t *testing.T) { testCreateOrUpdate(t, newRepo(t)) })
+	t.Run("Timestamps", func(t *testing.T) { testTimestamps(t, newRepo(t)) })
+	t.Run("GetByID", func(t *testing.T) { testGetByID(t, newRepo(t)) })
+	t.Run("Delete", func(t *testing.T) { testDelete(t, newRepo(t)) })
+	t.Run("DeleteAll", func(t *testing.T) { testDeleteAll(t, newRepo(t)) })
+	t.Run("Backdate", func(t *testing.T) { testBackdate(t, newRepo(t)) })
+	t.Run("BatchCreateOrUpdate", func(t *testing.T) { testBatchCreateOrUpdate(t, newRepo(t)) })
+	t.Run("GetList", func(t *testing.T) { testGetList(t, newRepo(t)) })
+	t.Run("Stream", func(t *testing.T) { testStream(t, newRepo(t)) })
+	t.Run("Tenancy", func(t *testing.T) { testTenancy(t, newRepo(t)) })
+	t.Run("Lifecycle", func(t *testing.T) { testLifecycle(t, newRepo(t)) })
+}
+
+// tenantContext scopes a subtest to a tenant of its own
+func tenantContext() context.Context {
+	return domain.WithTenant(context.Background(), "conformance-"+uuid.NewString()[:8])
+}
+
+// uniqueLastName keeps the users of a subtest apart from any other data
+func uniqueLastName() string {
+	return "conformance-" + uuid.NewString()[:8]
+}
+
+func newUser(firstName, lastName string) *domain.User {
+	email := firstName + "@mail.com"
+	return &domain.User{UUID: uuid.New(), FirstName: firstName, LastName: lastName, Email: &email}
+}
+
+func testCreate(t *testing.T, repo domain.UserRepository) {
+	t.Helper()
+	ctx := tenantContext()
+	lastName := uniqueLastName()
+
+	u := newUser("John", lastName)
+	got, err := repo.Create(ctx, u)
+	require.NoError(t, err)
+	assert.Equal(t, u, got)
+	assert.False(t, got.CreatedAt.IsZero())
+
+	_, err = repo.Create(ctx, &domain.User{UUID: uuid.New(), LastName: lastName})
+	assert.Error(t, err)
+
+	// A taken UUID is never overwritten, in this tenant or another one
+	taken := newUser("Helen", lastName)
+	taken.UUID = u.UUID
+	_, err = repo.Create(ctx, taken)
+	assert.ErrorIs(t, err, domain.ErrConflict)
+	_, err = repo.Create(tenantContext(), taken)
+	assert.ErrorIs(t, err, domain.ErrConflict)
+
+	stored, err := repo.GetByID(ctx, u.UUID)
+	require.NoError(t, err)
+	assert.Equal(t, "john", stored.FirstName)
+}
+
+func testCreateOrUpdate(t *testing.T, repo domain.UserRepository) {
+	t.Helper()
+	ctx := tenantContext()
+	lastName := uniqueLastName()
+	email := "jwick@mail.com"
+
+	testCases := map[string]struct {
+		have *domain.User
+		err  bool
+	}{
+		"valid":              {newUser("John", lastName), false},
+		"missing first name": {&domain.User{UUID: uuid.New(), LastName: lastName, Email: &email}, true},
+		"missing email":      {&domain.User{UUID: uuid.New(), FirstName: "John", LastName: lastName}, true},
+	}
+
+	for scenario, tc := range testCases { //nolint:paralleltest
+		t.Run(scenario, func(t *testing.T) {
+			got, err := repo.CreateOrUpdate(ctx, tc.have)
+			if tc.err {
+				assert.Error(t, err)
+				_, err := repo.GetByID(ctx, tc.have.UUID)
+				assert.ErrorIs(t, err, domain.ErrNotFound)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, tc.have, got)
+		})
+	}
+
+	// Writing an existing UUID replaces the user
+	u := newUser("Helen", lastName)
+	_, err := repo.CreateOrUpdate(ctx, u)
+	require.NoError(t, err)
+
+	u.FirstName = "Winston"
+	_, err = repo.CreateOrUpdate(ctx, u)
+	require.NoError(t, err)
+
+	got, err := repo.GetByID(ctx, u.UUID)
+	require.NoError(t, err)
+	assert.Equal(t, "winston", got.FirstName)
+}
+
+func testTimestamps(t *testing.T, repo domain.UserRepository) {
+	t.Helper()
+	ctx := tenantContext()
+
+	u := newUser("John", uniqueLastName())
+	_, err := repo.CreateOrUpdate(ctx, u)
+	require.NoError(t, err)
+	require.False(t, u.CreatedAt.IsZero())
+	assert.True(t, u.CreatedAt.Equal(u.UpdatedAt))
+	created := u.CreatedAt
+
+	// Writing the same fields again does not touch the user
+	_, err = repo.CreateOrUpdate(ctx, u)
+	require.NoError(t, err)
+	assert.True(t, created.Equal(u.CreatedAt))
+	assert.True(t, created.Equal(u.UpdatedAt))
+
+	time.Sleep(2 * time.Millisecond)
+	u.FirstName = "Jonathan"
+	_, err = repo.CreateOrUpdate(ctx, u)
+	require.NoError(t, err)
+	assert.True(t, created.Equal(u.CreatedAt))
+	assert.True(t, u.UpdatedAt.After(created))
+
+	got, err := repo.GetByID(ctx, u.UUID)
+	require.NoError(t, err)
+	assert.True(t, u.CreatedAt.Equal(got.CreatedAt))
+	assert.True(t, u.UpdatedAt.Equal(got.UpdatedAt))
+}
+
+func testGetByID(t *testing.T, repo domain.UserRepository) {
+	t.Helper()
+	ctx := tenantContext()
+
+	u := newUser("John", "Wick")
+	_, err := repo.CreateOrUpdate(ctx, u)
+	require.NoError(t, err)
+
+	testCases := map[string]struct {
+		id   uuid.UUID
+		want domain.User
+		err  error
+	}{
+		// Names are stored normalized
+		"existing": {u.UUID, domain.User{UUID: u.UUID, TenantID: u.TenantID, FirstName: "john", LastName: "wick", Email: u.Email, Status: domain.UserStatusActive}, nil},
+		"missing":  {uuid.New(), domain.User{}, domain.ErrNotFound},
+	}
+
+	for scenario, tc := range testCases { //nolint:paralleltest
+		t.Run(scenario, func(t *testing.T) {
+			got, err := repo.GetByID(ctx, tc.id)
+			if tc.err != nil {
+				assert.ErrorIs(t, err, tc.err)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.True(t, u.UpdatedAt.Equal(got.UpdatedAt), "updated_at %s, want %s", got.UpdatedAt, u.UpdatedAt)
+
+			// Timestamps are compared above, their location depends on the implementation
+			got.CreatedAt, got.UpdatedAt = time.Time{}, time.Time{}
+			assert.Equal(t, tc.want, got)
+		})
+	}
+
+	// The caller's struct is not shared with the repository
+	*u.Email = "changed@mail.com"
+	got, err := repo.GetByID(ctx, u.UUID)
+	require.NoError(t, err)
+	assert.Equal(t, "John@mail.com", *got.Email)
+}
+
+func testDelete(t *testing.T, repo domain.UserRepository) {
+	t.Helper()
+	ctx := tenantContext()
+
+	u := newUser("John", uniqueLastName())
+	_, err := repo.CreateOrUpdate(ctx, u)
+	require.NoError(t, err)
+
+	require.NoError(t, repo.Delete(ctx, u.UUID))
+	_, err = repo.GetByID(ctx, u.UUID)
+	assert.ErrorIs(t, err, domain.ErrNotFound)
+
+	// Deleting a missing user is not an error
+	assert.NoError(t, repo.Delete(ctx, u.UUID))
+}
+
+func testDeleteAll(t *testing.T, repo domain.UserRepository) {
+	t.Helper()
+	ctx, other := tenantContext(), tenantContext()
+
+	lastName := uniqueLastName()
+	var want []uuid.UUID
+	for range 3 {
+		u := newUser("John", lastName)
+		_, err := repo.CreateOrUpdate(ctx, u)
+		require.NoError(t, err)
+		want = append(want, u.UUID)
+	}
+	kept := newUser("Jane", lastName)
+	_, err := repo.CreateOrUpdate(other, kept)
+	require.NoError(t, err)
+
+	ids, err := repo.DeleteAll(ctx)
+	require.NoError(t, err)
+	assert.ElementsMatch(t, want, ids)
+	_, err = repo.GetByID(ctx, want[0])
+	assert.ErrorIs(t, err, domain.ErrNotFound)
+
+	// Other tenants keep their users
+	_, err = repo.GetByID(other, kept.UUID)
+	assert.NoError(t, err)
+
+	ids, err = repo.DeleteAll(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, ids)
+}
+
+func testBackdate(t *testing.T, repo domain.UserRepository) {
+	t.Helper()
+	ctx := tenantContext()
+
+	u := newUser("John", uniqueLastName())
+	_, err := repo.CreateOrUpdate(ctx, u)
+	require.NoError(t, err)
+
+	created := time.Date(2023, 3, 1, 12, 0, 0, 0, time.UTC)
+	updated := created.Add(30 * 24 * time.Hour)
+	stamped := &domain.User{UUID: u.UUID, CreatedAt: created, UpdatedAt: updated}
+	// Unknown users are ignored
+	missing := &domain.User{UUID: uuid.New(), CreatedAt: created, UpdatedAt: updated}
+	require.NoError(t, repo.Backdate(ctx, []*domain.User{stamped, missing}))
+
+	got, err := repo.GetByID(ctx, u.UUID)
+	require.NoError(t, err)
+	assert.True(t, created.Equal(got.CreatedAt), "created_at %s", got.CreatedAt)
+	assert.True(t, updated.Equal(got.UpdatedAt), "updated_at %s", got.UpdatedAt)
+}
+
+func testBatchCreateOrUpdate(t *testing.T, repo domain.UserRepository) {
+	t.Helper()
+	ctx := tenantContext()
+	lastName := uniqueLastName()
+
+	newBatch := func() []*domain.User {
+		u := newUser("John", lastName)
+		u.UUID = uuid.Nil
+		return []*domain.User{
+			u,
+			{FirstName: "Winston"},
+			newUser("Helen", lastName),
+		}
+	}
+
+	testCases := map[string]struct {
+		mode   domain.BatchMode
+		want   []domain.BatchStatus
+		stored int
+		err    error
+	}{
+		"atomic":  {domain.BatchModeAtomic, []domain.BatchStatus{domain.BatchStatusSkipped, domain.BatchStatusInvalid, domain.BatchStatusSkipped}, 0, domain.ErrBatchRejected},
+		"partial": {domain.BatchModePartial, []domain.BatchStatus{domain.BatchStatusOK, domain.BatchStatusInvalid, domain.BatchStatusOK}, 2, nil},
+	}
+
+	for scenario, tc := range testCases { //nolint:paralleltest
+		t.Run(scenario, func(t *testing.T) {
+			uu := newBatch()
+			results, err := repo.BatchCreateOrUpdate(ctx, uu, tc.mode)
+			assert.Equal(t, tc.err, err)
+			require.Len(t, results, len(tc.want))
+
+			stored := 0
+			for i, res := range results {
+				assert.Equal(t, i, res.Index)
+				assert.Equal(t, tc.want[i], res.Status)
+				// Users without a UUID are assigned one
+				assert.NotEqual(t, uuid.Nil, res.UUID)
+				assert.Equal(t, uu[i].UUID, res.UUID)
+
+				if _, err := repo.GetByID(ctx, res.UUID); err == nil {
+					stored++
+				}
+			}
+			assert.Equal(t, tc.stored, stored)
+		})
+	}
+}
+
+func testGetList(t *testing.T, repo domain.UserRepository) {
+	t.Helper()
+	ctx := tenantContext()
+	lastName := uniqueLastName()
+
+	for _, name := range []string{"Carol", "Ada", "Grace", "Bob", "Ada"} {
+		_, err := repo.CreateOrUpdate(ctx, newUser(name, lastName))
+		require.NoError(t, err)
+	}
+
+	testCases := map[string]struct {
+		filter   domain.UserFilter
+		page     int
+		size     int
+		orderBy  string
+		orderDir string
+		want     []string
+		total    int
+	}{
+		"ascending":     {domain.UserFilter{LastName: lastName}, 1, 10, "first_name", "asc", []string{"ada", "ada", "bob", "carol", "grace"}, 5},
+		"descending":    {domain.UserFilter{LastName: lastName}, 1, 10, "first_name", "desc", []string{"grace", "carol", "bob", "ada", "ada"}, 5},
+		"first page":    {domain.UserFilter{LastName: lastName}, 1, 2, "first_name", "asc", []string{"ada", "ada"}, 5},
+		"last page":     {domain.UserFilter{LastName: lastName}, 3, 2, "first_name", "asc", []string{"grace"}, 5},
+		"past the end":  {domain.UserFilter{LastName: lastName}, 4, 2, "first_name", "asc", nil, 5},
+		"filtered":      {domain.UserFilter{FirstName: "ADA", LastName: lastName}, 1, 10, "first_name", "asc", []string{"ada", "ada"}, 2},
+		"by email":      {domain.UserFilter{LastName: lastName, Email: "Bob@mail.com"}, 1, 10, "", "", []string{"bob"}, 1},
+		"no match":      {domain.UserFilter{FirstName: "Linus", LastName: lastName}, 1, 10, "", "", nil, 0},
+		"unknown order": {domain.UserFilter{FirstName: "Grace", LastName: lastName}, 1, 10, "password", "asc", []string{"grace"}, 1},
+	}
+
+	for scenario, tc := range testCases { //nolint:paralleltest
+		t.Run(scenario, func(t *testing.T) {
+			pq := &utils.PaginationQuery{Page: tc.page, Size: tc.size}
+			pq.SetOrderBy(tc.orderBy, tc.orderDir)
+
+			list, err := repo.GetList(ctx, pq, tc.filter)
+			require.NoError(t, err)
+			assert.Equal(t, tc.total, list.TotalCount)
+			assert.Equal(t, tc.page, list.Page)
+			assert.Equal(t, tc.size, list.Size)
+
+			var got []string
+			for _, u := range list.Values {
+				got = append(got, u.FirstName)
+			}
+			assert.Equal(t, tc.want, got)
+		})
+	}
+}
+
+func testStream(t *testing.T, repo domain.UserRepository) {
+	t.Helper()
+	ctx := tenantContext()
+	lastName := uniqueLastName()
+
+	for _, name := range []string{"Ada", "Grace", "Ada"} {
+		_, err := repo.CreateOrUpdate(ctx, newUser(name, lastName))
+		require.NoError(t, err)
+	}
+
+	testCases := map[string]struct {
+		filter domain.UserFilter
+		want   int
+	}{
+		"everyone":   {domain.UserFilter{LastName: lastName}, 3},
+		"first name": {domain.UserFilter{FirstName: "Ada", LastName: lastName}, 2},
+		"no match":   {domain.UserFilter{FirstName: "Linus", LastName: lastName}, 0},
+	}
+
+	for scenario, tc := range testCases { //nolint:paralleltest
+		t.Run(scenario, func(t *testing.T) {
+			var ids []uuid.UUID
+			err := repo.Stream(ctx, tc.filter, func(u *domain.User) error {
+				assert.Equal(t, lastName, u.LastName)
+				ids = append(ids, u.UUID)
+				return nil
+			})
+			require.NoError(t, err)
+			assert.Len(t, ids, tc.want)
+
+			// Users are streamed in UUID order
+			for i := 1; i < len(ids); i++ {
+				assert.Negative(t, bytes.Compare(ids[i-1][:], ids[i][:]))
+			}
+		})
+	}
+
+	// Iteration stops at the first error
+	errStop := errors.New("stop")
+	calls := 0
+	err := repo.Stream(ctx, domain.UserFilter{LastName: lastName}, func(*domain.User) error {
+		calls++
+		return errStop
+	})
+	assert.ErrorIs(t, err, errStop)
+	assert.Equal(t, 1, calls)
+}
+
+func testTenancy(t *testing.T, repo domain.UserRepository) {
+	t.Helper()
+	ctx, other := tenantContext(), tenantContext()
+	lastName := uniqueLastName()
+	tenant, _ := domain.TenantFromContext(ctx)
+
+	u := newUser("John", lastName)
+	_, err := repo.CreateOrUpdate(ctx, u)
+	require.NoError(t, err)
+	assert.Equal(t, tenant, u.TenantID)
+
+	// Nothing of the user is visible to another tenant
+	_, err = repo.GetByID(other, u.UUID)
+	assert.ErrorIs(t, err, domain.ErrNotFound)
+
+	list, err := repo.GetList(other, &utils.PaginationQuery{Page: 1, Size: 10}, domain.UserFilter{LastName: lastName})
+	require.NoError(t, err)
+	assert.Zero(t, list.TotalCount)
+
+	err = repo.Stream(other, domain.UserFilter{}, func(got *domain.User) error {
+		assert.NotEqual(t, u.UUID, got.UUID)
+		return nil
+	})
+	require.NoError(t, err)
+
+	// Nor can it overwrite or delete the user
+	taken := newUser("Mallory", lastName)
+	taken.UUID = u.UUID
+	_, err = repo.CreateOrUpdate(other, taken)
+	assert.ErrorIs(t, err, domain.ErrConflict)
+
+	results, err := repo.BatchCreateOrUpdate(other, []*domain.User{taken}, domain.BatchModePartial)
+	require.NoError(t, err)
+	assert.Equal(t, domain.BatchStatusFailed, results[0].Status)
+
+	_, err = repo.BatchCreateOrUpdate(other, []*domain.User{newUser("Eve", lastName), taken}, domain.BatchModeAtomic)
+	assert.ErrorIs(t, err, domain.ErrBatchRejected)
+
+	require.NoError(t, repo.Delete(other, u.UUID))
+
+	got, err := repo.GetByID(ctx, u.UUID)
+	require.NoError(t, err)
+	assert.Equal(t, "john", got.FirstName)
+
+	// Every operation needs a tenant
+	_, err = repo.GetByID(context.Background(), u.UUID)
+	assert.ErrorIs(t, err, domain.ErrTenantRequired)
+	_, err = repo.CreateOrUpdate(context.Background(), newUser("John", lastName))
+	assert.ErrorIs(t, err, domain.ErrTenantRequired)
+}
+
+func testLifecycle(t *testing.T, repo domain.UserRepository) {
+	t.Helper()
+	ctx, other := tenantContext(), tenantContext()
+	lastName := uniqueLastName()
+
+	// New users are active unless they are invited, other statuses are ignored
+	u := newUser("John", lastName)
+	u.Status = domain.UserStatusSuspended
+	_, err := repo.CreateOrUpdate(ctx, u)
+	require.NoError(t, err)
+	assert.Equal(t, domain.UserStatusActive, u.Status)
+
+	invited := newUser("Helen", lastName)
+	invited.Status = domain.UserStatusInvited
+	_, err = repo.CreateOrUpdate(ctx, invited)
+	require.NoError(t, err)
+	assert.Equal(t, domain.UserStatusInvited, invited.Status)
+
+	got, err := repo.Transition(ctx, u.UUID, domain.StatusChange{Status: domain.UserStatusSuspended, Reason: "chargeback"})
+	require.NoError(t, err)
+	assert.Equal(t, domain.UserStatusSuspended, got.Status)
+	assert.Equal(t, "chargeback", got.StatusReason)
+	assert.Equal(t, "john", got.FirstName)
+
+	// An update keeps the status
+	u.Status = domain.UserStatusActive
+	u.FirstName = "Jonathan"
+	_, err = repo.CreateOrUpdate(ctx, u)
+	require.NoError(t, err)
+	assert.Equal(t, domain.UserStatusSuspended, u.Status)
+
+	stored, err := repo.GetByID(ctx, u.UUID)
+	require.NoError(t, err)
+	assert.Equal(t, domain.UserStatusSuspended, stored.Status)
+	assert.Equal(t, "chargeback", stored.StatusReason)
+
+	list, err := repo.GetList(ctx, &utils.PaginationQuery{Page: 1, Size: 10}, domain.UserFilter{LastName: lastName, Status: domain.UserStatusInvited})
+	require.NoError(t, err)
+	require.Equal(t, 1, list.TotalCount)
+	assert.Equal(t, invited.UUID, list.Values[0].UUID)
+
+	// Illegal moves and unknown users are rejected and leave the user alone
+	_, err = repo.Transition(ctx, invited.UUID, domain.StatusChange{Status: domain.UserStatusSuspended, Reason: "spam"})
+	assert.ErrorIs(t, err, domain.ErrIllegalTransition)
+	_, err = repo.Transition(ctx, u.UUID, domain.StatusChange{Status: domain.UserStatusDeactivated})
+	assert.Error(t, err)
+	_, err = repo.Transition(other, u.UUID, domain.StatusChange{Status: domain.UserStatusActive})
+	assert.ErrorIs(t, err, domain.ErrNotFound)
+	_, err = repo.Transition(ctx, uuid.New(), domain.StatusChange{Status: domain.UserStatusActive})
+	assert.ErrorIs(t, err, domain.ErrNotFound)
+
+	// Activating clears the reason of the suspension
+	got, err = repo.Transition(ctx, u.UUID, domain.StatusChange{Status: domain.UserStatusActive})
+	require.NoError(t, err)
+	assert.Equal(t, domain.UserStatusActive, got.Status)
+	assert.Empty(t, got.StatusReason)
+
+	// Deactivated is final
+	_, err = repo.Transition(ctx, u.UUID, domain.StatusChange{Status: domain.UserStatusDeactivated, Reason: "closed"})
+	require.NoError(t, err)
+	_, err = repo.Transition(ctx, u.UUID, domain.StatusChange{Status: domain.UserStatusActive})
+	assert.ErrorIs(t, err, domain.ErrIllegalTransition)
+}
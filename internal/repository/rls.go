@@ -0,0 +1,176 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"go-project-template/internal/domain"
+	"os"
+	"strconv"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// setTenantQuery is SET LOCAL app.tenant_id with a parameter, the row-level
+// security policies of docker/provision/rls.sql compare tenant_id against it
+const setTenantQuery = "SELECT set_config('app.tenant_id', $1, true)"
+
+// rlsConnection runs every statement with a tenant in a transaction of its own
+// that sets app.tenant_id first, because the setting has to end with the
+// transaction to never leak into the next user of a pooled connection.
+// Statements without a tenant, like replica health checks, run as they are and
+// the policies show them no rows.
+type rlsConnection struct {
+	conn Connection
+}
+
+// NewRLSConnection returns a [Connection] that hands the tenant of the context
+// to the row-level security policies, as a second line of defense behind the
+// tenant condition of every query
+func NewRLSConnection(conn Connection) Connection {
+	return &rlsConnection{conn: conn}
+}
+
+// RLSConnectionFromEnv wraps conn in [NewRLSConnection] when
+// TENANT_ROW_LEVEL_SECURITY is set, for databases with the policies of
+// docker/provision/rls.sql. Otherwise conn is returned as it is.
+func RLSConnectionFromEnv(conn Connection) Connection {
+	if enabled, _ := strconv.ParseBool(os.Getenv("TENANT_ROW_LEVEL_SECURITY")); enabled {
+		return NewRLSConnection(conn)
+	}
+	return conn
+}
+
+// begin starts a transaction scoped to tenant
+func (c *rlsConnection) begin(ctx context.Context, tenant string) (pgx.Tx, error) {
+	tx, err := c.conn.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := tx.Exec(ctx, setTenantQuery, tenant); err != nil {
+		_ = tx.Rollback(ctx)
+		return nil, err
+	}
+	return tx, nil
+}
+
+func (c *rlsConnection) Exec(ctx context.Context, sql string, args ...interface{}) (pgconn.CommandTag, error) {
+	tenant, err := domain.TenantFromContext(ctx)
+	if err != nil {
+		return c.conn.Exec(ctx, sql, args...)
+	}
+
+	tx, err := c.begin(ctx, tenant)
+	if err != nil {
+		return pgconn.CommandTag{}, err
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	tag, err := tx.Exec(ctx, sql, args...)
+	if err != nil {
+		return tag, err
+	}
+	return tag, tx.Commit(ctx)
+}
+
+func (c *rlsConnection) Query(ctx context.Context, sql string, args ...interface{}) (pgx.Rows, error) {
+	tenant, err := domain.TenantFromContext(ctx)
+	if err != nil {
+		return c.conn.Query(ctx, sql, args...)
+	}
+
+	tx, err := c.begin(ctx, tenant)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := tx.Query(ctx, sql, args...)
+	if err != nil {
+		_ = tx.Rollback(ctx)
+		return nil, err
+	}
+	return &rlsRows{Rows: rows, ctx: ctx, tx: tx}, nil
+}
+
+func (c *rlsConnection) QueryRow(ctx context.Context, sql string, args ...interface{}) pgx.Row {
+	tenant, err := domain.TenantFromContext(ctx)
+	if err != nil {
+		return c.conn.QueryRow(ctx, sql, args...)
+	}
+
+	tx, err := c.begin(ctx, tenant)
+	if err != nil {
+		return errRow{err: err}
+	}
+	return &rlsRow{row: tx.QueryRow(ctx, sql, args...), ctx: ctx, tx: tx}
+}
+
+// Begin returns a transaction scoped to the tenant of ctx
+func (c *rlsConnection) Begin(ctx context.Context) (pgx.Tx, error) {
+	tenant, err := domain.TenantFromContext(ctx)
+	if err != nil {
+		return c.conn.Begin(ctx)
+	}
+	return c.begin(ctx, tenant)
+}
+
+// rlsRows ends the transaction of a query once its rows are closed
+type rlsRows struct {
+	pgx.Rows
+	ctx  context.Context
+	tx   pgx.Tx
+	done bool
+}
+
+func (r *rlsRows) Close() {
+	r.Rows.Close()
+	if r.done {
+		return
+	}
+	r.done = true
+
+	if r.Rows.Err() != nil {
+		_ = r.tx.Rollback(r.ctx)
+		return
+	}
+	_ = r.tx.Commit(r.ctx)
+}
+
+// Next closes the rows after the last one, like pgx does, so the transaction
+// also ends for callers that never call Close
+func (r *rlsRows) Next() bool {
+	if r.Rows.Next() {
+		return true
+	}
+	r.Close()
+	return false
+}
+
+// rlsRow ends the transaction of a query once its row is scanned
+type rlsRow struct {
+	row pgx.Row
+	ctx context.Context
+	tx  pgx.Tx
+}
+
+func (r *rlsRow) Scan(dest ...interface{}) error {
+	err := r.row.Scan(dest...)
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		_ = r.tx.Rollback(r.ctx)
+		return err
+	}
+
+	if cerr := r.tx.Commit(r.ctx); cerr != nil {
+		return cerr
+	}
+	return err
+}
+
+// errRow reports an error that happened before the query was sent
+type errRow struct {
+	err error
+}
+
+func (r errRow) Scan(...interface{}) error {
+	return r.err
+}
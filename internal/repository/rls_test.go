@@ -0,0 +1,218 @@
+package repository_test
+
+import (
+	"context"
+	"fmt"
+	"go-project-template/internal/domain"
+	"go-project-template/internal/repository"
+	"go-project-template/internal/testhelper"
+	"os"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// recordingConn logs the statements it runs and the transactions around them
+type recordingConn struct {
+	log []string
+}
+
+func (c *recordingConn) record(sql string, args ...interface{}) {
+	if len(args) > 0 {
+		sql = fmt.Sprintf("%s %v", sql, args)
+	}
+	c.log = append(c.log, sql)
+}
+
+func (c *recordingConn) Exec(_ context.Context, sql string, args ...interface{}) (pgconn.CommandTag, error) {
+	c.record(sql, args...)
+	return pgconn.CommandTag{}, nil
+}
+
+func (c *recordingConn) Query(_ context.Context, sql string, args ...interface{}) (pgx.Rows, error) {
+	c.record(sql, args...)
+	return emptyRows{}, nil
+}
+
+func (c *recordingConn) QueryRow(_ context.Context, sql string, args ...interface{}) pgx.Row {
+	c.record(sql, args...)
+	return emptyRow{}
+}
+
+func (c *recordingConn) Begin(context.Context) (pgx.Tx, error) {
+	c.record("BEGIN")
+	return &recordingTx{conn: c}, nil
+}
+
+type recordingTx struct {
+	pgx.Tx
+	conn *recordingConn
+	done bool
+}
+
+func (tx *recordingTx) Exec(ctx context.Context, sql string, args ...interface{}) (pgconn.CommandTag, error) {
+	return tx.conn.Exec(ctx, sql, args...)
+}
+
+func (tx *recordingTx) Query(ctx context.Context, sql string, args ...interface{}) (pgx.Rows, error) {
+	return tx.conn.Query(ctx, sql, args...)
+}
+
+func (tx *recordingTx) QueryRow(ctx context.Context, sql string, args ...interface{}) pgx.Row {
+	return tx.conn.QueryRow(ctx, sql, args...)
+}
+
+func (tx *recordingTx) Commit(context.Context) error {
+	if !tx.done {
+		tx.done = true
+		tx.conn.record("COMMIT")
+	}
+	return nil
+}
+
+func (tx *recordingTx) Rollback(context.Context) error {
+	if !tx.done {
+		tx.done = true
+		tx.conn.record("ROLLBACK")
+	}
+	return nil
+}
+
+func TestRLSConnection(t *testing.T) {
+	t.Parallel()
+	setTenant := "SELECT set_config('app.tenant_id', $1, true) [acme]"
+
+	testCases := map[string]struct {
+		ctx  context.Context
+		run  func(ctx context.Context, conn repository.Connection)
+		want []string
+	}{
+		"exec": {tenantContext(), func(ctx context.Context, conn repository.Connection) {
+			_, _ = conn.Exec(ctx, "DELETE FROM users")
+		}, []string{"BEGIN", setTenant, "DELETE FROM users", "COMMIT"}},
+		"query row": {tenantContext(), func(ctx context.Context, conn repository.Connection) {
+			var n int
+			_ = conn.QueryRow(ctx, "SELECT count(*) FROM users").Scan(&n)
+		}, []string{"BEGIN", setTenant, "SELECT count(*) FROM users", "COMMIT"}},
+		"query row without a row": {tenantContext(), func(ctx context.Context, conn repository.Connection) {
+			var id uuid.UUID
+			_ = conn.QueryRow(ctx, "SELECT uuid FROM users").Scan(&id)
+		}, []string{"BEGIN", setTenant, "SELECT uuid FROM users", "COMMIT"}},
+		"query": {tenantContext(), func(ctx context.Context, conn repository.Connection) {
+			rows, _ := conn.Query(ctx, "SELECT uuid FROM users")
+			for rows.Next() {
+			}
+			rows.Close()
+		}, []string{"BEGIN", setTenant, "SELECT uuid FROM users", "COMMIT"}},
+		"transaction": {tenantContext(), func(ctx context.Context, conn repository.Connection) {
+			tx, _ := conn.Begin(ctx)
+			_, _ = tx.Exec(ctx, "DELETE FROM users")
+			_ = tx.Commit(ctx)
+		}, []string{"BEGIN", setTenant, "DELETE FROM users", "COMMIT"}},
+		// Health checks and other statements without a tenant run as they are
+		"no tenant": {context.Background(), func(ctx context.Context, conn repository.Connection) {
+			var n int
+			_ = conn.QueryRow(ctx, "SELECT 1").Scan(&n)
+		}, []string{"SELECT 1"}},
+	}
+
+	for scenario, tc := range testCases { //nolint:paralleltest
+		t.Run(scenario, func(t *testing.T) {
+			rec := &recordingConn{}
+			tc.run(tc.ctx, repository.NewRLSConnection(rec))
+			assert.Equal(t, tc.want, rec.log)
+		})
+	}
+}
+
+func TestRLSConnectionFromEnv(t *testing.T) { //nolint:paralleltest
+	testCases := map[string]struct {
+		env  string
+		want []string
+	}{
+		"enabled":  {"true", []string{"BEGIN", "SELECT set_config('app.tenant_id', $1, true) [acme]", "DELETE FROM users", "COMMIT"}},
+		"disabled": {"", []string{"DELETE FROM users"}},
+	}
+
+	for scenario, tc := range testCases { //nolint:paralleltest
+		t.Run(scenario, func(t *testing.T) {
+			t.Setenv("TENANT_ROW_LEVEL_SECURITY", tc.env)
+			rec := &recordingConn{}
+			_, _ = repository.RLSConnectionFromEnv(rec).Exec(tenantContext(), "DELETE FROM users")
+			assert.Equal(t, tc.want, rec.log)
+		})
+	}
+}
+
+// TestPostgresUser_RowLevelSecurity applies docker/provision/rls.sql and checks
+// that the policies hide other tenants even from queries without a tenant condition
+func TestPostgresUser_RowLevelSecurity(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	conn := testhelper.NewTestPgxConn(t)
+
+	policies, err := os.ReadFile("../../docker/provision/rls.sql")
+	require.NoError(t, err)
+	_, err = conn.Exec(ctx, string(policies))
+	require.NoError(t, err)
+
+	// Superusers bypass the policies, so the test runs as a role of its own
+	var schema string
+	require.NoError(t, conn.QueryRow(ctx, "SELECT current_schema()").Scan(&schema))
+	role := pgx.Identifier{schema + "_app"}.Sanitize()
+	schemaID := pgx.Identifier{schema}.Sanitize()
+	for _, stmt := range []string{
+		"CREATE ROLE " + role + " NOLOGIN",
+		"GRANT USAGE ON SCHEMA " + schemaID + " TO " + role,
+		"GRANT SELECT, INSERT, UPDATE, DELETE ON ALL TABLES IN SCHEMA " + schemaID + " TO " + role,
+		"GRANT USAGE ON ALL SEQUENCES IN SCHEMA " + schemaID + " TO " + role,
+		"SET ROLE " + role,
+	} {
+		_, err := conn.Exec(ctx, stmt)
+		require.NoError(t, err, stmt)
+	}
+	t.Cleanup(func() {
+		for _, stmt := range []string{"RESET ROLE", "DROP OWNED BY " + role, "DROP ROLE " + role} {
+			if _, err := conn.Exec(ctx, stmt); err != nil {
+				t.Logf("%s: %v", stmt, err)
+			}
+		}
+	})
+
+	db := repository.NewRLSConnection(conn)
+	repo := repository.NewUserRepository(db)
+	acme := domain.WithTenant(ctx, "acme")
+	globex := domain.WithTenant(ctx, "globex")
+
+	u := testhelper.NewUser()
+	_, err = repo.CreateOrUpdate(acme, u)
+	require.NoError(t, err)
+
+	_, err = repo.GetByID(globex, u.UUID)
+	assert.ErrorIs(t, err, domain.ErrNotFound)
+
+	taken := testhelper.NewUser(func(taken *domain.User) { taken.UUID = u.UUID })
+	_, err = repo.CreateOrUpdate(globex, taken)
+	assert.ErrorIs(t, err, domain.ErrConflict)
+
+	testCases := map[string]struct {
+		ctx  context.Context
+		want int
+	}{
+		"own tenant":   {acme, 1},
+		"other tenant": {globex, 0},
+		"no tenant":    {ctx, 0},
+	}
+
+	for scenario, tc := range testCases { //nolint:paralleltest
+		t.Run(scenario, func(t *testing.T) {
+			var n int
+			require.NoError(t, db.QueryRow(tc.ctx, "SELECT count(*) FROM users").Scan(&n))
+			assert.Equal(t, tc.want, n)
+		})
+	}
+}
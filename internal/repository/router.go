@@ -0,0 +1,283 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"go.uber.org/zap"
+)
+
+// replicaLagQuery returns how far a replica is behind in seconds, or NULL when
+// its WAL receiver is not streaming: a replica cut off from the primary has
+// replayed everything it received and would otherwise look up to date. A
+// streaming replica that replayed everything is not lagging, however old its
+// last transaction is, and a server that is not in recovery never lags.
+const replicaLagQuery = `
+	SELECT CASE
+		WHEN NOT pg_is_in_recovery() THEN 0
+		WHEN NOT EXISTS (SELECT 1 FROM pg_stat_wal_receiver WHERE status = 'streaming') THEN NULL
+		WHEN pg_last_wal_receive_lsn() = pg_last_wal_replay_lsn() THEN 0
+		ELSE COALESCE(EXTRACT(EPOCH FROM now() - pg_last_xact_replay_timestamp()), 0)
+	END::float8`
+
+// errNotStreaming marks a replica that is not receiving WAL from the primary
+var errNotStreaming = errors.New("WAL receiver is not streaming")
+
+type RouterConfig struct {
+	// HealthCheckInterval is the time between two lag checks of every replica
+	HealthCheckInterval time.Duration
+	// MaxLag is the replication lag beyond which a replica stops serving reads
+	MaxLag time.Duration
+	// Sticky is how long the reads of a session stay on the primary after it wrote
+	Sticky time.Duration
+}
+
+func DefaultRouterConfig() RouterConfig {
+	return RouterConfig{
+		HealthCheckInterval: 5 * time.Second,
+		MaxLag:              5 * time.Second,
+		Sticky:              10 * time.Second,
+	}
+}
+
+type replica struct {
+	name    string
+	conn    Connection
+	healthy atomic.Bool
+}
+
+// Router is a [Connection] that sends writes to the primary and spreads reads
+// marked by the repositories over the healthy replicas. Replicas start out
+// unhealthy, so every statement goes to the primary until [Router.Run] or
+// [Router.CheckReplicas] found a replica within the lag threshold. Reads of a
+// [Session] that wrote within the sticky window stay on the primary.
+type Router struct {
+	primary  Connection
+	replicas []*replica
+	logger   *zap.Logger
+	cfg      RouterConfig
+
+	next atomic.Uint64
+}
+
+func NewRouter(primary Connection, replicas []Connection, logger *zap.Logger, cfg RouterConfig) *Router {
+	r := &Router{primary: primary, logger: logger, cfg: cfg}
+	for i, conn := range replicas {
+		r.replicas = append(r.replicas, &replica{name: fmt.Sprintf("replica-%d", i), conn: conn})
+	}
+	return r
+}
+
+// Run checks the replicas every HealthCheckInterval until ctx is done
+func (r *Router) Run(ctx context.Context) {
+	if len(r.replicas) == 0 {
+		return
+	}
+
+	ticker := time.NewTicker(r.cfg.HealthCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		r.CheckReplicas(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// CheckReplicas measures the lag of every replica and updates which of them serve reads
+func (r *Router) CheckReplicas(ctx context.Context) {
+	var wg sync.WaitGroup
+	for _, rep := range r.replicas {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			r.check(ctx, rep)
+		}()
+	}
+	wg.Wait()
+}
+
+func (r *Router) check(ctx context.Context, rep *replica) {
+	ctx, cancel := context.WithTimeout(ctx, r.cfg.HealthCheckInterval)
+	defer cancel()
+
+	var seconds *float64
+	err := rep.conn.QueryRow(ctx, replicaLagQuery).Scan(&seconds)
+	if err == nil && seconds == nil {
+		err = errNotStreaming
+	}
+	var lag time.Duration
+	if err == nil {
+		lag = time.Duration(*seconds * float64(time.Second))
+	}
+	healthy := err == nil && lag <= r.cfg.MaxLag
+
+	if rep.healthy.Swap(healthy) == healthy {
+		return
+	}
+	if healthy {
+		r.logger.Info("replica serves reads", zap.String("replica", rep.name), zap.Duration("lag", lag))
+		return
+	}
+	if err != nil {
+		r.logger.Warn("replica health check failed, reading from the primary", zap.String("replica", rep.name), zap.Error(err))
+		return
+	}
+	r.logger.Warn("replica lags behind, reading from the primary", zap.String("replica", rep.name), zap.Duration("lag", lag), zap.Duration("max#lag", r.cfg.MaxLag))
+}
+
+// reader returns the connection for a statement, the next healthy replica in
+// turn for reads, the pinned one if ctx has a pin, and the primary for
+// everything else
+func (r *Router) reader(ctx context.Context) Connection {
+	mode, _ := ctx.Value(readModeKey{}).(readMode)
+	switch mode {
+	case readPrimary:
+		return r.primary
+	case readReplica:
+	default:
+		r.wrote(ctx)
+		return r.primary
+	}
+
+	if s := sessionFromContext(ctx); s != nil && time.Now().Before(s.PrimaryUntil()) {
+		return r.primary
+	}
+
+	if p, ok := ctx.Value(pinKey{}).(*pin); ok {
+		p.mu.Lock()
+		defer p.mu.Unlock()
+		if p.conn == nil {
+			p.conn = r.pick()
+		}
+		return p.conn
+	}
+	return r.pick()
+}
+
+// pick returns the next healthy replica in turn, or the primary if there is none
+func (r *Router) pick() Connection {
+	n := uint64(len(r.replicas))
+	start := r.next.Add(1)
+	for i := uint64(0); i < n; i++ {
+		if rep := r.replicas[(start+i)%n]; rep.healthy.Load() {
+			return rep.conn
+		}
+	}
+	return r.primary
+}
+
+// wrote keeps the reads of the session on the primary until replicas caught up
+func (r *Router) wrote(ctx context.Context) {
+	if s := sessionFromContext(ctx); s != nil {
+		s.extend(time.Now().Add(r.cfg.Sticky))
+	}
+}
+
+func (r *Router) Exec(ctx context.Context, sql string, args ...interface{}) (pgconn.CommandTag, error) {
+	return r.reader(ctx).Exec(ctx, sql, args...)
+}
+
+func (r *Router) Query(ctx context.Context, sql string, args ...interface{}) (pgx.Rows, error) {
+	return r.reader(ctx).Query(ctx, sql, args...)
+}
+
+func (r *Router) QueryRow(ctx context.Context, sql string, args ...interface{}) pgx.Row {
+	return r.reader(ctx).QueryRow(ctx, sql, args...)
+}
+
+// Begin always starts the transaction on the primary
+func (r *Router) Begin(ctx context.Context) (pgx.Tx, error) {
+	r.wrote(ctx)
+	return r.primary.Begin(ctx)
+}
+
+// pin holds the connection chosen for the first read of a context
+type pin struct {
+	mu   sync.Mutex
+	conn Connection
+}
+
+type pinKey struct{}
+
+// pinReplica makes the reads run with ctx go to the same server, so statements
+// that belong together, like a count and its page, see the same replica
+func pinReplica(ctx context.Context) context.Context {
+	return context.WithValue(ctx, pinKey{}, &pin{})
+}
+
+// readMode tells the [Router] where the statements of a context may run,
+// statements without one are treated as writes
+type readMode int
+
+const (
+	readPrimary readMode = iota + 1
+	readReplica
+)
+
+type readModeKey struct{}
+
+// readFromReplica marks the statements run with ctx as reads that a replica may serve
+func readFromReplica(ctx context.Context) context.Context {
+	if _, ok := ctx.Value(readModeKey{}).(readMode); ok {
+		return ctx
+	}
+	return context.WithValue(ctx, readModeKey{}, readReplica)
+}
+
+// WithPrimary sends the reads run with ctx to the primary. Callers that keep
+// what they read, like caches, use it so they never store a stale row.
+func WithPrimary(ctx context.Context) context.Context {
+	return context.WithValue(ctx, readModeKey{}, readPrimary)
+}
+
+// Session gives a request, or a client across requests, read-your-writes
+// consistency: once it wrote through a [Router], its reads go to the primary
+// until the sticky window has passed.
+type Session struct {
+	mu           sync.Mutex
+	primaryUntil time.Time
+}
+
+// NewSession returns a session whose reads stay on the primary until primaryUntil,
+// which is zero for a session that has not written yet
+func NewSession(primaryUntil time.Time) *Session {
+	return &Session{primaryUntil: primaryUntil}
+}
+
+// PrimaryUntil returns the end of the sticky window of the session
+func (s *Session) PrimaryUntil() time.Time {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.primaryUntil
+}
+
+func (s *Session) extend(until time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if until.After(s.primaryUntil) {
+		s.primaryUntil = until
+	}
+}
+
+type sessionKey struct{}
+
+// WithSession attaches s to ctx, the statements run with ctx read the writes of s
+func WithSession(ctx context.Context, s *Session) context.Context {
+	return context.WithValue(ctx, sessionKey{}, s)
+}
+
+func sessionFromContext(ctx context.Context) *Session {
+	s, _ := ctx.Value(sessionKey{}).(*Session)
+	return s
+}
@@ -0,0 +1,276 @@
+package repository_test
+
+import (
+	"context"
+	"errors"
+	"go-project-template/internal/domain"
+	"go-project-template/internal/repository"
+	"go-project-template/internal/utils"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+// fakeConn counts the statements it runs and reports a configurable replication
+// lag, a negative lag stands for a WAL receiver that is not streaming. Counts
+// return count and every other query comes back empty.
+type fakeConn struct {
+	mu         sync.Mutex
+	statements int
+	lag        time.Duration
+	err        error
+	count      int
+}
+
+func (f *fakeConn) setLag(lag time.Duration, err error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.lag, f.err = lag, err
+}
+
+// ran returns the number of statements run since the last call
+func (f *fakeConn) ran() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	n := f.statements
+	f.statements = 0
+	return n
+}
+
+func (f *fakeConn) record() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.statements++
+}
+
+func (f *fakeConn) Exec(context.Context, string, ...interface{}) (pgconn.CommandTag, error) {
+	f.record()
+	return pgconn.CommandTag{}, nil
+}
+
+func (f *fakeConn) Query(context.Context, string, ...interface{}) (pgx.Rows, error) {
+	f.record()
+	return emptyRows{}, nil
+}
+
+func (f *fakeConn) QueryRow(_ context.Context, sql string, _ ...interface{}) pgx.Row {
+	if strings.Contains(sql, "pg_is_in_recovery") {
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		return lagRow{lag: f.lag, err: f.err}
+	}
+	f.record()
+	return emptyRow{count: f.count}
+}
+
+func (f *fakeConn) Begin(context.Context) (pgx.Tx, error) {
+	f.record()
+	return nil, errors.New("transactions are not supported")
+}
+
+type lagRow struct {
+	lag time.Duration
+	err error
+}
+
+func (r lagRow) Scan(dest ...interface{}) error {
+	if r.err != nil {
+		return r.err
+	}
+	seconds := dest[0].(**float64)
+	if r.lag < 0 {
+		*seconds = nil
+		return nil
+	}
+	s := r.lag.Seconds()
+	*seconds = &s
+	return nil
+}
+
+// emptyRow returns its count to a count query and finds no row otherwise
+type emptyRow struct {
+	count int
+}
+
+func (r emptyRow) Scan(dest ...interface{}) error {
+	if count, ok := dest[0].(*int); ok {
+		*count = r.count
+		return nil
+	}
+	return pgx.ErrNoRows
+}
+
+type emptyRows struct{}
+
+func (emptyRows) Close()                                       {}
+func (emptyRows) Err() error                                   { return nil }
+func (emptyRows) CommandTag() pgconn.CommandTag                { return pgconn.CommandTag{} }
+func (emptyRows) FieldDescriptions() []pgconn.FieldDescription { return nil }
+func (emptyRows) Next() bool                                   { return false }
+func (emptyRows) Scan(...interface{}) error                    { return pgx.ErrNoRows }
+func (emptyRows) Values() ([]interface{}, error)               { return nil, nil }
+func (emptyRows) RawValues() [][]byte                          { return nil }
+func (emptyRows) Conn() *pgx.Conn                              { return nil }
+
+func newTestRouter(replicas int) (*repository.Router, *fakeConn, []*fakeConn) {
+	primary := &fakeConn{}
+	fakes := make([]*fakeConn, replicas)
+	conns := make([]repository.Connection, replicas)
+	for i := range fakes {
+		fakes[i] = &fakeConn{}
+		conns[i] = fakes[i]
+	}
+	return repository.NewRouter(primary, conns, zap.NewNop(), repository.DefaultRouterConfig()), primary, fakes
+}
+
+func TestRouter_Routing(t *testing.T) {
+	t.Parallel()
+	ctx := tenantContext()
+	router, primary, replicas := newTestRouter(2)
+	repo := repository.NewUserRepository(router)
+
+	// Replicas serve nothing until they were checked
+	_, err := repo.GetByID(ctx, uuid.New())
+	require.ErrorIs(t, err, domain.ErrNotFound)
+	assert.Equal(t, 1, primary.ran())
+
+	router.CheckReplicas(ctx)
+
+	testCases := map[string]struct {
+		call    func() error
+		primary int
+		replica int
+	}{
+		"get by id": {func() error { _, err := repo.GetByID(ctx, uuid.New()); return err }, 0, 1},
+		"list": {func() error {
+			_, err := repo.GetList(ctx, &utils.PaginationQuery{Page: 1, Size: 10}, domain.UserFilter{})
+			return err
+		}, 0, 1},
+		"stream": {func() error { return repo.Stream(ctx, domain.UserFilter{}, func(*domain.User) error { return nil }) }, 0, 1},
+		"delete": {func() error { return repo.Delete(ctx, uuid.New()) }, 1, 0},
+		"primary read": {func() error {
+			_, err := repo.GetByID(repository.WithPrimary(ctx), uuid.New())
+			return err
+		}, 1, 0},
+	}
+
+	for scenario, tc := range testCases { //nolint:paralleltest
+		t.Run(scenario, func(t *testing.T) {
+			err := tc.call()
+			if err != nil {
+				require.ErrorIs(t, err, domain.ErrNotFound)
+			}
+
+			assert.Equal(t, tc.primary, primary.ran())
+			assert.Equal(t, tc.replica, replicas[0].ran()+replicas[1].ran())
+		})
+	}
+
+	// Reads take turns on the replicas
+	for range 4 {
+		_, _ = repo.GetByID(ctx, uuid.New())
+	}
+	assert.Equal(t, 2, replicas[0].ran())
+	assert.Equal(t, 2, replicas[1].ran())
+}
+
+func TestRouter_Health(t *testing.T) {
+	t.Parallel()
+	ctx := tenantContext()
+	router, primary, replicas := newTestRouter(2)
+	repo := repository.NewUserRepository(router)
+
+	read := func() {
+		_, err := repo.GetByID(ctx, uuid.New())
+		require.ErrorIs(t, err, domain.ErrNotFound)
+	}
+
+	testCases := []struct {
+		scenario string
+		lags     []time.Duration
+		errs     []error
+		// want is the number of reads out of four served by the primary and each replica
+		want []int
+	}{
+		{"healthy", []time.Duration{0, time.Second}, []error{nil, nil}, []int{0, 2, 2}},
+		{"lagging", []time.Duration{10 * time.Second, time.Second}, []error{nil, nil}, []int{0, 0, 4}},
+		{"unreachable", []time.Duration{0, 0}, []error{nil, errors.New("connection refused")}, []int{0, 4, 0}},
+		{"none left", []time.Duration{time.Minute, 0}, []error{nil, errors.New("connection refused")}, []int{4, 0, 0}},
+		{"not streaming", []time.Duration{-1, 0}, []error{nil, nil}, []int{0, 0, 4}},
+		{"recovered", []time.Duration{0, 0}, []error{nil, nil}, []int{0, 2, 2}},
+	}
+
+	// The cases run in order, replicas recover once their lag is back under the threshold
+	for _, tc := range testCases { //nolint:paralleltest
+		t.Run(tc.scenario, func(t *testing.T) {
+			for i, replica := range replicas {
+				replica.setLag(tc.lags[i], tc.errs[i])
+			}
+			router.CheckReplicas(ctx)
+
+			for range 4 {
+				read()
+			}
+			assert.Equal(t, tc.want, []int{primary.ran(), replicas[0].ran(), replicas[1].ran()})
+		})
+	}
+}
+
+func TestRouter_PinnedList(t *testing.T) {
+	t.Parallel()
+	ctx := tenantContext()
+	router, primary, replicas := newTestRouter(2)
+	repo := repository.NewUserRepository(router)
+	for _, replica := range replicas {
+		replica.count = 5
+	}
+	router.CheckReplicas(ctx)
+
+	// The count and the page of a list come from the same replica
+	for range 4 {
+		_, err := repo.GetList(ctx, &utils.PaginationQuery{Page: 1, Size: 10}, domain.UserFilter{})
+		require.NoError(t, err)
+
+		ran := []int{replicas[0].ran(), replicas[1].ran()}
+		assert.ElementsMatch(t, []int{0, 2}, ran)
+	}
+	assert.Equal(t, 0, primary.ran())
+}
+
+func TestRouter_ReadYourWrites(t *testing.T) {
+	t.Parallel()
+	router, primary, replicas := newTestRouter(1)
+	repo := repository.NewUserRepository(router)
+	router.CheckReplicas(context.Background())
+
+	session := repository.NewSession(time.Time{})
+	ctx := repository.WithSession(tenantContext(), session)
+
+	// Before writing, the session reads from the replica
+	_, _ = repo.GetByID(ctx, uuid.New())
+	assert.Equal(t, 1, replicas[0].ran())
+	assert.True(t, session.PrimaryUntil().IsZero())
+
+	require.NoError(t, repo.Delete(ctx, uuid.New()))
+	assert.Equal(t, 1, primary.ran())
+	assert.WithinDuration(t, time.Now().Add(repository.DefaultRouterConfig().Sticky), session.PrimaryUntil(), time.Second)
+
+	// Its reads then stay on the primary, other sessions keep using the replica
+	_, _ = repo.GetByID(ctx, uuid.New())
+	assert.Equal(t, 1, primary.ran())
+	_, _ = repo.GetByID(tenantContext(), uuid.New())
+	assert.Equal(t, 1, replicas[0].ran())
+
+	// A session restored after its sticky window reads from the replica again
+	expired := repository.WithSession(tenantContext(), repository.NewSession(time.Now().Add(-time.Second)))
+	_, _ = repo.GetByID(expired, uuid.New())
+	assert.Equal(t, 1, replicas[0].ran())
+}
@@ -0,0 +1,38 @@
+package repository
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// begin starts a transaction on conn. When ctx has a deadline, the statements of
+// the transaction get what is left of it as statement_timeout, so the server
+// gives up on them too instead of only the client.
+func begin(ctx context.Context, conn Connection) (pgx.Tx, error) {
+	tx, err := conn.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		return tx, nil
+	}
+
+	budget := time.Until(deadline)
+	if budget <= 0 {
+		_ = tx.Rollback(ctx)
+		return nil, context.DeadlineExceeded
+	}
+
+	// set_config with is_local is SET LOCAL with a parameter, it ends with the transaction
+	timeout := strconv.FormatInt(max(budget.Milliseconds(), 1), 10)
+	if _, err := tx.Exec(ctx, "SELECT set_config('statement_timeout', $1, true)", timeout); err != nil {
+		_ = tx.Rollback(ctx)
+		return nil, err
+	}
+	return tx, nil
+}
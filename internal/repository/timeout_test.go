@@ -0,0 +1,79 @@
+package repository_test
+
+import (
+	"context"
+	"errors"
+	"go-project-template/internal/domain"
+	"go-project-template/internal/repository"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var errStopped = errors.New("stopped after the statement timeout")
+
+// timeoutConn hands out transactions that record the first statement and fail it
+type timeoutConn struct {
+	fakeConn
+	sql  string
+	args []interface{}
+}
+
+func (c *timeoutConn) Begin(context.Context) (pgx.Tx, error) {
+	return &timeoutTx{conn: c}, nil
+}
+
+type timeoutTx struct {
+	pgx.Tx
+	conn *timeoutConn
+}
+
+func (tx *timeoutTx) Exec(_ context.Context, sql string, args ...interface{}) (pgconn.CommandTag, error) {
+	tx.conn.sql, tx.conn.args = sql, args
+	return pgconn.CommandTag{}, errStopped
+}
+
+func (tx *timeoutTx) Rollback(context.Context) error {
+	return nil
+}
+
+func TestStatementTimeout(t *testing.T) {
+	t.Parallel()
+
+	batch := func() []*domain.User {
+		email := "john@mail.com"
+		return []*domain.User{{UUID: uuid.New(), FirstName: "John", LastName: "Wick", Email: &email}}
+	}
+
+	t.Run("budget", func(t *testing.T) {
+		conn := &timeoutConn{}
+		ctx, cancel := context.WithTimeout(tenantContext(), 2*time.Second)
+		defer cancel()
+
+		_, err := repository.NewUserRepository(conn).BatchCreateOrUpdate(ctx, batch(), domain.BatchModeAtomic)
+		require.ErrorIs(t, err, errStopped)
+
+		// The transaction gets what is left of the deadline
+		assert.Equal(t, "SELECT set_config('statement_timeout', $1, true)", conn.sql)
+		require.Len(t, conn.args, 1)
+		ms, err := strconv.Atoi(conn.args[0].(string))
+		require.NoError(t, err)
+		assert.InDelta(t, 2000, ms, 500)
+	})
+
+	t.Run("expired", func(t *testing.T) {
+		conn := &timeoutConn{}
+		ctx, cancel := context.WithDeadline(tenantContext(), time.Now().Add(-time.Second))
+		defer cancel()
+
+		_, err := repository.NewUserRepository(conn).BatchCreateOrUpdate(ctx, batch(), domain.BatchModeAtomic)
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+		assert.Empty(t, conn.sql)
+	})
+}
@@ -0,0 +1,182 @@
+// Package scheduler enqueues jobs for periodic tasks on a single elected replica.
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"go-project-template/internal/domain"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// Lock elects the leader among scheduler replicas
+type Lock interface {
+	TryLock(ctx context.Context) (bool, error)
+	Held(ctx context.Context) bool
+	Unlock(ctx context.Context) error
+}
+
+type Config struct {
+	// Tick is how often the leader checks for due tasks
+	Tick time.Duration
+	// ElectionInterval is how often a follower tries to become leader
+	ElectionInterval time.Duration
+}
+
+func DefaultConfig() Config {
+	return Config{
+		Tick:             1 * time.Second,
+		ElectionInterval: 10 * time.Second,
+	}
+}
+
+type Scheduler struct {
+	store  domain.ScheduleRepository
+	lock   Lock
+	logger *zap.Logger
+	cfg    Config
+	tasks  []*Task
+}
+
+func New(store domain.ScheduleRepository, lock Lock, logger *zap.Logger, cfg Config, tasks []*Task) (*Scheduler, error) {
+	seen := map[string]bool{}
+	for _, t := range tasks {
+		if seen[t.Name] {
+			return nil, fmt.Errorf("duplicate task name %s", t.Name)
+		}
+		seen[t.Name] = true
+	}
+
+	return &Scheduler{
+		store:  store,
+		lock:   lock,
+		logger: logger,
+		cfg:    cfg,
+		tasks:  tasks,
+	}, nil
+}
+
+// Run campaigns for leadership and fires tasks while leading, until ctx is done
+func (s *Scheduler) Run(ctx context.Context) error {
+	for {
+		leading, err := s.lock.TryLock(ctx)
+		if err != nil && ctx.Err() == nil {
+			s.logger.Error("leader election failed", zap.Error(err))
+		}
+
+		if leading {
+			s.logger.Info("acquired scheduler leadership")
+			err := s.lead(ctx)
+			if uerr := s.lock.Unlock(context.WithoutCancel(ctx)); uerr != nil {
+				s.logger.Error("failed to release scheduler leadership", zap.Error(uerr))
+			}
+			if err != nil {
+				s.logger.Error("lost scheduler leadership", zap.Error(err))
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(s.cfg.ElectionInterval):
+		}
+	}
+}
+
+type taskState struct {
+	task *Task
+	last time.Time
+	due  time.Time
+}
+
+var errLostLeadership = errors.New("leader lock connection lost")
+
+func (s *Scheduler) lead(ctx context.Context) error {
+	lastRuns, err := s.store.LastRuns(ctx)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	states := make([]*taskState, 0, len(s.tasks))
+	for _, t := range s.tasks {
+		st := &taskState{task: t, last: lastRuns[t.Name]}
+		// A task that never ran starts counting from now instead of firing right away
+		if st.last.IsZero() {
+			st.last = now
+		}
+		st.due = t.NextRun(st.last, now).Add(t.jitter())
+		states = append(states, st)
+	}
+
+	ticker := time.NewTicker(s.cfg.Tick)
+	defer ticker.Stop()
+
+	for {
+		// Catch up immediately after election, then on every tick
+		s.tick(ctx, states, time.Now())
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+
+		if !s.lock.Held(ctx) {
+			return errLostLeadership
+		}
+	}
+}
+
+func (s *Scheduler) tick(ctx context.Context, states []*taskState, now time.Time) {
+	for _, st := range states {
+		if now.Before(st.due) {
+			continue
+		}
+
+		// A failing task is retried on the next tick, the others still fire
+		if err := s.runDue(ctx, st, now); err != nil {
+			s.logger.Error("failed to fire task", zap.String("task", st.task.Name), zap.Error(err))
+		}
+	}
+}
+
+// runDue fires the runs of a due task and schedules its next run. It stops at
+// the first run that fails, runs fired before it are not fired again.
+func (s *Scheduler) runDue(ctx context.Context, st *taskState, now time.Time) error {
+	runs, latest := st.task.DueRuns(st.last, now)
+	if skipped := len(runs) == 0 && latest.After(st.last); skipped {
+		s.logger.Warn("skipped missed task run", zap.String("task", st.task.Name), zap.Time("scheduled_at", latest))
+	}
+
+	for _, at := range runs {
+		if err := s.fire(ctx, st.task, at); err != nil {
+			return fmt.Errorf("run scheduled at %s: %w", at.Format(time.RFC3339), err)
+		}
+	}
+
+	st.last = latest
+	st.due = st.task.NextRun(st.last, now).Add(st.task.jitter())
+	return nil
+}
+
+func (s *Scheduler) fire(ctx context.Context, t *Task, scheduledAt time.Time) error {
+	j, err := t.NewJob(scheduledAt)
+	if err != nil {
+		return err
+	}
+
+	err = s.store.RecordRun(ctx, t.Name, scheduledAt, j)
+	if errors.Is(err, domain.ErrConflict) {
+		s.logger.Warn("task run already recorded", zap.String("task", t.Name), zap.Time("scheduled_at", scheduledAt))
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	s.logger.Info("fired task", zap.String("task", t.Name), zap.Time("scheduled_at", scheduledAt), zap.String("job#id", j.ID.String()))
+	return nil
+}
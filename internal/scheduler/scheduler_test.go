@@ -0,0 +1,209 @@
+package scheduler_test
+
+import (
+	"context"
+	"errors"
+	"go-project-template/internal/domain"
+	"go-project-template/internal/scheduler"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestParseSchedule(t *testing.T) {
+	t.Parallel()
+
+	base := time.Date(2024, 5, 1, 10, 7, 30, 0, time.UTC)
+
+	testCases := map[string]struct {
+		spec string
+		want time.Time
+		err  bool
+	}{
+		"cron":       {"0 3 * * *", time.Date(2024, 5, 2, 3, 0, 0, 0, time.UTC), false},
+		"descriptor": {"@hourly", time.Date(2024, 5, 1, 11, 0, 0, 0, time.UTC), false},
+		"interval":   {"@every 15m", time.Date(2024, 5, 1, 10, 15, 0, 0, time.UTC), false},
+		"too short":  {"@every 10ms", time.Time{}, true},
+		"invalid":    {"every day", time.Time{}, true},
+	}
+
+	for scenario, tc := range testCases { //nolint:paralleltest
+		t.Run(scenario, func(t *testing.T) {
+			s, err := scheduler.ParseSchedule(tc.spec)
+			if tc.err {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.want, s.Next(base).UTC())
+		})
+	}
+}
+
+func TestTask_DueRuns(t *testing.T) {
+	t.Parallel()
+
+	now := time.Date(2024, 5, 1, 10, 0, 10, 0, time.UTC)
+	late := now.Add(30 * time.Minute)
+
+	testCases := map[string]struct {
+		spec   string
+		policy scheduler.CatchUpPolicy
+		last   time.Time
+		now    time.Time
+		want   int
+	}{
+		"on time":          {"@every 1m", scheduler.CatchUpSkip, now.Add(-time.Minute), now, 1},
+		"nothing due":      {"@every 1m", scheduler.CatchUpAll, now.Add(-5 * time.Second), now, 0},
+		"skip older":       {"@every 1m", scheduler.CatchUpSkip, now.Add(-time.Hour), now, 1},
+		"skip after grace": {"@hourly", scheduler.CatchUpSkip, now.Add(-2 * time.Hour), late, 0},
+		"once for missed":  {"@hourly", scheduler.CatchUpOnce, now.Add(-5 * time.Hour), late, 1},
+		"all missed":       {"@every 1m", scheduler.CatchUpAll, now.Add(-5 * time.Minute), now, 5},
+		"all capped":       {"@every 1m", scheduler.CatchUpAll, now.Add(-48 * time.Hour), now, 100},
+	}
+
+	for scenario, tc := range testCases { //nolint:paralleltest
+		t.Run(scenario, func(t *testing.T) {
+			task, err := scheduler.NewTask("test", tc.spec, "test.job", nil)
+			require.NoError(t, err)
+			task.CatchUp = tc.policy
+
+			runs, latest := task.DueRuns(tc.last.Truncate(time.Minute), tc.now)
+			assert.Len(t, runs, tc.want)
+			assert.False(t, latest.After(tc.now))
+			for _, at := range runs {
+				assert.True(t, at.After(tc.last.Truncate(time.Minute)))
+			}
+		})
+	}
+}
+
+type fakeScheduleRepository struct {
+	mu       sync.Mutex
+	lastRuns map[string]time.Time
+	jobs     []*domain.Job
+	// failing tasks cannot record their runs
+	failing map[string]bool
+}
+
+func (f *fakeScheduleRepository) LastRuns(_ context.Context) (map[string]time.Time, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	runs := map[string]time.Time{}
+	for k, v := range f.lastRuns {
+		runs[k] = v
+	}
+	return runs, nil
+}
+
+func (f *fakeScheduleRepository) RecordRun(_ context.Context, task string, scheduledAt time.Time, j *domain.Job) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.failing[task] {
+		return errors.New("connection reset")
+	}
+	if !scheduledAt.After(f.lastRuns[task]) {
+		return domain.ErrConflict
+	}
+	f.lastRuns[task] = scheduledAt
+	f.jobs = append(f.jobs, j)
+	return nil
+}
+
+func (f *fakeScheduleRepository) jobCount() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return len(f.jobs)
+}
+
+type fakeLock struct {
+	free bool
+}
+
+func (l *fakeLock) TryLock(_ context.Context) (bool, error) { return l.free, nil }
+func (l *fakeLock) Held(_ context.Context) bool             { return l.free }
+func (l *fakeLock) Unlock(_ context.Context) error          { return nil }
+
+func runScheduler(t *testing.T, store *fakeScheduleRepository, lock scheduler.Lock, tasks ...*scheduler.Task) {
+	t.Helper()
+
+	cfg := scheduler.Config{Tick: 10 * time.Millisecond, ElectionInterval: 10 * time.Millisecond}
+	s, err := scheduler.New(store, lock, zap.NewNop(), cfg, tasks)
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	require.NoError(t, s.Run(ctx))
+}
+
+func TestScheduler_CatchUp(t *testing.T) {
+	t.Parallel()
+
+	task, err := scheduler.NewTask("catch-up", "@every 1h", "test.job", map[string]string{"k": "v"})
+	require.NoError(t, err)
+	task.CatchUp = scheduler.CatchUpAll
+
+	last := time.Now().Truncate(time.Hour).Add(-3 * time.Hour)
+	store := &fakeScheduleRepository{lastRuns: map[string]time.Time{"catch-up": last}}
+
+	runScheduler(t, store, &fakeLock{free: true}, task)
+
+	require.Equal(t, 3, store.jobCount())
+	for i, j := range store.jobs {
+		assert.Equal(t, "test.job", j.Type)
+		assert.Equal(t, last.Add(time.Duration(i+1)*time.Hour), j.RunAt)
+	}
+}
+
+func TestScheduler_FailingTask(t *testing.T) {
+	t.Parallel()
+
+	failing, err := scheduler.NewTask("failing", "@every 1h", "test.job", nil)
+	require.NoError(t, err)
+	healthy, err := scheduler.NewTask("healthy", "@every 1h", "test.job", nil)
+	require.NoError(t, err)
+
+	last := time.Now().Truncate(time.Hour).Add(-time.Hour)
+	store := &fakeScheduleRepository{
+		lastRuns: map[string]time.Time{"failing": last, "healthy": last},
+		failing:  map[string]bool{"failing": true},
+	}
+
+	// Both are due, the first one failing does not hold back the second
+	runScheduler(t, store, &fakeLock{free: true}, failing, healthy)
+
+	require.Equal(t, 1, store.jobCount())
+	assert.Equal(t, last.Add(time.Hour), store.lastRuns["healthy"])
+	assert.Equal(t, last, store.lastRuns["failing"])
+}
+
+func TestScheduler_Follower(t *testing.T) {
+	t.Parallel()
+
+	task, err := scheduler.NewTask("follower", "@every 1m", "test.job", nil)
+	require.NoError(t, err)
+
+	last := time.Now().Add(-time.Hour)
+	store := &fakeScheduleRepository{lastRuns: map[string]time.Time{"follower": last}}
+
+	// Another replica holds the lock
+	runScheduler(t, store, &fakeLock{free: false}, task)
+
+	assert.Equal(t, 0, store.jobCount())
+}
+
+func TestScheduler_DuplicateTask(t *testing.T) {
+	t.Parallel()
+
+	task, err := scheduler.NewTask("dup", "@hourly", "test.job", nil)
+	require.NoError(t, err)
+
+	_, err = scheduler.New(&fakeScheduleRepository{}, &fakeLock{}, zap.NewNop(), scheduler.DefaultConfig(), []*scheduler.Task{task, task})
+	assert.Error(t, err)
+}
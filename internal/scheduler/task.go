@@ -0,0 +1,154 @@
+package scheduler
+
+import (
+	"fmt"
+	"go-project-template/internal/domain"
+	"math/rand/v2"
+	"strings"
+	"time"
+
+	"github.com/robfig/cron/v3"
+)
+
+// Schedule returns the next activation time strictly after t
+type Schedule interface {
+	Next(t time.Time) time.Time
+}
+
+// interval fires every d, aligned to the Unix epoch so every replica agrees on the ticks
+type interval time.Duration
+
+func (i interval) Next(t time.Time) time.Time {
+	d := time.Duration(i)
+	return t.Truncate(d).Add(d)
+}
+
+// ParseSchedule accepts a standard five field cron expression, a descriptor such
+// as @hourly, or @every <duration> for a fixed interval.
+func ParseSchedule(spec string) (Schedule, error) {
+	if rest, ok := strings.CutPrefix(spec, "@every "); ok {
+		d, err := time.ParseDuration(strings.TrimSpace(rest))
+		if err != nil {
+			return nil, err
+		}
+		if d < time.Second {
+			return nil, fmt.Errorf("interval %s is shorter than one second", d)
+		}
+		return interval(d), nil
+	}
+	return cron.ParseStandard(spec)
+}
+
+// CatchUpPolicy decides what happens to runs missed while no scheduler was leading
+type CatchUpPolicy string
+
+const (
+	// CatchUpSkip drops missed runs and waits for the next one
+	CatchUpSkip CatchUpPolicy = "skip"
+	// CatchUpOnce fires a single run for any number of missed runs
+	CatchUpOnce CatchUpPolicy = "once"
+	// CatchUpAll fires every missed run, up to maxCatchUpRuns
+	CatchUpAll CatchUpPolicy = "all"
+)
+
+const (
+	// maxCatchUpRuns bounds the number of runs fired at once after downtime
+	maxCatchUpRuns = 100
+	// maxCatchUpWindow is how far back missed runs are looked for
+	maxCatchUpWindow = 24 * time.Hour
+	// missedRunGrace is how late a run may fire before it counts as missed
+	missedRunGrace = 1 * time.Minute
+)
+
+// Task enqueues a job of JobType every time its schedule fires
+type Task struct {
+	Name     string
+	Spec     string
+	Schedule Schedule
+	// Jitter delays each run by a random duration up to Jitter to spread load
+	Jitter   time.Duration
+	CatchUp  CatchUpPolicy
+	JobType  string
+	Payload  interface{}
+	Priority int
+}
+
+// NewTask parses spec with [ParseSchedule]. Missed runs are caught up once.
+func NewTask(name string, spec string, jobType string, payload interface{}) (*Task, error) {
+	schedule, err := ParseSchedule(spec)
+	if err != nil {
+		return nil, fmt.Errorf("task %s: %w", name, err)
+	}
+
+	return &Task{
+		Name:     name,
+		Spec:     spec,
+		Schedule: schedule,
+		CatchUp:  CatchUpOnce,
+		JobType:  jobType,
+		Payload:  payload,
+	}, nil
+}
+
+// NewJob builds the job enqueued for the run scheduled at scheduledAt
+func (t *Task) NewJob(scheduledAt time.Time) (*domain.Job, error) {
+	j, err := domain.NewJob(t.JobType, t.Payload)
+	if err != nil {
+		return nil, err
+	}
+	j.Priority = t.Priority
+	j.RunAt = scheduledAt
+	return j, nil
+}
+
+// NextRun returns the first run after last, or after now if the task never ran
+func (t *Task) NextRun(last time.Time, now time.Time) time.Time {
+	if last.IsZero() {
+		return t.Schedule.Next(now)
+	}
+	return t.Schedule.Next(last)
+}
+
+// jitter returns a random delay for a single run
+func (t *Task) jitter() time.Duration {
+	if t.Jitter <= 0 {
+		return 0
+	}
+	return rand.N(t.Jitter)
+}
+
+// DueRuns returns the scheduled times that should fire at now given the last
+// fired run, applying the catch-up policy, and the latest elapsed run time
+// which becomes the new last run.
+func (t *Task) DueRuns(last time.Time, now time.Time) ([]time.Time, time.Time) {
+	from := last
+	if now.Sub(from) > maxCatchUpWindow {
+		from = now.Add(-maxCatchUpWindow)
+	}
+
+	var elapsed []time.Time
+	for next := t.Schedule.Next(from); !next.After(now); next = t.Schedule.Next(next) {
+		elapsed = append(elapsed, next)
+		// Keep only the most recent runs when a long outage elapsed many of them
+		if len(elapsed) > maxCatchUpRuns {
+			elapsed = elapsed[1:]
+		}
+	}
+
+	if len(elapsed) == 0 {
+		return nil, last
+	}
+	latest := elapsed[len(elapsed)-1]
+
+	switch t.CatchUp {
+	case CatchUpAll:
+		return elapsed, latest
+	case CatchUpSkip:
+		if now.Sub(latest) > missedRunGrace+t.Jitter {
+			return nil, latest
+		}
+		return []time.Time{latest}, latest
+	default:
+		return []time.Time{latest}, latest
+	}
+}
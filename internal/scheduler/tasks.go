@@ -0,0 +1,17 @@
+package scheduler
+
+import (
+	"go-project-template/internal/worker"
+	"time"
+)
+
+// Tasks returns the periodic tasks run by the scheduler
+func Tasks() ([]*Task, error) {
+	purge, err := NewTask("purge-finished-jobs", "0 3 * * *", worker.JobTypePurgeJobs, worker.PurgeJobsPayload{RetentionDays: 7})
+	if err != nil {
+		return nil, err
+	}
+	purge.Jitter = 5 * time.Minute
+
+	return []*Task{purge}, nil
+}
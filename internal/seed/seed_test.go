@@ -0,0 +1,111 @@
+package seed_test
+
+import (
+	"context"
+	"go-project-template/internal/domain"
+	"go-project-template/internal/repository"
+	"go-project-template/internal/seed"
+	"go-project-template/internal/utils"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGenerator(t *testing.T) {
+	t.Parallel()
+
+	now := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	first, again, other := seed.NewGenerator(42, now), seed.NewGenerator(42, now), seed.NewGenerator(7, now)
+
+	emails := map[string]bool{}
+	differs := false
+	for range 1000 {
+		u := first.Next()
+		require.NoError(t, u.Validate())
+
+		// The same seed generates the same users
+		assert.Equal(t, u, again.Next())
+		if u.UUID != other.Next().UUID {
+			differs = true
+		}
+
+		assert.False(t, u.CreatedAt.Before(now.AddDate(-1, 0, 0)), "created_at %s", u.CreatedAt)
+		assert.False(t, u.UpdatedAt.Before(u.CreatedAt), "updated_at %s before created_at", u.UpdatedAt)
+		assert.False(t, u.UpdatedAt.After(now), "updated_at %s", u.UpdatedAt)
+
+		assert.False(t, emails[*u.Email], "duplicate email %s", *u.Email)
+		emails[*u.Email] = true
+	}
+	assert.True(t, differs, "different seeds generate different users")
+}
+
+func TestProfile_Users(t *testing.T) {
+	t.Parallel()
+
+	testCases := map[string]struct {
+		profile seed.Profile
+		want    int
+		err     bool
+	}{
+		"small":     {seed.ProfileSmall, 100, false},
+		"demo":      {seed.ProfileDemo, 1000, false},
+		"load test": {seed.ProfileLoadTest, 100000, false},
+		"unknown":   {"huge", 0, true},
+	}
+
+	for scenario, tc := range testCases { //nolint:paralleltest
+		t.Run(scenario, func(t *testing.T) {
+			n, err := tc.profile.Users()
+			if tc.err {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.want, n)
+		})
+	}
+}
+
+func TestSeeder(t *testing.T) {
+	t.Parallel()
+	ctx := domain.WithTenant(context.Background(), domain.DefaultTenant)
+
+	repo := repository.NewMemoryUserRepository()
+	s := seed.NewSeeder(repo, 30, io.Discard)
+
+	count := func() int {
+		list, err := repo.GetList(ctx, &utils.PaginationQuery{Page: 1, Size: 1}, domain.UserFilter{})
+		require.NoError(t, err)
+		return list.TotalCount
+	}
+
+	require.NoError(t, s.Seed(ctx, 100, 42))
+	assert.Equal(t, 100, count())
+
+	// Users are spread over the past year instead of sharing the time of the write
+	days := map[string]bool{}
+	require.NoError(t, repo.Stream(ctx, domain.UserFilter{}, func(u *domain.User) error {
+		days[u.CreatedAt.Format(time.DateOnly)] = true
+		return nil
+	}))
+	assert.Greater(t, len(days), 50)
+
+	// Seeding again updates the same users
+	require.NoError(t, s.Seed(ctx, 100, 42))
+	assert.Equal(t, 100, count())
+
+	// Reset removes every user, seeded or not
+	email := "manual@mail.com"
+	_, err := repo.CreateOrUpdate(ctx, &domain.User{FirstName: "Manual", LastName: "User", Email: &email})
+	require.NoError(t, err)
+
+	deleted, err := s.Reset(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 101, deleted)
+	assert.Equal(t, 0, count())
+
+	assert.Error(t, seed.NewSeeder(repo, 0, io.Discard).Seed(ctx, 10, 42))
+}
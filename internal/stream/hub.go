@@ -0,0 +1,393 @@
+// Package stream fans domain events out to live subscribers such as the SSE
+// change stream.
+//
+// The outbox is the source of truth: a Postgres notification only wakes the hub,
+// which then reads every event after its cursor. IDs are taken when an event is
+// written but become visible when the transaction commits, so the cursor only
+// moves past an ID once every transaction that was running when it was read has
+// finished. Until then the events above the cursor are read again and the ones
+// already broadcast are skipped. Event IDs are outbox IDs, so a subscriber that
+// reconnects can resume after the last event it received.
+package stream
+
+import (
+	"context"
+	"errors"
+	"go-project-template/internal/domain"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+var (
+	// ErrTooManySubscribers is returned when the subscriber cap is reached
+	ErrTooManySubscribers = errors.New("too many subscribers")
+	// ErrReplayTooLong is returned when a subscriber asks to resume further back
+	// than the replay limit, it should reload its state instead
+	ErrReplayTooLong = errors.New("too many events to replay")
+)
+
+// Listener calls fn for every notification on a channel until ctx is done or it fails
+type Listener interface {
+	Listen(ctx context.Context, channel string, fn func(payload string)) error
+}
+
+type Config struct {
+	// Channel is the notification channel that signals new events
+	Channel string
+	// AggregateType selects the outbox events that are streamed
+	AggregateType string
+	// MaxSubscribers caps the number of concurrent subscribers
+	MaxSubscribers int
+	// BufferSize is how many events a subscriber may fall behind before it is dropped
+	BufferSize int
+	// ReplayLimit is the largest number of events replayed on resume
+	ReplayLimit int
+	// PollInterval is how often the outbox is read without a notification, in
+	// case one was lost while the listener reconnected
+	PollInterval time.Duration
+	// RetryInterval is the wait before the listener reconnects
+	RetryInterval time.Duration
+}
+
+func DefaultConfig() Config {
+	return Config{
+		Channel:        "user_changes",
+		AggregateType:  domain.AggregateUser,
+		MaxSubscribers: 100,
+		BufferSize:     256,
+		ReplayLimit:    1000,
+		PollInterval:   5 * time.Second,
+		RetryInterval:  1 * time.Second,
+	}
+}
+
+const fetchSize = 500
+
+// Filter selects the events a subscriber receives. Empty fields match everything.
+type Filter struct {
+	Types       []string
+	AggregateID uuid.UUID
+	// TenantID limits the events to the aggregates of a tenant
+	TenantID string
+}
+
+func (f Filter) Match(e domain.Event) bool {
+	if f.TenantID != "" && f.TenantID != e.TenantID {
+		return false
+	}
+	if f.AggregateID != uuid.Nil && f.AggregateID != e.AggregateID {
+		return false
+	}
+	if len(f.Types) == 0 {
+		return true
+	}
+	for _, t := range f.Types {
+		if t == e.Type {
+			return true
+		}
+	}
+	return false
+}
+
+// ParseFilter reads a comma separated list of event types and an optional aggregate ID
+func ParseFilter(types string, aggregateID string) (Filter, error) {
+	var f Filter
+	for _, t := range strings.Split(types, ",") {
+		if t = strings.TrimSpace(t); t != "" {
+			f.Types = append(f.Types, t)
+		}
+	}
+
+	if aggregateID != "" {
+		id, err := uuid.Parse(aggregateID)
+		if err != nil {
+			return Filter{}, err
+		}
+		f.AggregateID = id
+	}
+	return f, nil
+}
+
+// Subscription receives matching events on C until it is closed, either by
+// [Hub.Unsubscribe] or by the hub when the subscriber falls too far behind.
+type Subscription struct {
+	C <-chan domain.Event
+
+	ch     chan domain.Event
+	filter Filter
+	// after is the Last-Event-ID the subscriber resumed from, live events up to
+	// it are skipped as the client saw them before
+	after int64
+	// replayed holds the IDs of the replayed events, they are not delivered again
+	replayed map[int64]struct{}
+}
+
+type Hub struct {
+	repo     domain.OutboxRepository
+	listener Listener
+	logger   *zap.Logger
+	cfg      Config
+
+	wake chan struct{}
+
+	mu   sync.Mutex
+	subs map[*Subscription]struct{}
+	// cursor is the ID up to which every event is visible and broadcast
+	cursor int64
+	// sent holds the IDs above the cursor that were already broadcast
+	sent map[int64]struct{}
+	// settle moves the cursor to upTo once the transactions older than xmax are done
+	settle struct{ upTo, xmax int64 }
+	ready  bool
+}
+
+func NewHub(repo domain.OutboxRepository, listener Listener, logger *zap.Logger, cfg Config) *Hub {
+	return &Hub{
+		repo:     repo,
+		listener: listener,
+		logger:   logger,
+		cfg:      cfg,
+		wake:     make(chan struct{}, 1),
+		subs:     map[*Subscription]struct{}{},
+		sent:     map[int64]struct{}{},
+	}
+}
+
+// Subscribe registers a subscriber. With a non-zero lastEventID the events after
+// it are returned for replay before live events arrive on the subscription.
+func (h *Hub) Subscribe(ctx context.Context, f Filter, lastEventID int64) (*Subscription, []domain.Event, error) {
+	ch := make(chan domain.Event, h.cfg.BufferSize)
+	s := &Subscription{C: ch, ch: ch, filter: f, after: lastEventID}
+
+	h.mu.Lock()
+	if len(h.subs) >= h.cfg.MaxSubscribers {
+		h.mu.Unlock()
+		return nil, nil, ErrTooManySubscribers
+	}
+	// Registering first means nothing broadcast during the replay is missed
+	h.subs[s] = struct{}{}
+	h.mu.Unlock()
+
+	if lastEventID <= 0 {
+		return s, nil, nil
+	}
+
+	replay, err := h.replay(ctx, f, lastEventID)
+	if err != nil {
+		h.Unsubscribe(s)
+		return nil, nil, err
+	}
+	if len(replay) > 0 {
+		replayed := make(map[int64]struct{}, len(replay))
+		for _, e := range replay {
+			replayed[e.ID] = struct{}{}
+		}
+		h.mu.Lock()
+		s.replayed = replayed
+		h.mu.Unlock()
+	}
+	return s, replay, nil
+}
+
+func (h *Hub) replay(ctx context.Context, f Filter, after int64) ([]domain.Event, error) {
+	var replay []domain.Event
+	scanned := 0
+	for {
+		ee, err := h.repo.After(ctx, h.cfg.AggregateType, after, fetchSize)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, e := range ee {
+			if f.Match(e) {
+				replay = append(replay, e)
+			}
+		}
+
+		scanned += len(ee)
+		if scanned > h.cfg.ReplayLimit {
+			return nil, ErrReplayTooLong
+		}
+		if len(ee) < fetchSize {
+			return replay, nil
+		}
+		after = ee[len(ee)-1].ID
+	}
+}
+
+// Unsubscribe removes the subscriber and closes its channel
+func (h *Hub) Unsubscribe(s *Subscription) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if _, ok := h.subs[s]; ok {
+		delete(h.subs, s)
+		close(s.ch)
+	}
+}
+
+// Subscribers returns the number of current subscribers
+func (h *Hub) Subscribers() int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return len(h.subs)
+}
+
+// Run listens for notifications and broadcasts new events until ctx is done
+func (h *Hub) Run(ctx context.Context) {
+	go h.listen(ctx)
+
+	ticker := time.NewTicker(h.cfg.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		if err := h.Dispatch(ctx); err != nil && ctx.Err() == nil {
+			h.logger.Error("failed to read change stream events", zap.Error(err))
+		}
+
+		select {
+		case <-ctx.Done():
+			h.closeAll()
+			return
+		case <-h.wake:
+		case <-ticker.C:
+		}
+	}
+}
+
+func (h *Hub) listen(ctx context.Context) {
+	for {
+		err := h.listener.Listen(ctx, h.cfg.Channel, func(string) { h.notify() })
+		if ctx.Err() != nil {
+			return
+		}
+		h.logger.Error("change stream listener failed", zap.Error(err))
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(h.cfg.RetryInterval):
+		}
+		// Catch up on anything that happened while disconnected
+		h.notify()
+	}
+}
+
+func (h *Hub) notify() {
+	select {
+	case h.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Dispatch broadcasts every event after the cursor that was not broadcast yet.
+// The first call only records the current position, subscribers start with live
+// events.
+func (h *Hub) Dispatch(ctx context.Context) error {
+	h.mu.Lock()
+	ready := h.ready
+	h.mu.Unlock()
+
+	if !ready {
+		id, err := h.repo.LastID(ctx)
+		if err != nil {
+			return err
+		}
+
+		h.mu.Lock()
+		h.cursor, h.ready = id, true
+		h.mu.Unlock()
+		return nil
+	}
+
+	// Transactions that finished before this read are visible to the reads below
+	xmin, _, err := h.repo.Horizon(ctx)
+	if err != nil {
+		return err
+	}
+
+	h.mu.Lock()
+	settled := h.settle.upTo > h.cursor && xmin >= h.settle.xmax
+	after := h.cursor
+	h.mu.Unlock()
+
+	seen := after
+	for {
+		ee, err := h.repo.After(ctx, h.cfg.AggregateType, after, fetchSize)
+		if err != nil {
+			return err
+		}
+
+		h.broadcast(ee)
+		if len(ee) > 0 {
+			after = ee[len(ee)-1].ID
+			seen = max(seen, after)
+		}
+		if len(ee) < fetchSize {
+			break
+		}
+	}
+
+	// A transaction that has not committed yet may hold an ID below the ones just
+	// read, the cursor passes them once it is done
+	_, xmax, err := h.repo.Horizon(ctx)
+	if err != nil {
+		return err
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if settled {
+		h.cursor = h.settle.upTo
+		for id := range h.sent {
+			if id <= h.cursor {
+				delete(h.sent, id)
+			}
+		}
+	}
+	if seen > h.cursor && h.settle.upTo <= h.cursor {
+		h.settle.upTo, h.settle.xmax = seen, xmax
+	}
+	return nil
+}
+
+func (h *Hub) broadcast(ee []domain.Event) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for _, e := range ee {
+		if _, ok := h.sent[e.ID]; ok {
+			continue
+		}
+		h.sent[e.ID] = struct{}{}
+
+		for s := range h.subs {
+			if _, ok := s.replayed[e.ID]; ok || e.ID <= s.after || !s.filter.Match(e) {
+				continue
+			}
+
+			select {
+			case s.ch <- e:
+			default:
+				// A subscriber that cannot keep up is dropped, it resumes with Last-Event-ID
+				h.logger.Warn("dropped slow change stream subscriber", zap.Int64("event#id", e.ID))
+				delete(h.subs, s)
+				close(s.ch)
+			}
+		}
+	}
+}
+
+func (h *Hub) closeAll() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for s := range h.subs {
+		delete(h.subs, s)
+		close(s.ch)
+	}
+}
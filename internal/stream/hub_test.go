@@ -0,0 +1,261 @@
+package stream_test
+
+import (
+	"context"
+	"go-project-template/internal/domain"
+	"go-project-template/internal/stream"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+// fakeOutboxRepository serves events appended by the test. Every event is
+// written by its own transaction, which commits right away unless it is begun.
+type fakeOutboxRepository struct {
+	mu     sync.Mutex
+	events []domain.Event
+	// running maps the event IDs of uncommitted transactions to their xid
+	running map[int64]int64
+	nextXID int64
+}
+
+func (f *fakeOutboxRepository) add(eventType string, aggregateID uuid.UUID) domain.Event {
+	e, commit := f.begin(eventType, aggregateID)
+	commit()
+	return e
+}
+
+// begin writes an event that stays invisible until commit is called
+func (f *fakeOutboxRepository) begin(eventType string, aggregateID uuid.UUID) (domain.Event, func()) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.running == nil {
+		f.running, f.nextXID = map[int64]int64{}, 1
+	}
+
+	e := domain.Event{
+		ID:            int64(len(f.events) + 1),
+		AggregateType: domain.AggregateUser,
+		AggregateID:   aggregateID,
+		Type:          eventType,
+		OccurredAt:    time.Now(),
+	}
+	f.events = append(f.events, e)
+	f.running[e.ID] = f.nextXID
+	f.nextXID++
+
+	return e, func() {
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		delete(f.running, e.ID)
+	}
+}
+
+func (f *fakeOutboxRepository) Pending(_ context.Context, _ int) ([]domain.Event, error) {
+	return nil, nil
+}
+
+func (f *fakeOutboxRepository) MarkPublished(_ context.Context, _ int64) error {
+	return nil
+}
+
+func (f *fakeOutboxRepository) MarkFailed(_ context.Context, _ int64, _ error) error {
+	return nil
+}
+
+func (f *fakeOutboxRepository) DeletePublished(_ context.Context, _ time.Time) (int64, error) {
+	return 0, nil
+}
+
+func (f *fakeOutboxRepository) After(_ context.Context, aggregateType string, afterID int64, limit int) ([]domain.Event, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	var ee []domain.Event
+	for _, e := range f.events {
+		if _, ok := f.running[e.ID]; ok {
+			continue
+		}
+		if e.AggregateType == aggregateType && e.ID > afterID && len(ee) < limit {
+			ee = append(ee, e)
+		}
+	}
+	return ee, nil
+}
+
+func (f *fakeOutboxRepository) LastID(_ context.Context) (int64, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return int64(len(f.events)), nil
+}
+
+func (f *fakeOutboxRepository) Horizon(_ context.Context) (int64, int64, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	xmin := f.nextXID
+	for _, xid := range f.running {
+		xmin = min(xmin, xid)
+	}
+	return xmin, f.nextXID, nil
+}
+
+// fakeListener blocks until the context is done, notifications are triggered through Dispatch
+type fakeListener struct{}
+
+func (fakeListener) Listen(ctx context.Context, _ string, _ func(string)) error {
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+func newTestHub(t *testing.T, cfg stream.Config) (*stream.Hub, *fakeOutboxRepository) {
+	t.Helper()
+
+	repo := &fakeOutboxRepository{}
+	repo.add(domain.EventUserCreated, uuid.New())
+
+	h := stream.NewHub(repo, fakeListener{}, zap.NewNop(), cfg)
+	// The first dispatch records the starting position
+	require.NoError(t, h.Dispatch(context.Background()))
+	return h, repo
+}
+
+func receive(t *testing.T, s *stream.Subscription) []int64 {
+	t.Helper()
+
+	var ids []int64
+	for {
+		select {
+		case e, ok := <-s.C:
+			if !ok {
+				return ids
+			}
+			ids = append(ids, e.ID)
+		default:
+			return ids
+		}
+	}
+}
+
+func TestHub_Filter(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	h, repo := newTestHub(t, stream.DefaultConfig())
+
+	watched := uuid.New()
+
+	testCases := map[string]struct {
+		filter stream.Filter
+		want   []int64
+	}{
+		"everything": {stream.Filter{}, []int64{2, 3, 4}},
+		"by type":    {stream.Filter{Types: []string{domain.EventUserDeleted}}, []int64{4}},
+		"by user":    {stream.Filter{AggregateID: watched}, []int64{2, 4}},
+	}
+
+	subs := map[string]*stream.Subscription{}
+	for scenario, tc := range testCases {
+		s, replay, err := h.Subscribe(ctx, tc.filter, 0)
+		require.NoError(t, err)
+		assert.Empty(t, replay)
+		subs[scenario] = s
+	}
+
+	repo.add(domain.EventUserCreated, watched)
+	repo.add(domain.EventUserUpdated, uuid.New())
+	repo.add(domain.EventUserDeleted, watched)
+	require.NoError(t, h.Dispatch(ctx))
+
+	for scenario, tc := range testCases { //nolint:paralleltest
+		t.Run(scenario, func(t *testing.T) {
+			assert.Equal(t, tc.want, receive(t, subs[scenario]))
+		})
+	}
+}
+
+func TestHub_Resume(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	cfg := stream.DefaultConfig()
+	cfg.ReplayLimit = 3
+	h, repo := newTestHub(t, cfg)
+
+	for range 3 {
+		repo.add(domain.EventUserUpdated, uuid.New())
+	}
+
+	// Events 3 and 4 were missed while disconnected
+	s, replay, err := h.Subscribe(ctx, stream.Filter{}, 2)
+	require.NoError(t, err)
+	require.Len(t, replay, 2)
+	assert.Equal(t, int64(3), replay[0].ID)
+	assert.Equal(t, int64(4), replay[1].ID)
+
+	// Replayed events are not delivered again when the hub catches up
+	repo.add(domain.EventUserDeleted, uuid.New())
+	require.NoError(t, h.Dispatch(ctx))
+	assert.Equal(t, []int64{5}, receive(t, s))
+
+	_, _, err = h.Subscribe(ctx, stream.Filter{}, 0)
+	require.NoError(t, err)
+	_, _, err = h.Subscribe(ctx, stream.Filter{}, 1)
+	assert.ErrorIs(t, err, stream.ErrReplayTooLong)
+}
+
+func TestHub_Limits(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	cfg := stream.DefaultConfig()
+	cfg.MaxSubscribers = 1
+	cfg.BufferSize = 1
+	h, repo := newTestHub(t, cfg)
+
+	slow, _, err := h.Subscribe(ctx, stream.Filter{}, 0)
+	require.NoError(t, err)
+
+	_, _, err = h.Subscribe(ctx, stream.Filter{}, 0)
+	assert.ErrorIs(t, err, stream.ErrTooManySubscribers)
+
+	// A subscriber that falls behind is dropped and frees its slot
+	repo.add(domain.EventUserCreated, uuid.New())
+	repo.add(domain.EventUserCreated, uuid.New())
+	require.NoError(t, h.Dispatch(ctx))
+
+	assert.Equal(t, []int64{2}, receive(t, slow))
+	assert.Equal(t, 0, h.Subscribers())
+
+	_, _, err = h.Subscribe(ctx, stream.Filter{}, 0)
+	assert.NoError(t, err)
+}
+
+func TestHub_LateCommit(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	h, repo := newTestHub(t, stream.DefaultConfig())
+
+	s, _, err := h.Subscribe(ctx, stream.Filter{}, 0)
+	require.NoError(t, err)
+
+	// Event 2 commits after event 3, which the hub reads first
+	_, commit := repo.begin(domain.EventUserCreated, uuid.New())
+	repo.add(domain.EventUserUpdated, uuid.New())
+	require.NoError(t, h.Dispatch(ctx))
+	assert.Equal(t, []int64{3}, receive(t, s))
+
+	commit()
+	require.NoError(t, h.Dispatch(ctx))
+	assert.Equal(t, []int64{2}, receive(t, s))
+
+	repo.add(domain.EventUserDeleted, uuid.New())
+	require.NoError(t, h.Dispatch(ctx))
+	require.NoError(t, h.Dispatch(ctx))
+	assert.Equal(t, []int64{4}, receive(t, s))
+}
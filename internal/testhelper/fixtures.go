@@ -0,0 +1,130 @@
+package testhelper
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"go-project-template/internal/domain"
+	"os"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+)
+
+// Execer is implemented by connections, pools and transactions
+type Execer interface {
+	Exec(ctx context.Context, sql string, args ...interface{}) (pgconn.CommandTag, error)
+}
+
+// LoadFixtures inserts the rows declared in YAML files. A fixture file maps
+// table names to lists of rows, tables are filled in the order they appear:
+//
+//	users:
+//	  - uuid: 3fe82b1f-ab3d-40a1-8bd8-bccd4dd166f8
+//	    first_name: john
+//	    last_name: wick
+//
+// Nested objects are stored as JSON and lists of strings as arrays.
+func LoadFixtures(t *testing.T, db Execer, paths ...string) {
+	t.Helper()
+	ctx := context.Background()
+
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		require.NoError(t, err)
+
+		var doc yaml.Node
+		require.NoError(t, yaml.Unmarshal(data, &doc), "parsing %s", path)
+		if len(doc.Content) == 0 {
+			continue
+		}
+
+		tables := doc.Content[0]
+		require.Equal(t, yaml.MappingNode, tables.Kind, "%s must map table names to rows", path)
+
+		for i := 0; i < len(tables.Content); i += 2 {
+			table := tables.Content[i].Value
+
+			var rows []map[string]interface{}
+			require.NoError(t, tables.Content[i+1].Decode(&rows), "%s: table %s", path, table)
+
+			for n, row := range rows {
+				query, args, err := insertQuery(table, row)
+				require.NoError(t, err, "%s: %s row %d", path, table, n)
+
+				_, err = db.Exec(ctx, query, args...)
+				require.NoError(t, err, "%s: %s row %d", path, table, n)
+			}
+		}
+	}
+}
+
+func insertQuery(table string, row map[string]interface{}) (string, []interface{}, error) {
+	columns := make([]string, 0, len(row))
+	for c := range row {
+		columns = append(columns, c)
+	}
+	sort.Strings(columns)
+
+	names := make([]string, len(columns))
+	params := make([]string, len(columns))
+	args := make([]interface{}, len(columns))
+	for i, c := range columns {
+		v, err := fixtureValue(row[c])
+		if err != nil {
+			return "", nil, fmt.Errorf("column %s: %w", c, err)
+		}
+
+		names[i] = pgx.Identifier{c}.Sanitize()
+		params[i] = fmt.Sprintf("$%d", i+1)
+		args[i] = v
+	}
+
+	query := fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s)",
+		pgx.Identifier{table}.Sanitize(), strings.Join(names, ", "), strings.Join(params, ", "))
+	return query, args, nil
+}
+
+// fixtureValue converts YAML values that have no direct Postgres counterpart
+func fixtureValue(v interface{}) (interface{}, error) {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		b, err := json.Marshal(v)
+		return string(b), err
+	case []interface{}:
+		ss := make([]string, 0, len(v))
+		for _, item := range v {
+			s, ok := item.(string)
+			if !ok {
+				b, err := json.Marshal(v)
+				return string(b), err
+			}
+			ss = append(ss, s)
+		}
+		return ss, nil
+	default:
+		return v, nil
+	}
+}
+
+// NewUser returns a valid user with unique values, changed by the given functions
+func NewUser(opts ...func(*domain.User)) *domain.User {
+	id := uuid.New()
+	email := id.String() + "@mail.com"
+	u := &domain.User{
+		UUID:      id,
+		FirstName: "John",
+		LastName:  "Wick",
+		Email:     &email,
+	}
+	for _, opt := range opts {
+		opt(u)
+	}
+	return u
+}
@@ -1,35 +1,145 @@
+// Package testhelper provisions Postgres for tests. Every test gets a schema of
+// its own with the project schema applied, so tests can run in parallel and
+// never see each other's rows.
 package testhelper
 
 import (
 	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"go-project-template/cmdutil"
+	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/joho/godotenv"
 	"github.com/stretchr/testify/require"
 )
 
-func NewTestPgxConn(t *testing.T) *pgx.Conn {
+// schemaFile holds the DDL the database is provisioned with, relative to the module root
+const schemaFile = "docker/provision/init.sql"
+
+// NewTestPgxConn returns a connection to a schema of its own for the test
+func NewTestPgxConn(t testing.TB) *pgx.Conn {
 	t.Helper()
+	ctx := context.Background()
+
+	config, err := pgx.ParseConfig(testDatabaseURL(t))
+	require.NoError(t, err)
+	config.RuntimeParams["search_path"] = newTestSchema(t, config) + ",public"
+
+	conn, err := pgx.ConnectConfig(ctx, config)
+	require.NoError(t, err)
+
+	t.Cleanup(func() {
+		conn.Close(ctx)
+	})
+
+	return conn
+}
 
+// NewTestPool returns a pool whose connections all use a schema of its own for
+// the test. It is configured like the pool of the commands, opts change that
+// configuration, for example to benchmark another exec mode.
+func NewTestPool(t testing.TB, opts ...func(*pgxpool.Config)) *pgxpool.Pool {
+	t.Helper()
 	ctx := context.Background()
 
-	envFile, _ := godotenv.Read("../../.env")
+	config, err := pgxpool.ParseConfig(testDatabaseURL(t))
+	require.NoError(t, err)
+	cmdutil.DefaultDatabaseConfig().Apply(config)
+	for _, opt := range opts {
+		opt(config)
+	}
+	config.ConnConfig.RuntimeParams["search_path"] = newTestSchema(t, config.ConnConfig) + ",public"
+
+	pool, err := pgxpool.NewWithConfig(ctx, config)
+	require.NoError(t, err)
+
+	t.Cleanup(pool.Close)
+
+	return pool
+}
+
+// testDatabaseURL reads TEST_DATABASE_URL from the environment or the .env file
+// at the module root, and skips the test without it
+func testDatabaseURL(t testing.TB) string {
+	t.Helper()
 
-	connString := envFile["TEST_DATABASE_URL"]
-	if connString == "" {
-		t.Skipf("skipping due to missing environment variable %v", "TEST_DATABASE_URL")
+	if url := os.Getenv("TEST_DATABASE_URL"); url != "" {
+		return url
 	}
 
-	config, err := pgx.ParseConfig(connString)
+	if root, err := moduleRoot(); err == nil {
+		envFile, _ := godotenv.Read(filepath.Join(root, ".env"))
+		if url := envFile["TEST_DATABASE_URL"]; url != "" {
+			return url
+		}
+	}
+
+	t.Skipf("skipping due to missing environment variable %v", "TEST_DATABASE_URL")
+	return ""
+}
+
+// newTestSchema creates a schema with the project schema applied and drops it
+// once the test and its connections are done
+func newTestSchema(t testing.TB, config *pgx.ConnConfig) string {
+	t.Helper()
+	ctx := context.Background()
+
+	root, err := moduleRoot()
+	require.NoError(t, err)
+	ddl, err := os.ReadFile(filepath.Join(root, schemaFile))
 	require.NoError(t, err)
 
-	conn, err := pgx.ConnectConfig(ctx, config)
+	b := make([]byte, 8)
+	_, err = rand.Read(b)
 	require.NoError(t, err)
+	schema := "test_" + hex.EncodeToString(b)
 
+	admin, err := pgx.ConnectConfig(ctx, config.Copy())
+	require.NoError(t, err)
+
+	// Registered first so it runs after the cleanups closing the test's connections
 	t.Cleanup(func() {
-		conn.Close(ctx)
+		_, err := admin.Exec(ctx, "DROP SCHEMA "+pgx.Identifier{schema}.Sanitize()+" CASCADE")
+		if err != nil {
+			t.Logf("failed to drop test schema %s: %v", schema, err)
+		}
+		admin.Close(ctx)
 	})
 
-	return conn
+	_, err = admin.Exec(ctx, "CREATE SCHEMA "+pgx.Identifier{schema}.Sanitize())
+	require.NoError(t, err)
+
+	// Extensions such as uuid-ossp stay in public, which is why it remains on the search path
+	_, err = admin.Exec(ctx, "SET search_path TO "+pgx.Identifier{schema}.Sanitize()+", public")
+	require.NoError(t, err)
+	_, err = admin.Exec(ctx, string(ddl))
+	require.NoError(t, err, "applying %s", schemaFile)
+
+	return schema
+}
+
+// moduleRoot walks up from the working directory to the directory holding go.mod
+func moduleRoot() (string, error) {
+	dir, err := os.Getwd()
+	if err != nil {
+		return "", err
+	}
+
+	for {
+		if _, err := os.Stat(filepath.Join(dir, "go.mod")); err == nil {
+			return dir, nil
+		}
+
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", errors.New("go.mod not found")
+		}
+		dir = parent
+	}
 }
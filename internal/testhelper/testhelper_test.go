@@ -0,0 +1,41 @@
+package testhelper_test
+
+import (
+	"context"
+	"go-project-template/internal/testhelper"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadFixtures(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	conn := testhelper.NewTestPgxConn(t)
+
+	testhelper.LoadFixtures(t, conn, "testdata/webhooks.yaml")
+
+	var eventTypes []string
+	require.NoError(t, conn.QueryRow(ctx, "SELECT event_types FROM webhooks").Scan(&eventTypes))
+	assert.Equal(t, []string{"user.created", "user.deleted"}, eventTypes)
+
+	var uuid string
+	require.NoError(t, conn.QueryRow(ctx, "SELECT payload->'data'->>'uuid' FROM webhook_deliveries").Scan(&uuid))
+	assert.Equal(t, "3fe82b1f-ab3d-40a1-8bd8-bccd4dd166f8", uuid)
+}
+
+func TestNewTestPgxConn_Isolation(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	first := testhelper.NewTestPgxConn(t)
+	second := testhelper.NewTestPgxConn(t)
+
+	_, err := first.Exec(ctx, "INSERT INTO users (uuid, first_name, last_name) VALUES (gen_random_uuid(), 'john', 'wick')")
+	require.NoError(t, err)
+
+	var count int
+	require.NoError(t, second.QueryRow(ctx, "SELECT count(*) FROM users").Scan(&count))
+	assert.Equal(t, 0, count)
+}
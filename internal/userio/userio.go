@@ -0,0 +1,268 @@
+// Package userio encodes and decodes users as CSV or NDJSON for imports and exports.
+package userio
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go-project-template/internal/domain"
+	"io"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+type Format string
+
+const (
+	FormatCSV    Format = "csv"
+	FormatNDJSON Format = "ndjson"
+)
+
+// Fields are the user columns in export order
+var Fields = []string{"uuid", "first_name", "last_name", "email"}
+
+// importNamespace is the UUIDv5 namespace of imported users without a uuid
+var importNamespace = uuid.MustParse("5b0e6b8e-3f0a-4f4c-9a43-2c6f0d1f7a51")
+
+// ImportUUID returns the uuid of an imported user that has none. It is derived
+// from the tenant and the email, so importing the same file again updates the
+// users instead of duplicating them.
+func ImportUUID(tenant string, email string) uuid.UUID {
+	return uuid.NewSHA1(importNamespace, []byte(tenant+"\x00"+strings.ToLower(strings.TrimSpace(email))))
+}
+
+func ParseFormat(s string) (Format, error) {
+	switch Format(strings.ToLower(s)) {
+	case FormatCSV:
+		return FormatCSV, nil
+	case FormatNDJSON:
+		return FormatNDJSON, nil
+	}
+	return "", fmt.Errorf("unsupported format %q, expected csv or ndjson", s)
+}
+
+func (f Format) ContentType() string {
+	if f == FormatCSV {
+		return "text/csv"
+	}
+	return "application/x-ndjson"
+}
+
+type Encoder interface {
+	Encode(*domain.User) error
+	Flush() error
+}
+
+// NewEncoder returns a buffered [Encoder]. Flush must be called once all users are encoded.
+func NewEncoder(w io.Writer, f Format) Encoder {
+	if f == FormatCSV {
+		return &csvEncoder{w: csv.NewWriter(w)}
+	}
+
+	buf := bufio.NewWriter(w)
+	return &ndjsonEncoder{buf: buf, enc: json.NewEncoder(buf)}
+}
+
+type ndjsonEncoder struct {
+	buf *bufio.Writer
+	enc *json.Encoder
+}
+
+func (e *ndjsonEncoder) Encode(u *domain.User) error {
+	return e.enc.Encode(u)
+}
+
+func (e *ndjsonEncoder) Flush() error {
+	return e.buf.Flush()
+}
+
+type csvEncoder struct {
+	w      *csv.Writer
+	header bool
+}
+
+func (e *csvEncoder) writeHeader() error {
+	if e.header {
+		return nil
+	}
+	e.header = true
+	return e.w.Write(Fields)
+}
+
+func (e *csvEncoder) Encode(u *domain.User) error {
+	if err := e.writeHeader(); err != nil {
+		return err
+	}
+
+	email := ""
+	if u.Email != nil {
+		email = *u.Email
+	}
+	return e.w.Write([]string{u.UUID.String(), u.FirstName, u.LastName, email})
+}
+
+func (e *csvEncoder) Flush() error {
+	if err := e.writeHeader(); err != nil {
+		return err
+	}
+
+	e.w.Flush()
+	return e.w.Error()
+}
+
+// Record is a single decoded user and the line it was read from.
+// Err is set when the line could not be turned into a user.
+type Record struct {
+	Line int
+	User *domain.User
+	Err  error
+}
+
+// Decoder reads users one record at a time
+type Decoder struct {
+	format  Format
+	mapping map[string]string
+
+	csv     *csv.Reader
+	columns map[string]int
+
+	scanner *bufio.Scanner
+	line    int
+}
+
+// NewDecoder returns a [Decoder] for r. The mapping translates a user field
+// (uuid, first_name, last_name, email) into the CSV column or JSON key to read it from.
+func NewDecoder(r io.Reader, f Format, mapping map[string]string) (*Decoder, error) {
+	for field := range mapping {
+		if !isField(field) {
+			return nil, fmt.Errorf("unknown user field %q in mapping", field)
+		}
+	}
+
+	d := &Decoder{format: f, mapping: mapping}
+	if f == FormatNDJSON {
+		d.scanner = bufio.NewScanner(r)
+		d.scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+		return d, nil
+	}
+
+	d.csv = csv.NewReader(r)
+	d.csv.FieldsPerRecord = -1
+	d.csv.TrimLeadingSpace = true
+
+	header, err := d.csv.Read()
+	if err != nil {
+		return nil, fmt.Errorf("reading csv header: %w", err)
+	}
+
+	d.columns = make(map[string]int, len(header))
+	for i, name := range header {
+		d.columns[strings.TrimSpace(name)] = i
+	}
+	return d, nil
+}
+
+// Next returns the next record, or [io.EOF] once the input is exhausted
+func (d *Decoder) Next() (Record, error) {
+	if d.format == FormatNDJSON {
+		return d.nextNDJSON()
+	}
+	return d.nextCSV()
+}
+
+func (d *Decoder) source(field string) string {
+	if src, ok := d.mapping[field]; ok {
+		return src
+	}
+	return field
+}
+
+func (d *Decoder) nextCSV() (Record, error) {
+	row, err := d.csv.Read()
+
+	var parseErr *csv.ParseError
+	if errors.As(err, &parseErr) {
+		return Record{Line: parseErr.StartLine, Err: parseErr.Err}, nil
+	}
+	if err != nil {
+		return Record{}, err
+	}
+	line, _ := d.csv.FieldPos(0)
+
+	values := make(map[string]string, len(Fields))
+	for _, field := range Fields {
+		if i, ok := d.columns[d.source(field)]; ok && i < len(row) {
+			values[field] = strings.TrimSpace(row[i])
+		}
+	}
+
+	u, err := userFromValues(values)
+	return Record{Line: line, User: u, Err: err}, nil
+}
+
+func (d *Decoder) nextNDJSON() (Record, error) {
+	for d.scanner.Scan() {
+		d.line++
+		if len(strings.TrimSpace(d.scanner.Text())) == 0 {
+			continue
+		}
+
+		raw := map[string]interface{}{}
+		if err := json.Unmarshal(d.scanner.Bytes(), &raw); err != nil {
+			return Record{Line: d.line, Err: err}, nil
+		}
+
+		values := make(map[string]string, len(Fields))
+		for _, field := range Fields {
+			v, ok := raw[d.source(field)]
+			if !ok || v == nil {
+				continue
+			}
+			s, ok := v.(string)
+			if !ok {
+				return Record{Line: d.line, Err: fmt.Errorf("%s: expected a string", field)}, nil
+			}
+			values[field] = s
+		}
+
+		u, err := userFromValues(values)
+		return Record{Line: d.line, User: u, Err: err}, nil
+	}
+
+	if err := d.scanner.Err(); err != nil {
+		return Record{}, err
+	}
+	return Record{}, io.EOF
+}
+
+func userFromValues(values map[string]string) (*domain.User, error) {
+	u := &domain.User{
+		FirstName: values["first_name"],
+		LastName:  values["last_name"],
+	}
+
+	if email := values["email"]; email != "" {
+		u.Email = &email
+	}
+
+	if id := values["uuid"]; id != "" {
+		parsed, err := uuid.Parse(id)
+		if err != nil {
+			return nil, fmt.Errorf("uuid: %w", err)
+		}
+		u.UUID = parsed
+	}
+	return u, nil
+}
+
+func isField(name string) bool {
+	for _, field := range Fields {
+		if field == name {
+			return true
+		}
+	}
+	return false
+}
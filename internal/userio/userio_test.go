@@ -0,0 +1,123 @@
+package userio_test
+
+import (
+	"bytes"
+	"errors"
+	"go-project-template/internal/domain"
+	"go-project-template/internal/userio"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func decodeAll(t *testing.T, dec *userio.Decoder) []userio.Record {
+	t.Helper()
+
+	var recs []userio.Record
+	for {
+		rec, err := dec.Next()
+		if errors.Is(err, io.EOF) {
+			return recs
+		}
+		require.NoError(t, err)
+		recs = append(recs, rec)
+	}
+}
+
+func TestDecoder_CSV(t *testing.T) {
+	t.Parallel()
+
+	input := "First Name,last_name,email\nJohn,Wick,jwick@mail.com\n\"Helen\nMarie\",Wick,\nWinston\n"
+	dec, err := userio.NewDecoder(strings.NewReader(input), userio.FormatCSV, map[string]string{"first_name": "First Name"})
+	require.NoError(t, err)
+
+	recs := decodeAll(t, dec)
+	require.Len(t, recs, 3)
+
+	assert.Equal(t, 2, recs[0].Line)
+	assert.Equal(t, "John", recs[0].User.FirstName)
+	assert.Equal(t, "jwick@mail.com", *recs[0].User.Email)
+
+	assert.Equal(t, 3, recs[1].Line)
+	assert.Equal(t, "Helen\nMarie", recs[1].User.FirstName)
+	assert.Nil(t, recs[1].User.Email)
+
+	assert.Equal(t, 5, recs[2].Line)
+	assert.Equal(t, "Winston", recs[2].User.FirstName)
+	assert.Empty(t, recs[2].User.LastName)
+}
+
+func TestDecoder_NDJSON(t *testing.T) {
+	t.Parallel()
+
+	id := uuid.New()
+	input := `{"uuid":"` + id.String() + `","first_name":"John","last_name":"Wick"}` + "\n\n{bad\n" + `{"uuid":"nope"}` + "\n"
+	dec, err := userio.NewDecoder(strings.NewReader(input), userio.FormatNDJSON, nil)
+	require.NoError(t, err)
+
+	recs := decodeAll(t, dec)
+	require.Len(t, recs, 3)
+
+	assert.Equal(t, 1, recs[0].Line)
+	assert.NoError(t, recs[0].Err)
+	assert.Equal(t, id, recs[0].User.UUID)
+
+	assert.Equal(t, 3, recs[1].Line)
+	assert.Error(t, recs[1].Err)
+
+	assert.Equal(t, 4, recs[2].Line)
+	assert.Error(t, recs[2].Err)
+}
+
+func TestDecoder_UnknownMapping(t *testing.T) {
+	t.Parallel()
+
+	_, err := userio.NewDecoder(strings.NewReader(""), userio.FormatNDJSON, map[string]string{"nickname": "Nick"})
+	assert.Error(t, err)
+}
+
+func TestEncoder_RoundTrip(t *testing.T) {
+	t.Parallel()
+
+	email := "jwick@mail.com"
+	users := []*domain.User{
+		{UUID: uuid.New(), FirstName: "john", LastName: "wick", Email: &email},
+		{UUID: uuid.New(), FirstName: "helen", LastName: "wick, sr"},
+	}
+
+	for _, format := range []userio.Format{userio.FormatCSV, userio.FormatNDJSON} { //nolint:paralleltest
+		t.Run(string(format), func(t *testing.T) {
+			var buf bytes.Buffer
+			enc := userio.NewEncoder(&buf, format)
+			for _, u := range users {
+				require.NoError(t, enc.Encode(u))
+			}
+			require.NoError(t, enc.Flush())
+
+			dec, err := userio.NewDecoder(&buf, format, nil)
+			require.NoError(t, err)
+
+			recs := decodeAll(t, dec)
+			require.Len(t, recs, len(users))
+			for i, rec := range recs {
+				require.NoError(t, rec.Err)
+				assert.Equal(t, users[i], rec.User)
+			}
+		})
+	}
+}
+
+func TestImportUUID(t *testing.T) {
+	t.Parallel()
+
+	id := userio.ImportUUID("acme", "jwick@mail.com")
+	assert.Equal(t, uuid.Version(5), id.Version())
+	assert.Equal(t, id, userio.ImportUUID("acme", " JWick@mail.com"))
+	assert.NotEqual(t, id, userio.ImportUUID("globex", "jwick@mail.com"))
+	assert.NotEqual(t, id, userio.ImportUUID("acme", "helen@mail.com"))
+}
@@ -15,12 +15,12 @@ const (
 // Pagination List Type Response Model
 // @Description Pagination List Type Response Model
 type PaginationResponse[T any] struct {
-	TotalCount int  `json:"total_count"`
-	TotalPages int  `json:"total_pages"`
-	Page       int  `json:"page"`
-	Size       int  `json:"size"`
-	HasMore    bool `json:"has_more"`
-	Values     []T  `json:"values"`
+	TotalCount int  `json:"total_count" yaml:"total_count"`
+	TotalPages int  `json:"total_pages" yaml:"total_pages"`
+	Page       int  `json:"page" yaml:"page"`
+	Size       int  `json:"size" yaml:"size"`
+	HasMore    bool `json:"has_more" yaml:"has_more"`
+	Values     []T  `json:"values" yaml:"values"`
 }
 
 func PaginatedResponse[T any](count int, pq *PaginationQuery, list []T) *PaginationResponse[T] {
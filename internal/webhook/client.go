@@ -0,0 +1,48 @@
+package webhook
+
+import (
+	"errors"
+	"fmt"
+	"go-project-template/internal/domain"
+	"net"
+	"net/http"
+	"net/netip"
+	"syscall"
+	"time"
+)
+
+// ErrForbiddenAddress is returned for deliveries to hosts that resolve to a
+// loopback, link-local or private address
+var ErrForbiddenAddress = errors.New("webhook host does not resolve to a public address")
+
+// NewClient returns the HTTP client deliveries are sent with. Unless
+// allowPrivate is set it only connects to public addresses. The address is
+// checked after the host is resolved, so a name cannot be rebound to an
+// internal address between the validation of the URL and the delivery.
+func NewClient(timeout time.Duration, allowPrivate bool) *http.Client {
+	dialer := &net.Dialer{Timeout: timeout, KeepAlive: 30 * time.Second}
+	if !allowPrivate {
+		dialer.Control = publicAddrOnly
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.DialContext = dialer.DialContext
+	// A proxy would connect on the client's behalf, past the check
+	transport.Proxy = nil
+
+	return &http.Client{Timeout: timeout, Transport: transport}
+}
+
+// publicAddrOnly is a [net.Dialer] Control refusing connections to addresses
+// that are not public
+func publicAddrOnly(_ string, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	addr, err := netip.ParseAddr(host)
+	if err != nil || !domain.PublicAddr(addr) {
+		return fmt.Errorf("%w: %s", ErrForbiddenAddress, host)
+	}
+	return nil
+}
@@ -0,0 +1,30 @@
+package webhook_test
+
+import (
+	"go-project-template/internal/webhook"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewClient(t *testing.T) {
+	t.Parallel()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	// The test server listens on loopback, which deliveries must not reach
+	_, err := webhook.NewClient(time.Second, false).Post(srv.URL, "application/json", nil)
+	assert.ErrorIs(t, err, webhook.ErrForbiddenAddress)
+
+	res, err := webhook.NewClient(time.Second, true).Post(srv.URL, "application/json", nil)
+	require.NoError(t, err)
+	defer res.Body.Close()
+	assert.Equal(t, http.StatusNoContent, res.StatusCode)
+}
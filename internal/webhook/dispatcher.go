@@ -0,0 +1,215 @@
+// Package webhook delivers domain events to webhook subscriptions.
+//
+// Deliveries are POSTed with the event envelope as the JSON body and signed with
+// the webhook secret. Receivers verify a delivery by computing
+//
+//	hex(HMAC-SHA256(secret, X-Webhook-Timestamp + "." + body))
+//
+// and comparing it with the v1 value of the X-Webhook-Signature header.
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"go-project-template/internal/domain"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const (
+	SignatureHeader = "X-Webhook-Signature"
+	TimestampHeader = "X-Webhook-Timestamp"
+	DeliveryHeader  = "X-Webhook-Delivery"
+	EventHeader     = "X-Webhook-Event"
+)
+
+type Config struct {
+	// PollInterval is the wait between polls when nothing is due
+	PollInterval time.Duration
+	// BatchSize is how many deliveries are claimed and sent concurrently
+	BatchSize int
+	// Timeout bounds a single delivery attempt
+	Timeout time.Duration
+	// MaxAttempts is how often a delivery is tried before it is marked failed
+	MaxAttempts int
+	// BackoffBase and BackoffMax bound the wait between attempts
+	BackoffBase time.Duration
+	BackoffMax  time.Duration
+	// DisableAfter is how many consecutive failed attempts disable a webhook
+	DisableAfter int
+}
+
+func DefaultConfig() Config {
+	return Config{
+		PollInterval: 1 * time.Second,
+		BatchSize:    10,
+		Timeout:      10 * time.Second,
+		MaxAttempts:  8,
+		BackoffBase:  30 * time.Second,
+		BackoffMax:   6 * time.Hour,
+		DisableAfter: 20,
+	}
+}
+
+type Dispatcher struct {
+	repo   domain.WebhookRepository
+	client *http.Client
+	logger *zap.Logger
+	cfg    Config
+}
+
+func NewDispatcher(repo domain.WebhookRepository, client *http.Client, logger *zap.Logger, cfg Config) *Dispatcher {
+	return &Dispatcher{
+		repo:   repo,
+		client: client,
+		logger: logger,
+		cfg:    cfg,
+	}
+}
+
+// Sign returns the signature of a delivery body sent at the given time
+func Sign(secret string, timestamp time.Time, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	_, _ = fmt.Fprintf(mac, "%d.", timestamp.Unix())
+	_, _ = mac.Write(body)
+	return "v1=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Run fans out new events and sends due deliveries until ctx is done
+func (d *Dispatcher) Run(ctx context.Context) {
+	for {
+		busy, err := d.DispatchOnce(ctx)
+		if err != nil && ctx.Err() == nil {
+			d.logger.Error("webhook dispatch failed", zap.Error(err))
+		}
+
+		wait := time.Duration(0)
+		if !busy || err != nil {
+			wait = d.cfg.PollInterval
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(wait):
+		}
+	}
+}
+
+// DispatchOnce fans out one batch of events and sends one batch of due
+// deliveries. It reports whether there may be more work waiting.
+func (d *Dispatcher) DispatchOnce(ctx context.Context) (bool, error) {
+	events, err := d.repo.FanOut(ctx, d.cfg.BatchSize)
+	if err != nil {
+		return false, err
+	}
+
+	// The lease outlasts the attempt so a slow receiver is not sent the delivery twice
+	deliveries, err := d.repo.ClaimDeliveries(ctx, d.cfg.BatchSize, 2*d.cfg.Timeout)
+	if err != nil {
+		return false, err
+	}
+
+	var wg sync.WaitGroup
+	for i := range deliveries {
+		wg.Add(1)
+		go func(dl *domain.WebhookDelivery) {
+			defer wg.Done()
+			d.deliver(ctx, dl)
+		}(&deliveries[i])
+	}
+	wg.Wait()
+
+	return events == d.cfg.BatchSize || len(deliveries) == d.cfg.BatchSize, nil
+}
+
+func (d *Dispatcher) deliver(ctx context.Context, dl *domain.WebhookDelivery) {
+	// Finish recording the outcome even if the dispatcher is shutting down
+	ctx = context.WithoutCancel(ctx)
+	logger := d.logger.With(zap.String("webhook#id", dl.WebhookID.String()), zap.String("delivery#id", dl.ID.String()))
+	w := dl.Webhook
+
+	attempt := d.send(ctx, w, dl)
+	if attempt.Err == nil {
+		if err := d.repo.CompleteDelivery(ctx, dl, attempt); err != nil {
+			logger.Error("failed to record webhook delivery", zap.Error(err))
+		}
+		return
+	}
+
+	var retryAt *time.Time
+	if dl.Attempts < d.cfg.MaxAttempts {
+		at := time.Now().Add(d.Backoff(dl.Attempts))
+		retryAt = &at
+	}
+
+	failures, err := d.repo.FailDelivery(ctx, dl, attempt, retryAt)
+	if err != nil {
+		logger.Error("failed to record webhook delivery", zap.Error(err))
+		return
+	}
+	logger.Warn("webhook delivery failed", zap.Int("attempt", dl.Attempts), zap.Int("consecutive_failures", failures), zap.Error(attempt.Err))
+
+	if failures >= d.cfg.DisableAfter {
+		if err := d.repo.Disable(ctx, w.ID); err != nil {
+			logger.Error("failed to disable webhook", zap.Error(err))
+			return
+		}
+		logger.Warn("disabled webhook after repeated failures", zap.Int("consecutive_failures", failures))
+	}
+}
+
+func (d *Dispatcher) send(ctx context.Context, w *domain.Webhook, dl *domain.WebhookDelivery) domain.DeliveryAttempt {
+	ctx, cancel := context.WithTimeout(ctx, d.cfg.Timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.URL, bytes.NewReader(dl.Payload))
+	if err != nil {
+		return domain.DeliveryAttempt{Err: err}
+	}
+
+	now := time.Now()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(DeliveryHeader, dl.ID.String())
+	req.Header.Set(EventHeader, dl.EventType)
+	req.Header.Set(TimestampHeader, strconv.FormatInt(now.Unix(), 10))
+	req.Header.Set(SignatureHeader, Sign(w.Secret, now, dl.Payload))
+
+	res, err := d.client.Do(req)
+	attempt := domain.DeliveryAttempt{Duration: time.Since(now)}
+	if err != nil {
+		attempt.Err = err
+		return attempt
+	}
+	defer res.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(res.Body, 1<<16))
+
+	attempt.ResponseCode = &res.StatusCode
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		attempt.Err = errors.New("webhook responded with " + res.Status)
+	}
+	return attempt
+}
+
+// Backoff returns the delay before the next attempt, doubling with every attempt
+// with up to 20% jitter, capped at BackoffMax
+func (d *Dispatcher) Backoff(attempt int) time.Duration {
+	delay := d.cfg.BackoffBase
+	for i := 1; i < attempt && delay < d.cfg.BackoffMax; i++ {
+		delay *= 2
+	}
+	delay = min(delay, d.cfg.BackoffMax)
+
+	return min(delay+time.Duration(rand.Int64N(int64(delay)/5+1)), d.cfg.BackoffMax)
+}
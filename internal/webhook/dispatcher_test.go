@@ -0,0 +1,216 @@
+package webhook_test
+
+import (
+	"context"
+	"encoding/json"
+	"go-project-template/internal/domain"
+	"go-project-template/internal/webhook"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+// fakeWebhookRepository holds a single webhook and hands out its pending deliveries
+type fakeWebhookRepository struct {
+	mu         sync.Mutex
+	webhook    *domain.Webhook
+	deliveries []*domain.WebhookDelivery
+	retryAt    *time.Time
+}
+
+func (f *fakeWebhookRepository) Create(_ context.Context, w *domain.Webhook) (*domain.Webhook, error) {
+	return w, nil
+}
+
+func (f *fakeWebhookRepository) GetByID(ctx context.Context, id uuid.UUID) (*domain.Webhook, error) {
+	// Like Postgres the lookup is scoped to a tenant, which the dispatcher does not have
+	if _, err := domain.TenantFromContext(ctx); err != nil {
+		return nil, err
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.webhook.ID != id {
+		return nil, domain.ErrNotFound
+	}
+	w := *f.webhook
+	return &w, nil
+}
+
+func (f *fakeWebhookRepository) List(_ context.Context) ([]domain.Webhook, error) {
+	return []domain.Webhook{*f.webhook}, nil
+}
+
+func (f *fakeWebhookRepository) Update(_ context.Context, w *domain.Webhook) (*domain.Webhook, error) {
+	return w, nil
+}
+
+func (f *fakeWebhookRepository) Delete(_ context.Context, _ uuid.UUID) error {
+	return nil
+}
+
+func (f *fakeWebhookRepository) Disable(_ context.Context, _ uuid.UUID) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.webhook.Active = false
+	return nil
+}
+
+func (f *fakeWebhookRepository) FanOut(_ context.Context, _ int) (int, error) {
+	return 0, nil
+}
+
+func (f *fakeWebhookRepository) ClaimDeliveries(_ context.Context, limit int, _ time.Duration) ([]domain.WebhookDelivery, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	var dd []domain.WebhookDelivery
+	for _, d := range f.deliveries {
+		if f.webhook.Active && d.Status == domain.DeliveryStatusPending && len(dd) < limit {
+			d.Attempts++
+			claimed := *d
+			w := *f.webhook
+			claimed.Webhook = &w
+			dd = append(dd, claimed)
+		}
+	}
+	return dd, nil
+}
+
+func (f *fakeWebhookRepository) find(id uuid.UUID) *domain.WebhookDelivery {
+	for _, d := range f.deliveries {
+		if d.ID == id {
+			return d
+		}
+	}
+	return nil
+}
+
+func (f *fakeWebhookRepository) CompleteDelivery(_ context.Context, d *domain.WebhookDelivery, a domain.DeliveryAttempt) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	stored := f.find(d.ID)
+	stored.Status = domain.DeliveryStatusSucceeded
+	stored.ResponseCode = a.ResponseCode
+	f.webhook.ConsecutiveFailures = 0
+	return nil
+}
+
+func (f *fakeWebhookRepository) FailDelivery(_ context.Context, d *domain.WebhookDelivery, a domain.DeliveryAttempt, retryAt *time.Time) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	stored := f.find(d.ID)
+	stored.ResponseCode = a.ResponseCode
+	if retryAt == nil {
+		stored.Status = domain.DeliveryStatusFailed
+	}
+	f.retryAt = retryAt
+	f.webhook.ConsecutiveFailures++
+	return f.webhook.ConsecutiveFailures, nil
+}
+
+func (f *fakeWebhookRepository) Deliveries(_ context.Context, _ uuid.UUID, _ int) ([]domain.WebhookDelivery, error) {
+	return nil, nil
+}
+
+func (f *fakeWebhookRepository) Redeliver(_ context.Context, _ uuid.UUID, _ uuid.UUID) (*domain.WebhookDelivery, error) {
+	return nil, domain.ErrNotFound
+}
+
+func newFakeWebhookRepository(url string, attempts int) *fakeWebhookRepository {
+	w := &domain.Webhook{ID: uuid.New(), URL: url, Secret: "whsec_test", Active: true}
+	return &fakeWebhookRepository{
+		webhook: w,
+		deliveries: []*domain.WebhookDelivery{{
+			ID:        uuid.New(),
+			WebhookID: w.ID,
+			EventID:   1,
+			EventType: domain.EventUserCreated,
+			Payload:   json.RawMessage(`{"id":1,"type":"user.created"}`),
+			Status:    domain.DeliveryStatusPending,
+			Attempts:  attempts,
+		}},
+	}
+}
+
+func TestDispatcher_DispatchOnce(t *testing.T) {
+	t.Parallel()
+
+	testCases := map[string]struct {
+		status       int
+		attempts     int
+		disableAfter int
+		want         domain.DeliveryStatus
+		retry        bool
+		active       bool
+	}{
+		"delivered":     {http.StatusOK, 0, 5, domain.DeliveryStatusSucceeded, false, true},
+		"retried":       {http.StatusInternalServerError, 0, 5, domain.DeliveryStatusPending, true, true},
+		"out of tries":  {http.StatusInternalServerError, 2, 5, domain.DeliveryStatusFailed, false, true},
+		"auto disabled": {http.StatusGone, 0, 1, domain.DeliveryStatusPending, true, false},
+	}
+
+	for scenario, tc := range testCases {
+		t.Run(scenario, func(t *testing.T) {
+			t.Parallel()
+
+			var repo *fakeWebhookRepository
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				body, err := io.ReadAll(r.Body)
+				assert.NoError(t, err)
+
+				// The receiver can verify the signature with the shared secret
+				ts, err := strconv.ParseInt(r.Header.Get(webhook.TimestampHeader), 10, 64)
+				assert.NoError(t, err)
+				assert.Equal(t, webhook.Sign("whsec_test", time.Unix(ts, 0), body), r.Header.Get(webhook.SignatureHeader))
+				assert.Equal(t, domain.EventUserCreated, r.Header.Get(webhook.EventHeader))
+				assert.Equal(t, repo.deliveries[0].ID.String(), r.Header.Get(webhook.DeliveryHeader))
+
+				w.WriteHeader(tc.status)
+			}))
+			defer srv.Close()
+
+			repo = newFakeWebhookRepository(srv.URL, tc.attempts)
+
+			cfg := webhook.DefaultConfig()
+			cfg.MaxAttempts = 3
+			cfg.DisableAfter = tc.disableAfter
+			d := webhook.NewDispatcher(repo, srv.Client(), zap.NewNop(), cfg)
+
+			_, err := d.DispatchOnce(context.Background())
+			require.NoError(t, err)
+
+			delivery := repo.deliveries[0]
+			assert.Equal(t, tc.want, delivery.Status)
+			require.NotNil(t, delivery.ResponseCode)
+			assert.Equal(t, tc.status, *delivery.ResponseCode)
+			assert.Equal(t, tc.retry, repo.retryAt != nil)
+			assert.Equal(t, tc.active, repo.webhook.Active)
+		})
+	}
+}
+
+func TestDispatcher_Backoff(t *testing.T) {
+	t.Parallel()
+
+	cfg := webhook.DefaultConfig()
+	cfg.BackoffBase = time.Second
+	cfg.BackoffMax = time.Minute
+	d := webhook.NewDispatcher(nil, nil, zap.NewNop(), cfg)
+
+	assert.InDelta(t, time.Second, d.Backoff(1), float64(200*time.Millisecond))
+	assert.InDelta(t, 8*time.Second, d.Backoff(4), float64(1600*time.Millisecond))
+	assert.Equal(t, time.Minute, d.Backoff(30))
+}
@@ -0,0 +1,55 @@
+package worker
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go-project-template/internal/domain"
+)
+
+// Handler processes jobs of a single type. Returning an error schedules a retry.
+type Handler interface {
+	Handle(ctx context.Context, j *domain.Job) error
+}
+
+// HandlerFunc adapts a function into a [Handler]
+type HandlerFunc func(ctx context.Context, j *domain.Job) error
+
+func (f HandlerFunc) Handle(ctx context.Context, j *domain.Job) error {
+	return f(ctx, j)
+}
+
+// Typed returns a [Handler] that decodes the job payload into T before calling fn.
+// Payloads that cannot be decoded are buried without retrying.
+func Typed[T any](fn func(ctx context.Context, payload T) error) Handler {
+	return HandlerFunc(func(ctx context.Context, j *domain.Job) error {
+		var payload T
+		if err := json.Unmarshal(j.Payload, &payload); err != nil {
+			return Permanent(fmt.Errorf("decoding %s payload: %w", j.Type, err))
+		}
+		return fn(ctx, payload)
+	})
+}
+
+type permanentError struct {
+	err error
+}
+
+func (e *permanentError) Error() string {
+	return e.err.Error()
+}
+
+func (e *permanentError) Unwrap() error {
+	return e.err
+}
+
+// Permanent marks err as not worth retrying, the job is moved to the dead-letter state
+func Permanent(err error) error {
+	return &permanentError{err: err}
+}
+
+func isPermanent(err error) bool {
+	var perr *permanentError
+	return errors.As(err, &perr)
+}
@@ -0,0 +1,76 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"go-project-template/internal/domain"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const JobTypePurgeJobs = "jobs.purge"
+
+// WelcomeEmailHandler greets a newly created user. Until an email provider is
+// configured the message is only logged.
+func WelcomeEmailHandler(users domain.UserRepository, logger *zap.Logger) Handler {
+	return Typed(func(ctx context.Context, p domain.WelcomeEmailPayload) error {
+		if p.TenantID == "" {
+			return Permanent(domain.ErrTenantRequired)
+		}
+
+		u, err := users.GetByID(domain.WithTenant(ctx, p.TenantID), p.UserID)
+		if errors.Is(err, domain.ErrNotFound) {
+			return Permanent(err)
+		}
+		if err != nil {
+			return err
+		}
+
+		if u.Email == nil {
+			return Permanent(errors.New("user has no email"))
+		}
+
+		logger.Info("sending welcome email", zap.String("user#uuid", u.UUID.String()), zap.String("email", *u.Email))
+		return nil
+	})
+}
+
+// InvitationEmailHandler sends an invitation its token. Until an email provider
+// is configured the message is only logged, without the token.
+func InvitationEmailHandler(logger *zap.Logger) Handler {
+	return Typed(func(_ context.Context, p domain.InvitationEmailPayload) error {
+		if p.Email == "" || p.Token == "" {
+			return Permanent(errors.New("invitation email needs an email and a token"))
+		}
+
+		logger.Info("sending invitation email",
+			zap.String("invitation#uuid", p.InvitationID.String()),
+			zap.String("organization#uuid", p.OrganizationID.String()),
+			zap.String("tenant", p.TenantID),
+			zap.String("email", p.Email),
+		)
+		return nil
+	})
+}
+
+type PurgeJobsPayload struct {
+	RetentionDays int `json:"retention_days"`
+}
+
+// PurgeJobsHandler deletes succeeded jobs older than the retention period
+func PurgeJobsHandler(jobs domain.JobRepository, logger *zap.Logger) Handler {
+	return Typed(func(ctx context.Context, p PurgeJobsPayload) error {
+		if p.RetentionDays <= 0 {
+			return Permanent(errors.New("retention_days must be positive"))
+		}
+
+		n, err := jobs.DeleteSucceeded(ctx, time.Now().AddDate(0, 0, -p.RetentionDays))
+		if err != nil {
+			return err
+		}
+
+		logger.Info("purged succeeded jobs", zap.Int64("count", n))
+		return nil
+	})
+}
@@ -0,0 +1,191 @@
+// Package worker runs background jobs from a [domain.JobRepository].
+package worker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"go-project-template/internal/domain"
+	"math"
+	"math/rand/v2"
+	"runtime/debug"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+type Config struct {
+	// Concurrency is the number of jobs processed at the same time
+	Concurrency int
+	// PollInterval is how long an idle worker waits before looking for jobs again
+	PollInterval time.Duration
+	// VisibilityTimeout is how long a claimed job stays hidden from other workers.
+	// It is also the deadline given to the handler.
+	VisibilityTimeout time.Duration
+	// BackoffBase and BackoffMax bound the exponential delay between retries
+	BackoffBase time.Duration
+	BackoffMax  time.Duration
+}
+
+// Validate reports settings the worker cannot run with
+func (c Config) Validate() error {
+	switch {
+	case c.Concurrency < 1:
+		return errors.New("concurrency must be at least 1")
+	case c.PollInterval <= 0:
+		return errors.New("poll interval must be positive")
+	case c.VisibilityTimeout <= 0:
+		return errors.New("visibility timeout must be positive")
+	case c.BackoffBase <= 0 || c.BackoffMax < c.BackoffBase:
+		return errors.New("backoff base must be positive and at most backoff max")
+	}
+	return nil
+}
+
+func DefaultConfig() Config {
+	return Config{
+		Concurrency:       4,
+		PollInterval:      1 * time.Second,
+		VisibilityTimeout: 5 * time.Minute,
+		BackoffBase:       5 * time.Second,
+		BackoffMax:        1 * time.Hour,
+	}
+}
+
+type Worker struct {
+	repo     domain.JobRepository
+	logger   *zap.Logger
+	cfg      Config
+	handlers map[string]Handler
+}
+
+func New(repo domain.JobRepository, logger *zap.Logger, cfg Config) *Worker {
+	return &Worker{
+		repo:     repo,
+		logger:   logger,
+		cfg:      cfg,
+		handlers: map[string]Handler{},
+	}
+}
+
+// Register routes jobs of jobType to h. It must be called before [Worker.Run].
+func (w *Worker) Register(jobType string, h Handler) {
+	w.handlers[jobType] = h
+}
+
+// Types returns the registered job types
+func (w *Worker) Types() []string {
+	types := make([]string, 0, len(w.handlers))
+	for t := range w.handlers {
+		types = append(types, t)
+	}
+	return types
+}
+
+// Run processes jobs until ctx is done, then waits for in-flight jobs to finish
+func (w *Worker) Run(ctx context.Context) error {
+	if len(w.handlers) == 0 {
+		return errors.New("no job handlers registered")
+	}
+	if err := w.cfg.Validate(); err != nil {
+		return err
+	}
+
+	types := w.Types()
+
+	var wg sync.WaitGroup
+	for i := 0; i < w.cfg.Concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			w.loop(ctx, types)
+		}()
+	}
+
+	wg.Wait()
+	return nil
+}
+
+func (w *Worker) loop(ctx context.Context, types []string) {
+	for ctx.Err() == nil {
+		processed, err := w.ProcessNext(ctx, types)
+		if err != nil && ctx.Err() == nil {
+			w.logger.Error("failed to process job", zap.Error(err))
+		}
+		if processed {
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+		case <-time.After(w.cfg.PollInterval):
+		}
+	}
+}
+
+// ProcessNext claims and handles a single job. It reports false if no job was ready.
+func (w *Worker) ProcessNext(ctx context.Context, types []string) (bool, error) {
+	j, err := w.repo.Dequeue(ctx, types, w.cfg.VisibilityTimeout)
+	if errors.Is(err, domain.ErrNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	// A claimed job is finished even while shutting down, within its visibility timeout
+	jobCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), w.cfg.VisibilityTimeout)
+	defer cancel()
+
+	logger := w.logger.With(
+		zap.String("job#id", j.ID.String()),
+		zap.String("job#type", j.Type),
+		zap.Int("job#attempt", j.Attempts),
+	)
+
+	// Jobs abandoned on their last attempt are not run again
+	if j.Attempts > j.MaxAttempts {
+		logger.Error("job exceeded max attempts")
+		return true, w.repo.Bury(jobCtx, j, errors.New("exceeded max attempts"))
+	}
+
+	start := time.Now()
+	herr := w.handle(jobCtx, j)
+	logger = logger.With(zap.Int64("duration", time.Since(start).Milliseconds()))
+
+	switch {
+	case herr == nil:
+		logger.Info("job succeeded")
+		return true, w.repo.Complete(jobCtx, j)
+	case isPermanent(herr) || j.Attempts >= j.MaxAttempts:
+		logger.Error("job moved to dead-letter", zap.Error(herr))
+		return true, w.repo.Bury(jobCtx, j, herr)
+	default:
+		runAt := time.Now().Add(w.Backoff(j.Attempts))
+		logger.Warn("job failed, retrying", zap.Error(herr), zap.Time("run_at", runAt))
+		return true, w.repo.Retry(jobCtx, j, runAt, herr)
+	}
+}
+
+func (w *Worker) handle(ctx context.Context, j *domain.Job) (err error) {
+	h, ok := w.handlers[j.Type]
+	if !ok {
+		return Permanent(fmt.Errorf("no handler registered for job type %s", j.Type))
+	}
+
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("handler panic: %v\n%s", r, debug.Stack())
+		}
+	}()
+
+	return h.Handle(ctx, j)
+}
+
+// Backoff returns the delay before the given attempt is retried: exponential
+// from BackoffBase with up to 20% jitter, capped at BackoffMax.
+func (w *Worker) Backoff(attempt int) time.Duration {
+	d := float64(w.cfg.BackoffBase) * math.Pow(2, float64(attempt-1)) * (1 + 0.2*rand.Float64())
+	return time.Duration(min(d, float64(w.cfg.BackoffMax)))
+}
@@ -0,0 +1,226 @@
+package worker_test
+
+import (
+	"context"
+	"errors"
+	"go-project-template/internal/domain"
+	"go-project-template/internal/worker"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+// fakeJobRepository hands out a single job and records what happened to it
+type fakeJobRepository struct {
+	mu      sync.Mutex
+	job     *domain.Job
+	claimed bool
+	runAt   time.Time
+	cause   error
+}
+
+func (f *fakeJobRepository) Enqueue(_ context.Context, j *domain.Job) (*domain.Job, error) {
+	f.job = j
+	return j, nil
+}
+
+func (f *fakeJobRepository) Dequeue(_ context.Context, _ []string, _ time.Duration) (*domain.Job, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.job == nil || f.claimed || f.job.Status != domain.JobStatusPending {
+		return nil, domain.ErrNotFound
+	}
+	f.claimed = true
+	f.job.Attempts++
+	f.job.Status = domain.JobStatusRunning
+	return f.job, nil
+}
+
+func (f *fakeJobRepository) finish(status domain.JobStatus, cause error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.claimed = false
+	f.job.Status = status
+	f.cause = cause
+}
+
+func (f *fakeJobRepository) Complete(_ context.Context, _ *domain.Job) error {
+	f.finish(domain.JobStatusSucceeded, nil)
+	return nil
+}
+
+func (f *fakeJobRepository) Retry(_ context.Context, _ *domain.Job, runAt time.Time, cause error) error {
+	f.runAt = runAt
+	f.finish(domain.JobStatusPending, cause)
+	return nil
+}
+
+func (f *fakeJobRepository) Bury(_ context.Context, _ *domain.Job, cause error) error {
+	f.finish(domain.JobStatusDead, cause)
+	return nil
+}
+
+func (f *fakeJobRepository) DeleteSucceeded(_ context.Context, _ time.Time) (int64, error) {
+	return 0, nil
+}
+
+type greeting struct {
+	Name string `json:"name"`
+}
+
+func newTestWorker(t *testing.T, maxAttempts int, h worker.Handler) (*worker.Worker, *fakeJobRepository) {
+	t.Helper()
+
+	j, err := domain.NewJob("greet", greeting{Name: "john"})
+	require.NoError(t, err)
+	j.MaxAttempts = maxAttempts
+
+	repo := &fakeJobRepository{job: j}
+	w := worker.New(repo, zap.NewNop(), worker.DefaultConfig())
+	w.Register("greet", h)
+
+	return w, repo
+}
+
+func TestWorker_ProcessNext(t *testing.T) {
+	t.Parallel()
+
+	errBoom := errors.New("boom")
+
+	testCases := map[string]struct {
+		maxAttempts int
+		handler     func(ctx context.Context, g greeting) error
+		status      domain.JobStatus
+		cause       error
+	}{
+		"success": {3, func(_ context.Context, g greeting) error {
+			assert.Equal(t, "john", g.Name)
+			return nil
+		}, domain.JobStatusSucceeded, nil},
+		"retry":         {3, func(context.Context, greeting) error { return errBoom }, domain.JobStatusPending, errBoom},
+		"last attempt":  {1, func(context.Context, greeting) error { return errBoom }, domain.JobStatusDead, errBoom},
+		"permanent":     {3, func(context.Context, greeting) error { return worker.Permanent(errBoom) }, domain.JobStatusDead, errBoom},
+		"handler panic": {1, func(context.Context, greeting) error { panic("boom") }, domain.JobStatusDead, nil},
+	}
+
+	for scenario, tc := range testCases {
+		t.Run(scenario, func(t *testing.T) {
+			t.Parallel()
+			ctx := context.Background()
+			w, repo := newTestWorker(t, tc.maxAttempts, worker.Typed(tc.handler))
+
+			processed, err := w.ProcessNext(ctx, w.Types())
+			require.NoError(t, err)
+			assert.True(t, processed)
+			assert.Equal(t, tc.status, repo.job.Status)
+
+			if tc.cause != nil {
+				assert.ErrorIs(t, repo.cause, tc.cause)
+			}
+			if tc.status == domain.JobStatusPending {
+				assert.True(t, repo.runAt.After(time.Now()))
+			}
+		})
+	}
+}
+
+func TestWorker_ProcessNextEmpty(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	w := worker.New(&fakeJobRepository{}, zap.NewNop(), worker.DefaultConfig())
+	processed, err := w.ProcessNext(ctx, []string{"greet"})
+	require.NoError(t, err)
+	assert.False(t, processed)
+}
+
+func TestWorker_Backoff(t *testing.T) {
+	t.Parallel()
+
+	cfg := worker.DefaultConfig()
+	cfg.BackoffBase = time.Second
+	cfg.BackoffMax = time.Minute
+	w := worker.New(&fakeJobRepository{}, zap.NewNop(), cfg)
+
+	testCases := map[int]struct {
+		min time.Duration
+		max time.Duration
+	}{
+		1: {time.Second, 1200 * time.Millisecond},
+		3: {4 * time.Second, 4800 * time.Millisecond},
+		// The jitter never takes the delay past the maximum
+		6:  {32 * time.Second, time.Minute},
+		20: {time.Minute, time.Minute},
+	}
+
+	for attempt, tc := range testCases {
+		d := w.Backoff(attempt)
+		assert.GreaterOrEqual(t, d, tc.min, "attempt %d", attempt)
+		assert.LessOrEqual(t, d, tc.max, "attempt %d", attempt)
+	}
+}
+
+func TestWorker_Config(t *testing.T) {
+	t.Parallel()
+
+	testCases := map[string]struct {
+		change func(*worker.Config)
+		err    string
+	}{
+		"default":          {func(*worker.Config) {}, ""},
+		"no concurrency":   {func(c *worker.Config) { c.Concurrency = 0 }, "concurrency must be at least 1"},
+		"negative":         {func(c *worker.Config) { c.Concurrency = -1 }, "concurrency must be at least 1"},
+		"no poll interval": {func(c *worker.Config) { c.PollInterval = 0 }, "poll interval must be positive"},
+		"max below base":   {func(c *worker.Config) { c.BackoffMax = time.Millisecond }, "backoff base must be positive and at most backoff max"},
+	}
+
+	for scenario, tc := range testCases { //nolint:paralleltest
+		t.Run(scenario, func(t *testing.T) {
+			cfg := worker.DefaultConfig()
+			tc.change(&cfg)
+
+			w := worker.New(&fakeJobRepository{}, zap.NewNop(), cfg)
+			w.Register("greet", worker.HandlerFunc(func(context.Context, *domain.Job) error { return nil }))
+
+			// An invalid configuration fails right away instead of stalling
+			ctx, cancel := context.WithCancel(context.Background())
+			cancel()
+			err := w.Run(ctx)
+			if tc.err == "" {
+				assert.NoError(t, err)
+				return
+			}
+			assert.EqualError(t, err, tc.err)
+		})
+	}
+}
+
+func TestWorker_RunStopsOnCancel(t *testing.T) {
+	t.Parallel()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+
+	w, repo := newTestWorker(t, 3, worker.HandlerFunc(func(context.Context, *domain.Job) error {
+		cancel()
+		return nil
+	}))
+
+	go func() {
+		assert.NoError(t, w.Run(ctx))
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("worker did not stop")
+	}
+	assert.Equal(t, domain.JobStatusSucceeded, repo.job.Status)
+}